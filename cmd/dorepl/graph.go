@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/do/graph"
+	"github.com/aybabtme/godotto/pkg/extra/do/inventory"
+	"golang.org/x/net/context"
+)
+
+// graphCmd prints the resources of the account and how they relate in the
+// Graphviz DOT language, as in `dorepl graph | dot -Tsvg > account.svg`. The
+// graph of an inventory taken earlier is printed when one is given.
+func graphCmd(ctx context.Context, client cloud.Client, args []string) error {
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var (
+		inv *inventory.Inventory
+		err error
+	)
+	switch fs.NArg() {
+	case 0:
+		inv, err = inventory.Take(ctx, client)
+	case 1:
+		inv, err = loadInventory(fs.Arg(0))
+	default:
+		return fmt.Errorf("usage: dorepl graph [inventory.json]")
+	}
+	if err != nil {
+		return err
+	}
+	return graph.Build(inv).WriteDOT(os.Stdout)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/do/inventory"
+	"golang.org/x/net/context"
+)
+
+// inventoryCmd prints an inventory of the account as JSON, as in
+// `dorepl inventory > before.json`.
+func inventoryCmd(ctx context.Context, client cloud.Client, args []string) error {
+	fs := flag.NewFlagSet("inventory", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	inv, err := inventory.Take(ctx, client)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(inv)
+}
+
+// inventoryDiffCmd prints what changed between two inventories, as in
+// `dorepl inventory diff before.json after.json`.
+func inventoryDiffCmd(args []string) error {
+	fs := flag.NewFlagSet("inventory diff", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the changes as JSON lines")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: dorepl inventory diff [-json] <before.json> <after.json>")
+	}
+	a, err := loadInventory(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := loadInventory(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	changes, err := inventory.Diff(a, b)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, c := range changes {
+			if err := enc.Encode(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	printChanges(os.Stdout, changes)
+	return nil
+}
+
+func loadInventory(filename string) (*inventory.Inventory, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	inv, err := inventory.Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return inv, nil
+}
+
+func printChanges(w io.Writer, changes []inventory.Change) {
+	ops := map[string]string{
+		inventory.Added:   "+",
+		inventory.Removed: "-",
+		inventory.Changed: "~",
+	}
+	for _, c := range changes {
+		fmt.Fprintf(w, "%s %s %s", ops[c.Op], c.Kind, c.ID)
+		if c.Name != "" {
+			fmt.Fprintf(w, " (%s)", c.Name)
+		}
+		fmt.Fprintln(w)
+		for _, f := range c.Fields {
+			old, _ := json.Marshal(f.Old)
+			cur, _ := json.Marshal(f.New)
+			fmt.Fprintf(w, "    %s: %s -> %s\n", f.Path, old, cur)
+		}
+	}
+}
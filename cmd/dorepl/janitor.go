@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/do/janitor"
+	"github.com/aybabtme/godotto/pkg/extra/format"
+	"golang.org/x/net/context"
+)
+
+// janitorCmd reports the resources that rules deem unused, and deletes them
+// with -apply, as in `dorepl janitor -rules janitor.json -apply`.
+func janitorCmd(ctx context.Context, client cloud.Client, args []string) error {
+	fs := flag.NewFlagSet("janitor", flag.ExitOnError)
+	rulesFile := fs.String("rules", "", "JSON file of rules, the default rules are used if empty")
+	stateFile := fs.String("state", filepath.Join(os.Getenv("HOME"), ".dorepl_janitor.json"), "file to remember when floating IPs were first seen unassigned")
+	apply := fs.Bool("apply", false, "delete the candidates instead of only reporting them")
+	output := fs.String("o", "table", "output format of the report, one of: json, yaml, table, csv, wide")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	printer, err := newPrinter(*output, "kind,id,name,rule,age,skip")
+	if err != nil {
+		return err
+	}
+	if printer.Format == format.JSON || printer.Format == format.YAML {
+		printer.Columns = nil
+	}
+
+	var rules []janitor.Rule
+	if *rulesFile != "" {
+		f, err := os.Open(*rulesFile)
+		if err != nil {
+			return err
+		}
+		rules, err = janitor.LoadRules(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", *rulesFile, err)
+		}
+	}
+	state, err := loadJanitorState(*stateFile)
+	if err != nil {
+		return err
+	}
+
+	j, err := janitor.New(client, rules, janitor.UseState(state))
+	if err != nil {
+		return err
+	}
+	plan, err := j.Plan(ctx)
+	if err != nil {
+		return err
+	}
+	if err := printer.Print(os.Stdout, plan.Candidates); err != nil {
+		return err
+	}
+	if !*apply {
+		if len(plan.Candidates) > 0 {
+			log.Printf("nothing was deleted, use -apply to delete the candidates")
+		}
+		return saveJanitorState(*stateFile, state)
+	}
+	failed := 0
+	for _, res := range j.Apply(ctx, plan) {
+		if res.Err != nil {
+			failed++
+			log.Printf("failed to delete %s %s: %v", res.Kind, res.ID, res.Err)
+			continue
+		}
+		log.Printf("deleted %s %s (%s)", res.Kind, res.ID, res.Name)
+	}
+	if err := saveJanitorState(*stateFile, state); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d deletions failed", failed)
+	}
+	return nil
+}
+
+func loadJanitorState(filename string) (*janitor.State, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return janitor.NewState(), nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	state, err := janitor.LoadState(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return state, nil
+}
+
+func saveJanitorState(filename string, state *janitor.State) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := state.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -2,26 +2,32 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
 	"flag"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"net"
 	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/aybabtme/godotto"
+	"github.com/aybabtme/godotto/pkg/droplets"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/spycloud"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/format"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
 	"github.com/aybabtme/godotto/pkg/extra/godoos"
+	"github.com/aybabtme/godotto/pkg/extra/godoutil"
 	"github.com/aybabtme/godotto/pkg/extra/ottoutil/jsvendor/corejs"
 	"github.com/aybabtme/godotto/pkg/extra/repl"
 	jsssh "github.com/aybabtme/godotto/pkg/extra/ssh"
+	"github.com/aybabtme/godotto/pkg/volumes"
 
 	"github.com/digitalocean/godo"
 	"github.com/robertkrimen/otto"
-	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/crypto/ssh/terminal"
 	"golang.org/x/net/context"
@@ -67,11 +73,41 @@ var defaultAPIUrl = func() string {
 func main() {
 	apiToken := flag.String("api.token", defaultToken, "token to use to communicate with the DO API")
 	apiURL := flag.String("api.url", defaultAPIUrl, "uses a different endpoint to send API requests")
+	output := flag.String("o", "json", "output format, one of: json, yaml, table, csv, wide")
+	columns := flag.String("columns", "", "columns of tables, like \"id,name,ip=networks.v4.ip_address\"")
+	knownHosts := flag.String("ssh.known_hosts", filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts"), "known_hosts file verifying the host keys of ssh sessions")
+	sshConfig := flag.String("ssh.config", filepath.Join(os.Getenv("HOME"), ".ssh", "config"), "OpenSSH config file with the user, port and identities of hosts")
+	sshIdentity := flag.String("ssh.identity", "", "private key file to use when the ssh agent has no keys, instead of ~/.ssh/id_*")
+	hostKey := flag.String("ssh.host_key", string(jsssh.TOFU), "default policy for host keys, one of: strict, tofu, insecure")
 	flag.Parse()
 
 	log.SetFlags(0)
 	log.SetPrefix("dorepl: ")
 
+	printer, err := newPrinter(*output, *columns)
+	if err != nil {
+		log.Fatal(err)
+	}
+	hostKeyPolicy, err := jsssh.ParseHostKeyPolicy(*hostKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// comparing inventories doesn't need the API
+	if flag.Arg(0) == "inventory" && flag.Arg(1) == "diff" {
+		if err := inventoryDiffCmd(flag.Args()[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	// nor does graphing one taken earlier
+	if flag.Arg(0) == "graph" && flag.NArg() > 1 {
+		if err := graphCmd(context.Background(), nil, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if *apiToken == "" {
 		flag.PrintDefaults()
 		log.Fatalf("At this time, the REPL requires you to provide an API token")
@@ -95,12 +131,53 @@ func main() {
 	if err := corejs.Load(vm); err != nil {
 		log.Fatal(err)
 	}
+	loop := eventloop.New()
+	eventloop.Use(vm, loop)
 
-	cloud, spy := spycloud.Client(cloud.New(cloud.UseGodo(gc)))
+	client := cloud.New(cloud.UseGodo(gc))
+	resolver := cloud.NewResolver(client)
+	cloud, spy := spycloud.Client(client)
 	defer enumerateLeftover(spy)
 
 	ctx := context.Background()
-	pkg, err := godotto.Apply(ctx, vm, cloud)
+	if terminal.IsTerminal(2) {
+		ctx = godoutil.WithWaitOptions(ctx, godoutil.WaitProgress(newStatusLine(os.Stderr).progress))
+	}
+
+	switch flag.Arg(0) {
+	case "watch":
+		if err := watchCmd(ctx, client, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "inventory":
+		if err := inventoryCmd(ctx, client, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "graph":
+		if err := graphCmd(ctx, client, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "janitor":
+		if err := janitorCmd(ctx, client, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "query":
+		if err := queryCmd(ctx, client, printer, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// the ssh package, applied below, waits for droplets and provisions volumes
+	provisioner := new(jsssh.Provisioner)
+	cloudCtx := godojs.WithResolver(ctx, resolver)
+	cloudCtx = droplets.WithReady(cloudCtx, provisioner.WaitReady)
+	cloudCtx = volumes.WithRun(cloudCtx, provisioner.RunScript)
+	pkg, err := godotto.Apply(cloudCtx, vm, cloud)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -112,9 +189,27 @@ func main() {
 	}
 	vm.Set("os", ospkg)
 
-	auth, done := sshAgent()
-	defer done()
-	if s, cleanup, err := jsssh.Apply(ctx, vm, auth); err != nil {
+	if err := repl.UseConsoleTable(vm, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+
+	// shared by the REPL, shells and passphrase prompts
+	stdin := repl.NewStdin(os.Stdin)
+	sshOpts := []jsssh.Option{
+		jsssh.UseKnownHosts(*knownHosts),
+		jsssh.UseHostKeyPolicy(hostKeyPolicy),
+		jsssh.UseConfig(*sshConfig),
+		jsssh.UseIdentities(defaultIdentities(*sshIdentity)...),
+		jsssh.UsePassphrase(askPassphrase(stdin)),
+		jsssh.UseCloud(cloud),
+		jsssh.UseConsole(stdin),
+		jsssh.UseProvisioner(provisioner),
+	}
+	if a, done := sshAgent(); a != nil {
+		defer done()
+		sshOpts = append(sshOpts, jsssh.UseAgent(a))
+	}
+	if s, cleanup, err := jsssh.Apply(ctx, vm, nil, sshOpts...); err != nil {
 		log.Fatal(err)
 	} else {
 		defer cleanup()
@@ -129,15 +224,14 @@ func main() {
 			log.Printf("logged in as %s", acc.Email)
 		}
 
-		if err := repl.Run(vm, ">", prelude); err != nil && err != io.EOF {
+		if err := repl.Run(vm, ">", prelude, repl.UseLoop(loop), repl.UsePrinter(printer), repl.UseStdin(stdin)); err != nil && err != io.EOF {
 			log.Fatal(err)
 		}
 	} else {
 
 		// run scripts
 
-		enc := json.NewEncoder(os.Stdout)
-		for _, filename := range os.Args[1:] {
+		for _, filename := range flag.Args() {
 			raw, err := ioutil.ReadFile(filename)
 			if err != nil {
 				log.Fatal(err)
@@ -149,12 +243,16 @@ func main() {
 			if err != nil {
 				log.Fatal(err)
 			}
-			gov, err := v.Export()
-			if err != nil {
+			// let the operations started by the script complete
+			if err := loop.Wait(ctx); err != nil {
 				log.Fatal(err)
 			}
 			if v.IsDefined() {
-				if err := enc.Encode(gov); err != nil {
+				gov, err := repl.ToGo(v)
+				if err != nil {
+					log.Fatal(err)
+				}
+				if err := printer.Print(os.Stdout, gov); err != nil {
 					log.Fatal(err)
 				}
 			}
@@ -162,16 +260,66 @@ func main() {
 	}
 }
 
-func sshAgent() (ssh.AuthMethod, func()) {
+func newPrinter(output, columns string) (*format.Printer, error) {
+	f, err := format.Parse(output)
+	if err != nil {
+		return nil, err
+	}
+	p := &format.Printer{Format: f}
+	if columns != "" {
+		if p.Columns, err = format.ParseColumns(columns); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func sshAgent() (agent.Agent, func()) {
 	sshAgent, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
 	if err != nil {
 		return nil, func() {}
 	}
-	return ssh.PublicKeysCallback(agent.NewClient(sshAgent).Signers), func() {
+	return agent.NewClient(sshAgent), func() {
 		_ = sshAgent.Close()
 	}
 }
 
+// defaultIdentities are the private key files tried when the agent has no
+// keys, those OpenSSH tries unless one is given.
+func defaultIdentities(identity string) []string {
+	if identity != "" {
+		return []string{identity}
+	}
+	var files []string
+	for _, name := range []string{"id_rsa", "id_ecdsa", "id_ed25519", "id_dsa"} {
+		files = append(files, filepath.Join(os.Getenv("HOME"), ".ssh", name))
+	}
+	return files
+}
+
+// askPassphrase prompts for the passphrase of a private key, when there's a
+// terminal to prompt on.
+func askPassphrase(stdin *repl.Stdin) jsssh.PassphraseFunc {
+	return func(filename string) ([]byte, error) {
+		if !terminal.IsTerminal(0) {
+			return nil, fmt.Errorf("%s is encrypted and there's no terminal to ask for its passphrase", filename)
+		}
+		in, release := stdin.Take()
+		defer release()
+		state, err := terminal.MakeRaw(0)
+		if err != nil {
+			return nil, err
+		}
+		defer terminal.Restore(0, state)
+		t := terminal.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{in, os.Stderr}, "")
+		pass, err := t.ReadPassword(fmt.Sprintf("Enter passphrase for %s: ", filename))
+		return []byte(pass), err
+	}
+}
+
 func enumerateLeftover(spy func(...spycloud.Spy)) {
 	var once sync.Once
 	print := func() {
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/do/query"
+	"github.com/aybabtme/godotto/pkg/extra/format"
+	"golang.org/x/net/context"
+)
+
+// queryCmd prints the resources matching a query, as in
+// `dorepl query "droplets where 'web' in tags" -o table`.
+func queryCmd(ctx context.Context, client cloud.Client, printer *format.Printer, args []string) error {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	output := fs.String("o", string(printer.Format), "output format, one of: json, yaml, table, csv, wide")
+	columns := fs.String("columns", "", "columns of tables, the selected fields by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return fmt.Errorf("usage: dorepl query [-o format] [-columns spec] '<query>'")
+	}
+	src := fs.Arg(0)
+	// flags may also come after the query
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments after the query: %s", strings.Join(fs.Args(), " "))
+	}
+
+	q, err := query.Parse(src)
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(*output, *columns)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(p.Columns) > 0:
+	case *columns == "" && len(printer.Columns) > 0:
+		p.Columns = printer.Columns
+	case len(q.Fields) > 0:
+		p.Columns, err = format.ParseColumns(strings.Join(q.Fields, ","))
+		if err != nil {
+			return err
+		}
+	}
+	results, err := q.Run(ctx, client)
+	if err != nil {
+		return err
+	}
+	return p.Print(os.Stdout, results)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/digitalocean/godo"
+)
+
+// statusLine shows the progress of the actions being waited on, on the last
+// line of a terminal, such as "resize droplet 123: in-progress 45s".
+type statusLine struct {
+	mu    sync.Mutex
+	w     io.Writer
+	shown map[int]bool
+}
+
+func newStatusLine(w io.Writer) *statusLine {
+	return &statusLine{w: w, shown: make(map[int]bool)}
+}
+
+func (s *statusLine) progress(action *godo.Action, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inProgress := action.Status == "in-progress"
+	if !inProgress && !s.shown[action.ID] {
+		// actions that complete right away aren't worth a line
+		return
+	}
+	line := fmt.Sprintf("%s %s %d: %s %v",
+		strings.Replace(action.Type, "_", " ", -1),
+		action.ResourceType,
+		action.ResourceID,
+		action.Status,
+		elapsed.Truncate(time.Second),
+	)
+	// go back to the start of the line and clear it
+	fmt.Fprint(s.w, "\r\x1b[K"+line)
+	if inProgress {
+		s.shown[action.ID] = true
+	} else {
+		delete(s.shown, action.ID)
+		fmt.Fprintln(s.w)
+	}
+}
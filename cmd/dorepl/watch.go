@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/actions"
+	"github.com/digitalocean/godo"
+	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/net/context"
+)
+
+// watchCmd tails the activity of the account, as in `dorepl watch`. Events
+// are printed as lines of text in a terminal, and as JSON lines otherwise.
+func watchCmd(ctx context.Context, client cloud.Client, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	resourceType := fs.String("resource_type", "", "only watch the actions on this type of resource, like droplet or volume")
+	interval := fs.Duration("interval", 5*time.Second, "how often to poll for actions")
+	asJSON := fs.Bool("json", !terminal.IsTerminal(1), "print events as JSON lines")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt)
+	defer signal.Stop(sigc)
+	go func() {
+		select {
+		case <-sigc:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	opts := []actions.WatchOpt{actions.WatchInterval(*interval)}
+	if *resourceType != "" {
+		opts = append(opts, actions.WatchResourceType(*resourceType))
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for ev := range client.Actions().Watch(ctx, opts...) {
+		if ev.Err != nil {
+			log.Printf("can't poll actions: %v", ev.Err)
+			continue
+		}
+		a := ev.Action.Struct()
+		if *asJSON {
+			err := enc.Encode(struct {
+				Type   actions.EventType `json:"type"`
+				Action *godo.Action      `json:"action"`
+			}{ev.Type, a})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Printf("%s %-9s %s %s %d\n",
+			time.Now().Format("15:04:05"),
+			ev.Type,
+			strings.Replace(a.Type, "_", " ", -1),
+			a.ResourceType,
+			a.ResourceID,
+		)
+	}
+	return nil
+}
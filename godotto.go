@@ -6,19 +6,25 @@ import (
 
 	"github.com/aybabtme/godotto/pkg/accounts"
 	"github.com/aybabtme/godotto/pkg/actions"
+	"github.com/aybabtme/godotto/pkg/cloudinit"
 	"github.com/aybabtme/godotto/pkg/domains"
 	"github.com/aybabtme/godotto/pkg/droplets"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/firewalls"
 	"github.com/aybabtme/godotto/pkg/floatingips"
+	"github.com/aybabtme/godotto/pkg/graph"
 	"github.com/aybabtme/godotto/pkg/images"
+	"github.com/aybabtme/godotto/pkg/inventory"
 	"github.com/aybabtme/godotto/pkg/keys"
 	"github.com/aybabtme/godotto/pkg/loadbalancers"
+	"github.com/aybabtme/godotto/pkg/parallel"
+	"github.com/aybabtme/godotto/pkg/query"
 	"github.com/aybabtme/godotto/pkg/regions"
 	"github.com/aybabtme/godotto/pkg/sizes"
 	"github.com/aybabtme/godotto/pkg/snapshots"
 	"github.com/aybabtme/godotto/pkg/tags"
 	"github.com/aybabtme/godotto/pkg/volumes"
+	"github.com/aybabtme/godotto/pkg/waitfor"
 	"github.com/robertkrimen/otto"
 )
 
@@ -48,6 +54,12 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 		{"load_balancers", loadbalancers.Apply},
 		{"snapshots", snapshots.Apply},
 		{"firewalls", firewalls.Apply},
+		{"inventory", inventory.Apply},
+		{"graph", graph.Apply},
+		{"query", query.Apply},
+		{"parallel", parallel.Apply},
+		{"wait_for", waitfor.Apply},
+		{"cloudinit", cloudinit.Apply},
 	} {
 		svc, err := applier.Apply(ctx, vm, client)
 		if err != nil {
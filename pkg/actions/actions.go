@@ -8,6 +8,7 @@ import (
 	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud/actions"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
 	"github.com/robertkrimen/otto"
 )
 
@@ -30,6 +31,7 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 	}{
 		{"get", svc.get},
 		{"list", svc.list},
+		{"watch", svc.watch},
 	} {
 		if err := root.Set(applier.Name, applier.Method); err != nil {
 			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
@@ -55,21 +57,95 @@ func (svc *actionSvc) get(all otto.FunctionCall) otto.Value {
 	return godojs.ActionToVM(vm, a.Struct())
 }
 
+// argListOpts reads filters like `{resource_type: "droplet"}`.
+func (svc *actionSvc) argListOpts(all otto.FunctionCall, i int) []actions.ListOpt {
+	vm := all.Otto
+	arg := all.Argument(i)
+	if !arg.IsObject() {
+		return nil
+	}
+	var opts []actions.ListOpt
+	if v := ottoutil.GetObject(vm, arg, "resource_type", false); v.IsDefined() {
+		opts = append(opts, actions.FilterResourceType(ottoutil.String(vm, v)))
+	}
+	return opts
+}
+
 func (svc *actionSvc) list(all otto.FunctionCall) otto.Value {
 
 	vm := all.Otto
-	var actions = make([]otto.Value, 0)
-	actionc, errc := svc.svc.List(svc.ctx)
-	for action := range actionc {
-		actions = append(actions, godojs.ActionToVM(vm, action.Struct()))
+	opts := svc.argListOpts(all, 0)
+	ctx, cancel := context.WithCancel(svc.ctx)
+	actionc, errc := svc.svc.List(ctx, opts...)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+		action, more := <-actionc
+		if !more {
+			return q, false, <-errc
+		}
+		return godojs.ActionToVM(vm, action.Struct()), true, nil
+	}, cancel)
+}
+
+// watch calls fn with every event on the actions of the account, until fn
+// returns false, as in:
+//
+//	cloud.actions.watch(function(ev) {
+//	  console.log(ev.type + " " + ev.action.type + " " + ev.action.resource_id);
+//	}, {resource_type: "droplet", interval: "10s"});
+//
+// When polling fails, fn is called with `{type: "error", error: "..."}`.
+func (svc *actionSvc) watch(all otto.FunctionCall) otto.Value {
+	vm := all.Otto
+	fn := all.Argument(0)
+	if !fn.IsFunction() {
+		ottoutil.Throw(vm, "argument must be a function, got a %q", fn.Class())
 	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
+	var opts []actions.WatchOpt
+	if arg := all.Argument(1); arg.IsObject() {
+		if v := ottoutil.GetObject(vm, arg, "resource_type", false); v.IsDefined() {
+			opts = append(opts, actions.WatchResourceType(ottoutil.String(vm, v)))
+		}
+		if v := ottoutil.GetObject(vm, arg, "interval", false); v.IsDefined() {
+			opts = append(opts, actions.WatchInterval(ottoutil.Duration(vm, v)))
+		}
 	}
 
-	v, err := vm.ToValue(actions)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	ctx, cancel := context.WithCancel(svc.ctx)
+	defer cancel()
+	eventc := svc.svc.Watch(ctx, opts...)
+	// the script can still get its async results while watching, if
+	// something runs its loop
+	var ready <-chan struct{}
+	loop := eventloop.For(vm)
+	if loop != nil {
+		ready = loop.Ready()
+	}
+	for {
+		select {
+		case ev, more := <-eventc:
+			if !more {
+				return q
+			}
+			if ret := ottoutil.Call(vm, fn, nil, eventToVM(vm, ev)); ret.IsBoolean() && !ottoutil.Bool(vm, ret) {
+				return q
+			}
+		case <-ready:
+			if err := loop.RunPending(); err != nil {
+				ottoutil.Throw(vm, err.Error())
+			}
+		}
+	}
+}
+
+func eventToVM(vm *otto.Otto, ev actions.Event) otto.Value {
+	if ev.Err != nil {
+		return ottoutil.ToPkg(vm, map[string]interface{}{
+			"type":  "error",
+			"error": ev.Err.Error(),
+		})
 	}
-	return v
+	return ottoutil.ToPkg(vm, map[string]interface{}{
+		"type":   string(ev.Type),
+		"action": godojs.ActionToVM(vm, ev.Action.Struct()),
+	})
 }
@@ -82,7 +82,7 @@ func TestList(t *testing.T) {
 	}
 
 	cloud := mockcloud.Client(nil)
-	cloud.MockActions.ListFn = func(_ context.Context) (<-chan actions.Action, <-chan error) {
+	cloud.MockActions.ListFn = func(_ context.Context, _ ...actions.ListOpt) (<-chan actions.Action, <-chan error) {
 		lc := make(chan actions.Action, 1)
 		lc <- &action{want}
 		close(lc)
@@ -119,7 +119,7 @@ func TestThrows(t *testing.T) {
 	cloud.MockActions.GetFn = func(_ context.Context, _ int) (actions.Action, error) {
 		return nil, errors.New("throw me")
 	}
-	cloud.MockActions.ListFn = func(_ context.Context) (<-chan actions.Action, <-chan error) {
+	cloud.MockActions.ListFn = func(_ context.Context, _ ...actions.ListOpt) (<-chan actions.Action, <-chan error) {
 		lc := make(chan actions.Action)
 		close(lc)
 		ec := make(chan error, 1)
@@ -146,3 +146,98 @@ try {
 }
     `)
 }
+
+func TestListCursor(t *testing.T) {
+	var listCtx context.Context
+	cloud := mockcloud.Client(nil)
+	cloud.MockActions.ListFn = func(ctx context.Context, _ ...actions.ListOpt) (<-chan actions.Action, <-chan error) {
+		listCtx = ctx
+		lc := make(chan actions.Action)
+		ec := make(chan error, 1)
+		go func() {
+			defer close(lc)
+			defer close(ec)
+			for i := 1; ; i++ {
+				select {
+				case lc <- &action{&godo.Action{ID: i, StartedAt: &godo.Timestamp{}, CompletedAt: &godo.Timestamp{}}}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return lc, ec
+	}
+
+	vmtest.Run(t, cloud, `
+var pkg = cloud.actions;
+
+var c = pkg.list({cursor: true});
+equals(c.next().id, 1, "should get the first action");
+
+var ids = c.take(2).map(function(a) { return a.id; });
+equals(ids, [2, 3], "should take the next actions");
+
+var even = c.filter(function(a) { return a.id % 2 == 0; });
+equals(even.next().id, 4, "should skip filtered actions");
+equals(even.next().id, 6, "should skip filtered actions");
+
+var seen = [];
+c.each(function(a) {
+	seen.push(a.id);
+	return seen.length < 3;
+});
+equals(seen, [7, 8, 9], "should stop iterating when told to");
+equals(c.next(), null, "should be done");
+    `)
+
+	if listCtx.Err() == nil {
+		t.Fatal("want the listing to be cancelled")
+	}
+}
+
+func TestActionWatch(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	var canceled = make(chan struct{})
+	cloud.MockActions.WatchFn = func(ctx context.Context, _ ...actions.WatchOpt) <-chan actions.Event {
+		eventc := make(chan actions.Event)
+		go func() {
+			defer close(eventc)
+			for _, ev := range []actions.Event{
+				{Type: actions.EventStarted, Action: &action{&godo.Action{ID: 1, Status: "in-progress", Type: "resize", ResourceType: "droplet", ResourceID: 42}}},
+				{Err: errors.New("can't poll")},
+				{Type: actions.EventCompleted, Action: &action{&godo.Action{ID: 1, Status: "completed", Type: "resize", ResourceType: "droplet", ResourceID: 42}}},
+				{Type: actions.EventStarted, Action: &action{&godo.Action{ID: 2, Status: "in-progress", Type: "reboot", ResourceType: "droplet", ResourceID: 42}}},
+			} {
+				select {
+				case eventc <- ev:
+				case <-ctx.Done():
+					close(canceled)
+					return
+				}
+			}
+			<-ctx.Done()
+			close(canceled)
+		}()
+		return eventc
+	}
+
+	vmtest.Run(t, cloud, `
+var seen = [];
+cloud.actions.watch(function(ev) {
+	if (ev.type == "error") {
+		seen.push(ev.error);
+		return;
+	}
+	seen.push(ev.type + " " + ev.action.type + " " + ev.action.resource_id);
+	return ev.type != "completed";
+}, {resource_type: "droplet", interval: "1s"});
+
+equals(seen, ["started resize 42", "can't poll", "completed resize 42"], "should see events until fn returns false");
+`)
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("want the watch to be canceled")
+	}
+}
@@ -0,0 +1,272 @@
+package cloudinit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aybabtme/godotto/pkg/extra/cloudinit"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/robertkrimen/otto"
+)
+
+var q = otto.Value{}
+
+// Apply creates `cloud.cloudinit`, which builds the user data of droplets:
+// cloud-configs with `config(spec)`, archives of many of them and of scripts
+// with `multipart(parts)`, and checks them with `validate(user_data)`.
+func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value, error) {
+	root, err := vm.Object(`({})`)
+	if err != nil {
+		return q, err
+	}
+
+	svc := cloudinitSvc{ctx: ctx, cloud: client}
+
+	for _, applier := range []struct {
+		Name   string
+		Method func(otto.FunctionCall) otto.Value
+	}{
+		{"config", svc.config},
+		{"multipart", svc.multipart},
+		{"validate", svc.validate},
+	} {
+		if err := root.Set(applier.Name, applier.Method); err != nil {
+			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
+		}
+	}
+
+	return root.Value(), nil
+}
+
+type cloudinitSvc struct {
+	ctx   context.Context
+	cloud cloud.Client
+}
+
+func (svc *cloudinitSvc) config(all otto.FunctionCall) otto.Value {
+	vm := all.Otto
+	userData, err := svc.argConfig(vm, all.Argument(0)).Marshal()
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	return ottoutil.ToValue(vm, userData)
+}
+
+func (svc *cloudinitSvc) multipart(all otto.FunctionCall) otto.Value {
+	vm := all.Otto
+	var parts []string
+	ottoutil.LoadArray(vm, all.Argument(0), func(v otto.Value) {
+		if !v.IsObject() {
+			parts = append(parts, ottoutil.String(vm, v))
+			return
+		}
+		part, err := svc.argConfig(vm, v).Marshal()
+		if err != nil {
+			ottoutil.Throw(vm, err.Error())
+		}
+		parts = append(parts, part)
+	})
+	userData, err := cloudinit.Multipart(parts...)
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	return ottoutil.ToValue(vm, userData)
+}
+
+func (svc *cloudinitSvc) validate(all otto.FunctionCall) otto.Value {
+	vm := all.Otto
+	if err := cloudinit.ValidateUserData(ottoutil.String(vm, all.Argument(0))); err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	return q
+}
+
+// keys of the specs of configs, other than those in `extra`
+var specKeys = map[string]bool{
+	"users": true, "ssh_keys": true,
+	"package_update": true, "package_upgrade": true, "packages": true,
+	"write_files": true, "mounts": true, "runcmd": true, "extra": true,
+}
+
+// argConfig reads the spec of a config, like:
+//
+//	{
+//	  users: [{name: "deploy", groups: ["docker"], sudo: true, ssh_keys: "account"}],
+//	  packages: ["nginx"],
+//	  write_files: [{path: "/etc/motd", content: "hello\n", permissions: 0644}],
+//	  mounts: [{volume: vol, path: "/data"}],
+//	  runcmd: ["systemctl restart nginx", ["touch", "/tmp/done"]],
+//	  extra: {timezone: "UTC"},
+//	}
+func (svc *cloudinitSvc) argConfig(vm *otto.Otto, v otto.Value) *cloudinit.Config {
+	if !v.IsObject() {
+		ottoutil.Throw(vm, "argument must be an Object, got a %q", v.Class())
+	}
+	for _, k := range v.Object().Keys() {
+		if !specKeys[k] {
+			ottoutil.Throw(vm, "unknown key %q, other cloud-config keys go in `extra`", k)
+		}
+	}
+	get := func(name string) otto.Value { return ottoutil.GetObject(vm, v, name, false) }
+
+	cfg := &cloudinit.Config{
+		SSHAuthorizedKeys: svc.argKeys(vm, get("ssh_keys")),
+		PackageUpdate:     ottoutil.Bool(vm, get("package_update")),
+		PackageUpgrade:    ottoutil.Bool(vm, get("package_upgrade")),
+		Packages:          ottoutil.StringSlice(vm, get("packages")),
+	}
+	ottoutil.LoadArray(vm, get("users"), func(v otto.Value) {
+		cfg.Users = append(cfg.Users, svc.argUser(vm, v))
+	})
+	ottoutil.LoadArray(vm, get("write_files"), func(v otto.Value) {
+		cfg.WriteFiles = append(cfg.WriteFiles, argFile(vm, v))
+	})
+	ottoutil.LoadArray(vm, get("mounts"), func(v otto.Value) {
+		cfg.AddMount(argMount(vm, v))
+	})
+	ottoutil.LoadArray(vm, get("runcmd"), func(v otto.Value) {
+		if v.IsObject() {
+			cfg.RunCmd = append(cfg.RunCmd, cloudinit.Command{Args: ottoutil.StringSlice(vm, v)})
+		} else {
+			cfg.RunCmd = append(cfg.RunCmd, cloudinit.Command{Shell: ottoutil.String(vm, v)})
+		}
+	})
+	if extra := get("extra"); extra.IsDefined() {
+		if !extra.IsObject() {
+			ottoutil.Throw(vm, "extra must be an Object, got a %q", extra.Class())
+		}
+		ev, err := extra.Export()
+		if err != nil {
+			ottoutil.Throw(vm, err.Error())
+		}
+		cfg.Extra, _ = ev.(map[string]interface{})
+	}
+	return cfg
+}
+
+func (svc *cloudinitSvc) argUser(vm *otto.Otto, v otto.Value) cloudinit.User {
+	if !v.IsObject() {
+		ottoutil.Throw(vm, "users must be Objects, got a %q", v.Class())
+	}
+	get := func(name string) otto.Value { return ottoutil.GetObject(vm, v, name, false) }
+	u := cloudinit.User{
+		Name:              ottoutil.String(vm, ottoutil.GetObject(vm, v, "name", true)),
+		Shell:             ottoutil.String(vm, get("shell")),
+		SSHAuthorizedKeys: svc.argKeys(vm, get("ssh_keys")),
+	}
+	if groups := get("groups"); groups.IsString() {
+		u.Groups = strings.Split(groups.String(), ",")
+	} else {
+		u.Groups = ottoutil.StringSlice(vm, groups)
+	}
+	if sudo := get("sudo"); sudo.IsBoolean() {
+		if ottoutil.Bool(vm, sudo) {
+			u.Sudo = cloudinit.Sudo
+		}
+	} else {
+		u.Sudo = ottoutil.String(vm, sudo)
+	}
+	if lock := get("lock_passwd"); lock.IsDefined() {
+		locked := ottoutil.Bool(vm, lock)
+		u.LockPasswd = &locked
+	}
+	return u
+}
+
+// argKeys reads public keys, as strings or as keys of the account, or all
+// the keys of the account with "account".
+func (svc *cloudinitSvc) argKeys(vm *otto.Otto, v otto.Value) []string {
+	if !v.IsDefined() {
+		return nil
+	}
+	var pubs []string
+	add := func(v otto.Value) {
+		switch {
+		case v.IsString() && v.String() == "account":
+			pubs = append(pubs, svc.accountKeys(vm)...)
+		case v.IsObject():
+			key := godojs.ArgKey(vm, v)
+			if key.PublicKey == "" {
+				ottoutil.Throw(vm, "key %q has no public_key", key.Name)
+			}
+			pubs = append(pubs, key.PublicKey)
+		default:
+			pubs = append(pubs, ottoutil.String(vm, v))
+		}
+	}
+	if v.Class() == "Array" {
+		ottoutil.LoadArray(vm, v, add)
+	} else {
+		add(v)
+	}
+	return pubs
+}
+
+func (svc *cloudinitSvc) accountKeys(vm *otto.Otto) []string {
+	ctx, cancel := context.WithCancel(svc.ctx)
+	defer cancel()
+	keyc, errc := svc.cloud.Keys().List(ctx)
+	var pubs []string
+	for key := range keyc {
+		pubs = append(pubs, key.Struct().PublicKey)
+	}
+	if err := <-errc; err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	if len(pubs) == 0 {
+		ottoutil.Throw(vm, "the account has no ssh keys")
+	}
+	return pubs
+}
+
+func argFile(vm *otto.Otto, v otto.Value) cloudinit.File {
+	if !v.IsObject() {
+		ottoutil.Throw(vm, "write_files must be Objects, got a %q", v.Class())
+	}
+	get := func(name string) otto.Value { return ottoutil.GetObject(vm, v, name, false) }
+	f := cloudinit.File{
+		Path:     ottoutil.String(vm, ottoutil.GetObject(vm, v, "path", true)),
+		Content:  ottoutil.String(vm, get("content")),
+		Owner:    ottoutil.String(vm, get("owner")),
+		Encoding: ottoutil.String(vm, get("encoding")),
+		Append:   ottoutil.Bool(vm, get("append")),
+	}
+	// like chmod, 0644 is octal
+	if perm := get("permissions"); perm.IsNumber() {
+		f.Permissions = fmt.Sprintf("%04o", ottoutil.Int(vm, perm))
+	} else {
+		f.Permissions = ottoutil.String(vm, perm)
+	}
+	return f
+}
+
+// argMount reads a mount of a volume, or of a device, which is formatted
+// unless told otherwise.
+func argMount(vm *otto.Otto, v otto.Value) cloudinit.Mount {
+	if !v.IsObject() {
+		ottoutil.Throw(vm, "mounts must be Objects, got a %q", v.Class())
+	}
+	get := func(name string) otto.Value { return ottoutil.GetObject(vm, v, name, false) }
+	m := cloudinit.Mount{
+		Device:     ottoutil.String(vm, get("device")),
+		Path:       ottoutil.String(vm, ottoutil.GetObject(vm, v, "path", true)),
+		Filesystem: ottoutil.String(vm, get("fs")),
+		Options:    ottoutil.String(vm, get("options")),
+		Format:     true,
+	}
+	switch vol := get("volume"); {
+	case vol.IsObject():
+		m.Device = cloudinit.VolumeDevice(godojs.ArgVolume(vm, vol).Name)
+	case vol.IsDefined():
+		m.Device = cloudinit.VolumeDevice(ottoutil.String(vm, vol))
+	case m.Device == "":
+		ottoutil.Throw(vm, "mounts need a volume or a device")
+	}
+	if format := get("format"); format.IsDefined() {
+		m.Format = ottoutil.Bool(vm, format)
+	}
+	return m
+}
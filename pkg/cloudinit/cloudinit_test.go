@@ -0,0 +1,128 @@
+package cloudinit_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/keys"
+	"github.com/aybabtme/godotto/pkg/extra/do/mockcloud"
+	"github.com/aybabtme/godotto/pkg/extra/vmtest"
+	"github.com/digitalocean/godo"
+)
+
+type key struct {
+	*godo.Key
+}
+
+func (k *key) Struct() *godo.Key { return k.Key }
+
+const (
+	laptop = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl me@laptop"
+	ci     = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl ci@build"
+)
+
+func TestApply(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	vmtest.Run(t, cloud, `
+var pkg = cloud.cloudinit;
+
+assert(pkg != null, "package should be loaded");
+assert(pkg.config != null, "config function should be defined");
+assert(pkg.multipart != null, "multipart function should be defined");
+assert(pkg.validate != null, "validate function should be defined");
+    `)
+}
+
+func TestConfig(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	cloud.MockKeys.ListFn = func(_ context.Context) (<-chan keys.Key, <-chan error) {
+		kc, ec := make(chan keys.Key, 2), make(chan error)
+		kc <- &key{&godo.Key{ID: 1, Name: "laptop", PublicKey: laptop}}
+		kc <- &key{&godo.Key{ID: 2, Name: "ci", PublicKey: ci}}
+		close(kc)
+		close(ec)
+		return kc, ec
+	}
+
+	vmtest.Run(t, cloud, `
+var pkg = cloud.cloudinit;
+
+var userData = pkg.config({
+	users: [{name: "deploy", groups: "docker,adm", shell: "/bin/bash", sudo: true, ssh_keys: "account", lock_passwd: false}],
+	ssh_keys: [{name: "laptop", public_key: "`+laptop+`"}],
+	package_update: true,
+	packages: ["nginx"],
+	write_files: [{path: "/etc/motd", content: "hello\n", permissions: 0644}],
+	mounts: [{volume: {name: "data"}, path: "/data", fs: "xfs"}, {device: "/dev/sdb", path: "/scratch", format: false}],
+	runcmd: ["systemctl restart nginx", ["touch", "/tmp/done"]],
+	extra: {timezone: "UTC"},
+});
+
+equals(userData, "#cloud-config\n" +
+	"fs_setup:\n" +
+	"  - device: /dev/disk/by-id/scsi-0DO_Volume_data\n" +
+	"    filesystem: xfs\n" +
+	"    partition: none\n" +
+	"mounts:\n" +
+	"  - - /dev/disk/by-id/scsi-0DO_Volume_data\n" +
+	"    - /data\n" +
+	"    - xfs\n" +
+	"    - defaults,nofail,discard,noatime\n" +
+	"    - \"0\"\n" +
+	"    - \"2\"\n" +
+	"  - - /dev/sdb\n" +
+	"    - /scratch\n" +
+	"    - ext4\n" +
+	"    - defaults,nofail,discard,noatime\n" +
+	"    - \"0\"\n" +
+	"    - \"2\"\n" +
+	"package_update: true\n" +
+	"packages:\n" +
+	"  - nginx\n" +
+	"runcmd:\n" +
+	"  - systemctl restart nginx\n" +
+	"  - - touch\n" +
+	"    - /tmp/done\n" +
+	"ssh_authorized_keys:\n" +
+	"  - `+laptop+`\n" +
+	"timezone: UTC\n" +
+	"users:\n" +
+	"  - default\n" +
+	"  - groups: docker,adm\n" +
+	"    lock_passwd: false\n" +
+	"    name: deploy\n" +
+	"    shell: /bin/bash\n" +
+	"    ssh_authorized_keys:\n" +
+	"      - `+laptop+`\n" +
+	"      - `+ci+`\n" +
+	"    sudo: ALL=(ALL) NOPASSWD:ALL\n" +
+	"write_files:\n" +
+	"  - content: \"hello\\n\"\n" +
+	"    path: /etc/motd\n" +
+	"    permissions: \"0644\"\n");
+pkg.validate(userData);
+
+var archive = pkg.multipart(["#!/bin/sh\necho hi\n", {packages: ["git"]}]);
+assert(archive.indexOf("Content-Type: multipart/mixed;") == 0, "should be a MIME archive");
+assert(archive.indexOf("#cloud-config\npackages:\n  - git\n") > 0, "should have built the config");
+pkg.validate(archive);
+
+[
+	[function() { pkg.config({users: [{name: "Deploy"}]}) }, 'invalid cloud-config: invalid user name "Deploy"'],
+	[function() { pkg.config({ssh_keys: [{name: "laptop"}]}) }, 'key "laptop" has no public_key'],
+	[function() { pkg.config({mounts: [{path: "/data"}]}) }, "mounts need a volume or a device"],
+	[function() { pkg.config({timezone: "UTC"}) }, 'unknown key "timezone", other cloud-config keys go in `+"`extra`"+`'],
+	[function() { pkg.multipart(["echo hi"]) }, 'part 0: unknown kind of user data, starting with "echo hi"'],
+	[function() { pkg.validate("#cloud-config\n\tpackages: []\n") }, "line 2 of the cloud-config is indented with a tab"],
+	[function() {
+		cloud.droplets.create({name: "web", region: "nyc3", size: "s-1vcpu-1gb", image: {slug: "ubuntu"}, user_data: "#!\nls\n"});
+	}, 'invalid user_data: scripts must start with an interpreter, like "#!/bin/sh"'],
+].forEach(function(tt) {
+	try {
+		tt[0](); throw "dont catch me";
+	} catch (e) {
+		equals(tt[1], e.message, "should send the right exception");
+	}
+});
+    `)
+}
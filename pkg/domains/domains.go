@@ -4,10 +4,11 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/aybabtme/godotto/pkg/extra/godojs"
-	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud/domains"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 
 	"github.com/robertkrimen/otto"
 )
@@ -31,16 +32,27 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 	}{
 		{"list", svc.list},
 		{"get", svc.get},
-		{"create", svc.create},
-		{"delete", svc.delete},
 
 		{"records", svc.records},
 		{"record", svc.record},
+	} {
+		if err := root.Set(applier.Name, applier.Method); err != nil {
+			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
+		}
+	}
+
+	for _, applier := range []struct {
+		Name    string
+		Prepare func(otto.FunctionCall) eventloop.Task
+	}{
+		{"create", svc.create},
+		{"delete", svc.delete},
+
 		{"create_record", svc.createRecord},
 		{"edit_record", svc.editRecord},
 		{"delete_record", svc.deleteRecord},
 	} {
-		if err := root.Set(applier.Name, applier.Method); err != nil {
+		if err := eventloop.Bind(root, applier.Name, applier.Prepare); err != nil {
 			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
 		}
 	}
@@ -53,82 +65,86 @@ type domainSvc struct {
 	svc domains.Client
 }
 
-func (svc *domainSvc) create(all otto.FunctionCall) otto.Value {
+func (svc *domainSvc) create(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 	arg := all.Argument(0)
 
 	req := godojs.ArgDomainCreateRequest(vm, arg)
-	d, err := svc.svc.Create(
-		svc.ctx,
-		req.Name, req.IPAddress,
-	)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		d, err := svc.svc.Create(
+			svc.ctx,
+			req.Name, req.IPAddress,
+		)
+		if err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.DomainKind)
+		return func(vm *otto.Otto) otto.Value {
+			return svc.domainToVM(vm, d.Struct())
+		}, nil
 	}
-
-	return godojs.DomainToVM(vm, d.Struct())
 }
 
 func (svc *domainSvc) get(all otto.FunctionCall) otto.Value {
 	var (
 		vm   = all.Otto
-		name = godojs.ArgDomainName(vm, all.Argument(0))
+		name = godojs.ArgDomainName(svc.ctx, vm, all.Argument(0))
 	)
 	d, err := svc.svc.Get(svc.ctx, name)
 	if err != nil {
 		ottoutil.Throw(vm, err.Error())
 	}
-	return godojs.DomainToVM(vm, d.Struct())
+	return svc.domainToVM(vm, d.Struct())
 }
 
-func (svc *domainSvc) delete(all otto.FunctionCall) otto.Value {
+func (svc *domainSvc) delete(all otto.FunctionCall) eventloop.Task {
 	var (
 		vm   = all.Otto
-		name = godojs.ArgDomainName(vm, all.Argument(0))
+		name = godojs.ArgDomainName(godojs.Fresh(svc.ctx), vm, all.Argument(0))
 	)
-	err := svc.svc.Delete(svc.ctx, name)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		if err := svc.svc.Delete(svc.ctx, name); err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.DomainKind)
+		return nil, nil
 	}
-	return q
 }
 
 func (svc *domainSvc) list(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
-	var domains = make([]otto.Value, 0)
-	domainc, errc := svc.svc.List(svc.ctx)
-	for d := range domainc {
-		domains = append(domains, godojs.DomainToVM(vm, d.Struct()))
-	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	v, err := vm.ToValue(domains)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-	return v
+	ctx, cancel := context.WithCancel(svc.ctx)
+	domainc, errc := svc.svc.List(ctx)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+		d, more := <-domainc
+		if !more {
+			return q, false, <-errc
+		}
+		return svc.domainToVM(vm, d.Struct()), true, nil
+	}, cancel)
 }
 
-func (svc *domainSvc) createRecord(all otto.FunctionCall) otto.Value {
+func (svc *domainSvc) createRecord(all otto.FunctionCall) eventloop.Task {
 	var (
 		vm     = all.Otto
-		name   = godojs.ArgDomainName(vm, all.Argument(0))
+		name   = godojs.ArgDomainName(svc.ctx, vm, all.Argument(0))
 		record = godojs.ArgDomainRecord(vm, all.Argument(1))
 	)
-	d, err := svc.svc.CreateRecord(svc.ctx, name, domains.UseGodoRecord(record))
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		d, err := svc.svc.CreateRecord(svc.ctx, name, domains.UseGodoRecord(record))
+		if err != nil {
+			return nil, err
+		}
+		return func(vm *otto.Otto) otto.Value {
+			return godojs.DomainRecordToVM(vm, d.Struct())
+		}, nil
 	}
-
-	return godojs.DomainRecordToVM(vm, d.Struct())
 }
 
 func (svc *domainSvc) record(all otto.FunctionCall) otto.Value {
 	var (
 		vm   = all.Otto
-		name = godojs.ArgDomainName(vm, all.Argument(0))
+		name = godojs.ArgDomainName(svc.ctx, vm, all.Argument(0))
 		id   = godojs.ArgRecordID(vm, all.Argument(1))
 	)
 	d, err := svc.svc.GetRecord(svc.ctx, name, id)
@@ -139,50 +155,65 @@ func (svc *domainSvc) record(all otto.FunctionCall) otto.Value {
 	return godojs.DomainRecordToVM(vm, d.Struct())
 }
 
-func (svc *domainSvc) editRecord(all otto.FunctionCall) otto.Value {
+func (svc *domainSvc) editRecord(all otto.FunctionCall) eventloop.Task {
 	var (
 		vm     = all.Otto
-		name   = godojs.ArgDomainName(vm, all.Argument(0))
+		name   = godojs.ArgDomainName(svc.ctx, vm, all.Argument(0))
 		id     = godojs.ArgRecordID(vm, all.Argument(1))
 		record = godojs.ArgDomainRecord(vm, all.Argument(1))
 	)
-	d, err := svc.svc.UpdateRecord(svc.ctx, name, id, domains.UseGodoRecord(record))
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		d, err := svc.svc.UpdateRecord(svc.ctx, name, id, domains.UseGodoRecord(record))
+		if err != nil {
+			return nil, err
+		}
+		return func(vm *otto.Otto) otto.Value {
+			return godojs.DomainRecordToVM(vm, d.Struct())
+		}, nil
 	}
-	return godojs.DomainRecordToVM(vm, d.Struct())
 }
 
-func (svc *domainSvc) deleteRecord(all otto.FunctionCall) otto.Value {
+func (svc *domainSvc) deleteRecord(all otto.FunctionCall) eventloop.Task {
 	var (
 		vm   = all.Otto
-		name = godojs.ArgDomainName(vm, all.Argument(0))
+		name = godojs.ArgDomainName(svc.ctx, vm, all.Argument(0))
 		id   = godojs.ArgRecordID(vm, all.Argument(1))
 	)
-	err := svc.svc.DeleteRecord(svc.ctx, name, id)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.DeleteRecord(svc.ctx, name, id)
 	}
-	return q
+}
+
+// argRecordListOpts reads filters like `{type: "A", name: "www"}`.
+func (svc *domainSvc) argRecordListOpts(all otto.FunctionCall, i int) []domains.RecordListOpt {
+	vm := all.Otto
+	arg := all.Argument(i)
+	if !arg.IsObject() {
+		return nil
+	}
+	var opts []domains.RecordListOpt
+	if v := ottoutil.GetObject(vm, arg, "type", false); v.IsDefined() {
+		opts = append(opts, domains.FilterRecordType(ottoutil.String(vm, v)))
+	}
+	if v := ottoutil.GetObject(vm, arg, "name", false); v.IsDefined() {
+		opts = append(opts, domains.FilterRecordName(ottoutil.String(vm, v)))
+	}
+	return opts
 }
 
 func (svc *domainSvc) records(all otto.FunctionCall) otto.Value {
 	var (
 		vm   = all.Otto
-		name = godojs.ArgDomainName(vm, all.Argument(0))
+		name = godojs.ArgDomainName(svc.ctx, vm, all.Argument(0))
 	)
-	var records = make([]otto.Value, 0)
-	recordc, errc := svc.svc.ListRecord(svc.ctx, name)
-	for d := range recordc {
-		records = append(records, godojs.DomainRecordToVM(vm, d.Struct()))
-	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	v, err := vm.ToValue(records)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-	return v
+	opts := svc.argRecordListOpts(all, 1)
+	ctx, cancel := context.WithCancel(svc.ctx)
+	recordc, errc := svc.svc.ListRecord(ctx, name, opts...)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(1)), func() (otto.Value, bool, error) {
+		d, more := <-recordc
+		if !more {
+			return q, false, <-errc
+		}
+		return godojs.DomainRecordToVM(vm, d.Struct()), true, nil
+	}, cancel)
 }
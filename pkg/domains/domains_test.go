@@ -50,7 +50,7 @@ func TestThrows(t *testing.T) {
 	cloud.MockDomains.DeleteFn = func(_ context.Context, _ string) error {
 		return errors.New("throw me")
 	}
-	cloud.MockDomains.ListRecordFn = func(_ context.Context, _ string) (<-chan domains.Record, <-chan error) {
+	cloud.MockDomains.ListRecordFn = func(_ context.Context, _ string, _ ...domains.RecordListOpt) (<-chan domains.Record, <-chan error) {
 		lc := make(chan domains.Record)
 		close(lc)
 		ec := make(chan error, 1)
@@ -211,7 +211,7 @@ pkg.delete("my name");
 func TestListRecord(t *testing.T) {
 	wantName := "my name"
 	cloud := mockcloud.Client(nil)
-	cloud.MockDomains.ListRecordFn = func(_ context.Context, gotName string) (<-chan domains.Record, <-chan error) {
+	cloud.MockDomains.ListRecordFn = func(_ context.Context, gotName string, _ ...domains.RecordListOpt) (<-chan domains.Record, <-chan error) {
 		if gotName != wantName {
 			t.Fatalf("want %q got %q", wantName, gotName)
 		}
@@ -353,3 +353,62 @@ var pkg = cloud.domains;
 pkg.delete_record("my name", 42);
 `)
 }
+
+func TestDomainMethods(t *testing.T) {
+	wantName := "my_name"
+	cloud := mockcloud.Client(nil)
+	cloud.MockDomains.GetFn = func(_ context.Context, _ string) (domains.Domain, error) {
+		return &domain{&godo.Domain{Name: wantName, TTL: 42, ZoneFile: "my_zone_file"}}, nil
+	}
+	cloud.MockDomains.ListRecordFn = func(_ context.Context, gotName string, _ ...domains.RecordListOpt) (<-chan domains.Record, <-chan error) {
+		if gotName != wantName {
+			t.Fatalf("want %q got %q", wantName, gotName)
+		}
+		lc := make(chan domains.Record, 1)
+		lc <- &record{&godo.DomainRecord{ID: 42, Type: "A", Name: "www", Data: "127.0.0.1"}}
+		close(lc)
+		ec := make(chan error)
+		close(ec)
+		return lc, ec
+	}
+	cloud.MockDomains.DeleteRecordFn = func(_ context.Context, gotName string, gotID int) error {
+		if gotName != wantName || gotID != 42 {
+			t.Fatalf("want %q/%d got %q/%d", wantName, 42, gotName, gotID)
+		}
+		return nil
+	}
+
+	vmtest.Run(t, cloud, `
+var d = cloud.domains.get("my_name");
+
+var records = d.records();
+assert(records.length == 1, "should have received the records");
+d.delete_record(records[0]);
+`)
+}
+
+func TestAsync(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	cloud.MockDomains.CreateFn = func(_ context.Context, name, ip string, opt ...domains.CreateOpt) (domains.Domain, error) {
+		return &domain{&godo.Domain{Name: name, TTL: 42}}, nil
+	}
+	var created []string
+	cloud.MockDomains.CreateRecordFn = func(_ context.Context, name string, _ ...domains.RecordOpt) (domains.Record, error) {
+		created = append(created, name)
+		return &record{&godo.DomainRecord{ID: len(created), Type: "A", Name: "www", Data: "127.0.0.1"}}, nil
+	}
+
+	vmtest.Run(t, cloud, `
+var pkg = cloud.domains;
+var d = pkg.create_async({name: "example.com", ip_address: "127.0.0.1"}).wait("1s");
+equals(d.name, "example.com", "should have created the domain");
+var r = d.create_record_async({type: "A", name: "www", data: "127.0.0.1", priority: 0, port: 0, weight: 0}).wait("1s");
+equals(r.id, 1, "should have created the record");
+r = pkg.create_record_async("example.com", {type: "A", name: "www", data: "127.0.0.1", priority: 0, port: 0, weight: 0}).wait("1s");
+equals(r.id, 2, "should have created the record");
+`)
+
+	if len(created) != 2 || created[0] != "example.com" || created[1] != "example.com" {
+		t.Fatalf("want records created on %q, got %v", "example.com", created)
+	}
+}
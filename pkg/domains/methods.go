@@ -0,0 +1,76 @@
+package domains
+
+import (
+	"context"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/domains"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/digitalocean/godo"
+	"github.com/robertkrimen/otto"
+)
+
+// domainToVM converts a domain and binds methods to it, so that scripts
+// can do `domain.records()`.
+func (svc *domainSvc) domainToVM(vm *otto.Otto, g *godo.Domain) otto.Value {
+	v := godojs.DomainToVM(vm, g)
+	if g == nil {
+		return v
+	}
+	name := g.Name
+
+	ottoutil.SetMethods(vm, v, map[string]func(otto.FunctionCall) otto.Value{
+		"refresh": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			d, err := svc.svc.Get(svc.ctx, name)
+			if err != nil {
+				ottoutil.Throw(vm, err.Error())
+			}
+			return ottoutil.Assign(vm, all.This, godojs.DomainToVM(vm, d.Struct()))
+		},
+		"records": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			opts := svc.argRecordListOpts(all, 0)
+			ctx, cancel := context.WithCancel(svc.ctx)
+			recordc, errc := svc.svc.ListRecord(ctx, name, opts...)
+			return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+				d, more := <-recordc
+				if !more {
+					return q, false, <-errc
+				}
+				return godojs.DomainRecordToVM(vm, d.Struct()), true, nil
+			}, cancel)
+		},
+	})
+	return eventloop.Methods(vm, v, map[string]func(otto.FunctionCall) eventloop.Task{
+		"delete": func(all otto.FunctionCall) eventloop.Task {
+			return func() (eventloop.Result, error) {
+				if err := svc.svc.Delete(svc.ctx, name); err != nil {
+					return nil, err
+				}
+				godojs.Invalidate(svc.ctx, cloud.DomainKind)
+				return nil, nil
+			}
+		},
+		"create_record": func(all otto.FunctionCall) eventloop.Task {
+			record := godojs.ArgDomainRecord(all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				d, err := svc.svc.CreateRecord(svc.ctx, name, domains.UseGodoRecord(record))
+				if err != nil {
+					return nil, err
+				}
+				return func(vm *otto.Otto) otto.Value {
+					return godojs.DomainRecordToVM(vm, d.Struct())
+				}, nil
+			}
+		},
+		"delete_record": func(all otto.FunctionCall) eventloop.Task {
+			id := godojs.ArgRecordID(all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				return nil, svc.svc.DeleteRecord(svc.ctx, name, id)
+			}
+		},
+	})
+}
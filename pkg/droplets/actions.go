@@ -6,6 +6,7 @@ import (
 
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud/droplets"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
 	"github.com/aybabtme/godotto/pkg/extra/godojs"
 	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/robertkrimen/otto"
@@ -22,8 +23,8 @@ func applyAction(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.
 		svc: client.Droplets().Actions(),
 	}
 	for _, applier := range []struct {
-		Name   string
-		Method func(otto.FunctionCall) otto.Value
+		Name    string
+		Prepare func(otto.FunctionCall) eventloop.Task
 	}{
 		{"shutdown", svc.shutdown},
 		{"power_off", svc.powerOff},
@@ -41,7 +42,7 @@ func applyAction(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.
 		{"enable_ipv6", svc.enableIPv6},
 		{"enable_private_networking", svc.enablePrivateNetworking},
 	} {
-		if err := root.Set(applier.Name, applier.Method); err != nil {
+		if err := eventloop.Bind(root, applier.Name, applier.Prepare); err != nil {
 			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
 		}
 	}
@@ -54,158 +55,137 @@ type actionSvc struct {
 	svc droplets.ActionClient
 }
 
-func (svc *actionSvc) shutdown(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) shutdown(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	dropletID := godojs.ArgDropletID(vm, all.Argument(0))
-	err := svc.svc.Shutdown(svc.ctx, dropletID)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.Shutdown(svc.ctx, dropletID)
 	}
-	return q
 }
 
-func (svc *actionSvc) powerOff(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) powerOff(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	dropletID := godojs.ArgDropletID(vm, all.Argument(0))
-	err := svc.svc.PowerOff(svc.ctx, dropletID)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.PowerOff(svc.ctx, dropletID)
 	}
-	return q
 }
 
-func (svc *actionSvc) powerOn(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) powerOn(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	dropletID := godojs.ArgDropletID(vm, all.Argument(0))
-	err := svc.svc.PowerOn(svc.ctx, dropletID)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.PowerOn(svc.ctx, dropletID)
 	}
-	return q
 }
 
-func (svc *actionSvc) powerCycle(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) powerCycle(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	dropletID := godojs.ArgDropletID(vm, all.Argument(0))
-	err := svc.svc.PowerCycle(svc.ctx, dropletID)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.PowerCycle(svc.ctx, dropletID)
 	}
-	return q
 }
 
-func (svc *actionSvc) reboot(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) reboot(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	dropletID := godojs.ArgDropletID(vm, all.Argument(0))
-	err := svc.svc.Reboot(svc.ctx, dropletID)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.Reboot(svc.ctx, dropletID)
 	}
-	return q
 }
 
-func (svc *actionSvc) restore(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) restore(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	dropletID := godojs.ArgDropletID(vm, all.Argument(0))
-	imageID := godojs.ArgImageID(vm, all.Argument(1))
-	err := svc.svc.Restore(svc.ctx, dropletID, imageID)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))
+	imageID := godojs.ArgImageID(svc.ctx, vm, all.Argument(1))
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.Restore(svc.ctx, dropletID, imageID)
 	}
-	return q
 }
 
-func (svc *actionSvc) resize(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) resize(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	dropletID := godojs.ArgDropletID(vm, all.Argument(0))
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))
 	sizeSlug := godojs.ArgSizeSlug(vm, all.Argument(1))
 	resizeDisk := ottoutil.Bool(vm, all.Argument(2))
-	err := svc.svc.Resize(svc.ctx, dropletID, sizeSlug, resizeDisk)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.Resize(svc.ctx, dropletID, sizeSlug, resizeDisk)
 	}
-	return q
 }
 
-func (svc *actionSvc) rename(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) rename(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	dropletID := godojs.ArgDropletID(vm, all.Argument(0))
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))
 	name := ottoutil.String(vm, all.Argument(1))
-	err := svc.svc.Rename(svc.ctx, dropletID, name)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		if err := svc.svc.Rename(svc.ctx, dropletID, name); err != nil {
+			return nil, err
+		}
+		// floating IPs are found by the name of their droplet
+		godojs.Invalidate(svc.ctx, cloud.DropletKind, cloud.FloatingIPKind)
+		return nil, nil
 	}
-	return q
 }
 
-func (svc *actionSvc) snapshot(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) snapshot(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	dropletID := godojs.ArgDropletID(vm, all.Argument(0))
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))
 	name := ottoutil.String(vm, all.Argument(1))
-	err := svc.svc.Snapshot(svc.ctx, dropletID, name)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		if err := svc.svc.Snapshot(svc.ctx, dropletID, name); err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.ImageKind, cloud.SnapshotKind)
+		return nil, nil
 	}
-	return q
 }
 
-func (svc *actionSvc) enableBackups(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) enableBackups(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	dropletID := godojs.ArgDropletID(vm, all.Argument(0))
-	err := svc.svc.EnableBackups(svc.ctx, dropletID)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.EnableBackups(svc.ctx, dropletID)
 	}
-	return q
 }
 
-func (svc *actionSvc) disableBackups(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) disableBackups(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	dropletID := godojs.ArgDropletID(vm, all.Argument(0))
-	err := svc.svc.DisableBackups(svc.ctx, dropletID)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.DisableBackups(svc.ctx, dropletID)
 	}
-	return q
 }
 
-func (svc *actionSvc) passwordReset(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) passwordReset(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	dropletID := godojs.ArgDropletID(vm, all.Argument(0))
-	err := svc.svc.PasswordReset(svc.ctx, dropletID)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.PasswordReset(svc.ctx, dropletID)
 	}
-	return q
 }
 
-func (svc *actionSvc) changeKernel(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) changeKernel(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	dropletID := godojs.ArgDropletID(vm, all.Argument(0))
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))
 	kernelID := godojs.ArgKernelID(vm, all.Argument(1))
-	err := svc.svc.ChangeKernel(svc.ctx, dropletID, kernelID)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.ChangeKernel(svc.ctx, dropletID, kernelID)
 	}
-	return q
 }
 
-func (svc *actionSvc) enableIPv6(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) enableIPv6(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	dropletID := godojs.ArgDropletID(vm, all.Argument(0))
-	err := svc.svc.EnableIPv6(svc.ctx, dropletID)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.EnableIPv6(svc.ctx, dropletID)
 	}
-	return q
 }
 
-func (svc *actionSvc) enablePrivateNetworking(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) enablePrivateNetworking(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	dropletID := godojs.ArgDropletID(vm, all.Argument(0))
-	err := svc.svc.EnablePrivateNetworking(svc.ctx, dropletID)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.EnablePrivateNetworking(svc.ctx, dropletID)
 	}
-	return q
 }
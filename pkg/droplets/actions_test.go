@@ -7,6 +7,7 @@ import (
 
 	"github.com/aybabtme/godotto/pkg/extra/do/mockcloud"
 	"github.com/aybabtme/godotto/pkg/extra/vmtest"
+	"github.com/robertkrimen/otto"
 )
 
 func TestDropletActionsApply(t *testing.T) {
@@ -378,3 +379,58 @@ var pkg = cloud.droplets.actions;
 pkg.enable_private_networking(42);
 	`)
 }
+
+func TestDropletActionAsync(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	mock := cloud.MockDroplets.MockDropletActions
+
+	rebooting := make(chan struct{})
+	mock.RebootFn = func(ctx context.Context, dropletID int) error {
+		<-rebooting
+		return nil
+	}
+	mock.PowerOffFn = func(ctx context.Context, dropletID int) error {
+		return errors.New("can't power off")
+	}
+	vmtest.Run(t, cloud, `
+var pkg = cloud.droplets.actions;
+
+var rebooted = false;
+var reboot = pkg.reboot_async(42).then(function() { rebooted = true; });
+assert(!reboot.done(), "should not be done");
+equals(reboot.status(), "pending", "should be pending");
+try {
+	reboot.wait("10ms");
+	throw "dont catch me";
+} catch (e) {
+	assert(e != "dont catch me", "should have timed out");
+}
+
+var failure = null;
+var powerOff = pkg.power_off_async(42).then(function() {
+	throw "should not succeed";
+}, function(err) {
+	failure = err;
+});
+
+release();
+reboot.wait("1s");
+assert(reboot.done(), "should be done");
+equals(reboot.status(), "done", "should be done");
+assert(rebooted, "should have called the callback");
+
+try {
+	powerOff.wait("1s");
+	throw "dont catch me";
+} catch (e) {
+	equals(e.message, "can't power off", "should throw the error");
+}
+equals(powerOff.status(), "errored", "should have failed");
+equals(failure, "can't power off", "should have called the error callback");
+	`, func(vm *otto.Otto) error {
+		return vm.Set("release", func(otto.FunctionCall) otto.Value {
+			close(rebooting)
+			return otto.UndefinedValue()
+		})
+	})
+}
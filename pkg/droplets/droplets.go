@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/aybabtme/godotto/pkg/extra/cloudinit"
 	"github.com/aybabtme/godotto/pkg/extra/godojs"
 	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud/droplets"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
 
 	"github.com/robertkrimen/otto"
 )
@@ -21,8 +23,9 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 	}
 
 	svc := dropletSvc{
-		ctx: ctx,
-		svc: client.Droplets(),
+		ctx:   ctx,
+		svc:   client.Droplets(),
+		cloud: client,
 	}
 
 	actions, err := applyAction(ctx, vm, client)
@@ -36,12 +39,23 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 	}{
 		{"list", svc.list},
 		{"get", svc.get},
+		{"actions", actions},
+	} {
+		if err := root.Set(applier.Name, applier.Method); err != nil {
+			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
+		}
+	}
+
+	for _, applier := range []struct {
+		Name    string
+		Prepare func(otto.FunctionCall) eventloop.Task
+	}{
 		{"create", svc.create},
 		{"create_multiple", svc.createMultiple},
 		{"delete", svc.delete},
-		{"actions", actions},
+		{"wait_public_ipv4", svc.waitPublicIPv4},
 	} {
-		if err := root.Set(applier.Name, applier.Method); err != nil {
+		if err := eventloop.Bind(root, applier.Name, applier.Prepare); err != nil {
 			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
 		}
 	}
@@ -50,90 +64,133 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 }
 
 type dropletSvc struct {
-	ctx context.Context
-	svc droplets.Client
+	ctx   context.Context
+	svc   droplets.Client
+	cloud cloud.Client
 }
 
-func (svc *dropletSvc) create(all otto.FunctionCall) otto.Value {
+func (svc *dropletSvc) create(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 	arg := all.Argument(0)
 
 	req := godojs.ArgDropletCreateRequest(vm, arg)
-
-	d, err := svc.svc.Create(svc.ctx, req.Name, req.Region, req.Size, req.Image.Slug, droplets.UseGodoCreate(req))
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	if err := cloudinit.ValidateUserData(req.UserData); err != nil {
+		ottoutil.Throw(vm, "invalid user_data: %v", err)
+	}
+	var ready bool
+	switch opts := all.Argument(1); {
+	case opts.IsObject():
+		ready = ottoutil.Bool(vm, ottoutil.GetObject(vm, opts, "wait_ready", false))
+	case opts.IsDefined() && !opts.IsNull():
+		ottoutil.Throw(vm, "options must be an Object")
 	}
 
-	return godojs.DropletToVM(vm, d.Struct())
+	return func() (eventloop.Result, error) {
+		d, err := svc.svc.Create(svc.ctx, req.Name, req.Region, req.Size, req.Image.Slug, droplets.UseGodoCreate(req))
+		if err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.DropletKind)
+		if ready {
+			// the droplet has its IPs once it's active
+			if d, err = svc.svc.Get(svc.ctx, d.Struct().ID); err != nil {
+				return nil, err
+			}
+			if err := waitReady(svc.ctx, d.Struct()); err != nil {
+				return nil, err
+			}
+		}
+		return func(vm *otto.Otto) otto.Value {
+			return svc.dropletToVM(vm, d.Struct())
+		}, nil
+	}
 }
 
 func (svc *dropletSvc) get(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
 	arg := all.Argument(0)
 
-	did := godojs.ArgDropletID(vm, arg)
+	did := godojs.ArgDropletID(svc.ctx, vm, arg)
 
 	d, err := svc.svc.Get(svc.ctx, did)
 	if err != nil {
 		ottoutil.Throw(vm, err.Error())
 	}
-	return godojs.DropletToVM(vm, d.Struct())
+	return svc.dropletToVM(vm, d.Struct())
 }
 
-func (svc *dropletSvc) delete(all otto.FunctionCall) otto.Value {
+func (svc *dropletSvc) delete(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 	arg := all.Argument(0)
 
-	did := godojs.ArgDropletID(vm, arg)
+	did := godojs.ArgDropletID(godojs.Fresh(svc.ctx), vm, arg)
 
-	err := svc.svc.Delete(svc.ctx, did)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		if err := svc.svc.Delete(svc.ctx, did); err != nil {
+			return nil, err
+		}
+		// its floating IP, if any, is unassigned
+		godojs.Invalidate(svc.ctx, cloud.DropletKind, cloud.FloatingIPKind)
+		return nil, nil
 	}
-	return q
 }
 
-func (svc *dropletSvc) list(all otto.FunctionCall) otto.Value {
+// argListOpts reads filters like `{tag: "web"}`.
+func (svc *dropletSvc) argListOpts(all otto.FunctionCall, i int) []droplets.ListOpt {
 	vm := all.Otto
-
-	var droplets = make([]otto.Value, 0)
-	dropletc, errc := svc.svc.List(svc.ctx)
-	for d := range dropletc {
-		droplets = append(droplets, godojs.DropletToVM(vm, d.Struct()))
+	arg := all.Argument(i)
+	if !arg.IsObject() {
+		return nil
 	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
+	var opts []droplets.ListOpt
+	if v := ottoutil.GetObject(vm, arg, "tag", false); v.IsDefined() {
+		opts = append(opts, droplets.FilterTag(ottoutil.String(vm, v)))
 	}
+	return opts
+}
 
-	v, err := vm.ToValue(droplets)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-	return v
+func (svc *dropletSvc) list(all otto.FunctionCall) otto.Value {
+	vm := all.Otto
+
+	opts := svc.argListOpts(all, 0)
+	ctx, cancel := context.WithCancel(svc.ctx)
+	dropletc, errc := svc.svc.List(ctx, opts...)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+		d, more := <-dropletc
+		if !more {
+			return q, false, <-errc
+		}
+		return svc.dropletToVM(vm, d.Struct()), true, nil
+	}, cancel)
 }
 
-func (svc *dropletSvc) createMultiple(all otto.FunctionCall) otto.Value {
+func (svc *dropletSvc) createMultiple(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 
 	arg := all.Argument(0)
 
 	req := godojs.ArgDropletMultiCreateRequest(vm, arg)
-
-	droplets, err := svc.svc.CreateMultiple(svc.ctx, req.Names, req.Region, req.Size, req.Image.Slug, droplets.UseGodoMultiCreate(req))
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	var d = make([]otto.Value, 0, len(droplets))
-	for _, droplet := range droplets {
-		d = append(d, godojs.DropletToVM(vm, droplet.Struct()))
+	if err := cloudinit.ValidateUserData(req.UserData); err != nil {
+		ottoutil.Throw(vm, "invalid user_data: %v", err)
 	}
 
-	v, err := vm.ToValue(d)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		droplets, err := svc.svc.CreateMultiple(svc.ctx, req.Names, req.Region, req.Size, req.Image.Slug, droplets.UseGodoMultiCreate(req))
+		if err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.DropletKind)
+		return func(vm *otto.Otto) otto.Value {
+			var d = make([]otto.Value, 0, len(droplets))
+			for _, droplet := range droplets {
+				d = append(d, svc.dropletToVM(vm, droplet.Struct()))
+			}
+
+			v, err := vm.ToValue(d)
+			if err != nil {
+				ottoutil.Throw(vm, err.Error())
+			}
+			return v
+		}, nil
 	}
-
-	return v
 }
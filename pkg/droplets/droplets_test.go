@@ -3,11 +3,16 @@ package droplets_test
 import (
 	"context"
 	"errors"
+	"sync"
 	"testing"
+	"time"
 
-	"github.com/aybabtme/godotto/pkg/extra/vmtest"
+	jsdroplets "github.com/aybabtme/godotto/pkg/droplets"
+	doCloud "github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud/droplets"
 	"github.com/aybabtme/godotto/pkg/extra/do/mockcloud"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/vmtest"
 	"github.com/digitalocean/godo"
 )
 
@@ -28,7 +33,7 @@ assert(pkg.delete != null, "delete function should be defined");
 func TestDropletThrows(t *testing.T) {
 	cloud := mockcloud.Client(nil)
 
-	cloud.MockDroplets.ListFn = func(_ context.Context) (<-chan droplets.Droplet, <-chan error) {
+	cloud.MockDroplets.ListFn = func(_ context.Context, _ ...droplets.ListOpt) (<-chan droplets.Droplet, <-chan error) {
 		lc := make(chan droplets.Droplet)
 		close(lc)
 		ec := make(chan error, 1)
@@ -103,7 +108,7 @@ func (k *droplet) Struct() *godo.Droplet { return k.Droplet }
 
 func TestDropletList(t *testing.T) {
 	cloud := mockcloud.Client(nil)
-	cloud.MockDroplets.ListFn = func(_ context.Context) (<-chan droplets.Droplet, <-chan error) {
+	cloud.MockDroplets.ListFn = func(_ context.Context, _ ...droplets.ListOpt) (<-chan droplets.Droplet, <-chan error) {
 		lc := make(chan droplets.Droplet, 1)
 		lc <- &droplet{d}
 		close(lc)
@@ -249,6 +254,61 @@ equals(d, want, "should have proper object");
 `)
 }
 
+func TestDropletCreateWaitReady(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	cloud.MockDroplets.CreateFn = func(_ context.Context, name, region, size, image string, _ ...droplets.CreateOpt) (droplets.Droplet, error) {
+		return &droplet{&godo.Droplet{ID: 42, Name: name}}, nil
+	}
+	cloud.MockDroplets.GetFn = func(_ context.Context, id int) (droplets.Droplet, error) {
+		return &droplet{&godo.Droplet{ID: id, Name: "my_name", Networks: &godo.Networks{
+			V4: []godo.NetworkV4{{IPAddress: "192.0.2.10", Type: "public"}},
+		}}}, nil
+	}
+
+	req := `var req = {name: "my_name", region: "nyc3", size: "4gb", image: {slug: "ubuntu-16-04-x64"}};`
+	vmtest.Run(t, cloud, req+`
+try {
+	cloud.droplets.create(req, {wait_ready: true});
+	assert(false, "should throw without a way to wait");
+} catch (e) {
+	assert(String(e).indexOf("nothing knows how to wait for droplets to be ready") >= 0, "unexpected error: " + e);
+}
+
+equals(42, cloud.droplets.create(req, null).id, "should take null as no options");
+try {
+	cloud.droplets.create(req, true);
+	assert(false, "should throw for options that aren't an object");
+} catch (e) {
+	assert(String(e).indexOf("options must be an Object") >= 0, "unexpected error: " + e);
+}
+`)
+
+	var (
+		mu     sync.Mutex
+		waited []string
+	)
+	ctx := jsdroplets.WithReady(context.Background(), func(_ context.Context, d *godo.Droplet) error {
+		ip, _ := d.PublicIPv4()
+		mu.Lock()
+		waited = append(waited, ip)
+		mu.Unlock()
+		return nil
+	})
+	vmtest.RunContext(t, ctx, cloud, req+`
+var d = cloud.droplets.create(req, {wait_ready: true});
+equals("192.0.2.10", d.public_ipv4, "should have the droplet once active");
+
+var res = cloud.parallel([1, 2], function() {
+	return cloud.droplets.create(req, {wait_ready: true}).public_ipv4;
+});
+equals("192.0.2.10", res[0].value, "should wait for droplets in workers too");
+equals("192.0.2.10", res[1].value, "should wait for droplets in workers too");
+`)
+	if len(waited) != 3 || waited[0] != "192.0.2.10" {
+		t.Errorf("want to have waited for the droplets at their IP, got %v", waited)
+	}
+}
+
 func TestDropletCreateMultiple(t *testing.T) {
 	cloud := mockcloud.Client(nil)
 	cloud.MockDroplets.CreateMultipleFn = func(_ context.Context, names []string, region, size, image string, _ ...droplets.CreateMultipleOpt) ([]droplets.Droplet, error) {
@@ -327,3 +387,169 @@ var pkg = cloud.droplets;
 pkg.delete(42);
 `)
 }
+
+func TestDropletMethods(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	var gets int
+	cloud.MockDroplets.GetFn = func(_ context.Context, id int) (droplets.Droplet, error) {
+		gets++
+		if gets == 1 {
+			return &droplet{d}, nil
+		}
+		refreshed := *d
+		refreshed.Status = "active"
+		return &droplet{&refreshed}, nil
+	}
+	var rebooted []int
+	cloud.MockDroplets.MockDropletActions.RebootFn = func(_ context.Context, id int) error {
+		rebooted = append(rebooted, id)
+		return nil
+	}
+	var resized string
+	cloud.MockDroplets.MockDropletActions.ResizeFn = func(_ context.Context, id int, sizeSlug string, resizeDisk bool) error {
+		resized = sizeSlug
+		return nil
+	}
+
+	vmtest.Run(t, cloud, `
+var pkg = cloud.droplets;
+
+var d = pkg.get(42);
+assert(Object.keys(d).indexOf("reboot") < 0, "methods should not be enumerable");
+assert(JSON.parse(JSON.stringify(d)).reboot === undefined, "methods should not be serialized");
+
+d.reboot();
+d.resize("2gb", false);
+d.reboot_async().wait("1s");
+
+equals(d.status, "loling", "should have the old status");
+d.refresh();
+equals(d.status, "active", "should have refreshed in place");
+`)
+
+	if len(rebooted) != 2 || rebooted[0] != 42 || rebooted[1] != 42 {
+		t.Fatalf("want 2 reboots of %d, got %v", 42, rebooted)
+	}
+	if resized != "2gb" {
+		t.Fatalf("want resize to %q, got %q", "2gb", resized)
+	}
+}
+
+func TestDropletGetByName(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	cloud.MockDroplets.ListFn = func(_ context.Context, _ ...droplets.ListOpt) (<-chan droplets.Droplet, <-chan error) {
+		lc := make(chan droplets.Droplet, 3)
+		lc <- &droplet{&godo.Droplet{ID: 42, Name: "web-1", Region: region}}
+		lc <- &droplet{&godo.Droplet{ID: 43, Name: "db-1", Region: region}}
+		lc <- &droplet{&godo.Droplet{ID: 44, Name: "db-1", Region: region}}
+		close(lc)
+		ec := make(chan error)
+		close(ec)
+		return lc, ec
+	}
+	cloud.MockDroplets.GetFn = func(_ context.Context, id int) (droplets.Droplet, error) {
+		if id != 42 {
+			t.Fatalf("want %v got %v", 42, id)
+		}
+		return &droplet{d}, nil
+	}
+
+	ctx := godojs.WithResolver(context.Background(), doCloud.NewResolver(cloud))
+	vmtest.RunContext(t, ctx, cloud, `
+var pkg = cloud.droplets;
+
+var d = pkg.get("web-1");
+assert(d.id == 42, "should have found the droplet by name");
+
+try {
+	pkg.get("db-1"); throw "dont catch me";
+} catch (e) {
+	equals(e.message, "2 droplets are named \"db-1\", use an ID instead: 43 (nyc3), 44 (nyc3)", "should list the candidates");
+}
+
+try {
+	pkg.get("nope"); throw "dont catch me";
+} catch (e) {
+	equals(e.message, "no droplet named \"nope\"", "should say the name is unknown");
+}
+`)
+}
+
+func TestDropletNamesAfterChanges(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		gds     = []*godo.Droplet{{ID: 42, Name: "web-1"}}
+		lists   int
+		deleted []int
+	)
+	cloud := mockcloud.Client(nil)
+	cloud.MockDroplets.ListFn = func(_ context.Context, _ ...droplets.ListOpt) (<-chan droplets.Droplet, <-chan error) {
+		mu.Lock()
+		defer mu.Unlock()
+		lists++
+		lc := make(chan droplets.Droplet, len(gds))
+		for _, g := range gds {
+			lc <- &droplet{g}
+		}
+		close(lc)
+		ec := make(chan error)
+		close(ec)
+		return lc, ec
+	}
+	cloud.MockDroplets.GetFn = func(_ context.Context, id int) (droplets.Droplet, error) {
+		return &droplet{&godo.Droplet{ID: id, Name: "web-1"}}, nil
+	}
+	cloud.MockDroplets.DeleteFn = func(_ context.Context, id int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		deleted = append(deleted, id)
+		gds = nil
+		return nil
+	}
+	cloud.MockDroplets.CreateFn = func(_ context.Context, name, _, _, _ string, _ ...droplets.CreateOpt) (droplets.Droplet, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		g := &godo.Droplet{ID: 43, Name: name}
+		gds = append(gds, g)
+		return &droplet{g}, nil
+	}
+
+	resolver := doCloud.NewResolver(cloud, doCloud.ResolverTTL(time.Hour))
+	ctx := godojs.WithResolver(context.Background(), resolver)
+	vmtest.RunContext(t, ctx, cloud, `
+var pkg = cloud.droplets;
+
+assert(pkg.get("web-1").id == 42, "should have found the droplet by name");
+pkg.delete("web-1");
+pkg.create({name: "web-1", region: "nyc3", size: "512mb", image: {slug: "coreos-stable"}});
+assert(pkg.get("web-1").id == 43, "should have found the new droplet by name");
+`)
+	if len(deleted) != 1 || deleted[0] != 42 {
+		t.Fatalf("want droplet 42 deleted, got %v", deleted)
+	}
+	// once to get, again before deleting, and once more after creating
+	if want, got := 3, lists; want != got {
+		t.Fatalf("want %d listings got %d", want, got)
+	}
+}
+
+func TestDropletListByTag(t *testing.T) {
+	var gotOpts []droplets.ListOpt
+	cloud := mockcloud.Client(nil)
+	cloud.MockDroplets.ListFn = func(_ context.Context, opts ...droplets.ListOpt) (<-chan droplets.Droplet, <-chan error) {
+		gotOpts = opts
+		lc := make(chan droplets.Droplet, 1)
+		lc <- &droplet{d}
+		close(lc)
+		ec := make(chan error)
+		close(ec)
+		return lc, ec
+	}
+	vmtest.Run(t, cloud, `
+var list = cloud.droplets.list({tag: "test"});
+assert(list.length == 1, "should have received the tagged droplets")
+`)
+	if len(gotOpts) != 1 {
+		t.Fatalf("want a tag filter, got %d options", len(gotOpts))
+	}
+}
@@ -0,0 +1,137 @@
+package droplets
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/digitalocean/godo"
+	"github.com/robertkrimen/otto"
+)
+
+// dropletToVM converts a droplet and binds methods to it, so that scripts
+// can do `d.reboot()` instead of `cloud.droplets.actions.reboot(d)`.
+func (svc *dropletSvc) dropletToVM(vm *otto.Otto, g *godo.Droplet) otto.Value {
+	v := godojs.DropletToVM(vm, g)
+	if g == nil {
+		return v
+	}
+	id := g.ID
+	actions := svc.svc.Actions()
+
+	// do calls fn, after which the names of kinds are forgotten
+	do := func(fn func(context.Context, int) error, kinds ...string) func(otto.FunctionCall) eventloop.Task {
+		return func(all otto.FunctionCall) eventloop.Task {
+			return func() (eventloop.Result, error) {
+				if err := fn(svc.ctx, id); err != nil {
+					return nil, err
+				}
+				if len(kinds) > 0 {
+					godojs.Invalidate(svc.ctx, kinds...)
+				}
+				return nil, nil
+			}
+		}
+	}
+
+	ottoutil.SetMethods(vm, v, map[string]func(otto.FunctionCall) otto.Value{
+		"refresh": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			d, err := svc.svc.Get(svc.ctx, id)
+			if err != nil {
+				ottoutil.Throw(vm, err.Error())
+			}
+			return ottoutil.Assign(vm, all.This, godojs.DropletToVM(vm, d.Struct()))
+		},
+		"ssh": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			pkg, err := vm.Get("ssh")
+			if err != nil || !pkg.IsObject() {
+				ottoutil.Throw(vm, "the ssh package isn't loaded")
+			}
+			session := ottoutil.GetObject(vm, pkg, "session", true)
+			args := []interface{}{all.This}
+			if len(all.ArgumentList) > 0 {
+				args = append(args, all.Argument(0))
+			}
+			return ottoutil.Call(vm, session, pkg, args...)
+		},
+	})
+	return eventloop.Methods(vm, v, map[string]func(otto.FunctionCall) eventloop.Task{
+		"delete":                    do(svc.svc.Delete, cloud.DropletKind, cloud.FloatingIPKind),
+		"shutdown":                  do(actions.Shutdown),
+		"power_off":                 do(actions.PowerOff),
+		"power_on":                  do(actions.PowerOn),
+		"power_cycle":               do(actions.PowerCycle),
+		"reboot":                    do(actions.Reboot),
+		"enable_backups":            do(actions.EnableBackups),
+		"disable_backups":           do(actions.DisableBackups),
+		"password_reset":            do(actions.PasswordReset),
+		"enable_ipv6":               do(actions.EnableIPv6),
+		"enable_private_networking": do(actions.EnablePrivateNetworking),
+		"resize": func(all otto.FunctionCall) eventloop.Task {
+			vm := all.Otto
+			sizeSlug := godojs.ArgSizeSlug(vm, all.Argument(0))
+			resizeDisk := ottoutil.Bool(vm, all.Argument(1))
+			return func() (eventloop.Result, error) {
+				return nil, actions.Resize(svc.ctx, id, sizeSlug, resizeDisk)
+			}
+		},
+		"rename": func(all otto.FunctionCall) eventloop.Task {
+			name := ottoutil.String(all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				if err := actions.Rename(svc.ctx, id, name); err != nil {
+					return nil, err
+				}
+				godojs.Invalidate(svc.ctx, cloud.DropletKind, cloud.FloatingIPKind)
+				return nil, nil
+			}
+		},
+		"snapshot": func(all otto.FunctionCall) eventloop.Task {
+			name := ottoutil.String(all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				if err := actions.Snapshot(svc.ctx, id, name); err != nil {
+					return nil, err
+				}
+				godojs.Invalidate(svc.ctx, cloud.ImageKind, cloud.SnapshotKind)
+				return nil, nil
+			}
+		},
+		"attach": func(all otto.FunctionCall) eventloop.Task {
+			volumeID := godojs.ArgVolumeID(svc.ctx, all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				return nil, svc.cloud.Volumes().Actions().Attach(svc.ctx, volumeID, id)
+			}
+		},
+		"detach": func(all otto.FunctionCall) eventloop.Task {
+			volumeID := godojs.ArgVolumeID(svc.ctx, all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				return nil, svc.cloud.Volumes().Actions().DetachByDropletID(svc.ctx, volumeID, id)
+			}
+		},
+		"tag": func(all otto.FunctionCall) eventloop.Task {
+			name := ottoutil.String(all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				res := []godo.Resource{{ID: strconv.Itoa(id), Type: godo.DropletResourceType}}
+				tags := svc.cloud.Tags()
+				if _, err := tags.Get(svc.ctx, name); err != nil {
+					// the tag must exist before resources can be tagged with it
+					if _, err := tags.Create(svc.ctx, name); err != nil {
+						return nil, err
+					}
+				}
+				return nil, tags.TagResources(svc.ctx, name, res)
+			}
+		},
+		"untag": func(all otto.FunctionCall) eventloop.Task {
+			name := ottoutil.String(all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				res := []godo.Resource{{ID: strconv.Itoa(id), Type: godo.DropletResourceType}}
+				return nil, svc.cloud.Tags().UntagResources(svc.ctx, name, res)
+			}
+		},
+	})
+}
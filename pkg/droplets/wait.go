@@ -0,0 +1,59 @@
+package droplets
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/droplets"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/godoutil"
+	"github.com/digitalocean/godo"
+	"github.com/robertkrimen/otto"
+)
+
+// ReadyFunc waits for a droplet to be provisioned, once it's active.
+type ReadyFunc func(ctx context.Context, d *godo.Droplet) error
+
+type readyKey struct{}
+
+// WithReady returns a copy of ctx with which `create(..., {wait_ready: true})`
+// waits for droplets with fn, like the ssh package does, when ctx is given
+// to Apply.
+func WithReady(ctx context.Context, fn ReadyFunc) context.Context {
+	return context.WithValue(ctx, readyKey{}, fn)
+}
+
+func waitReady(ctx context.Context, d *godo.Droplet) error {
+	fn, ok := ctx.Value(readyKey{}).(ReadyFunc)
+	if !ok || fn == nil {
+		return fmt.Errorf("nothing knows how to wait for droplets to be ready, like the ssh package")
+	}
+	return fn(ctx, d)
+}
+
+// waitPublicIPv4 waits for a droplet to get a public IPv4, as in
+// `cloud.droplets.wait_public_ipv4(d, {timeout: "5m"})`.
+func (svc *dropletSvc) waitPublicIPv4(all otto.FunctionCall) eventloop.Task {
+	vm := all.Otto
+	id := godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))
+	opts := godojs.ArgWaitOptions(vm, all.Argument(1))
+
+	return func() (eventloop.Result, error) {
+		var d droplets.Droplet
+		err := godoutil.WaitFor(svc.ctx, func() (bool, error) {
+			var err error
+			if d, err = svc.svc.Get(svc.ctx, id); err != nil {
+				return false, err
+			}
+			ip, err := d.Struct().PublicIPv4()
+			return ip != "", err
+		}, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("waiting for droplet %d to have a public IPv4: %v", id, err)
+		}
+		return func(vm *otto.Otto) otto.Value {
+			return svc.dropletToVM(vm, d.Struct())
+		}, nil
+	}
+}
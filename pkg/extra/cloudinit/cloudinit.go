@@ -0,0 +1,295 @@
+// Package cloudinit builds the user data that cloud-init provisions droplets
+// with, as `#cloud-config` documents and multipart MIME archives, and checks
+// it before droplets are created with it.
+package cloudinit
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/aybabtme/godotto/pkg/extra/format"
+)
+
+// Header starts every cloud-config document.
+const Header = "#cloud-config"
+
+// MaxUserDataSize is the most user data droplets can be created with.
+const MaxUserDataSize = 64 << 10
+
+// Sudo lets a user run anything as root without a password.
+const Sudo = "ALL=(ALL) NOPASSWD:ALL"
+
+// VolumeDevice is where a block storage volume shows up on the droplets it's
+// attached to.
+func VolumeDevice(name string) string {
+	return "/dev/disk/by-id/scsi-0DO_Volume_" + name
+}
+
+// Config is a cloud-config document. Keys that aren't modeled can be set in
+// Extra.
+type Config struct {
+	Users             []User       `json:"users,omitempty"`
+	SSHAuthorizedKeys []string     `json:"ssh_authorized_keys,omitempty"`
+	PackageUpdate     bool         `json:"package_update,omitempty"`
+	PackageUpgrade    bool         `json:"package_upgrade,omitempty"`
+	Packages          []string     `json:"packages,omitempty"`
+	WriteFiles        []File       `json:"write_files,omitempty"`
+	FSSetup           []Filesystem `json:"fs_setup,omitempty"`
+	Mounts            [][]string   `json:"mounts,omitempty"`
+	RunCmd            []Command    `json:"runcmd,omitempty"`
+
+	Extra map[string]interface{} `json:"-"`
+}
+
+// User to create. The default user of the image is kept when users are
+// given, like cloud-init does with `default` as the first user.
+type User struct {
+	Name              string   `json:"name"`
+	Groups            []string `json:"-"`
+	Shell             string   `json:"shell,omitempty"`
+	Sudo              string   `json:"sudo,omitempty"`
+	SSHAuthorizedKeys []string `json:"ssh_authorized_keys,omitempty"`
+	LockPasswd        *bool    `json:"lock_passwd,omitempty"`
+}
+
+// MarshalJSON writes the groups of a user as cloud-init reads them.
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User
+	return json.Marshal(struct {
+		user
+		Groups string `json:"groups,omitempty"`
+	}{user(u), strings.Join(u.Groups, ",")})
+}
+
+// File to write.
+type File struct {
+	Path        string `json:"path"`
+	Content     string `json:"content"`
+	Owner       string `json:"owner,omitempty"`       // like "root:root"
+	Permissions string `json:"permissions,omitempty"` // in octal, like "0644"
+	Encoding    string `json:"encoding,omitempty"`    // of the content, like "b64"
+	Append      bool   `json:"append,omitempty"`
+}
+
+// Filesystem to make on a device, unless it already has one.
+type Filesystem struct {
+	Device     string `json:"device"`
+	Filesystem string `json:"filesystem"`
+	Label      string `json:"label,omitempty"`
+	Partition  string `json:"partition,omitempty"`
+}
+
+// Mount of a device.
+type Mount struct {
+	Device     string
+	Path       string
+	Filesystem string // ext4 when empty
+	Options    string // DefaultMountOptions when empty
+	// Format the device when it has no filesystem, as with new volumes.
+	Format bool
+}
+
+// DefaultMountOptions let droplets boot when a volume is detached.
+const DefaultMountOptions = "defaults,nofail,discard,noatime"
+
+// AddMount mounts a device on boot, formatting it first if asked to.
+func (c *Config) AddMount(m Mount) {
+	if m.Filesystem == "" {
+		m.Filesystem = "ext4"
+	}
+	if m.Options == "" {
+		m.Options = DefaultMountOptions
+	}
+	if m.Format {
+		c.FSSetup = append(c.FSSetup, Filesystem{
+			Device:     m.Device,
+			Filesystem: m.Filesystem,
+			Partition:  "none", // volumes are formatted whole
+		})
+	}
+	c.Mounts = append(c.Mounts, []string{m.Device, m.Path, m.Filesystem, m.Options, "0", "2"})
+}
+
+// Command to run once, either through a shell or as a list of arguments.
+type Command struct {
+	Shell string
+	Args  []string
+}
+
+// MarshalJSON writes a command as cloud-init reads it.
+func (c Command) MarshalJSON() ([]byte, error) {
+	if c.Args != nil {
+		return json.Marshal(c.Args)
+	}
+	return json.Marshal(c.Shell)
+}
+
+// Marshal writes a valid config as user data.
+func (c *Config) Marshal() (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+	v, err := c.generic()
+	if err != nil {
+		return "", err
+	}
+	data, err := format.MarshalYAML(v)
+	if err != nil {
+		return "", err
+	}
+	userData := Header + "\n" + string(data)
+	if len(userData) > MaxUserDataSize {
+		return "", fmt.Errorf("user data is %d bytes, more than the %d allowed", len(userData), MaxUserDataSize)
+	}
+	return userData, nil
+}
+
+// generic merges the extra keys with the modeled ones, keeping the default
+// user.
+func (c *Config) generic() (map[string]interface{}, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	v := make(map[string]interface{})
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	if users, ok := v["users"].([]interface{}); ok {
+		v["users"] = append([]interface{}{"default"}, users...)
+	}
+	for k, ev := range c.Extra {
+		v[k] = ev
+	}
+	return v, nil
+}
+
+var (
+	userName    = regexp.MustCompile(`^[a-z_][a-z0-9_-]*\$?$`)
+	permissions = regexp.MustCompile(`^0?[0-7]{3,4}$`)
+)
+
+// encodings of the content of files that cloud-init understands
+var encodings = map[string]bool{
+	"": true, "b64": true, "base64": true,
+	"gz": true, "gzip": true,
+	"gz+b64": true, "gz+base64": true, "gzip+b64": true, "gzip+base64": true,
+}
+
+// Validate tells everything that's wrong with a config, at once.
+func (c *Config) Validate() error {
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	checkKeys := func(owner string, keys []string) {
+		for i, key := range keys {
+			if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key)); err != nil {
+				fail("ssh key %d of %s isn't a public key", i, owner)
+			}
+		}
+	}
+
+	checkKeys("the default user", c.SSHAuthorizedKeys)
+	users := make(map[string]bool)
+	for _, u := range c.Users {
+		switch {
+		case u.Name == "":
+			fail("users need a name")
+			continue
+		case len(u.Name) > 32 || !userName.MatchString(u.Name):
+			fail("invalid user name %q", u.Name)
+		case users[u.Name]:
+			fail("user %q is given more than once", u.Name)
+		}
+		users[u.Name] = true
+		checkKeys(fmt.Sprintf("user %q", u.Name), u.SSHAuthorizedKeys)
+	}
+
+	for _, p := range c.Packages {
+		if p == "" || strings.ContainsAny(p, " \t\n") {
+			fail("invalid package name %q", p)
+		}
+	}
+
+	files := make(map[string]bool)
+	for _, f := range c.WriteFiles {
+		if !path.IsAbs(f.Path) {
+			fail("file path %q isn't absolute", f.Path)
+			continue
+		}
+		if files[f.Path] && !f.Append {
+			fail("file %q is written more than once", f.Path)
+		}
+		files[f.Path] = true
+		if f.Permissions != "" && !permissions.MatchString(f.Permissions) {
+			fail("permissions of file %q aren't in octal, like \"0644\": %q", f.Path, f.Permissions)
+		}
+		if !encodings[f.Encoding] {
+			fail("unknown encoding of file %q: %q", f.Path, f.Encoding)
+		} else if strings.HasSuffix(f.Encoding, "64") {
+			if _, err := base64.StdEncoding.DecodeString(f.Content); err != nil {
+				fail("content of file %q isn't base64: %v", f.Path, err)
+			}
+		}
+	}
+
+	for _, fs := range c.FSSetup {
+		if !path.IsAbs(fs.Device) {
+			fail("device %q isn't absolute", fs.Device)
+		}
+		if fs.Filesystem != "ext4" && fs.Filesystem != "xfs" {
+			fail("can't format %q as %q, only as ext4 or xfs", fs.Device, fs.Filesystem)
+		}
+	}
+	mounts := make(map[string]bool)
+	for _, m := range c.Mounts {
+		if len(m) < 2 {
+			fail("mounts need a device and a path")
+			continue
+		}
+		if !path.IsAbs(m[1]) {
+			fail("mount path %q isn't absolute", m[1])
+		}
+		if mounts[m[1]] {
+			fail("more than one device is mounted on %q", m[1])
+		}
+		mounts[m[1]] = true
+	}
+
+	for i, cmd := range c.RunCmd {
+		if cmd.Args == nil && strings.TrimSpace(cmd.Shell) == "" || cmd.Args != nil && len(cmd.Args) == 0 {
+			fail("command %d is empty", i)
+		}
+	}
+
+	extras := make([]string, 0, len(c.Extra))
+	for k := range c.Extra {
+		extras = append(extras, k)
+	}
+	sort.Strings(extras)
+	for _, k := range extras {
+		if modeledKeys[k] {
+			fail("%q can't be given as an extra key", k)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid cloud-config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// keys of the config that are set through its fields
+var modeledKeys = map[string]bool{
+	"users": true, "ssh_authorized_keys": true,
+	"package_update": true, "package_upgrade": true, "packages": true,
+	"write_files": true, "fs_setup": true, "mounts": true, "runcmd": true,
+}
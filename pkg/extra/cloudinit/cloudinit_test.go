@@ -0,0 +1,236 @@
+package cloudinit
+
+import (
+	"strings"
+	"testing"
+)
+
+const pubKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl me@host"
+
+func TestMarshal(t *testing.T) {
+	cfg := &Config{
+		Users: []User{
+			{Name: "deploy", Groups: []string{"docker", "adm"}, Sudo: Sudo, SSHAuthorizedKeys: []string{pubKey}},
+		},
+		PackageUpdate: true,
+		Packages:      []string{"nginx"},
+		WriteFiles:    []File{{Path: "/etc/motd", Content: "hello\nworld\n", Permissions: "0644"}},
+		RunCmd: []Command{
+			{Shell: "systemctl restart nginx"},
+			{Args: []string{"touch", "/tmp/done"}},
+		},
+		Extra: map[string]interface{}{"timezone": "UTC"},
+	}
+	cfg.AddMount(Mount{Device: VolumeDevice("data"), Path: "/data", Format: true})
+
+	got, err := cfg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `#cloud-config
+fs_setup:
+  - device: /dev/disk/by-id/scsi-0DO_Volume_data
+    filesystem: ext4
+    partition: none
+mounts:
+  - - /dev/disk/by-id/scsi-0DO_Volume_data
+    - /data
+    - ext4
+    - defaults,nofail,discard,noatime
+    - "0"
+    - "2"
+package_update: true
+packages:
+  - nginx
+runcmd:
+  - systemctl restart nginx
+  - - touch
+    - /tmp/done
+timezone: UTC
+users:
+  - default
+  - groups: docker,adm
+    name: deploy
+    ssh_authorized_keys:
+      - ` + pubKey + `
+    sudo: ALL=(ALL) NOPASSWD:ALL
+write_files:
+  - content: |
+      hello
+      world
+    path: /etc/motd
+    permissions: "0644"
+`
+	if got != want {
+		t.Errorf("want\n%s\ngot\n%s", want, got)
+	}
+	if err := ValidateUserData(got); err != nil {
+		t.Errorf("should be valid user data: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{
+			name: "users",
+			cfg: Config{Users: []User{
+				{Name: "Deploy"},
+				{Name: "ops", SSHAuthorizedKeys: []string{"not a key"}},
+				{Name: "ops"},
+				{},
+			}},
+			want: []string{
+				`invalid user name "Deploy"`,
+				`ssh key 0 of user "ops" isn't a public key`,
+				`user "ops" is given more than once`,
+				`users need a name`,
+			},
+		},
+		{
+			name: "files",
+			cfg: Config{WriteFiles: []File{
+				{Path: "etc/motd"},
+				{Path: "/etc/motd", Permissions: "rw-r--r--"},
+				{Path: "/etc/motd", Append: true, Encoding: "b64", Content: "%%"},
+				{Path: "/etc/issue", Encoding: "rot13"},
+				{Path: "/etc/issue"},
+			}},
+			want: []string{
+				`file path "etc/motd" isn't absolute`,
+				`permissions of file "/etc/motd" aren't in octal`,
+				`content of file "/etc/motd" isn't base64`,
+				`unknown encoding of file "/etc/issue": "rot13"`,
+				`file "/etc/issue" is written more than once`,
+			},
+		},
+		{
+			name: "mounts",
+			cfg: func() Config {
+				var cfg Config
+				cfg.AddMount(Mount{Device: VolumeDevice("a"), Path: "/data", Filesystem: "ntfs", Format: true})
+				cfg.AddMount(Mount{Device: VolumeDevice("b"), Path: "/data"})
+				cfg.AddMount(Mount{Device: VolumeDevice("c"), Path: "data"})
+				return cfg
+			}(),
+			want: []string{
+				`can't format "/dev/disk/by-id/scsi-0DO_Volume_a" as "ntfs", only as ext4 or xfs`,
+				`more than one device is mounted on "/data"`,
+				`mount path "data" isn't absolute`,
+			},
+		},
+		{
+			name: "others",
+			cfg: Config{
+				SSHAuthorizedKeys: []string{pubKey, "ssh-rsa nope"},
+				Packages:          []string{"nginx", "git curl"},
+				RunCmd:            []Command{{Shell: " "}, {Args: []string{}}},
+				Extra:             map[string]interface{}{"runcmd": []string{"ls"}, "timezone": "UTC"},
+			},
+			want: []string{
+				`ssh key 1 of the default user isn't a public key`,
+				`invalid package name "git curl"`,
+				`command 0 is empty`,
+				`command 1 is empty`,
+				`"runcmd" can't be given as an extra key`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if err == nil {
+				t.Fatal("should be invalid")
+			}
+			problems := strings.Split(strings.TrimPrefix(err.Error(), "invalid cloud-config: "), "; ")
+			if len(problems) != len(tt.want) {
+				t.Fatalf("want %d problems, got %d: %v", len(tt.want), len(problems), err)
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(problems[i], want) {
+					t.Errorf("problem %d: want %q, got %q", i, want, problems[i])
+				}
+			}
+		})
+	}
+
+	if err := (&Config{}).Validate(); err != nil {
+		t.Errorf("empty configs should be valid: %v", err)
+	}
+}
+
+func TestMultipart(t *testing.T) {
+	userData, err := Multipart("#!/bin/sh\necho hi\n", "#cloud-config\npackages:\n  - nginx\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Content-Type: multipart/mixed; boundary=",
+		"Content-Type: text/x-shellscript; charset=\"utf-8\"\r\n",
+		"\r\n\r\n#!/bin/sh\necho hi\n\r\n--",
+		"Content-Type: text/cloud-config; charset=\"utf-8\"\r\n",
+		"\r\n\r\n#cloud-config\npackages:\n  - nginx\n\r\n--",
+	} {
+		if !strings.Contains(userData, want) {
+			t.Errorf("should contain %q, got %q", want, userData)
+		}
+	}
+
+	if _, err := Multipart(`{"ignition": {}}`); err == nil || !strings.Contains(err.Error(), "part 0: unknown kind of user data") {
+		t.Errorf("unknown parts should fail, got %v", err)
+	}
+	if _, err := Multipart("#cloud-config\n\tpackages: []\n"); err == nil || !strings.Contains(err.Error(), "part 0: line 2 of the cloud-config is indented with a tab") {
+		t.Errorf("invalid parts should fail, got %v", err)
+	}
+}
+
+func TestValidateUserData(t *testing.T) {
+	mime := func(parts string) string {
+		return "Content-Type: multipart/mixed; boundary=\"XX\"\nMIME-Version: 1.0\n\n" + parts + "--XX--\n"
+	}
+	tests := []struct {
+		name     string
+		userData string
+		want     string
+	}{
+		{name: "empty"},
+		{name: "cloud-config", userData: "#cloud-config\nruncmd:\n  - ls\n"},
+		{name: "script", userData: "#!/bin/bash\nls\n"},
+		{name: "other kinds", userData: `{"ignition": {"version": "2.2.0"}}`},
+		{name: "tabs", userData: "#cloud-config\nruncmd:\n\t- ls\n", want: "line 3 of the cloud-config is indented with a tab"},
+		{name: "no interpreter", userData: "#! \nls\n", want: `scripts must start with an interpreter, like "#!/bin/sh"`},
+		{name: "too big", userData: "#!/bin/sh\n" + strings.Repeat("#", MaxUserDataSize), want: "user data is 65546 bytes, more than the 65536 allowed"},
+		{
+			name: "multipart",
+			userData: mime("--XX\nContent-Type: text/x-shellscript\n\n#!/bin/sh\nls\n" +
+				"--XX\nContent-Type: text/cloud-config\nContent-Transfer-Encoding: base64\n\nI2Nsb3VkLWNvbmZpZwpwYWNrYWdlczogW10K\n"),
+		},
+		{
+			name:     "invalid part",
+			userData: mime("--XX\nContent-Type: text/cloud-config\n\n#cloud-config\n\truncmd: []\n"),
+			want:     "part 0: line 2 of the cloud-config is indented with a tab",
+		},
+		{
+			name:     "parts without types",
+			userData: mime("--XX\n\n#cloud-config\nruncmd: []\n--XX\n\necho hi\n"),
+		},
+		{name: "no parts", userData: mime(""), want: "invalid MIME user data: multipart: NextPart: EOF"},
+		{name: "not multipart", userData: "Content-Type: text/plain\n\nhi\n", want: `MIME user data must be multipart, not "text/plain"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUserData(tt.userData)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("should be valid: %v", err)
+			case tt.want != "" && err == nil:
+				t.Errorf("should be invalid")
+			case tt.want != "" && err.Error() != tt.want:
+				t.Errorf("want %q, got %q", tt.want, err)
+			}
+		})
+	}
+}
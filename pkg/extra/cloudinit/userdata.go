@@ -0,0 +1,157 @@
+package cloudinit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// kinds of user data cloud-init tells apart by their first line, and the
+// types of the MIME parts that hold them
+var partTypes = []struct {
+	prefix string
+	ctype  string
+}{
+	{"#cloud-config-archive", "text/cloud-config-archive"},
+	{Header, "text/cloud-config"},
+	{"#cloud-boothook", "text/cloud-boothook"},
+	{"#include", "text/x-include-url"},
+	{"#part-handler", "text/part-handler"},
+	{"#upstart-job", "text/upstart-job"},
+	{"#!", "text/x-shellscript"},
+}
+
+func partType(content string) (string, bool) {
+	for _, pt := range partTypes {
+		if strings.HasPrefix(content, pt.prefix) {
+			return pt.ctype, true
+		}
+	}
+	return "", false
+}
+
+// Multipart combines many kinds of user data, like scripts and
+// cloud-configs, into a MIME archive. Parts are handled in order.
+func Multipart(parts ...string) (string, error) {
+	if len(parts) == 0 {
+		return "", fmt.Errorf("multipart user data needs parts")
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for i, part := range parts {
+		ctype, ok := partType(part)
+		if !ok {
+			return "", fmt.Errorf("part %d: unknown kind of user data, starting with %q", i, firstLine(part))
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Type", ctype+`; charset="utf-8"`)
+		h.Set("MIME-Version", "1.0")
+		h.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="part-%03d"`, i+1))
+		pw, err := w.CreatePart(h)
+		if err != nil {
+			return "", err
+		}
+		if _, err := io.WriteString(pw, part); err != nil {
+			return "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	userData := fmt.Sprintf("Content-Type: multipart/mixed; boundary=%q\r\nMIME-Version: 1.0\r\n\r\n", w.Boundary()) + body.String()
+	if err := ValidateUserData(userData); err != nil {
+		return "", err
+	}
+	return userData, nil
+}
+
+// ValidateUserData checks user data before droplets are created with it.
+// Cloud-configs, scripts and multipart archives of them are checked, other
+// kinds of user data are left to cloud-init.
+func ValidateUserData(userData string) error {
+	if len(userData) > MaxUserDataSize {
+		return fmt.Errorf("user data is %d bytes, more than the %d allowed", len(userData), MaxUserDataSize)
+	}
+	if isMIME(userData) {
+		return validateMultipart(userData)
+	}
+	ctype, _ := partType(userData)
+	return validatePart(ctype, userData)
+}
+
+func isMIME(userData string) bool {
+	line := strings.ToLower(firstLine(userData))
+	return strings.HasPrefix(line, "content-type:") || strings.HasPrefix(line, "mime-version:")
+}
+
+func validateMultipart(userData string) error {
+	msg, err := mail.ReadMessage(strings.NewReader(userData))
+	if err != nil {
+		return fmt.Errorf("invalid MIME user data: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("invalid MIME user data: %v", err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return fmt.Errorf("MIME user data must be multipart, not %q", mediaType)
+	}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for n := 0; ; n++ {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid MIME user data: %v", err)
+		}
+		var content io.Reader = part
+		if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+			content = base64.NewDecoder(base64.StdEncoding, part)
+		}
+		data, err := ioutil.ReadAll(content)
+		if err != nil {
+			return fmt.Errorf("part %d: %v", n, err)
+		}
+		ctype, _, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if err != nil || ctype == "text/plain" {
+			// cloud-init tells what the part is by its first line
+			ctype, _ = partType(string(data))
+		}
+		if err := validatePart(ctype, string(data)); err != nil {
+			return fmt.Errorf("part %d: %v", n, err)
+		}
+	}
+}
+
+func validatePart(ctype, content string) error {
+	switch ctype {
+	case "text/cloud-config":
+		// YAML can't be indented with tabs, which is the usual mistake of
+		// documents written by hand
+		for i, line := range strings.Split(content, "\n") {
+			if strings.HasPrefix(line, "\t") {
+				return fmt.Errorf("line %d of the cloud-config is indented with a tab", i+1)
+			}
+		}
+	case "text/x-shellscript":
+		if !strings.HasPrefix(content, "#!") || strings.TrimSpace(firstLine(content)[2:]) == "" {
+			return fmt.Errorf("scripts must start with an interpreter, like \"#!/bin/sh\"")
+		}
+	}
+	return nil
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSuffix(s, "\r")
+}
@@ -10,7 +10,8 @@ import (
 // A Client can interact with the DigitalOcean Actions service.
 type Client interface {
 	Get(context.Context, int) (Action, error)
-	List(context.Context) (<-chan Action, <-chan error)
+	List(context.Context, ...ListOpt) (<-chan Action, <-chan error)
+	Watch(context.Context, ...WatchOpt) <-chan Event
 }
 
 // A Action in the DigitalOcean cloud.
@@ -38,24 +39,51 @@ func (svc *client) Get(ctx context.Context, id int) (Action, error) {
 	return &action{g: svc.g, d: d}, nil
 }
 
-func (svc *client) List(ctx context.Context) (<-chan Action, <-chan error) {
+// ListOpt is an optional argument to actions.List.
+type ListOpt func(*listOpt)
+
+// FilterResourceType only lists the actions on resources of the given type,
+// like "droplet" or "volume". The API can't filter on this, so actions
+// are filtered as they are received.
+func FilterResourceType(resourceType string) ListOpt {
+	return func(opt *listOpt) { opt.resourceType = resourceType }
+}
+
+type listOpt struct {
+	resourceType string
+}
+
+func (opt *listOpt) match(d *godo.Action) bool {
+	return opt.resourceType == "" || opt.resourceType == d.ResourceType
+}
+
+func (svc *client) List(ctx context.Context, opts ...ListOpt) (<-chan Action, <-chan error) {
+	opt := &listOpt{}
+	for _, fn := range opts {
+		fn(opt)
+	}
+
 	outc := make(chan Action, 1)
 	errc := make(chan error, 1)
 
 	go func() {
 		defer close(outc)
 		defer close(errc)
-		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (*godo.Response, error) {
-			r, resp, err := svc.g.Actions.List(ctx, opt)
-			for _, d := range r {
-				dd := d // copy ranged over variable
-				select {
-				case outc <- &action{g: svc.g, d: &dd}:
-				case <-ctx.Done():
-					return resp, err
+		err := godoutil.IterateList(ctx, func(ctx context.Context, lopt *godo.ListOptions) (func(), *godo.Response, error) {
+			r, resp, err := svc.g.Actions.List(ctx, lopt)
+			return func() {
+				for _, d := range r {
+					if !opt.match(&d) {
+						continue
+					}
+					dd := d // copy ranged over variable
+					select {
+					case outc <- &action{g: svc.g, d: &dd}:
+					case <-ctx.Done():
+						return
+					}
 				}
-			}
-			return resp, err
+			}, resp, err
 		})
 		if err != nil {
 			errc <- err
@@ -0,0 +1,172 @@
+package actions
+
+import (
+	"context"
+	"time"
+
+	"github.com/digitalocean/godo"
+)
+
+// EventType tells what happened to an action.
+type EventType string
+
+// The events emitted when watching actions.
+const (
+	EventStarted   EventType = "started"
+	EventCompleted EventType = "completed"
+	EventErrored   EventType = "errored"
+)
+
+// An Event tells that an action started, completed or errored. When polling
+// for actions fails, Err is set and the watch keeps going.
+type Event struct {
+	Type   EventType
+	Action Action
+	Err    error
+}
+
+// WatchOpt is an optional argument to actions.Watch.
+type WatchOpt func(*watchOpt)
+
+// WatchResourceType only watches the actions on resources of the given
+// type, like "droplet" or "volume".
+func WatchResourceType(resourceType string) WatchOpt {
+	return func(opt *watchOpt) { opt.resourceType = resourceType }
+}
+
+// WatchInterval sets how often actions are polled.
+func WatchInterval(d time.Duration) WatchOpt {
+	return func(opt *watchOpt) {
+		if d > 0 {
+			opt.interval = d
+		}
+	}
+}
+
+type watchOpt struct {
+	listOpt
+	interval time.Duration
+}
+
+// Watch polls the most recent actions of the account, and emits an event
+// whenever an action starts, completes or errors. Actions that were already
+// over when the watch began aren't emitted. The channel is closed once ctx
+// is done.
+func (svc *client) Watch(ctx context.Context, opts ...WatchOpt) <-chan Event {
+	opt := &watchOpt{interval: 5 * time.Second}
+	for _, fn := range opts {
+		fn(opt)
+	}
+
+	eventc := make(chan Event)
+	go func() {
+		defer close(eventc)
+		w := &watcher{svc: svc, opt: opt, eventc: eventc}
+		for first := true; ; first = false {
+			if err := w.poll(ctx, first); err != nil && ctx.Err() == nil {
+				if !w.emit(ctx, Event{Err: err}) {
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(opt.interval):
+			}
+		}
+	}()
+	return eventc
+}
+
+type watcher struct {
+	svc    *client
+	opt    *watchOpt
+	eventc chan<- Event
+	// the last known status of the recent actions
+	status map[int]string
+}
+
+func (w *watcher) emit(ctx context.Context, ev Event) bool {
+	select {
+	case w.eventc <- ev:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// poll compares the most recent actions with what was seen before. The
+// first poll only takes note of them.
+func (w *watcher) poll(ctx context.Context, first bool) error {
+	recent, _, err := w.svc.g.Actions.List(ctx, &godo.ListOptions{Page: 1, PerPage: 200})
+	if err != nil {
+		return err
+	}
+	prev := w.status
+	w.status = make(map[int]string, len(recent))
+
+	// oldest first, such that events are emitted in order
+	for i := len(recent) - 1; i >= 0; i-- {
+		d := recent[i]
+		if !w.opt.match(&d) {
+			continue
+		}
+		last, known := prev[d.ID]
+		w.status[d.ID] = d.Status
+		if first {
+			continue
+		}
+		if !w.update(ctx, &d, last, known) {
+			return nil
+		}
+	}
+
+	// actions in progress that aren't recent anymore are fetched directly
+	var firstErr error
+	for id, last := range prev {
+		if _, ok := w.status[id]; ok || last != "in-progress" {
+			continue
+		}
+		d, _, err := w.svc.g.Actions.Get(ctx, id)
+		if err != nil {
+			// tried again on the next poll
+			w.status[id] = last
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if d.Status == "in-progress" {
+			w.status[id] = d.Status
+		}
+		if !w.update(ctx, d, last, true) {
+			return nil
+		}
+	}
+	return firstErr
+}
+
+// update emits the events of an action given its last known status.
+func (w *watcher) update(ctx context.Context, d *godo.Action, last string, known bool) bool {
+	if known && last == d.Status {
+		return true
+	}
+	var events []EventType
+	if !known {
+		events = append(events, EventStarted)
+	}
+	switch d.Status {
+	case "in-progress":
+	case "errored":
+		events = append(events, EventErrored)
+	default:
+		events = append(events, EventCompleted)
+	}
+	for _, typ := range events {
+		dd := *d
+		if !w.emit(ctx, Event{Type: typ, Action: &action{g: w.svc.g, d: &dd}}) {
+			return false
+		}
+	}
+	return true
+}
@@ -2,6 +2,7 @@ package domains
 
 import (
 	"context"
+	"strings"
 
 	"github.com/aybabtme/godotto/pkg/extra/godoutil"
 	"github.com/digitalocean/godo"
@@ -18,7 +19,7 @@ type Client interface {
 	GetRecord(context.Context, string, int) (Record, error)
 	UpdateRecord(context.Context, string, int, ...RecordOpt) (Record, error)
 	DeleteRecord(context.Context, string, int) error
-	ListRecord(ctx context.Context, name string) (<-chan Record, <-chan error)
+	ListRecord(ctx context.Context, name string, opts ...RecordListOpt) (<-chan Record, <-chan error)
 }
 
 // A Domain in the DigitalOcean cloud.
@@ -91,17 +92,18 @@ func (svc *client) List(ctx context.Context) (<-chan Domain, <-chan error) {
 	go func() {
 		defer close(outc)
 		defer close(errc)
-		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (*godo.Response, error) {
+		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (func(), *godo.Response, error) {
 			r, resp, err := svc.g.Domains.List(ctx, opt)
-			for _, d := range r {
-				dd := d // copy ranged over variable
-				select {
-				case outc <- &domain{g: svc.g, d: &dd}:
-				case <-ctx.Done():
-					return resp, err
+			return func() {
+				for _, d := range r {
+					dd := d // copy ranged over variable
+					select {
+					case outc <- &domain{g: svc.g, d: &dd}:
+					case <-ctx.Done():
+						return
+					}
 				}
-			}
-			return resp, err
+			}, resp, err
 		})
 		if err != nil {
 			errc <- err
@@ -172,24 +174,57 @@ func (svc *client) DeleteRecord(ctx context.Context, name string, id int) error
 	return err
 }
 
-func (svc *client) ListRecord(ctx context.Context, name string) (<-chan Record, <-chan error) {
+// RecordListOpt is an optional argument to domains.ListRecord. The API
+// can't filter records, so they are filtered as they are received.
+type RecordListOpt func(*recordListOpt)
+
+// FilterRecordType only lists the records of the given type, like "A".
+func FilterRecordType(typ string) RecordListOpt {
+	return func(opt *recordListOpt) { opt.typ = typ }
+}
+
+// FilterRecordName only lists the records with the given name, like "www".
+func FilterRecordName(name string) RecordListOpt {
+	return func(opt *recordListOpt) { opt.name = name }
+}
+
+type recordListOpt struct {
+	typ  string
+	name string
+}
+
+func (opt *recordListOpt) match(d *godo.DomainRecord) bool {
+	return (opt.typ == "" || strings.EqualFold(opt.typ, d.Type)) &&
+		(opt.name == "" || opt.name == d.Name)
+}
+
+func (svc *client) ListRecord(ctx context.Context, name string, opts ...RecordListOpt) (<-chan Record, <-chan error) {
+	opt := &recordListOpt{}
+	for _, fn := range opts {
+		fn(opt)
+	}
+
 	outc := make(chan Record, 1)
 	errc := make(chan error, 1)
 
 	go func() {
 		defer close(outc)
 		defer close(errc)
-		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (*godo.Response, error) {
-			r, resp, err := svc.g.Domains.Records(ctx, name, opt)
-			for _, d := range r {
-				dd := d // copy ranged over variable
-				select {
-				case outc <- &record{g: svc.g, d: &dd}:
-				case <-ctx.Done():
-					return resp, err
+		err := godoutil.IterateList(ctx, func(ctx context.Context, lopt *godo.ListOptions) (func(), *godo.Response, error) {
+			r, resp, err := svc.g.Domains.Records(ctx, name, lopt)
+			return func() {
+				for _, d := range r {
+					if !opt.match(&d) {
+						continue
+					}
+					dd := d // copy ranged over variable
+					select {
+					case outc <- &record{g: svc.g, d: &dd}:
+					case <-ctx.Done():
+						return
+					}
 				}
-			}
-			return resp, err
+			}, resp, err
 		})
 		if err != nil {
 			errc <- err
@@ -13,7 +13,7 @@ type Client interface {
 	CreateMultiple(ctx context.Context, names []string, region, size, image string, opts ...CreateMultipleOpt) ([]Droplet, error)
 	Get(ctx context.Context, id int) (Droplet, error)
 	Delete(ctx context.Context, id int) error
-	List(ctx context.Context) (<-chan Droplet, <-chan error)
+	List(ctx context.Context, opts ...ListOpt) (<-chan Droplet, <-chan error)
 	Actions() ActionClient
 }
 
@@ -129,24 +129,51 @@ func (svc *client) Delete(ctx context.Context, id int) error {
 	return godoutil.WaitForActions(ctx, svc.g, resp.Links)
 }
 
-func (svc *client) List(ctx context.Context) (<-chan Droplet, <-chan error) {
+// ListOpt is an optional argument to droplets.List.
+type ListOpt func(*listOpt)
+
+// FilterTag only lists the droplets with the given tag.
+func FilterTag(tag string) ListOpt {
+	return func(opt *listOpt) { opt.tag = tag }
+}
+
+type listOpt struct {
+	tag string
+}
+
+func (svc *client) List(ctx context.Context, opts ...ListOpt) (<-chan Droplet, <-chan error) {
+	opt := &listOpt{}
+	for _, fn := range opts {
+		fn(opt)
+	}
+
 	outc := make(chan Droplet, 1)
 	errc := make(chan error, 1)
 
 	go func() {
 		defer close(outc)
 		defer close(errc)
-		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (*godo.Response, error) {
-			r, resp, err := svc.g.Droplets.List(ctx, opt)
-			for _, d := range r {
-				dd := d // copy ranged over variable
-				select {
-				case outc <- &droplet{g: svc.g, d: &dd}:
-				case <-ctx.Done():
-					return resp, err
-				}
+		err := godoutil.IterateList(ctx, func(ctx context.Context, lopt *godo.ListOptions) (func(), *godo.Response, error) {
+			var (
+				r    []godo.Droplet
+				resp *godo.Response
+				err  error
+			)
+			if opt.tag != "" {
+				r, resp, err = svc.g.Droplets.ListByTag(ctx, opt.tag, lopt)
+			} else {
+				r, resp, err = svc.g.Droplets.List(ctx, lopt)
 			}
-			return resp, err
+			return func() {
+				for _, d := range r {
+					dd := d // copy ranged over variable
+					select {
+					case outc <- &droplet{g: svc.g, d: &dd}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}, resp, err
 		})
 		if err != nil {
 			errc <- err
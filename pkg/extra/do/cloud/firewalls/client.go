@@ -98,18 +98,18 @@ func (svc *client) List(ctx context.Context) (<-chan Firewall, <-chan error) {
 	go func() {
 		defer close(outc)
 		defer close(errc)
-		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (*godo.Response, error) {
+		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (func(), *godo.Response, error) {
 			r, resp, err := svc.g.Firewalls.List(ctx, opt)
-			for _, f := range r {
-				ff := f
-				select {
-				case outc <- &firewall{g: svc.g, f: &ff}:
-				case <-ctx.Done():
-					return resp, err
+			return func() {
+				for _, f := range r {
+					ff := f
+					select {
+					case outc <- &firewall{g: svc.g, f: &ff}:
+					case <-ctx.Done():
+						return
+					}
 				}
-			}
-
-			return resp, err
+			}, resp, err
 		})
 		if err != nil {
 			errc <- err
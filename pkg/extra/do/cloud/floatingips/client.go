@@ -88,17 +88,18 @@ func (svc *client) List(ctx context.Context) (<-chan FloatingIP, <-chan error) {
 	go func() {
 		defer close(outc)
 		defer close(errc)
-		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (*godo.Response, error) {
+		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (func(), *godo.Response, error) {
 			r, resp, err := svc.g.FloatingIPs.List(ctx, opt)
-			for _, d := range r {
-				dd := d // copy ranged over variable
-				select {
-				case outc <- &floatingIP{g: svc.g, d: &dd}:
-				case <-ctx.Done():
-					return resp, err
+			return func() {
+				for _, d := range r {
+					dd := d // copy ranged over variable
+					select {
+					case outc <- &floatingIP{g: svc.g, d: &dd}:
+					case <-ctx.Done():
+						return
+					}
 				}
-			}
-			return resp, err
+			}, resp, err
 		})
 		if err != nil {
 			errc <- err
@@ -2,6 +2,7 @@ package images
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/aybabtme/godotto/pkg/extra/godoutil"
 	"github.com/digitalocean/godo"
@@ -13,7 +14,7 @@ type Client interface {
 	GetBySlug(context.Context, string) (Image, error)
 	Update(context.Context, int, ...UpdateOpt) (Image, error)
 	Delete(context.Context, int) error
-	List(context.Context) (<-chan Image, <-chan error)
+	List(context.Context, ...ListOpt) (<-chan Image, <-chan error)
 	ListApplication(context.Context) (<-chan Image, <-chan error)
 	ListDistribution(context.Context) (<-chan Image, <-chan error)
 	ListUser(context.Context) (<-chan Image, <-chan error)
@@ -86,8 +87,65 @@ func (svc *client) Delete(ctx context.Context, id int) error {
 	return err
 }
 
-func (svc *client) List(ctx context.Context) (<-chan Image, <-chan error) {
-	return svc.listCommon(ctx, svc.g.Images.List)
+// ListOpt is an optional argument to images.List.
+type ListOpt func(*listOpt)
+
+// FilterType only lists the images of the given type, either
+// "distribution" or "application".
+func FilterType(typ string) ListOpt {
+	return func(opt *listOpt) { opt.typ = typ }
+}
+
+// FilterPrivate only lists the images that are private to the user, or
+// only the public ones.
+func FilterPrivate(private bool) ListOpt {
+	return func(opt *listOpt) { opt.private = &private }
+}
+
+type listOpt struct {
+	typ     string
+	private *bool
+}
+
+func (opt *listOpt) match(d *godo.Image) bool {
+	return opt.private == nil || *opt.private != d.Public
+}
+
+func (svc *client) List(ctx context.Context, opts ...ListOpt) (<-chan Image, <-chan error) {
+	opt := &listOpt{}
+	for _, fn := range opts {
+		fn(opt)
+	}
+	listFn := svc.g.Images.List
+	switch {
+	case opt.typ == "distribution":
+		listFn = svc.g.Images.ListDistribution
+	case opt.typ == "application":
+		listFn = svc.g.Images.ListApplication
+	case opt.typ != "":
+		return failList(fmt.Errorf("unknown image type %q, must be distribution or application", opt.typ))
+	case opt.private != nil && *opt.private:
+		listFn = svc.g.Images.ListUser
+	}
+	return svc.listCommon(ctx, func(ctx context.Context, lopt *godo.ListOptions) ([]godo.Image, *godo.Response, error) {
+		r, resp, err := listFn(ctx, lopt)
+		filtered := r[:0]
+		for _, d := range r {
+			if opt.match(&d) {
+				filtered = append(filtered, d)
+			}
+		}
+		return filtered, resp, err
+	})
+}
+
+func failList(err error) (<-chan Image, <-chan error) {
+	outc := make(chan Image)
+	errc := make(chan error, 1)
+	close(outc)
+	errc <- err
+	close(errc)
+	return outc, errc
 }
 
 func (svc *client) ListApplication(ctx context.Context) (<-chan Image, <-chan error) {
@@ -111,17 +169,18 @@ func (svc *client) listCommon(ctx context.Context, listFn listfunc) (<-chan Imag
 	go func() {
 		defer close(outc)
 		defer close(errc)
-		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (*godo.Response, error) {
+		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (func(), *godo.Response, error) {
 			r, resp, err := listFn(ctx, opt)
-			for _, d := range r {
-				dd := d // copy ranged over variable
-				select {
-				case outc <- &image{g: svc.g, d: &dd}:
-				case <-ctx.Done():
-					return resp, err
+			return func() {
+				for _, d := range r {
+					dd := d // copy ranged over variable
+					select {
+					case outc <- &image{g: svc.g, d: &dd}:
+					case <-ctx.Done():
+						return
+					}
 				}
-			}
-			return resp, err
+			}, resp, err
 		})
 		if err != nil {
 			errc <- err
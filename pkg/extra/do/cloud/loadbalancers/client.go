@@ -127,17 +127,18 @@ func (svc *client) List(ctx context.Context) (<-chan LoadBalancer, <-chan error)
 	go func() {
 		defer close(outc)
 		defer close(errc)
-		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (*godo.Response, error) {
+		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (func(), *godo.Response, error) {
 			r, resp, err := svc.g.LoadBalancers.List(ctx, opt)
-			for _, l := range r {
-				ll := l // copy ranged over variable
-				select {
-				case outc <- &loadBalancer{g: svc.g, l: &ll}:
-				case <-ctx.Done():
-					return resp, err
+			return func() {
+				for _, l := range r {
+					ll := l // copy ranged over variable
+					select {
+					case outc <- &loadBalancer{g: svc.g, l: &ll}:
+					case <-ctx.Done():
+						return
+					}
 				}
-			}
-			return resp, err
+			}, resp, err
 		})
 		if err != nil {
 			errc <- err
@@ -0,0 +1,349 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/digitalocean/godo"
+)
+
+// The kinds of resources a Resolver knows the names of.
+const (
+	DropletKind      = "droplet"
+	VolumeKind       = "volume"
+	SnapshotKind     = "snapshot"
+	FirewallKind     = "firewall"
+	LoadBalancerKind = "load balancer"
+	ImageKind        = "image"
+	KeyKind          = "key"
+	FloatingIPKind   = "floating IP"
+	DomainKind       = "domain"
+)
+
+// A Candidate is one of the resources a name could refer to.
+type Candidate struct {
+	ID     string
+	Name   string
+	Region string
+}
+
+func (c Candidate) String() string {
+	if c.Region == "" {
+		return c.ID
+	}
+	return c.ID + " (" + c.Region + ")"
+}
+
+// NameNotFoundError is returned when no resource has the given name.
+type NameNotFoundError struct {
+	Kind string
+	Name string
+}
+
+func (e *NameNotFoundError) Error() string {
+	return fmt.Sprintf("no %s named %q", e.Kind, e.Name)
+}
+
+// AmbiguousNameError is returned when many resources share the given name.
+type AmbiguousNameError struct {
+	Kind       string
+	Name       string
+	Candidates []Candidate
+}
+
+func (e *AmbiguousNameError) Error() string {
+	ids := make([]string, 0, len(e.Candidates))
+	for _, c := range e.Candidates {
+		ids = append(ids, c.String())
+	}
+	return fmt.Sprintf("%d %ss are named %q, use an ID instead: %s",
+		len(e.Candidates), e.Kind, e.Name, strings.Join(ids, ", "))
+}
+
+type resolverOpts struct {
+	ttl time.Duration
+}
+
+// ResolverOpt is an option to configure a Resolver.
+type ResolverOpt func(*resolverOpts)
+
+// ResolverTTL sets how long a name index is trusted before being listed
+// again. A name that isn't found causes the index to be rebuilt once, after
+// which the name is known to be missing until the TTL expires.
+func ResolverTTL(ttl time.Duration) ResolverOpt {
+	return func(opt *resolverOpts) { opt.ttl = ttl }
+}
+
+// A Resolver finds the ID of resources given their name. Names are looked up
+// in an index built by listing the resources, which is cached.
+type Resolver struct {
+	client Client
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	indexes map[string]*nameIndex
+}
+
+type nameIndex struct {
+	builtAt time.Time
+	byName  map[string][]Candidate
+	missing map[string]time.Time
+}
+
+// NewResolver creates a Resolver for the resources of the client.
+func NewResolver(client Client, opts ...ResolverOpt) *Resolver {
+	opt := &resolverOpts{ttl: time.Minute}
+	for _, fn := range opts {
+		fn(opt)
+	}
+	return &Resolver{
+		client:  client,
+		ttl:     opt.ttl,
+		indexes: make(map[string]*nameIndex),
+	}
+}
+
+// Invalidate drops the cached name indexes of kinds, once resources of
+// theirs were created, deleted or renamed. Without kinds, all of them are
+// dropped.
+func (r *Resolver) Invalidate(kinds ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(kinds) == 0 {
+		r.indexes = make(map[string]*nameIndex)
+		return
+	}
+	for _, kind := range kinds {
+		delete(r.indexes, kind)
+	}
+}
+
+type freshKey struct{}
+
+// WithFreshNames returns a copy of ctx with which names are resolved from a
+// new listing rather than a cached one, as is safer before deleting what
+// they refer to.
+func WithFreshNames(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshKey{}, true)
+}
+
+// DropletID finds the ID of the droplet with the given name.
+func (r *Resolver) DropletID(ctx context.Context, name string) (int, error) {
+	id, err := r.resolve(ctx, DropletKind, name, func(ctx context.Context, add func(Candidate)) error {
+		dc, errc := r.client.Droplets().List(ctx)
+		for d := range dc {
+			g := d.Struct()
+			add(Candidate{ID: strconv.Itoa(g.ID), Name: g.Name, Region: regionSlug(g.Region)})
+		}
+		return <-errc
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(id)
+}
+
+// VolumeID finds the ID of the volume with the given name.
+func (r *Resolver) VolumeID(ctx context.Context, name string) (string, error) {
+	return r.resolve(ctx, VolumeKind, name, func(ctx context.Context, add func(Candidate)) error {
+		vc, errc := r.client.Volumes().ListVolumes(ctx)
+		for v := range vc {
+			g := v.Struct()
+			add(Candidate{ID: g.ID, Name: g.Name, Region: regionSlug(g.Region)})
+		}
+		return <-errc
+	})
+}
+
+// SnapshotID finds the ID of the snapshot with the given name.
+func (r *Resolver) SnapshotID(ctx context.Context, name string) (string, error) {
+	return r.resolve(ctx, SnapshotKind, name, func(ctx context.Context, add func(Candidate)) error {
+		sc, errc := r.client.Snapshots().List(ctx)
+		for s := range sc {
+			g := s.Struct()
+			add(Candidate{ID: g.ID, Name: g.Name, Region: strings.Join(g.Regions, ",")})
+		}
+		return <-errc
+	})
+}
+
+// FirewallID finds the ID of the firewall with the given name.
+func (r *Resolver) FirewallID(ctx context.Context, name string) (string, error) {
+	return r.resolve(ctx, FirewallKind, name, func(ctx context.Context, add func(Candidate)) error {
+		fc, errc := r.client.Firewalls().List(ctx)
+		for f := range fc {
+			g := f.Struct()
+			add(Candidate{ID: g.ID, Name: g.Name})
+		}
+		return <-errc
+	})
+}
+
+// LoadBalancerID finds the ID of the load balancer with the given name.
+func (r *Resolver) LoadBalancerID(ctx context.Context, name string) (string, error) {
+	return r.resolve(ctx, LoadBalancerKind, name, func(ctx context.Context, add func(Candidate)) error {
+		lc, errc := r.client.LoadBalancers().List(ctx)
+		for l := range lc {
+			g := l.Struct()
+			add(Candidate{ID: g.ID, Name: g.Name, Region: regionSlug(g.Region)})
+		}
+		return <-errc
+	})
+}
+
+// ImageID finds the ID of the private image, like a snapshot or a backup,
+// with the given name.
+func (r *Resolver) ImageID(ctx context.Context, name string) (int, error) {
+	id, err := r.resolve(ctx, ImageKind, name, func(ctx context.Context, add func(Candidate)) error {
+		ic, errc := r.client.Images().ListUser(ctx)
+		for i := range ic {
+			g := i.Struct()
+			add(Candidate{ID: strconv.Itoa(g.ID), Name: g.Name, Region: strings.Join(g.Regions, ",")})
+		}
+		return <-errc
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(id)
+}
+
+// KeyID finds the ID of the SSH key with the given name.
+func (r *Resolver) KeyID(ctx context.Context, name string) (int, error) {
+	id, err := r.resolve(ctx, KeyKind, name, func(ctx context.Context, add func(Candidate)) error {
+		kc, errc := r.client.Keys().List(ctx)
+		for k := range kc {
+			g := k.Struct()
+			add(Candidate{ID: strconv.Itoa(g.ID), Name: g.Name})
+		}
+		return <-errc
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(id)
+}
+
+// FloatingIP finds the floating IP assigned to the droplet with the given
+// name.
+func (r *Resolver) FloatingIP(ctx context.Context, dropletName string) (string, error) {
+	return r.resolve(ctx, FloatingIPKind, dropletName, func(ctx context.Context, add func(Candidate)) error {
+		fc, errc := r.client.FloatingIPs().List(ctx)
+		for f := range fc {
+			g := f.Struct()
+			if g.Droplet == nil {
+				continue
+			}
+			add(Candidate{ID: g.IP, Name: g.Droplet.Name, Region: regionSlug(g.Region)})
+		}
+		return <-errc
+	})
+}
+
+// DomainName finds the domain with the given name, ignoring its case and
+// trailing dot.
+func (r *Resolver) DomainName(ctx context.Context, name string) (string, error) {
+	return r.resolve(ctx, DomainKind, domainKey(name), func(ctx context.Context, add func(Candidate)) error {
+		dc, errc := r.client.Domains().List(ctx)
+		for d := range dc {
+			g := d.Struct()
+			add(Candidate{ID: g.Name, Name: domainKey(g.Name)})
+		}
+		return <-errc
+	})
+}
+
+func domainKey(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+func (r *Resolver) resolve(
+	ctx context.Context,
+	kind, name string,
+	list func(context.Context, func(Candidate)) error,
+) (string, error) {
+	r.mu.Lock()
+	idx, ok := r.indexes[kind]
+	fresh, _ := ctx.Value(freshKey{}).(bool)
+	stale := fresh || !ok || r.expired(idx.builtAt)
+	if !stale {
+		if _, found := idx.byName[name]; !found {
+			// might have been created since the index was built, unless
+			// it was already missing from a recent one
+			missedAt, missed := idx.missing[name]
+			stale = !missed || r.expired(missedAt)
+		}
+	}
+	r.mu.Unlock()
+
+	if stale {
+		var err error
+		if idx, err = r.build(ctx, kind, list); err != nil {
+			return "", err
+		}
+	}
+
+	r.mu.Lock()
+	candidates := idx.byName[name]
+	if len(candidates) == 0 && stale {
+		idx.missing[name] = idx.builtAt
+	}
+	r.mu.Unlock()
+
+	switch len(candidates) {
+	case 0:
+		return "", &NameNotFoundError{Kind: kind, Name: name}
+	case 1:
+		return candidates[0].ID, nil
+	default:
+		return "", &AmbiguousNameError{Kind: kind, Name: name, Candidates: candidates}
+	}
+}
+
+func (r *Resolver) build(
+	ctx context.Context,
+	kind string,
+	list func(context.Context, func(Candidate)) error,
+) (*nameIndex, error) {
+	idx := &nameIndex{
+		builtAt: time.Now(),
+		byName:  make(map[string][]Candidate),
+		missing: make(map[string]time.Time),
+	}
+	err := list(ctx, func(c Candidate) {
+		idx.byName[c.Name] = append(idx.byName[c.Name], c)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing %ss to resolve names: %v", kind, err)
+	}
+	for _, candidates := range idx.byName {
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].ID < candidates[j].ID })
+	}
+	r.mu.Lock()
+	if old, ok := r.indexes[kind]; ok {
+		for name, missedAt := range old.missing {
+			if _, found := idx.byName[name]; !found && !r.expired(missedAt) {
+				idx.missing[name] = missedAt
+			}
+		}
+	}
+	r.indexes[kind] = idx
+	r.mu.Unlock()
+	return idx, nil
+}
+
+func (r *Resolver) expired(t time.Time) bool {
+	return time.Since(t) > r.ttl
+}
+
+func regionSlug(r *godo.Region) string {
+	if r == nil {
+		return ""
+	}
+	return r.Slug
+}
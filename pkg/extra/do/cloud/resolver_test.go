@@ -0,0 +1,264 @@
+package cloud_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/domains"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/droplets"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/floatingips"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/images"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/keys"
+	"github.com/aybabtme/godotto/pkg/extra/do/mockcloud"
+	"github.com/digitalocean/godo"
+)
+
+type droplet struct {
+	*godo.Droplet
+}
+
+func (d *droplet) Struct() *godo.Droplet { return d.Droplet }
+
+// listing counts the listings of droplets, which are those of gds.
+type listing struct {
+	mu    sync.Mutex
+	gds   []*godo.Droplet
+	lists int
+}
+
+func (l *listing) client() *mockcloud.Mock {
+	client := mockcloud.Client(nil)
+	client.MockDroplets.ListFn = func(_ context.Context, _ ...droplets.ListOpt) (<-chan droplets.Droplet, <-chan error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.lists++
+		lc := make(chan droplets.Droplet, len(l.gds))
+		for _, g := range l.gds {
+			lc <- &droplet{g}
+		}
+		close(lc)
+		ec := make(chan error)
+		close(ec)
+		return lc, ec
+	}
+	return client
+}
+
+func (l *listing) add(g *godo.Droplet) {
+	l.mu.Lock()
+	l.gds = append(l.gds, g)
+	l.mu.Unlock()
+}
+
+func (l *listing) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lists
+}
+
+func TestResolverTTL(t *testing.T) {
+	l := &listing{gds: []*godo.Droplet{{ID: 42, Name: "web-1"}}}
+	r := cloud.NewResolver(l.client(), cloud.ResolverTTL(50*time.Millisecond))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		id, err := r.DropletID(ctx, "web-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != 42 {
+			t.Fatalf("want %d got %d", 42, id)
+		}
+	}
+	if want, got := 1, l.count(); want != got {
+		t.Fatalf("want %d listings before the TTL expires, got %d", want, got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := r.DropletID(ctx, "web-1"); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, l.count(); want != got {
+		t.Fatalf("want %d listings once the TTL expired, got %d", want, got)
+	}
+}
+
+func TestResolverInvalidate(t *testing.T) {
+	l := &listing{gds: []*godo.Droplet{{ID: 42, Name: "web-1"}}}
+	r := cloud.NewResolver(l.client(), cloud.ResolverTTL(time.Hour))
+	ctx := context.Background()
+
+	if _, err := r.DropletID(ctx, "web-1"); err != nil {
+		t.Fatal(err)
+	}
+	l.add(&godo.Droplet{ID: 43, Name: "web-1"})
+	if _, err := r.DropletID(ctx, "web-1"); err != nil {
+		t.Fatalf("want the cached index to be used, got %v", err)
+	}
+
+	r.Invalidate()
+	_, err := r.DropletID(ctx, "web-1")
+	if _, ok := err.(*cloud.AmbiguousNameError); !ok {
+		t.Fatalf("want the index to be rebuilt and the name to be ambiguous, got %v", err)
+	}
+	if want, got := 2, l.count(); want != got {
+		t.Fatalf("want %d listings got %d", want, got)
+	}
+}
+
+func TestResolverInvalidateKinds(t *testing.T) {
+	l := &listing{gds: []*godo.Droplet{{ID: 42, Name: "web-1"}}}
+	r := cloud.NewResolver(l.client(), cloud.ResolverTTL(time.Hour))
+	ctx := context.Background()
+
+	if _, err := r.DropletID(ctx, "web-1"); err != nil {
+		t.Fatal(err)
+	}
+	r.Invalidate(cloud.VolumeKind)
+	if _, err := r.DropletID(ctx, "web-1"); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, l.count(); want != got {
+		t.Fatalf("want droplets to stay cached when volumes are invalidated, got %d listings", got)
+	}
+	r.Invalidate(cloud.DropletKind)
+	if _, err := r.DropletID(ctx, "web-1"); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, l.count(); want != got {
+		t.Fatalf("want %d listings got %d", want, got)
+	}
+
+	if _, err := r.DropletID(cloud.WithFreshNames(ctx), "web-1"); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 3, l.count(); want != got {
+		t.Fatalf("want fresh names to be listed again, got %d listings", got)
+	}
+}
+
+func TestResolverAmbiguous(t *testing.T) {
+	l := &listing{gds: []*godo.Droplet{
+		{ID: 44, Name: "db-1", Region: &godo.Region{Slug: "sfo2"}},
+		{ID: 42, Name: "web-1", Region: &godo.Region{Slug: "nyc3"}},
+		{ID: 43, Name: "db-1", Region: &godo.Region{Slug: "nyc3"}},
+	}}
+	r := cloud.NewResolver(l.client())
+
+	_, err := r.DropletID(context.Background(), "db-1")
+	amb, ok := err.(*cloud.AmbiguousNameError)
+	if !ok {
+		t.Fatalf("want an AmbiguousNameError, got %v", err)
+	}
+	if len(amb.Candidates) != 2 || amb.Candidates[0].ID != "43" || amb.Candidates[1].ID != "44" {
+		t.Fatalf("want candidates 43 and 44, got %v", amb.Candidates)
+	}
+	want := `2 droplets are named "db-1", use an ID instead: 43 (nyc3), 44 (sfo2)`
+	if got := err.Error(); got != want {
+		t.Fatalf("want %q got %q", want, got)
+	}
+}
+
+func TestResolverNotFound(t *testing.T) {
+	l := &listing{gds: []*godo.Droplet{{ID: 42, Name: "web-1"}}}
+	r := cloud.NewResolver(l.client(), cloud.ResolverTTL(50*time.Millisecond))
+	ctx := context.Background()
+
+	if _, err := r.DropletID(ctx, "web-1"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		_, err := r.DropletID(ctx, "web-2")
+		if _, ok := err.(*cloud.NameNotFoundError); !ok {
+			t.Fatalf("want a NameNotFoundError, got %v", err)
+		}
+	}
+	// listed once more, in case it was created since
+	if want, got := 2, l.count(); want != got {
+		t.Fatalf("want %d listings while the name is known to be missing, got %d", want, got)
+	}
+
+	l.add(&godo.Droplet{ID: 43, Name: "web-2"})
+	time.Sleep(60 * time.Millisecond)
+	id, err := r.DropletID(ctx, "web-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 43 {
+		t.Fatalf("want %d got %d", 43, id)
+	}
+}
+
+type image struct{ *godo.Image }
+
+func (i *image) Struct() *godo.Image { return i.Image }
+
+type key struct{ *godo.Key }
+
+func (k *key) Struct() *godo.Key { return k.Key }
+
+type floatingIP struct{ *godo.FloatingIP }
+
+func (f *floatingIP) Struct() *godo.FloatingIP { return f.FloatingIP }
+
+type domain struct{ *godo.Domain }
+
+func (d *domain) Struct() *godo.Domain { return d.Domain }
+
+func TestResolverKinds(t *testing.T) {
+	client := mockcloud.Client(nil)
+	client.MockImages.ListUserFn = func(_ context.Context) (<-chan images.Image, <-chan error) {
+		ic := make(chan images.Image, 1)
+		ic <- &image{&godo.Image{ID: 7, Name: "web-backup"}}
+		close(ic)
+		ec := make(chan error)
+		close(ec)
+		return ic, ec
+	}
+	client.MockKeys.ListFn = func(_ context.Context) (<-chan keys.Key, <-chan error) {
+		kc := make(chan keys.Key, 1)
+		kc <- &key{&godo.Key{ID: 8, Name: "deploy"}}
+		close(kc)
+		ec := make(chan error)
+		close(ec)
+		return kc, ec
+	}
+	client.MockFloatingIPs.ListFn = func(_ context.Context) (<-chan floatingips.FloatingIP, <-chan error) {
+		fc := make(chan floatingips.FloatingIP, 2)
+		fc <- &floatingIP{&godo.FloatingIP{IP: "192.0.2.1"}}
+		fc <- &floatingIP{&godo.FloatingIP{IP: "192.0.2.2", Droplet: &godo.Droplet{ID: 42, Name: "web-1"}}}
+		close(fc)
+		ec := make(chan error)
+		close(ec)
+		return fc, ec
+	}
+	client.MockDomains.ListFn = func(_ context.Context) (<-chan domains.Domain, <-chan error) {
+		dc := make(chan domains.Domain, 1)
+		dc <- &domain{&godo.Domain{Name: "example.com"}}
+		close(dc)
+		ec := make(chan error)
+		close(ec)
+		return dc, ec
+	}
+	r := cloud.NewResolver(client)
+	ctx := context.Background()
+
+	if id, err := r.ImageID(ctx, "web-backup"); err != nil || id != 7 {
+		t.Fatalf("want image %d, got %d (%v)", 7, id, err)
+	}
+	if id, err := r.KeyID(ctx, "deploy"); err != nil || id != 8 {
+		t.Fatalf("want key %d, got %d (%v)", 8, id, err)
+	}
+	if ip, err := r.FloatingIP(ctx, "web-1"); err != nil || ip != "192.0.2.2" {
+		t.Fatalf("want IP %q, got %q (%v)", "192.0.2.2", ip, err)
+	}
+	if name, err := r.DomainName(ctx, "Example.COM."); err != nil || name != "example.com" {
+		t.Fatalf("want domain %q, got %q (%v)", "example.com", name, err)
+	}
+	if _, err := r.DomainName(ctx, "example.org"); err == nil {
+		t.Fatal("want an error for an unknown domain")
+	}
+}
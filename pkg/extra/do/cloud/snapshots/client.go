@@ -56,17 +56,18 @@ func (svc *client) List(ctx context.Context) (<-chan Snapshot, <-chan error) {
 	go func() {
 		defer close(outc)
 		defer close(errc)
-		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (*godo.Response, error) {
+		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (func(), *godo.Response, error) {
 			r, resp, err := svc.g.Snapshots.List(ctx, opt)
-			for _, s := range r {
-				ss := s // copy ranged over variable
-				select {
-				case outc <- &snapshot{g: svc.g, s: &ss}:
-				case <-ctx.Done():
-					return resp, err
+			return func() {
+				for _, s := range r {
+					ss := s // copy ranged over variable
+					select {
+					case outc <- &snapshot{g: svc.g, s: &ss}:
+					case <-ctx.Done():
+						return
+					}
 				}
-			}
-			return resp, err
+			}, resp, err
 		})
 		if err != nil {
 			errc <- err
@@ -83,17 +84,18 @@ func (svc *client) ListDroplet(ctx context.Context) (<-chan Snapshot, <-chan err
 	go func() {
 		defer close(outc)
 		defer close(errc)
-		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (*godo.Response, error) {
+		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (func(), *godo.Response, error) {
 			r, resp, err := svc.g.Snapshots.ListDroplet(ctx, opt)
-			for _, s := range r {
-				ss := s // copy ranged over variable
-				select {
-				case outc <- &snapshot{g: svc.g, s: &ss}:
-				case <-ctx.Done():
-					return resp, err
+			return func() {
+				for _, s := range r {
+					ss := s // copy ranged over variable
+					select {
+					case outc <- &snapshot{g: svc.g, s: &ss}:
+					case <-ctx.Done():
+						return
+					}
 				}
-			}
-			return resp, err
+			}, resp, err
 		})
 		if err != nil {
 			errc <- err
@@ -110,17 +112,18 @@ func (svc *client) ListVolume(ctx context.Context) (<-chan Snapshot, <-chan erro
 	go func() {
 		defer close(outc)
 		defer close(errc)
-		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (*godo.Response, error) {
+		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (func(), *godo.Response, error) {
 			r, resp, err := svc.g.Snapshots.ListVolume(ctx, opt)
-			for _, s := range r {
-				ss := s // copy ranged over variable
-				select {
-				case outc <- &snapshot{g: svc.g, s: &ss}:
-				case <-ctx.Done():
-					return resp, err
+			return func() {
+				for _, s := range r {
+					ss := s // copy ranged over variable
+					select {
+					case outc <- &snapshot{g: svc.g, s: &ss}:
+					case <-ctx.Done():
+						return
+					}
 				}
-			}
-			return resp, err
+			}, resp, err
 		})
 		if err != nil {
 			errc <- err
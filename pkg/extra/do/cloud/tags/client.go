@@ -118,17 +118,18 @@ func (svc *client) List(ctx context.Context) (<-chan Tag, <-chan error) {
 	go func() {
 		defer close(outc)
 		defer close(errc)
-		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (*godo.Response, error) {
+		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (func(), *godo.Response, error) {
 			r, resp, err := svc.g.Tags.List(ctx, opt)
-			for _, t := range r {
-				tt := t // copy ranged over variable
-				select {
-				case outc <- &tag{g: svc.g, t: &tt}:
-				case <-ctx.Done():
-					return resp, err
+			return func() {
+				for _, t := range r {
+					tt := t // copy ranged over variable
+					select {
+					case outc <- &tag{g: svc.g, t: &tt}:
+					case <-ctx.Done():
+						return
+					}
 				}
-			}
-			return resp, err
+			}, resp, err
 		})
 		if err != nil {
 			errc <- err
@@ -12,7 +12,7 @@ type Client interface {
 	CreateVolume(ctx context.Context, name, region string, sizeGibiBytes int64, opts ...CreateOpt) (Volume, error)
 	GetVolume(context.Context, string) (Volume, error)
 	DeleteVolume(context.Context, string) error
-	ListVolumes(context.Context) (<-chan Volume, <-chan error)
+	ListVolumes(context.Context, ...ListOpt) (<-chan Volume, <-chan error)
 
 	CreateSnapshot(ctx context.Context, volumeID, name string, opts ...SnapshotOpt) (Snapshot, error)
 	GetSnapshot(context.Context, string) (Snapshot, error)
@@ -102,25 +102,49 @@ func (svc *client) DeleteVolume(ctx context.Context, name string) error {
 	return err
 }
 
-func (svc *client) ListVolumes(ctx context.Context) (<-chan Volume, <-chan error) {
+// ListOpt is an optional argument to volumes.ListVolumes.
+type ListOpt func(*listOpt)
+
+// FilterRegion only lists the volumes in the given region.
+func FilterRegion(region string) ListOpt {
+	return func(opt *listOpt) { opt.region = region }
+}
+
+// FilterName only lists the volumes with the given name.
+func FilterName(name string) ListOpt {
+	return func(opt *listOpt) { opt.name = name }
+}
+
+type listOpt struct {
+	region string
+	name   string
+}
+
+func (svc *client) ListVolumes(ctx context.Context, opts ...ListOpt) (<-chan Volume, <-chan error) {
+	opt := &listOpt{}
+	for _, fn := range opts {
+		fn(opt)
+	}
+
 	outc := make(chan Volume, 1)
 	errc := make(chan error, 1)
 
 	go func() {
 		defer close(outc)
 		defer close(errc)
-		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (*godo.Response, error) {
-			lvp := &godo.ListVolumeParams{ListOptions: opt}
+		err := godoutil.IterateList(ctx, func(ctx context.Context, lopt *godo.ListOptions) (func(), *godo.Response, error) {
+			lvp := &godo.ListVolumeParams{Region: opt.region, Name: opt.name, ListOptions: lopt}
 			r, resp, err := svc.g.Storage.ListVolumes(ctx, lvp)
-			for _, d := range r {
-				dd := d // copy ranged over variable
-				select {
-				case outc <- &volume{g: svc.g, d: &dd}:
-				case <-ctx.Done():
-					return resp, err
+			return func() {
+				for _, d := range r {
+					dd := d // copy ranged over variable
+					select {
+					case outc <- &volume{g: svc.g, d: &dd}:
+					case <-ctx.Done():
+						return
+					}
 				}
-			}
-			return resp, err
+			}, resp, err
 		})
 		if err != nil {
 			errc <- err
@@ -193,17 +217,18 @@ func (svc *client) ListSnapshots(ctx context.Context, volumeID string) (<-chan S
 	go func() {
 		defer close(outc)
 		defer close(errc)
-		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (*godo.Response, error) {
+		err := godoutil.IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (func(), *godo.Response, error) {
 			r, resp, err := svc.g.Storage.ListSnapshots(ctx, volumeID, opt)
-			for _, d := range r {
-				dd := d // copy ranged over variable
-				select {
-				case outc <- &snapshot{g: svc.g, d: &dd}:
-				case <-ctx.Done():
-					return resp, err
+			return func() {
+				for _, d := range r {
+					dd := d // copy ranged over variable
+					select {
+					case outc <- &snapshot{g: svc.g, d: &dd}:
+					case <-ctx.Done():
+						return
+					}
 				}
-			}
-			return resp, err
+			}, resp, err
 		})
 		if err != nil {
 			errc <- err
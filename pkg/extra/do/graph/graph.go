@@ -0,0 +1,380 @@
+// Package graph ties the resources of an account together, such that one can
+// tell what depends on a resource before changing or deleting it.
+package graph
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/inventory"
+	"github.com/digitalocean/godo"
+)
+
+// The kinds of nodes in a graph.
+const (
+	Droplet      = "droplet"
+	Volume       = "volume"
+	Snapshot     = "snapshot"
+	Image        = "image"
+	Domain       = "domain"
+	FloatingIP   = "floating_ip"
+	Key          = "key"
+	Tag          = "tag"
+	LoadBalancer = "load_balancer"
+	Firewall     = "firewall"
+)
+
+// The relations between resources. An edge goes from the resource that
+// references another one, to the resource it references.
+const (
+	// a droplet uses the volumes attached to it
+	Uses = "uses"
+	// a floating IP is assigned to a droplet
+	AssignedTo = "assigned_to"
+	// a load balancer targets droplets, directly or through a tag
+	Targets = "targets"
+	// a firewall applies to droplets, directly or through a tag
+	AppliesTo = "applies_to"
+	// a domain points to droplets or floating IPs through its records
+	PointsTo = "points_to"
+	// a resource carries a tag
+	Tagged = "tagged"
+	// a snapshot was taken of a droplet or volume
+	SnapshotOf = "snapshot_of"
+	// a droplet was created from a private image
+	CreatedFrom = "created_from"
+)
+
+// breaking relations are those where the referencing resource stops working
+// as intended once the referenced resource is gone. A snapshot outlives its
+// source, and a tag doesn't need the resources that carry it.
+var breaking = map[string]bool{
+	Uses:       true,
+	AssignedTo: true,
+	Targets:    true,
+	AppliesTo:  true,
+	PointsTo:   true,
+}
+
+// A Node is a resource of the account.
+type Node struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// Ref is how a node is referred to, like `droplet:1234`.
+func (n Node) Ref() string { return Ref(n.Kind, n.ID) }
+
+// Ref builds the reference to a node of the given kind and ID.
+func Ref(kind, id string) string { return kind + ":" + id }
+
+// An Edge is a reference from one resource to another. Via tells how the
+// reference is made when it's indirect, like `tag:web` for a firewall that
+// applies to the droplets tagged `web`.
+type Edge struct {
+	From Node   `json:"from"`
+	To   Node   `json:"to"`
+	Rel  string `json:"rel"`
+	Via  string `json:"via,omitempty"`
+}
+
+// A Graph of the resources of an account.
+type Graph struct {
+	nodes map[string]Node
+	refs  []string // in insertion order
+	out   map[string][]Edge
+	in    map[string][]Edge
+}
+
+// Build the graph of the resources in an inventory. References to resources
+// that aren't in the inventory, like public images, are left out.
+func Build(inv *inventory.Inventory) *Graph {
+	g := &Graph{
+		nodes: make(map[string]Node),
+		out:   make(map[string][]Edge),
+		in:    make(map[string][]Edge),
+	}
+
+	for _, d := range inv.Droplets {
+		g.add(Droplet, strconv.Itoa(d.ID), d.Name)
+	}
+	for _, v := range inv.Volumes {
+		g.add(Volume, v.ID, v.Name)
+	}
+	for _, s := range inv.Snapshots {
+		g.add(Snapshot, s.ID, s.Name)
+	}
+	for _, img := range inv.Images {
+		g.add(Image, strconv.Itoa(img.ID), img.Name)
+	}
+	for _, d := range inv.Domains {
+		g.add(Domain, d.Name, d.Name)
+	}
+	for _, f := range inv.FloatingIPs {
+		g.add(FloatingIP, f.IP, f.IP)
+	}
+	for _, k := range inv.Keys {
+		g.add(Key, strconv.Itoa(k.ID), k.Name)
+	}
+	for _, t := range inv.Tags {
+		g.add(Tag, t.Name, t.Name)
+	}
+	for _, lb := range inv.LoadBalancers {
+		g.add(LoadBalancer, lb.ID, lb.Name)
+	}
+	for _, fw := range inv.Firewalls {
+		g.add(Firewall, fw.ID, fw.Name)
+	}
+
+	tagged := make(map[string][]string) // tag -> droplet IDs
+	addrs := make(map[string]string)    // IP address -> node
+	for _, d := range inv.Droplets {
+		id := strconv.Itoa(d.ID)
+		for _, vid := range d.VolumeIDs {
+			g.link(Ref(Droplet, id), Ref(Volume, vid), Uses, "")
+		}
+		for _, tag := range d.Tags {
+			g.link(Ref(Droplet, id), Ref(Tag, tag), Tagged, "")
+			tagged[tag] = append(tagged[tag], id)
+		}
+		if d.Image != nil {
+			g.link(Ref(Droplet, id), Ref(Image, strconv.Itoa(d.Image.ID)), CreatedFrom, "")
+		}
+		for _, addr := range dropletAddrs(&d) {
+			addrs[addr] = Ref(Droplet, id)
+		}
+	}
+	for _, v := range inv.Volumes {
+		// a volume knows of the droplets it's attached to, even when the
+		// droplets don't list it yet
+		for _, did := range v.DropletIDs {
+			g.link(Ref(Droplet, strconv.Itoa(did)), Ref(Volume, v.ID), Uses, "")
+		}
+	}
+	for _, s := range inv.Snapshots {
+		g.link(Ref(Snapshot, s.ID), Ref(s.ResourceType, s.ResourceID), SnapshotOf, "")
+	}
+	for _, f := range inv.FloatingIPs {
+		addrs[f.IP] = Ref(FloatingIP, f.IP)
+		if f.Droplet != nil {
+			g.link(Ref(FloatingIP, f.IP), Ref(Droplet, strconv.Itoa(f.Droplet.ID)), AssignedTo, "")
+		}
+	}
+	for _, lb := range inv.LoadBalancers {
+		from := Ref(LoadBalancer, lb.ID)
+		for _, did := range lb.DropletIDs {
+			g.link(from, Ref(Droplet, strconv.Itoa(did)), Targets, "")
+		}
+		if lb.Tag != "" {
+			g.linkTag(from, lb.Tag, Targets, tagged)
+		}
+	}
+	for _, fw := range inv.Firewalls {
+		from := Ref(Firewall, fw.ID)
+		for _, did := range fw.DropletIDs {
+			g.link(from, Ref(Droplet, strconv.Itoa(did)), AppliesTo, "")
+		}
+		for _, tag := range fw.Tags {
+			g.linkTag(from, tag, AppliesTo, tagged)
+		}
+	}
+	for _, d := range inv.Domains {
+		for _, r := range d.Records {
+			if r.Type != "A" && r.Type != "AAAA" {
+				continue
+			}
+			if to, ok := addrs[r.Data]; ok {
+				g.link(Ref(Domain, d.Name), to, PointsTo, "record:"+r.Name)
+			}
+		}
+	}
+	return g
+}
+
+func dropletAddrs(d *godo.Droplet) []string {
+	if d.Networks == nil {
+		return nil
+	}
+	var addrs []string
+	for _, v4 := range d.Networks.V4 {
+		if v4.Type == "public" {
+			addrs = append(addrs, v4.IPAddress)
+		}
+	}
+	for _, v6 := range d.Networks.V6 {
+		if v6.Type == "public" {
+			addrs = append(addrs, v6.IPAddress)
+		}
+	}
+	return addrs
+}
+
+func (g *Graph) add(kind, id, name string) {
+	n := Node{Kind: kind, ID: id, Name: name}
+	if _, ok := g.nodes[n.Ref()]; !ok {
+		g.refs = append(g.refs, n.Ref())
+	}
+	g.nodes[n.Ref()] = n
+}
+
+// link adds an edge between two nodes of the graph, once.
+func (g *Graph) link(from, to, rel, via string) {
+	fn, ok := g.nodes[from]
+	if !ok {
+		return
+	}
+	tn, ok := g.nodes[to]
+	if !ok {
+		return
+	}
+	for _, e := range g.out[from] {
+		if e.To.Ref() == to && e.Rel == rel && e.Via == via {
+			return
+		}
+	}
+	e := Edge{From: fn, To: tn, Rel: rel, Via: via}
+	g.out[from] = append(g.out[from], e)
+	g.in[to] = append(g.in[to], e)
+}
+
+// linkTag links a resource to a tag, and to every droplet carrying it.
+func (g *Graph) linkTag(from, tag, rel string, tagged map[string][]string) {
+	g.link(from, Ref(Tag, tag), rel, "")
+	for _, did := range tagged[tag] {
+		g.link(from, Ref(Droplet, did), rel, Ref(Tag, tag))
+	}
+}
+
+// Node finds a node given its reference.
+func (g *Graph) Node(ref string) (Node, bool) {
+	n, ok := g.nodes[ref]
+	return n, ok
+}
+
+// Nodes are all the resources of the graph, in the order they were added.
+func (g *Graph) Nodes() []Node {
+	nodes := make([]Node, 0, len(g.refs))
+	for _, ref := range g.refs {
+		nodes = append(nodes, g.nodes[ref])
+	}
+	return nodes
+}
+
+// Edges are all the references between resources.
+func (g *Graph) Edges() []Edge {
+	edges := []Edge{}
+	for _, ref := range g.refs {
+		edges = append(edges, g.out[ref]...)
+	}
+	return edges
+}
+
+// Dependencies are the references from a resource to others, optionally
+// only to resources of the given kinds.
+func (g *Graph) Dependencies(ref string, kinds ...string) []Edge {
+	return filter(g.out[ref], func(e Edge) bool { return ofKind(e.To, kinds) })
+}
+
+// Dependents are the references to a resource from others, optionally only
+// from resources of the given kinds.
+func (g *Graph) Dependents(ref string, kinds ...string) []Edge {
+	return filter(g.in[ref], func(e Edge) bool { return ofKind(e.From, kinds) })
+}
+
+// Impact tells which resources would break if the given one was deleted.
+// Snapshots of it and resources that merely carry it, like tags, aren't
+// impacted.
+func (g *Graph) Impact(ref string) []Edge {
+	return filter(g.in[ref], func(e Edge) bool { return breaking[e.Rel] })
+}
+
+// FirewallsFor are the firewalls that apply to a droplet, be it directly or
+// through one of its tags.
+func (g *Graph) FirewallsFor(dropletID int) []Edge {
+	return g.Dependents(Ref(Droplet, strconv.Itoa(dropletID)), Firewall)
+}
+
+func ofKind(n Node, kinds []string) bool {
+	if len(kinds) == 0 {
+		return true
+	}
+	for _, k := range kinds {
+		if n.Kind == k {
+			return true
+		}
+	}
+	return false
+}
+
+func filter(edges []Edge, keep func(Edge) bool) []Edge {
+	out := []Edge{}
+	for _, e := range edges {
+		if keep(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language, as in
+// `dot -Tsvg graph.dot > graph.svg`.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	ew := &errWriter{w: w}
+	ew.printf("digraph account {\n")
+	ew.printf("  rankdir=LR;\n")
+	ew.printf("  node [shape=box];\n")
+
+	byKind := make(map[string][]Node)
+	var kinds []string
+	for _, n := range g.Nodes() {
+		if _, ok := byKind[n.Kind]; !ok {
+			kinds = append(kinds, n.Kind)
+		}
+		byKind[n.Kind] = append(byKind[n.Kind], n)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		ew.printf("  subgraph %s {\n", quote("cluster_"+kind))
+		ew.printf("    label=%s;\n", quote(kind))
+		for _, n := range byKind[kind] {
+			label := n.Kind + "\n" + n.ID
+			if n.Name != "" && n.Name != n.ID {
+				label = n.Kind + "\n" + n.Name + "\n" + n.ID
+			}
+			ew.printf("    %s [label=%s];\n", quote(n.Ref()), quote(label))
+		}
+		ew.printf("  }\n")
+	}
+	for _, e := range g.Edges() {
+		label := e.Rel
+		style := ""
+		if e.Via != "" {
+			label += "\nvia " + e.Via
+			style = ", style=dashed"
+		}
+		ew.printf("  %s -> %s [label=%s%s];\n", quote(e.From.Ref()), quote(e.To.Ref()), quote(label), style)
+	}
+	ew.printf("}\n")
+	return ew.err
+}
+
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
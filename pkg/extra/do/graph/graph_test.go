@@ -0,0 +1,230 @@
+package graph
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/inventory"
+	"github.com/digitalocean/godo"
+)
+
+func publicIP(ip string) *godo.Networks {
+	return &godo.Networks{V4: []godo.NetworkV4{{IPAddress: ip, Type: "public"}}}
+}
+
+func account() *inventory.Inventory {
+	return &inventory.Inventory{
+		Droplets: []godo.Droplet{
+			{ID: 1, Name: "web-1", Tags: []string{"web"}, VolumeIDs: []string{"vol-a"}, Image: &godo.Image{ID: 100}, Networks: publicIP("192.0.2.10")},
+			// public images aren't in the inventory
+			{ID: 2, Name: "web-2", Tags: []string{"web"}, Image: &godo.Image{ID: 999}},
+			{ID: 3, Name: "db-1"},
+		},
+		Volumes: []godo.Volume{
+			{ID: "vol-a", Name: "assets", DropletIDs: []int{1}},
+			// attached, but droplet 3 doesn't list it yet
+			{ID: "vol-b", Name: "pg-data", DropletIDs: []int{3}},
+		},
+		Snapshots: []godo.Snapshot{
+			{ID: "snap-1", Name: "web-1-backup", ResourceType: "droplet", ResourceID: "1"},
+			{ID: "snap-2", Name: "assets-backup", ResourceType: "volume", ResourceID: "vol-a"},
+		},
+		Images: []godo.Image{{ID: 100, Name: "web-base"}},
+		Domains: []inventory.Domain{{
+			Domain: godo.Domain{Name: "example.com"},
+			Records: []godo.DomainRecord{
+				{Type: "A", Name: "@", Data: "192.0.2.10"},
+				{Type: "A", Name: "db", Data: "192.0.2.20"},
+				{Type: "CNAME", Name: "www", Data: "@"},
+				{Type: "A", Name: "elsewhere", Data: "198.51.100.1"},
+			},
+		}},
+		FloatingIPs: []godo.FloatingIP{{IP: "192.0.2.20", Droplet: &godo.Droplet{ID: 3}}},
+		Keys:        []godo.Key{{ID: 7, Name: "deploy"}},
+		Tags:        []godo.Tag{{Name: "web"}},
+		LoadBalancers: []godo.LoadBalancer{
+			{ID: "lb-1", Name: "www", Tag: "web"},
+			{ID: "lb-2", Name: "db", DropletIDs: []int{3}},
+		},
+		Firewalls: []godo.Firewall{
+			{ID: "fw-1", Name: "http", Tags: []string{"web"}},
+			{ID: "fw-2", Name: "pg", DropletIDs: []int{3}},
+		},
+	}
+}
+
+// edges formats edges as "from rel to", followed by how they're made if
+// they're indirect.
+func edges(es []Edge) []string {
+	out := []string{}
+	for _, e := range es {
+		s := e.From.Ref() + " " + e.Rel + " " + e.To.Ref()
+		if e.Via != "" {
+			s += " via " + e.Via
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func wantEdges(t *testing.T, what string, want []string, got []Edge) {
+	t.Helper()
+	if !reflect.DeepEqual(want, edges(got)) {
+		t.Errorf("%s: want\n%q\ngot\n%q", what, want, edges(got))
+	}
+}
+
+func TestBuild(t *testing.T) {
+	g := Build(account())
+
+	if want, got := 16, len(g.Nodes()); want != got {
+		t.Fatalf("want %d nodes got %d", want, got)
+	}
+	n, ok := g.Node("volume:vol-b")
+	if !ok {
+		t.Fatal("want volume vol-b to be a node")
+	}
+	if want := (Node{Kind: Volume, ID: "vol-b", Name: "pg-data"}); n != want {
+		t.Fatalf("want %#v got %#v", want, n)
+	}
+
+	wantEdges(t, "edges", []string{
+		"droplet:1 uses volume:vol-a",
+		"droplet:1 tagged tag:web",
+		"droplet:1 created_from image:100",
+		"droplet:2 tagged tag:web",
+		"droplet:3 uses volume:vol-b",
+		"snapshot:snap-1 snapshot_of droplet:1",
+		"snapshot:snap-2 snapshot_of volume:vol-a",
+		"domain:example.com points_to droplet:1 via record:@",
+		"domain:example.com points_to floating_ip:192.0.2.20 via record:db",
+		"floating_ip:192.0.2.20 assigned_to droplet:3",
+		"load_balancer:lb-1 targets tag:web",
+		"load_balancer:lb-1 targets droplet:1 via tag:web",
+		"load_balancer:lb-1 targets droplet:2 via tag:web",
+		"load_balancer:lb-2 targets droplet:3",
+		"firewall:fw-1 applies_to tag:web",
+		"firewall:fw-1 applies_to droplet:1 via tag:web",
+		"firewall:fw-1 applies_to droplet:2 via tag:web",
+		"firewall:fw-2 applies_to droplet:3",
+	}, g.Edges())
+}
+
+func TestDependents(t *testing.T) {
+	g := Build(account())
+
+	wantEdges(t, "dependents of droplet 1", []string{
+		"snapshot:snap-1 snapshot_of droplet:1",
+		"load_balancer:lb-1 targets droplet:1 via tag:web",
+		"firewall:fw-1 applies_to droplet:1 via tag:web",
+		"domain:example.com points_to droplet:1 via record:@",
+	}, g.Dependents("droplet:1"))
+	wantEdges(t, "load balancers of droplet 3", []string{
+		"load_balancer:lb-2 targets droplet:3",
+	}, g.Dependents("droplet:3", LoadBalancer))
+	wantEdges(t, "dependencies of droplet 1", []string{
+		"droplet:1 uses volume:vol-a",
+		"droplet:1 created_from image:100",
+	}, g.Dependencies("droplet:1", Volume, Image))
+	wantEdges(t, "dependents of an unknown droplet", []string{}, g.Dependents("droplet:404"))
+}
+
+func TestImpact(t *testing.T) {
+	g := Build(account())
+
+	for _, tt := range []struct {
+		ref  string
+		want []string
+	}{
+		{"droplet:1", []string{
+			"load_balancer:lb-1 targets droplet:1 via tag:web",
+			"firewall:fw-1 applies_to droplet:1 via tag:web",
+			"domain:example.com points_to droplet:1 via record:@",
+		}},
+		// its snapshot outlives it
+		{"volume:vol-a", []string{
+			"droplet:1 uses volume:vol-a",
+		}},
+		{"volume:vol-b", []string{
+			"droplet:3 uses volume:vol-b",
+		}},
+		// the droplets carrying it don't need it
+		{"tag:web", []string{
+			"load_balancer:lb-1 targets tag:web",
+			"firewall:fw-1 applies_to tag:web",
+		}},
+		{"floating_ip:192.0.2.20", []string{
+			"domain:example.com points_to floating_ip:192.0.2.20 via record:db",
+		}},
+		{"droplet:3", []string{
+			"floating_ip:192.0.2.20 assigned_to droplet:3",
+			"load_balancer:lb-2 targets droplet:3",
+			"firewall:fw-2 applies_to droplet:3",
+		}},
+		{"image:100", []string{}},
+		{"key:7", []string{}},
+	} {
+		wantEdges(t, "impact of "+tt.ref, tt.want, g.Impact(tt.ref))
+	}
+}
+
+func TestFirewallsFor(t *testing.T) {
+	g := Build(account())
+
+	wantEdges(t, "firewalls of droplet 2", []string{
+		"firewall:fw-1 applies_to droplet:2 via tag:web",
+	}, g.FirewallsFor(2))
+	wantEdges(t, "firewalls of droplet 3", []string{
+		"firewall:fw-2 applies_to droplet:3",
+	}, g.FirewallsFor(3))
+	wantEdges(t, "firewalls of an unknown droplet", []string{}, g.FirewallsFor(404))
+}
+
+func TestWriteDOT(t *testing.T) {
+	g := Build(&inventory.Inventory{
+		Droplets:  []godo.Droplet{{ID: 1, Name: "web-1", Tags: []string{"web"}}},
+		Tags:      []godo.Tag{{Name: "web"}},
+		Firewalls: []godo.Firewall{{ID: "fw-1", Name: "http", Tags: []string{"web"}}},
+	})
+
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph account {
+  rankdir=LR;
+  node [shape=box];
+  subgraph "cluster_droplet" {
+    label="droplet";
+    "droplet:1" [label="droplet\nweb-1\n1"];
+  }
+  subgraph "cluster_firewall" {
+    label="firewall";
+    "firewall:fw-1" [label="firewall\nhttp\nfw-1"];
+  }
+  subgraph "cluster_tag" {
+    label="tag";
+    "tag:web" [label="tag\nweb"];
+  }
+  "droplet:1" -> "tag:web" [label="tagged"];
+  "firewall:fw-1" -> "tag:web" [label="applies_to"];
+  "firewall:fw-1" -> "droplet:1" [label="applies_to\nvia tag:web", style=dashed];
+}
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("want\n%s\ngot\n%s", want, got)
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestWriteDOTError(t *testing.T) {
+	g := Build(account())
+	if err := g.WriteDOT(failWriter{}); err == nil || err.Error() != "disk full" {
+		t.Fatalf("want the error of the writer, got %v", err)
+	}
+}
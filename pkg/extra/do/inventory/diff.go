@@ -0,0 +1,210 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// The operations of a Change.
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// A Change to a resource between two inventories.
+type Change struct {
+	Op     string        `json:"op"`
+	Kind   string        `json:"kind"`
+	ID     string        `json:"id"`
+	Name   string        `json:"name,omitempty"`
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// A FieldChange is a field of a resource that has a different value. Nested
+// fields have a path like `networks.v4[0].ip_address`, or like
+// `records[id=12].data` for elements that have an ID.
+type FieldChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// the kinds of resources in an inventory, and the field identifying them
+var kinds = []struct {
+	name string
+	key  string
+}{
+	{"droplets", "id"},
+	{"volumes", "id"},
+	{"snapshots", "id"},
+	{"images", "id"},
+	{"domains", "name"},
+	{"floating_ips", "ip"},
+	{"keys", "id"},
+	{"tags", "name"},
+	{"load_balancers", "id"},
+	{"firewalls", "id"},
+}
+
+// Diff tells which resources were added, removed or changed from a to b.
+func Diff(a, b *Inventory) ([]Change, error) {
+	adoc, err := toDoc(a)
+	if err != nil {
+		return nil, err
+	}
+	bdoc, err := toDoc(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for _, kind := range kinds {
+		before := index(adoc[kind.name], kind.key)
+		after := index(bdoc[kind.name], kind.key)
+
+		var ids []string
+		for id := range before {
+			ids = append(ids, id)
+		}
+		for id := range after {
+			if _, ok := before[id]; !ok {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			old, hadOld := before[id]
+			cur, hasCur := after[id]
+			switch {
+			case !hadOld:
+				changes = append(changes, Change{Op: Added, Kind: kind.name, ID: id, Name: nameOf(cur)})
+			case !hasCur:
+				changes = append(changes, Change{Op: Removed, Kind: kind.name, ID: id, Name: nameOf(old)})
+			default:
+				if fields := diffFields(old, cur); len(fields) > 0 {
+					changes = append(changes, Change{Op: Changed, Kind: kind.name, ID: id, Name: nameOf(cur), Fields: fields})
+				}
+			}
+		}
+	}
+	return changes, nil
+}
+
+// toDoc turns an inventory into the generic form of its JSON document.
+func toDoc(inv *Inventory) (map[string]interface{}, error) {
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // IDs stay as they are, rather than as floats
+	var doc map[string]interface{}
+	return doc, dec.Decode(&doc)
+}
+
+func index(v interface{}, key string) map[string]map[string]interface{} {
+	out := make(map[string]map[string]interface{})
+	items, _ := v.([]interface{})
+	for _, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		out[fmt.Sprint(obj[key])] = obj
+	}
+	return out
+}
+
+func nameOf(obj map[string]interface{}) string {
+	name, _ := obj["name"].(string)
+	return name
+}
+
+func diffFields(a, b map[string]interface{}) []FieldChange {
+	before, after := make(map[string]interface{}), make(map[string]interface{})
+	flatten("", a, before)
+	flatten("", b, after)
+
+	var paths []string
+	for path := range before {
+		paths = append(paths, path)
+	}
+	for path := range after {
+		if _, ok := before[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var fields []FieldChange
+	for _, path := range paths {
+		old, cur := before[path], after[path]
+		if !reflect.DeepEqual(old, cur) {
+			fields = append(fields, FieldChange{Path: path, Old: old, New: cur})
+		}
+	}
+	return fields
+}
+
+// flatten collects the leaf values of v by path. Arrays of plain values,
+// like tags, are kept whole. Elements of other arrays are found by their ID
+// when they all have one, so that reordering them changes nothing.
+func flatten(path string, v interface{}, out map[string]interface{}) {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		for k, el := range tv {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			flatten(p, el, out)
+		}
+	case []interface{}:
+		plain := true
+		for _, el := range tv {
+			switch el.(type) {
+			case map[string]interface{}, []interface{}:
+				plain = false
+			}
+		}
+		if plain {
+			out[path] = tv
+			return
+		}
+		ids, ok := elementIDs(tv)
+		for i, el := range tv {
+			if ok {
+				flatten(fmt.Sprintf("%s[id=%s]", path, ids[i]), el, out)
+			} else {
+				flatten(fmt.Sprintf("%s[%d]", path, i), el, out)
+			}
+		}
+	default:
+		out[path] = tv
+	}
+}
+
+// elementIDs gives the IDs of the elements of an array, if they are objects
+// that all have a distinct one.
+func elementIDs(arr []interface{}) ([]string, bool) {
+	ids := make([]string, len(arr))
+	seen := make(map[string]bool, len(arr))
+	for i, el := range arr {
+		obj, ok := el.(map[string]interface{})
+		if !ok || obj["id"] == nil {
+			return nil, false
+		}
+		id := fmt.Sprint(obj["id"])
+		if seen[id] {
+			return nil, false
+		}
+		seen[id] = true
+		ids[i] = id
+	}
+	return ids, true
+}
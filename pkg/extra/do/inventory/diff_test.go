@@ -0,0 +1,80 @@
+package inventory
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/digitalocean/godo"
+)
+
+func TestDiff(t *testing.T) {
+	a := &Inventory{
+		Droplets: []godo.Droplet{
+			{ID: 1, Name: "web-1", Memory: 1024},
+			{ID: 2, Name: "web-2", Memory: 1024},
+		},
+		Domains: []Domain{{
+			Domain: godo.Domain{Name: "example.com"},
+			Records: []godo.DomainRecord{
+				{ID: 10, Type: "A", Name: "www", Data: "10.0.0.1"},
+				{ID: 11, Type: "A", Name: "api", Data: "10.0.0.2"},
+			},
+		}},
+	}
+	b := &Inventory{
+		Droplets: []godo.Droplet{
+			{ID: 1, Name: "web-1", Memory: 2048},
+			{ID: 3, Name: "db-1", Memory: 4096},
+		},
+		Domains: []Domain{{
+			Domain: godo.Domain{Name: "example.com"},
+			Records: []godo.DomainRecord{
+				{ID: 12, Type: "A", Name: "mail", Data: "10.0.0.3"},
+				{ID: 11, Type: "A", Name: "api", Data: "10.0.0.4"},
+				{ID: 10, Type: "A", Name: "www", Data: "10.0.0.1"},
+			},
+		}},
+	}
+
+	got, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Op: Changed, Kind: "droplets", ID: "1", Name: "web-1", Fields: []FieldChange{
+			{Path: "memory", Old: json.Number("1024"), New: json.Number("2048")},
+		}},
+		{Op: Removed, Kind: "droplets", ID: "2", Name: "web-2"},
+		{Op: Added, Kind: "droplets", ID: "3", Name: "db-1"},
+		{Op: Changed, Kind: "domains", ID: "example.com", Name: "example.com", Fields: []FieldChange{
+			{Path: "records[id=11].data", Old: "10.0.0.2", New: "10.0.0.4"},
+			{Path: "records[id=12].data", New: "10.0.0.3"},
+			{Path: "records[id=12].flags", New: json.Number("0")},
+			{Path: "records[id=12].id", New: json.Number("12")},
+			{Path: "records[id=12].name", New: "mail"},
+			{Path: "records[id=12].type", New: "A"},
+		}},
+	}
+	if !reflect.DeepEqual(want, got) {
+		wantJSON, _ := json.MarshalIndent(want, "", "  ")
+		gotJSON, _ := json.MarshalIndent(got, "", "  ")
+		t.Fatalf("want\n%s\ngot\n%s", wantJSON, gotJSON)
+	}
+
+	if changes, err := Diff(a, a); err != nil || len(changes) != 0 {
+		t.Fatalf("want no changes between an inventory and itself, got %v, %v", changes, err)
+	}
+}
+
+func TestFlattenWithoutIDs(t *testing.T) {
+	out := make(map[string]interface{})
+	flatten("rules", []interface{}{
+		map[string]interface{}{"port": 80},
+		map[string]interface{}{"port": 443},
+	}, out)
+	want := map[string]interface{}{"rules[0].port": 80, "rules[1].port": 443}
+	if !reflect.DeepEqual(want, out) {
+		t.Fatalf("want %v, got %v", want, out)
+	}
+}
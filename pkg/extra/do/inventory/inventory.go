@@ -0,0 +1,212 @@
+// Package inventory takes snapshots of all the resources of an account, and
+// compares them.
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/images"
+	"github.com/digitalocean/godo"
+)
+
+// Version of the inventory documents. It changes when a document can't be
+// read the same way anymore.
+const Version = 1
+
+// An Inventory is a snapshot of all the resources of an account.
+type Inventory struct {
+	Version int       `json:"version"`
+	TakenAt time.Time `json:"taken_at"`
+
+	Droplets      []godo.Droplet      `json:"droplets"`
+	Volumes       []godo.Volume       `json:"volumes"`
+	Snapshots     []godo.Snapshot     `json:"snapshots"`
+	Images        []godo.Image        `json:"images"`
+	Domains       []Domain            `json:"domains"`
+	FloatingIPs   []godo.FloatingIP   `json:"floating_ips"`
+	Keys          []godo.Key          `json:"keys"`
+	Tags          []godo.Tag          `json:"tags"`
+	LoadBalancers []godo.LoadBalancer `json:"load_balancers"`
+	Firewalls     []godo.Firewall     `json:"firewalls"`
+}
+
+// A Domain and its records.
+type Domain struct {
+	godo.Domain
+	Records []godo.DomainRecord `json:"records"`
+}
+
+// Take lists every resource of the account. The resource types are listed
+// concurrently, and the first error aborts the inventory.
+func Take(ctx context.Context, client cloud.Client) (*Inventory, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	inv := &Inventory{Version: Version, TakenAt: time.Now().UTC()}
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for _, list := range []struct {
+		kind string
+		fn   func() error
+	}{
+		{"droplets", func() (err error) { inv.Droplets, err = listDroplets(ctx, client); return }},
+		{"volumes", func() (err error) { inv.Volumes, err = listVolumes(ctx, client); return }},
+		{"snapshots", func() (err error) { inv.Snapshots, err = listSnapshots(ctx, client); return }},
+		{"images", func() (err error) { inv.Images, err = listImages(ctx, client); return }},
+		{"domains", func() (err error) { inv.Domains, err = listDomains(ctx, client); return }},
+		{"floating IPs", func() (err error) { inv.FloatingIPs, err = listFloatingIPs(ctx, client); return }},
+		{"keys", func() (err error) { inv.Keys, err = listKeys(ctx, client); return }},
+		{"tags", func() (err error) { inv.Tags, err = listTags(ctx, client); return }},
+		{"load balancers", func() (err error) { inv.LoadBalancers, err = listLoadBalancers(ctx, client); return }},
+		{"firewalls", func() (err error) { inv.Firewalls, err = listFirewalls(ctx, client); return }},
+	} {
+		wg.Add(1)
+		go func(kind string, fn func() error) {
+			defer wg.Done()
+			if err := fn(); err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("listing %s: %v", kind, err)
+					cancel()
+				})
+			}
+		}(list.kind, list.fn)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return inv, nil
+}
+
+// Load reads an inventory written as JSON.
+func Load(r io.Reader) (*Inventory, error) {
+	inv := new(Inventory)
+	if err := json.NewDecoder(r).Decode(inv); err != nil {
+		return nil, err
+	}
+	if inv.Version < 1 || inv.Version > Version {
+		return nil, fmt.Errorf("unsupported inventory version %d, want at most %d", inv.Version, Version)
+	}
+	return inv, nil
+}
+
+func listDroplets(ctx context.Context, client cloud.Client) ([]godo.Droplet, error) {
+	out := []godo.Droplet{}
+	itemc, errc := client.Droplets().List(ctx)
+	for item := range itemc {
+		out = append(out, *item.Struct())
+	}
+	return out, <-errc
+}
+
+func listVolumes(ctx context.Context, client cloud.Client) ([]godo.Volume, error) {
+	out := []godo.Volume{}
+	itemc, errc := client.Volumes().ListVolumes(ctx)
+	for item := range itemc {
+		out = append(out, *item.Struct())
+	}
+	return out, <-errc
+}
+
+func listSnapshots(ctx context.Context, client cloud.Client) ([]godo.Snapshot, error) {
+	out := []godo.Snapshot{}
+	itemc, errc := client.Snapshots().List(ctx)
+	for item := range itemc {
+		out = append(out, *item.Struct())
+	}
+	return out, <-errc
+}
+
+// listImages only lists the images of the user, the public ones being the
+// same for every account.
+func listImages(ctx context.Context, client cloud.Client) ([]godo.Image, error) {
+	out := []godo.Image{}
+	itemc, errc := client.Images().List(ctx, images.FilterPrivate(true))
+	for item := range itemc {
+		out = append(out, *item.Struct())
+	}
+	return out, <-errc
+}
+
+func listDomains(ctx context.Context, client cloud.Client) ([]Domain, error) {
+	var names []godo.Domain
+	itemc, errc := client.Domains().List(ctx)
+	for item := range itemc {
+		names = append(names, *item.Struct())
+	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+
+	out := []Domain{}
+	for _, d := range names {
+		records := []godo.DomainRecord{}
+		recordc, errc := client.Domains().ListRecord(ctx, d.Name)
+		for r := range recordc {
+			records = append(records, *r.Struct())
+		}
+		if err := <-errc; err != nil {
+			return nil, err
+		}
+		// sorted, such that inventories can be compared
+		sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+		out = append(out, Domain{Domain: d, Records: records})
+	}
+	return out, nil
+}
+
+func listFloatingIPs(ctx context.Context, client cloud.Client) ([]godo.FloatingIP, error) {
+	out := []godo.FloatingIP{}
+	itemc, errc := client.FloatingIPs().List(ctx)
+	for item := range itemc {
+		out = append(out, *item.Struct())
+	}
+	return out, <-errc
+}
+
+func listKeys(ctx context.Context, client cloud.Client) ([]godo.Key, error) {
+	out := []godo.Key{}
+	itemc, errc := client.Keys().List(ctx)
+	for item := range itemc {
+		out = append(out, *item.Struct())
+	}
+	return out, <-errc
+}
+
+func listTags(ctx context.Context, client cloud.Client) ([]godo.Tag, error) {
+	out := []godo.Tag{}
+	itemc, errc := client.Tags().List(ctx)
+	for item := range itemc {
+		out = append(out, *item.Struct())
+	}
+	return out, <-errc
+}
+
+func listLoadBalancers(ctx context.Context, client cloud.Client) ([]godo.LoadBalancer, error) {
+	out := []godo.LoadBalancer{}
+	itemc, errc := client.LoadBalancers().List(ctx)
+	for item := range itemc {
+		out = append(out, *item.Struct())
+	}
+	return out, <-errc
+}
+
+func listFirewalls(ctx context.Context, client cloud.Client) ([]godo.Firewall, error) {
+	out := []godo.Firewall{}
+	itemc, errc := client.Firewalls().List(ctx)
+	for item := range itemc {
+		out = append(out, *item.Struct())
+	}
+	return out, <-errc
+}
@@ -0,0 +1,378 @@
+// Package janitor finds the resources of an account that are unused, given
+// rules like "droplets named ci-* older than 6h", and deletes them.
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/godoutil"
+	"github.com/digitalocean/godo"
+)
+
+// A Candidate is a resource matched by a rule. It's kept when Skip tells
+// why it can't be deleted safely.
+type Candidate struct {
+	Kind string   `json:"kind"`
+	ID   string   `json:"id"`
+	Name string   `json:"name,omitempty"`
+	Rule string   `json:"rule"`
+	Age  Duration `json:"age"`
+	Skip string   `json:"skip,omitempty"`
+
+	after []string // droplets to wait on being deleted, as it's attached to them
+}
+
+// A Plan are the candidates for deletion, in the order they'd be deleted.
+type Plan struct {
+	Candidates []Candidate `json:"candidates"`
+}
+
+// A Result of deleting a candidate.
+type Result struct {
+	Candidate
+	Err error `json:"-"`
+}
+
+// A Janitor evaluates rules against the resources of an account.
+type Janitor struct {
+	client cloud.Client
+	rules  []Rule
+	state  *State
+	now    func() time.Time
+}
+
+// Option configures a janitor.
+type Option func(*Janitor)
+
+// UseState keeps track, across runs, of when floating IPs were first seen
+// unassigned. Without it, unassigned floating IPs are always new.
+func UseState(s *State) Option {
+	return func(j *Janitor) { j.state = s }
+}
+
+// UseClock tells the time with now.
+func UseClock(now func() time.Time) Option {
+	return func(j *Janitor) { j.now = now }
+}
+
+// New janitor for the account of client, with DefaultRules if none are
+// given.
+func New(client cloud.Client, rules []Rule, opts ...Option) (*Janitor, error) {
+	if len(rules) == 0 {
+		rules = DefaultRules
+	}
+	for i := range rules {
+		if err := rules[i].check(); err != nil {
+			return nil, fmt.Errorf("rule %q: %v", rules[i].Name, err)
+		}
+	}
+	j := &Janitor{client: client, rules: rules, state: NewState(), now: time.Now}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j, nil
+}
+
+// the order in which kinds are deleted: droplets first, as they hold volumes
+// and floating IPs, then snapshots, as volumes can't be deleted while they
+// have some
+var deleteOrder = []string{Droplets, FloatingIPs, Snapshots, Volumes}
+
+// resource is what rules are evaluated against.
+type resource struct {
+	kind     string
+	id       string
+	name     string
+	created  time.Time // zero when unknown
+	attached []string  // IDs of the droplets it's attached to
+	tags     []string
+	source   string // ID of the droplet a snapshot was taken of
+	volume   string // ID of the volume a snapshot was taken of
+}
+
+// Plan lists the resources of the account and tells which ones the rules
+// match. A resource matched by many rules is a candidate once, for the first
+// rule.
+func (j *Janitor) Plan(ctx context.Context) (*Plan, error) {
+	now := j.now()
+	need := make(map[string]bool)
+	for _, rule := range j.rules {
+		need[rule.Kind] = true
+		if rule.Kind == Snapshots && len(rule.ExceptTags) > 0 {
+			need[Droplets] = true
+		}
+		if rule.Kind == Volumes {
+			// volumes with snapshots can't be deleted
+			need[Snapshots] = true
+		}
+	}
+	listed := make(map[string][]resource)
+	for _, kind := range deleteOrder {
+		if !need[kind] {
+			continue
+		}
+		resources, err := j.list(ctx, kind)
+		if err != nil {
+			return nil, fmt.Errorf("listing %s: %v", kind, err)
+		}
+		listed[kind] = resources
+	}
+	j.state.update(listed[FloatingIPs], now)
+
+	dropletTags := make(map[string][]string)
+	for _, d := range listed[Droplets] {
+		dropletTags[d.id] = d.tags
+	}
+	volumeSnapshots := make(map[string][]string)
+	for _, s := range listed[Snapshots] {
+		if s.volume != "" {
+			volumeSnapshots[s.volume] = append(volumeSnapshots[s.volume], s.id)
+		}
+	}
+
+	plan := &Plan{Candidates: []Candidate{}}
+	seen := make(map[string]bool)
+	deleted := make(map[string]bool) // "kind:id" of what will be deleted
+	for _, kind := range deleteOrder {
+		var candidates []Candidate
+		for _, r := range listed[kind] {
+			for _, rule := range j.rules {
+				if rule.Kind != kind || seen[kind+":"+r.id] {
+					continue
+				}
+				age, skip, ok := j.match(&rule, &r, dropletTags, now)
+				if !ok {
+					continue
+				}
+				seen[kind+":"+r.id] = true
+				c := Candidate{Kind: kind, ID: r.id, Name: r.name, Rule: rule.Name, Age: age, Skip: skip}
+				for _, did := range r.attached {
+					if c.Skip == "" && !deleted[Droplets+":"+did] {
+						c.Skip = fmt.Sprintf("attached to droplet %s", did)
+						break
+					}
+					c.after = append(c.after, did)
+				}
+				for _, sid := range volumeSnapshots[r.id] {
+					if c.Skip == "" && !deleted[Snapshots+":"+sid] {
+						c.Skip = fmt.Sprintf("has snapshot %s", sid)
+					}
+				}
+				candidates = append(candidates, c)
+			}
+		}
+		// oldest first
+		sort.SliceStable(candidates, func(i, k int) bool { return candidates[i].Age > candidates[k].Age })
+		for _, c := range candidates {
+			if c.Skip == "" {
+				deleted[kind+":"+c.ID] = true
+			}
+		}
+		plan.Candidates = append(plan.Candidates, candidates...)
+	}
+	return plan, nil
+}
+
+// match tells if the rule matches r, and the age it has. Matches whose
+// tags can't be told are kept, with the reason why.
+func (j *Janitor) match(rule *Rule, r *resource, dropletTags map[string][]string, now time.Time) (Duration, string, bool) {
+	if rule.NameMatches != "" {
+		if ok, _ := path.Match(rule.NameMatches, r.name); !ok {
+			return 0, "", false
+		}
+	}
+	if rule.Unattached && len(r.attached) > 0 {
+		return 0, "", false
+	}
+	var skip string
+	tags := r.tags
+	if r.kind == Snapshots && len(rule.ExceptTags) > 0 {
+		// snapshots have the tags of their droplet
+		var found bool
+		tags, found = dropletTags[r.source]
+		switch {
+		case r.volume != "":
+			skip = fmt.Sprintf("can't tell the tags of volume %s", r.volume)
+		case !found:
+			skip = fmt.Sprintf("can't tell the tags of droplet %s, which is gone", r.source)
+		}
+	}
+	for _, keep := range rule.ExceptTags {
+		for _, tag := range tags {
+			if tag == keep {
+				return 0, "", false
+			}
+		}
+	}
+	var age time.Duration
+	switch {
+	case r.kind == FloatingIPs:
+		age = now.Sub(j.state.unattachedSince(r.id, now))
+	case !r.created.IsZero():
+		age = now.Sub(r.created)
+	case rule.OlderThan > 0:
+		// can't tell, better keep it
+		return 0, "", false
+	}
+	if age < time.Duration(rule.OlderThan) {
+		return 0, "", false
+	}
+	return Duration(age.Truncate(time.Second)), skip, true
+}
+
+// Apply deletes the candidates of a plan that aren't skipped, in order. It
+// keeps going when a deletion fails. Volumes and floating IPs attached to
+// droplets are only deleted once the droplets are gone.
+func (j *Janitor) Apply(ctx context.Context, plan *Plan) []Result {
+	var results []Result
+	gone := make(map[string]error) // droplets waited on
+	for _, c := range plan.Candidates {
+		if c.Skip != "" {
+			continue
+		}
+		var err error
+		for _, did := range c.after {
+			werr, ok := gone[did]
+			if !ok {
+				werr = j.waitDeleted(ctx, did)
+				gone[did] = werr
+			}
+			if werr != nil {
+				err = fmt.Errorf("droplet %s it's attached to wasn't deleted: %v", did, werr)
+				break
+			}
+		}
+		if err == nil {
+			err = j.delete(ctx, c)
+		}
+		if err != nil && c.Kind == Droplets {
+			gone[c.ID] = err
+		}
+		results = append(results, Result{Candidate: c, Err: err})
+	}
+	return results
+}
+
+// waitDeleted waits until the droplet is gone, which is once the volumes
+// and floating IPs it had are released.
+func (j *Janitor) waitDeleted(ctx context.Context, dropletID string) error {
+	id, err := strconv.Atoi(dropletID)
+	if err != nil {
+		return err
+	}
+	return godoutil.WaitFor(ctx, func() (bool, error) {
+		_, err := j.client.Droplets().Get(ctx, id)
+		if e, ok := err.(*godo.ErrorResponse); ok && e.Response != nil && e.Response.StatusCode == http.StatusNotFound {
+			return true, nil
+		}
+		return false, err
+	}, godoutil.WaitMaxDuration(10*time.Minute))
+}
+
+func (j *Janitor) delete(ctx context.Context, c Candidate) error {
+	switch c.Kind {
+	case Droplets:
+		id, err := strconv.Atoi(c.ID)
+		if err != nil {
+			return err
+		}
+		return j.client.Droplets().Delete(ctx, id)
+	case FloatingIPs:
+		if err := j.client.FloatingIPs().Delete(ctx, c.ID); err != nil {
+			return err
+		}
+		j.state.forget(c.ID)
+		return nil
+	case Snapshots:
+		return j.client.Snapshots().Delete(ctx, c.ID)
+	case Volumes:
+		return j.client.Volumes().DeleteVolume(ctx, c.ID)
+	}
+	return fmt.Errorf("can't delete %s", c.Kind)
+}
+
+func (j *Janitor) list(ctx context.Context, kind string) ([]resource, error) {
+	var out []resource
+	switch kind {
+	case Droplets:
+		itemc, errc := j.client.Droplets().List(ctx)
+		for item := range itemc {
+			d := item.Struct()
+			out = append(out, resource{
+				kind:    kind,
+				id:      strconv.Itoa(d.ID),
+				name:    d.Name,
+				created: parseTime(d.Created),
+				tags:    d.Tags,
+			})
+		}
+		return out, <-errc
+	case Volumes:
+		itemc, errc := j.client.Volumes().ListVolumes(ctx)
+		for item := range itemc {
+			v := item.Struct()
+			out = append(out, resource{
+				kind:     kind,
+				id:       v.ID,
+				name:     v.Name,
+				created:  v.CreatedAt,
+				attached: intsToStrings(v.DropletIDs),
+			})
+		}
+		return out, <-errc
+	case Snapshots:
+		itemc, errc := j.client.Snapshots().List(ctx)
+		for item := range itemc {
+			s := item.Struct()
+			r := resource{
+				kind:    kind,
+				id:      s.ID,
+				name:    s.Name,
+				created: parseTime(s.Created),
+			}
+			switch s.ResourceType {
+			case "droplet":
+				r.source = s.ResourceID
+			case "volume":
+				r.volume = s.ResourceID
+			}
+			out = append(out, r)
+		}
+		return out, <-errc
+	case FloatingIPs:
+		itemc, errc := j.client.FloatingIPs().List(ctx)
+		for item := range itemc {
+			f := item.Struct()
+			r := resource{kind: kind, id: f.IP, name: f.IP}
+			if f.Droplet != nil {
+				r.attached = []string{strconv.Itoa(f.Droplet.ID)}
+			}
+			out = append(out, r)
+		}
+		return out, <-errc
+	}
+	return nil, fmt.Errorf("unknown kind %q", kind)
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func intsToStrings(ids []int) []string {
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, strconv.Itoa(id))
+	}
+	return out
+}
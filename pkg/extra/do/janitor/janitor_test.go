@@ -0,0 +1,365 @@
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/droplets"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/floatingips"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/snapshots"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/volumes"
+	"github.com/aybabtme/godotto/pkg/extra/do/mockcloud"
+	"github.com/aybabtme/godotto/pkg/extra/godoutil"
+	"github.com/digitalocean/godo"
+)
+
+type droplet struct{ *godo.Droplet }
+
+func (d *droplet) Struct() *godo.Droplet { return d.Droplet }
+
+type volume struct{ *godo.Volume }
+
+func (v *volume) Struct() *godo.Volume { return v.Volume }
+
+type snapshot struct{ *godo.Snapshot }
+
+func (s *snapshot) Struct() *godo.Snapshot { return s.Snapshot }
+
+type floatingIP struct{ *godo.FloatingIP }
+
+func (f *floatingIP) Struct() *godo.FloatingIP { return f.FloatingIP }
+
+func noErr() <-chan error {
+	errc := make(chan error)
+	close(errc)
+	return errc
+}
+
+var now = time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func ago(d time.Duration) string { return now.Add(-d).Format(time.RFC3339) }
+
+// fakeCloud records the deletions in deleted, as "kind:id".
+func fakeCloud(deleted *[]string) *mockcloud.Mock {
+	cloud := mockcloud.Client(nil)
+	cloud.MockDroplets.ListFn = func(context.Context, ...droplets.ListOpt) (<-chan droplets.Droplet, <-chan error) {
+		c := make(chan droplets.Droplet, 4)
+		c <- &droplet{&godo.Droplet{ID: 1, Name: "ci-old", Created: ago(8 * time.Hour)}}
+		c <- &droplet{&godo.Droplet{ID: 2, Name: "ci-older", Created: ago(10 * time.Hour)}}
+		c <- &droplet{&godo.Droplet{ID: 3, Name: "ci-new", Created: ago(time.Hour)}}
+		c <- &droplet{&godo.Droplet{ID: 4, Name: "db", Created: ago(400 * time.Hour), Tags: []string{"keep"}}}
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockVolumes.ListVolumesFn = func(context.Context, ...volumes.ListOpt) (<-chan volumes.Volume, <-chan error) {
+		c := make(chan volumes.Volume, 3)
+		c <- &volume{&godo.Volume{ID: "vol-free", Name: "free", CreatedAt: now.Add(-time.Hour)}}
+		c <- &volume{&godo.Volume{ID: "vol-ci", Name: "ci-data", CreatedAt: now.Add(-time.Hour)}}
+		c <- &volume{&godo.Volume{ID: "vol-db", Name: "db-data", CreatedAt: now.Add(-time.Hour), DropletIDs: []int{4}}}
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockSnapshots.ListFn = func(context.Context) (<-chan snapshots.Snapshot, <-chan error) {
+		c := make(chan snapshots.Snapshot, 3)
+		c <- &snapshot{&godo.Snapshot{ID: "s-old", Name: "old", ResourceType: "droplet", ResourceID: "1", Created: ago(40 * 24 * time.Hour)}}
+		c <- &snapshot{&godo.Snapshot{ID: "s-kept", Name: "kept", ResourceType: "droplet", ResourceID: "4", Created: ago(40 * 24 * time.Hour)}}
+		c <- &snapshot{&godo.Snapshot{ID: "s-new", Name: "new", ResourceType: "droplet", ResourceID: "1", Created: ago(24 * time.Hour)}}
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockFloatingIPs.ListFn = func(context.Context) (<-chan floatingips.FloatingIP, <-chan error) {
+		c := make(chan floatingips.FloatingIP, 2)
+		c <- &floatingIP{&godo.FloatingIP{IP: "10.0.0.1"}}
+		c <- &floatingIP{&godo.FloatingIP{IP: "10.0.0.2", Droplet: &godo.Droplet{ID: 4}}}
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockDroplets.DeleteFn = func(_ context.Context, id int) error {
+		*deleted = append(*deleted, fmt.Sprintf("droplets:%d", id))
+		return nil
+	}
+	cloud.MockVolumes.DeleteVolumeFn = func(_ context.Context, id string) error {
+		*deleted = append(*deleted, "volumes:"+id)
+		return nil
+	}
+	cloud.MockSnapshots.DeleteFn = func(_ context.Context, id string) error {
+		*deleted = append(*deleted, "snapshots:"+id)
+		return nil
+	}
+	cloud.MockFloatingIPs.DeleteFn = func(_ context.Context, ip string) error {
+		*deleted = append(*deleted, "floating_ips:"+ip)
+		return fmt.Errorf("floating IP is locked")
+	}
+	return cloud
+}
+
+func summarize(plan *Plan) []string {
+	var out []string
+	for _, c := range plan.Candidates {
+		s := c.Kind + ":" + c.ID
+		if c.Skip != "" {
+			s += " (" + c.Skip + ")"
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestJanitor(t *testing.T) {
+	var deleted []string
+	cloud := fakeCloud(&deleted)
+	state := NewState()
+	clock := now.Add(-2 * 24 * time.Hour)
+	rules := append([]Rule{
+		{Name: "attached volumes", Kind: Volumes, NameMatches: "db-*"},
+	}, DefaultRules...)
+	j, err := New(cloud, rules, UseState(state), UseClock(func() time.Time { return clock }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the floating IP is first seen unassigned two days ago
+	if _, err := j.Plan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	clock = now
+	plan, err := j.Plan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"droplets:2",
+		"droplets:1",
+		"floating_ips:10.0.0.1",
+		"snapshots:s-old",
+		"volumes:vol-free",
+		"volumes:vol-ci",
+		"volumes:vol-db (attached to droplet 4)",
+	}
+	if got := summarize(plan); !reflect.DeepEqual(want, got) {
+		t.Fatalf("want plan\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if got := plan.Candidates[2].Age; got != Duration(48*time.Hour) {
+		t.Errorf("want floating IP unassigned for 2d, got %v", got)
+	}
+	if got := plan.Candidates[4].Rule; got != "unattached volumes" {
+		t.Errorf("want volume matched by its first rule, got %q", got)
+	}
+
+	results := j.Apply(context.Background(), plan)
+	if len(results) != 6 {
+		t.Fatalf("want 6 results, got %d", len(results))
+	}
+	wantDeleted := []string{
+		"droplets:2", "droplets:1", "floating_ips:10.0.0.1",
+		"snapshots:s-old", "volumes:vol-free", "volumes:vol-ci",
+	}
+	if !reflect.DeepEqual(wantDeleted, deleted) {
+		t.Errorf("want deletions %v, got %v", wantDeleted, deleted)
+	}
+	for _, res := range results {
+		if (res.Kind == FloatingIPs) != (res.Err != nil) {
+			t.Errorf("%s %s: unexpected error %v", res.Kind, res.ID, res.Err)
+		}
+	}
+	if _, ok := state.Unassigned["10.0.0.1"]; !ok {
+		t.Errorf("want floating IP that failed to be deleted to stay in state")
+	}
+}
+
+func TestJanitorSnapshotTags(t *testing.T) {
+	var deleted []string
+	cloud := fakeCloud(&deleted)
+	cloud.MockSnapshots.ListFn = func(context.Context) (<-chan snapshots.Snapshot, <-chan error) {
+		c := make(chan snapshots.Snapshot, 3)
+		c <- &snapshot{&godo.Snapshot{ID: "s-old", Name: "old", ResourceType: "droplet", ResourceID: "1", Created: ago(40 * 24 * time.Hour)}}
+		c <- &snapshot{&godo.Snapshot{ID: "s-gone", Name: "gone", ResourceType: "droplet", ResourceID: "99", Created: ago(40 * 24 * time.Hour)}}
+		c <- &snapshot{&godo.Snapshot{ID: "s-vol", Name: "vol", ResourceType: "volume", ResourceID: "vol-db", Created: ago(40 * 24 * time.Hour)}}
+		close(c)
+		return c, noErr()
+	}
+	rules := []Rule{
+		{Name: "stale snapshots", Kind: Snapshots, OlderThan: Duration(30 * 24 * time.Hour), ExceptTags: []string{"keep"}},
+		{Name: "all snapshots", Kind: Snapshots, NameMatches: "vol"},
+	}
+	j, err := New(cloud, rules, UseClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := j.Plan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"snapshots:s-old",
+		"snapshots:s-gone (can't tell the tags of droplet 99, which is gone)",
+		"snapshots:s-vol (can't tell the tags of volume vol-db)",
+	}
+	if got := summarize(plan); !reflect.DeepEqual(want, got) {
+		t.Fatalf("want plan\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	j.Apply(context.Background(), plan)
+	if want := []string{"snapshots:s-old"}; !reflect.DeepEqual(want, deleted) {
+		t.Errorf("want deletions %v, got %v", want, deleted)
+	}
+}
+
+func TestJanitorAttached(t *testing.T) {
+	var log []string
+	cloud := mockcloud.Client(nil)
+	cloud.MockDroplets.ListFn = func(context.Context, ...droplets.ListOpt) (<-chan droplets.Droplet, <-chan error) {
+		c := make(chan droplets.Droplet, 2)
+		c <- &droplet{&godo.Droplet{ID: 1, Name: "ci-old", Created: ago(8 * time.Hour)}}
+		c <- &droplet{&godo.Droplet{ID: 2, Name: "ci-broken", Created: ago(7 * time.Hour)}}
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockVolumes.ListVolumesFn = func(context.Context, ...volumes.ListOpt) (<-chan volumes.Volume, <-chan error) {
+		c := make(chan volumes.Volume, 4)
+		c <- &volume{&godo.Volume{ID: "vol-ci", Name: "ci-data", CreatedAt: now.Add(-time.Hour), DropletIDs: []int{1}}}
+		c <- &volume{&godo.Volume{ID: "vol-broken", Name: "ci-broken", CreatedAt: now.Add(-time.Hour), DropletIDs: []int{2}}}
+		c <- &volume{&godo.Volume{ID: "vol-backed", Name: "ci-backed", CreatedAt: now.Add(-time.Hour)}}
+		c <- &volume{&godo.Volume{ID: "vol-stale", Name: "ci-stale", CreatedAt: now.Add(-time.Hour)}}
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockSnapshots.ListFn = func(context.Context) (<-chan snapshots.Snapshot, <-chan error) {
+		c := make(chan snapshots.Snapshot, 2)
+		c <- &snapshot{&godo.Snapshot{ID: "s-backup", Name: "backup", ResourceType: "volume", ResourceID: "vol-backed", Created: ago(time.Hour)}}
+		c <- &snapshot{&godo.Snapshot{ID: "s-stale", Name: "stale", ResourceType: "volume", ResourceID: "vol-stale", Created: ago(40 * 24 * time.Hour)}}
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockFloatingIPs.ListFn = func(context.Context) (<-chan floatingips.FloatingIP, <-chan error) {
+		c := make(chan floatingips.FloatingIP, 1)
+		c <- &floatingIP{&godo.FloatingIP{IP: "10.0.0.3", Droplet: &godo.Droplet{ID: 1}}}
+		close(c)
+		return c, noErr()
+	}
+	gets := 0
+	cloud.MockDroplets.GetFn = func(_ context.Context, id int) (droplets.Droplet, error) {
+		log = append(log, fmt.Sprintf("get:%d", id))
+		if gets++; gets < 3 {
+			return &droplet{&godo.Droplet{ID: id, Status: "active"}}, nil
+		}
+		return nil, &godo.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}}
+	}
+	cloud.MockDroplets.DeleteFn = func(_ context.Context, id int) error {
+		log = append(log, fmt.Sprintf("droplets:%d", id))
+		if id == 2 {
+			return fmt.Errorf("droplet is locked")
+		}
+		return nil
+	}
+	cloud.MockVolumes.DeleteVolumeFn = func(_ context.Context, id string) error {
+		log = append(log, "volumes:"+id)
+		return nil
+	}
+	cloud.MockSnapshots.DeleteFn = func(_ context.Context, id string) error {
+		log = append(log, "snapshots:"+id)
+		return nil
+	}
+	cloud.MockFloatingIPs.DeleteFn = func(_ context.Context, ip string) error {
+		log = append(log, "floating_ips:"+ip)
+		return nil
+	}
+
+	rules := []Rule{
+		{Name: "CI droplets", Kind: Droplets, NameMatches: "ci-*", OlderThan: Duration(6 * time.Hour)},
+		{Name: "CI volumes", Kind: Volumes, NameMatches: "ci-*"},
+		{Name: "floating IPs", Kind: FloatingIPs},
+		{Name: "stale snapshots", Kind: Snapshots, OlderThan: Duration(30 * 24 * time.Hour)},
+	}
+	j, err := New(cloud, rules, UseClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := j.Plan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"droplets:1",
+		"droplets:2",
+		"floating_ips:10.0.0.3",
+		"snapshots:s-stale",
+		"volumes:vol-ci",
+		"volumes:vol-broken",
+		"volumes:vol-backed (has snapshot s-backup)",
+		"volumes:vol-stale",
+	}
+	if got := summarize(plan); !reflect.DeepEqual(want, got) {
+		t.Fatalf("want plan\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	ctx := godoutil.WithWaitOptions(context.Background(), godoutil.WaitInterval(time.Millisecond))
+	results := j.Apply(ctx, plan)
+	wantLog := []string{
+		"droplets:1", "droplets:2",
+		// the floating IP and volume are released once their droplet is gone
+		"get:1", "get:1", "get:1", "floating_ips:10.0.0.3",
+		"snapshots:s-stale", "volumes:vol-ci", "volumes:vol-stale",
+	}
+	if !reflect.DeepEqual(wantLog, log) {
+		t.Errorf("want calls\n%s\ngot\n%s", strings.Join(wantLog, "\n"), strings.Join(log, "\n"))
+	}
+	for _, res := range results {
+		switch res.ID {
+		case "2":
+		case "vol-broken":
+			if res.Err == nil || !strings.Contains(res.Err.Error(), "droplet 2 it's attached to wasn't deleted") {
+				t.Errorf("want volume of the droplet that failed to be deleted to fail, got %v", res.Err)
+			}
+		default:
+			if res.Err != nil {
+				t.Errorf("%s %s: unexpected error %v", res.Kind, res.ID, res.Err)
+			}
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	rules, err := LoadRules(strings.NewReader(`{"rules": [
+		{"name": "old", "kind": "snapshots", "older_than": "1d12h", "except_tags": ["keep"]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rules[0].OlderThan; got != Duration(36*time.Hour) {
+		t.Errorf("want 1d12h, got %v", got)
+	}
+
+	for _, src := range []string{
+		`{"rules": [{"name": "x", "kind": "kittens"}]}`,
+		`{"rules": [{"name": "x", "kind": "droplets", "unattached": true}]}`,
+		`{"rules": [{"name": "x", "kind": "volumes", "except_tags": ["keep"]}]}`,
+		`{"rules": [{"name": "x", "kind": "droplets", "name_matches": "["}]}`,
+		`{"rules": [{"name": "x", "kind": "droplets", "older_than": "soon"}]}`,
+	} {
+		if _, err := LoadRules(strings.NewReader(src)); err == nil {
+			t.Errorf("want error loading %s", src)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	for _, d := range []Duration{
+		Duration(30 * 24 * time.Hour),
+		Duration(31*24*time.Hour + 2*time.Hour),
+		Duration(90 * time.Minute),
+	} {
+		got, err := ParseDuration(d.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != d {
+			t.Errorf("want %v, got %v", d, got)
+		}
+	}
+}
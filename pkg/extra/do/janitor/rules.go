@@ -0,0 +1,135 @@
+package janitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The kinds of resources the janitor cleans up.
+const (
+	Droplets    = "droplets"
+	Volumes     = "volumes"
+	Snapshots   = "snapshots"
+	FloatingIPs = "floating_ips"
+)
+
+// A Rule tells which resources of a kind are unused. A resource is a
+// candidate for deletion when it matches every condition set on a rule.
+type Rule struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// NameMatches is a pattern like `ci-*` that names must match.
+	NameMatches string `json:"name_matches,omitempty"`
+	// OlderThan is how old resources must be. Floating IPs have no creation
+	// date, their age is how long they've been seen unassigned.
+	OlderThan Duration `json:"older_than,omitempty"`
+	// Unattached only matches volumes without droplets and floating IPs
+	// without a droplet.
+	Unattached bool `json:"unattached,omitempty"`
+	// ExceptTags keeps the resources with any of these tags. Snapshots can't
+	// be tagged, so the tags of the droplet they were taken of are used.
+	ExceptTags []string `json:"except_tags,omitempty"`
+}
+
+// DefaultRules are used when none are given.
+var DefaultRules = []Rule{
+	{Name: "stale snapshots", Kind: Snapshots, OlderThan: Duration(30 * 24 * time.Hour), ExceptTags: []string{"keep"}},
+	{Name: "unassigned floating IPs", Kind: FloatingIPs, Unattached: true, OlderThan: Duration(24 * time.Hour)},
+	{Name: "unattached volumes", Kind: Volumes, Unattached: true},
+	{Name: "CI droplets", Kind: Droplets, NameMatches: "ci-*", OlderThan: Duration(6 * time.Hour)},
+}
+
+// LoadRules reads rules written as JSON, like:
+//
+//	{"rules": [
+//	  {"name": "CI droplets", "kind": "droplets", "name_matches": "ci-*", "older_than": "6h"},
+//	  {"name": "stale snapshots", "kind": "snapshots", "older_than": "30d", "except_tags": ["keep"]}
+//	]}
+func LoadRules(r io.Reader) ([]Rule, error) {
+	var doc struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	for i, rule := range doc.Rules {
+		if err := rule.check(); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i+1, err)
+		}
+	}
+	return doc.Rules, nil
+}
+
+func (rule *Rule) check() error {
+	switch rule.Kind {
+	case Droplets, Volumes, Snapshots, FloatingIPs:
+	default:
+		return fmt.Errorf("unknown kind %q, want one of %s", rule.Kind,
+			strings.Join([]string{Droplets, Volumes, Snapshots, FloatingIPs}, ", "))
+	}
+	if rule.NameMatches != "" {
+		if _, err := path.Match(rule.NameMatches, ""); err != nil {
+			return fmt.Errorf("invalid name pattern %q: %v", rule.NameMatches, err)
+		}
+	}
+	if rule.Unattached && rule.Kind != Volumes && rule.Kind != FloatingIPs {
+		return fmt.Errorf("only volumes and floating IPs can be unattached")
+	}
+	if len(rule.ExceptTags) > 0 && rule.Kind != Droplets && rule.Kind != Snapshots {
+		return fmt.Errorf("only droplets and snapshots have tags")
+	}
+	return nil
+}
+
+// Duration is a time.Duration written like "6h" or "30d" in JSON.
+type Duration time.Duration
+
+// ParseDuration parses durations like time.ParseDuration does, and also
+// counts of days like "30d" or "1d12h".
+func ParseDuration(s string) (Duration, error) {
+	var days float64
+	if i := strings.Index(s, "d"); i > 0 {
+		var err error
+		if days, err = strconv.ParseFloat(s[:i], 64); err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		if s = s[i+1:]; s == "" {
+			return Duration(days * float64(24*time.Hour)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	return Duration(days*float64(24*time.Hour)) + Duration(d), err
+}
+
+func (d Duration) String() string {
+	switch {
+	case d > 0 && time.Duration(d)%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", time.Duration(d)/(24*time.Hour))
+	case time.Duration(d) > 24*time.Hour:
+		days := time.Duration(d) / (24 * time.Hour)
+		return fmt.Sprintf("%dd%v", days, time.Duration(d)-days*24*time.Hour)
+	}
+	return time.Duration(d).String()
+}
+
+// MarshalJSON writes d as a string.
+func (d Duration) MarshalJSON() ([]byte, error) { return json.Marshal(d.String()) }
+
+// UnmarshalJSON reads d from a string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
@@ -0,0 +1,75 @@
+package janitor
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// State is what the janitor remembers across runs: when floating IPs were
+// first seen unassigned, as the API doesn't tell.
+type State struct {
+	mu         sync.Mutex
+	Unassigned map[string]time.Time `json:"unassigned_floating_ips"`
+}
+
+// NewState is an empty state.
+func NewState() *State {
+	return &State{Unassigned: make(map[string]time.Time)}
+}
+
+// LoadState reads a state written with Save.
+func LoadState(r io.Reader) (*State, error) {
+	s := NewState()
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, err
+	}
+	if s.Unassigned == nil {
+		s.Unassigned = make(map[string]time.Time)
+	}
+	return s, nil
+}
+
+// Save writes the state as JSON.
+func (s *State) Save(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// update takes note of the floating IPs that are unassigned, and forgets
+// those that aren't anymore.
+func (s *State) update(ips []resource, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unassigned := make(map[string]time.Time, len(ips))
+	for _, ip := range ips {
+		if len(ip.attached) > 0 {
+			continue
+		}
+		since, ok := s.Unassigned[ip.id]
+		if !ok {
+			since = now
+		}
+		unassigned[ip.id] = since
+	}
+	s.Unassigned = unassigned
+}
+
+func (s *State) unattachedSince(ip string, now time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if since, ok := s.Unassigned[ip]; ok {
+		return since
+	}
+	return now
+}
+
+func (s *State) forget(ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Unassigned, ip)
+}
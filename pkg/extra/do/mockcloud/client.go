@@ -94,7 +94,7 @@ type MockDroplets struct {
 	CreateMultipleFn   func(ctx context.Context, names []string, region, size, image string, opts ...droplets.CreateMultipleOpt) ([]droplets.Droplet, error)
 	GetFn              func(ctx context.Context, id int) (droplets.Droplet, error)
 	DeleteFn           func(ctx context.Context, id int) error
-	ListFn             func(ctx context.Context) (<-chan droplets.Droplet, <-chan error)
+	ListFn             func(ctx context.Context, opts ...droplets.ListOpt) (<-chan droplets.Droplet, <-chan error)
 	MockDropletActions *MockDropletActions
 }
 
@@ -124,11 +124,11 @@ func (mock *MockDroplets) Delete(ctx context.Context, id int) error {
 	}
 	return mock.wrap.Droplets().Delete(ctx, id)
 }
-func (mock *MockDroplets) List(ctx context.Context) (<-chan droplets.Droplet, <-chan error) {
+func (mock *MockDroplets) List(ctx context.Context, opts ...droplets.ListOpt) (<-chan droplets.Droplet, <-chan error) {
 	if mock.ListFn != nil {
-		return mock.ListFn(ctx)
+		return mock.ListFn(ctx, opts...)
 	}
-	return mock.wrap.Droplets().List(ctx)
+	return mock.wrap.Droplets().List(ctx, opts...)
 }
 func (mock *MockDroplets) Actions() droplets.ActionClient {
 	if mock.MockDropletActions != nil {
@@ -280,9 +280,10 @@ func (mock *MockAccounts) Get(ctx context.Context) (accounts.Account, error) {
 // Actions
 
 type MockActions struct {
-	wrap   cloud.Client
-	GetFn  func(ctx context.Context, id int) (actions.Action, error)
-	ListFn func(ctx context.Context) (<-chan actions.Action, <-chan error)
+	wrap    cloud.Client
+	GetFn   func(ctx context.Context, id int) (actions.Action, error)
+	ListFn  func(ctx context.Context, opts ...actions.ListOpt) (<-chan actions.Action, <-chan error)
+	WatchFn func(ctx context.Context, opts ...actions.WatchOpt) <-chan actions.Event
 }
 
 func (mock *MockActions) Get(ctx context.Context, id int) (actions.Action, error) {
@@ -292,11 +293,18 @@ func (mock *MockActions) Get(ctx context.Context, id int) (actions.Action, error
 	return mock.wrap.Actions().Get(ctx, id)
 }
 
-func (mock *MockActions) List(ctx context.Context) (<-chan actions.Action, <-chan error) {
+func (mock *MockActions) List(ctx context.Context, opts ...actions.ListOpt) (<-chan actions.Action, <-chan error) {
 	if mock.ListFn != nil {
-		return mock.ListFn(ctx)
+		return mock.ListFn(ctx, opts...)
 	}
-	return mock.wrap.Actions().List(ctx)
+	return mock.wrap.Actions().List(ctx, opts...)
+}
+
+func (mock *MockActions) Watch(ctx context.Context, opts ...actions.WatchOpt) <-chan actions.Event {
+	if mock.WatchFn != nil {
+		return mock.WatchFn(ctx, opts...)
+	}
+	return mock.wrap.Actions().Watch(ctx, opts...)
 }
 
 // Domains
@@ -311,7 +319,7 @@ type MockDomains struct {
 	GetRecordFn    func(ctx context.Context, name string, id int) (domains.Record, error)
 	UpdateRecordFn func(ctx context.Context, name string, id int, opts ...domains.RecordOpt) (domains.Record, error)
 	DeleteRecordFn func(ctx context.Context, name string, id int) error
-	ListRecordFn   func(ctx context.Context, name string) (<-chan domains.Record, <-chan error)
+	ListRecordFn   func(ctx context.Context, name string, opts ...domains.RecordListOpt) (<-chan domains.Record, <-chan error)
 }
 
 func (mock *MockDomains) Create(ctx context.Context, name, ip string, opts ...domains.CreateOpt) (domains.Domain, error) {
@@ -370,11 +378,11 @@ func (mock *MockDomains) DeleteRecord(ctx context.Context, name string, id int)
 	return mock.wrap.Domains().DeleteRecord(ctx, name, id)
 }
 
-func (mock *MockDomains) ListRecord(ctx context.Context, name string) (<-chan domains.Record, <-chan error) {
+func (mock *MockDomains) ListRecord(ctx context.Context, name string, opts ...domains.RecordListOpt) (<-chan domains.Record, <-chan error) {
 	if mock.ListRecordFn != nil {
-		return mock.ListRecordFn(ctx, name)
+		return mock.ListRecordFn(ctx, name, opts...)
 	}
-	return mock.wrap.Domains().ListRecord(ctx, name)
+	return mock.wrap.Domains().ListRecord(ctx, name, opts...)
 }
 
 // Images
@@ -385,7 +393,7 @@ type MockImages struct {
 	GetBySlugFn        func(context.Context, string) (images.Image, error)
 	UpdateFn           func(context.Context, int, ...images.UpdateOpt) (images.Image, error)
 	DeleteFn           func(context.Context, int) error
-	ListFn             func(context.Context) (<-chan images.Image, <-chan error)
+	ListFn             func(context.Context, ...images.ListOpt) (<-chan images.Image, <-chan error)
 	ListApplicationFn  func(context.Context) (<-chan images.Image, <-chan error)
 	ListDistributionFn func(context.Context) (<-chan images.Image, <-chan error)
 	ListUserFn         func(context.Context) (<-chan images.Image, <-chan error)
@@ -415,11 +423,11 @@ func (mock *MockImages) Delete(ctx context.Context, id int) error {
 	}
 	return mock.wrap.Images().Delete(ctx, id)
 }
-func (mock *MockImages) List(ctx context.Context) (<-chan images.Image, <-chan error) {
+func (mock *MockImages) List(ctx context.Context, opts ...images.ListOpt) (<-chan images.Image, <-chan error) {
 	if mock.ListFn != nil {
-		return mock.ListFn(ctx)
+		return mock.ListFn(ctx, opts...)
 	}
-	return mock.wrap.Images().List(ctx)
+	return mock.wrap.Images().List(ctx, opts...)
 }
 func (mock *MockImages) ListApplication(ctx context.Context) (<-chan images.Image, <-chan error) {
 	if mock.ListApplicationFn != nil {
@@ -609,7 +617,7 @@ type MockVolumes struct {
 	CreateVolumeFn    func(ctx context.Context, name, region string, sizeGibiBytes int64, opts ...volumes.CreateOpt) (volumes.Volume, error)
 	GetVolumeFn       func(context.Context, string) (volumes.Volume, error)
 	DeleteVolumeFn    func(context.Context, string) error
-	ListVolumesFn     func(context.Context) (<-chan volumes.Volume, <-chan error)
+	ListVolumesFn     func(context.Context, ...volumes.ListOpt) (<-chan volumes.Volume, <-chan error)
 	CreateSnapshotFn  func(ctx context.Context, volumeID, name string, opts ...volumes.SnapshotOpt) (volumes.Snapshot, error)
 	GetSnapshotFn     func(context.Context, string) (volumes.Snapshot, error)
 	DeleteSnapshotFn  func(context.Context, string) error
@@ -634,11 +642,11 @@ func (mock *MockVolumes) DeleteVolume(ctx context.Context, id string) error {
 	}
 	return mock.wrap.Volumes().DeleteVolume(ctx, id)
 }
-func (mock *MockVolumes) ListVolumes(ctx context.Context) (<-chan volumes.Volume, <-chan error) {
+func (mock *MockVolumes) ListVolumes(ctx context.Context, opts ...volumes.ListOpt) (<-chan volumes.Volume, <-chan error) {
 	if mock.ListVolumesFn != nil {
-		return mock.ListVolumesFn(ctx)
+		return mock.ListVolumesFn(ctx, opts...)
 	}
-	return mock.wrap.Volumes().ListVolumes(ctx)
+	return mock.wrap.Volumes().ListVolumes(ctx, opts...)
 }
 func (mock *MockVolumes) CreateSnapshot(ctx context.Context, volumeID, name string, opts ...volumes.SnapshotOpt) (volumes.Snapshot, error) {
 	if mock.CreateSnapshotFn != nil {
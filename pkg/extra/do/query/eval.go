@@ -0,0 +1,360 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/digitalocean/godo"
+)
+
+type expr interface {
+	eval(item reflect.Value) bool
+}
+
+type operand interface {
+	values(item reflect.Value) []interface{}
+}
+
+type andExpr struct{ l, r expr }
+
+func (e andExpr) eval(item reflect.Value) bool { return e.l.eval(item) && e.r.eval(item) }
+
+type orExpr struct{ l, r expr }
+
+func (e orExpr) eval(item reflect.Value) bool { return e.l.eval(item) || e.r.eval(item) }
+
+type notExpr struct{ e expr }
+
+func (e notExpr) eval(item reflect.Value) bool { return !e.e.eval(item) }
+
+type truthExpr struct{ f fieldOperand }
+
+func (e truthExpr) eval(item reflect.Value) bool {
+	for _, v := range e.f.values(item) {
+		if b, ok := v.(bool); ok && b {
+			return true
+		}
+	}
+	return false
+}
+
+// cmpExpr holds when any value of l compares to any value of r, such that
+// `'web' in tags` and `networks.v4.ip_address = '10.0.0.1'` work on lists.
+type cmpExpr struct {
+	op   string
+	l, r operand
+}
+
+func (e cmpExpr) eval(item reflect.Value) bool {
+	lvals, rvals := e.l.values(item), e.r.values(item)
+	if isNull(e.l) || isNull(e.r) {
+		vals := lvals
+		if isNull(e.l) {
+			vals = rvals
+		}
+		switch e.op {
+		case "=":
+			return len(vals) == 0
+		case "!=":
+			return len(vals) != 0
+		}
+		return false
+	}
+	if e.op == "!=" {
+		return !anyPair(lvals, rvals, func(c int) bool { return c == 0 })
+	}
+	return anyPair(lvals, rvals, func(c int) bool {
+		switch e.op {
+		case "=", "in":
+			return c == 0
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		case ">=":
+			return c >= 0
+		}
+		return false
+	})
+}
+
+func anyPair(lvals, rvals []interface{}, holds func(c int) bool) bool {
+	for _, l := range lvals {
+		for _, r := range rvals {
+			if c, ok := compare(l, r); ok && holds(c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type likeExpr struct {
+	l  operand
+	re *regexp.Regexp
+}
+
+func (e likeExpr) eval(item reflect.Value) bool {
+	for _, v := range e.l.values(item) {
+		if e.re.MatchString(text(v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// text formats a value the way it's printed, so numbers match patterns
+// without exponents, like `size_gigabytes like '1048%'`.
+func text(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+type literalOperand struct {
+	vals []interface{}
+	null bool
+}
+
+func (lit literalOperand) values(reflect.Value) []interface{} { return lit.vals }
+
+func isNull(o operand) bool {
+	lit, ok := o.(literalOperand)
+	return ok && lit.null
+}
+
+type fieldOperand struct {
+	path []string
+	pos  int
+}
+
+func (f fieldOperand) values(item reflect.Value) []interface{} {
+	var vals []interface{}
+	for _, v := range lookup(item, f.path, true) {
+		vals = append(vals, normalize(v))
+	}
+	return vals
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	timestampType = reflect.TypeOf(godo.Timestamp{})
+)
+
+// lookup finds the values at path, the names of fields being those of their
+// JSON form. Lists along the path are walked through, as are lists at the end
+// of the path when flatten is set.
+func lookup(v reflect.Value, path []string, flatten bool) []reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 && (len(path) > 0 || flatten) {
+		var out []reflect.Value
+		for i := 0; i < v.Len(); i++ {
+			out = append(out, lookup(v.Index(i), path, flatten)...)
+		}
+		return out
+	}
+	if len(path) == 0 {
+		return []reflect.Value{v}
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType || v.Type() == timestampType {
+			return nil
+		}
+		field, ok := fieldByJSONName(v, path[0])
+		if !ok {
+			return nil
+		}
+		return lookup(field, path[1:], flatten)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		el := v.MapIndex(reflect.ValueOf(path[0]).Convert(v.Type().Key()))
+		if !el.IsValid() {
+			return nil
+		}
+		return lookup(el, path[1:], flatten)
+	}
+	return nil
+}
+
+func fieldByJSONName(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous {
+			embedded := reflect.Indirect(v.Field(i))
+			if !embedded.IsValid() || embedded.Kind() != reflect.Struct {
+				continue
+			}
+			if f, ok := fieldByJSONName(embedded, name); ok {
+				return f, true
+			}
+			continue
+		}
+		if jsonName(sf) == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func jsonName(sf reflect.StructField) string {
+	tag := sf.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return sf.Name
+}
+
+// checkPath tells if path leads somewhere in values of type t.
+func checkPath(t reflect.Type, path []string) error {
+	for len(path) > 0 {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array:
+			t = t.Elem()
+			continue
+		case reflect.Map, reflect.Interface:
+			// anything goes in there
+			return nil
+		case reflect.Struct:
+			if t == timeType || t == timestampType {
+				break
+			}
+			sf, ok := structFieldByJSONName(t, path[0])
+			if !ok {
+				return fmt.Errorf("no field %q", path[0])
+			}
+			t, path = sf.Type, path[1:]
+			continue
+		}
+		return fmt.Errorf("no field %q", path[0])
+	}
+	return nil
+}
+
+func structFieldByJSONName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() != reflect.Struct {
+				continue
+			}
+			if f, ok := structFieldByJSONName(ft, name); ok {
+				return f, true
+			}
+			continue
+		}
+		if jsonName(sf) == name {
+			return sf, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// normalize turns a value into a string, float64 or bool such that it can be
+// compared. Times are compared in their RFC 3339 form, and resources by
+// their slug, ID or name, such that `region = 'nyc3'` holds for droplets in
+// that region.
+func normalize(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Struct:
+		switch v.Type() {
+		case timeType:
+			return v.Interface().(time.Time).UTC().Format(time.RFC3339)
+		case timestampType:
+			return v.Interface().(godo.Timestamp).UTC().Format(time.RFC3339)
+		}
+		for _, name := range []string{"slug", "id", "name"} {
+			if f, ok := fieldByJSONName(v, name); ok && !isZero(f) {
+				return normalize(f)
+			}
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// compare tells how a compares to b, if they can be compared.
+func compare(a, b interface{}) (int, bool) {
+	switch at := a.(type) {
+	case float64:
+		bt, ok := b.(float64)
+		if !ok {
+			s, isString := b.(string)
+			if !isString {
+				return 0, false
+			}
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return 0, false
+			}
+			bt = f
+		}
+		switch {
+		case at < bt:
+			return -1, true
+		case at > bt:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		if _, ok := b.(float64); ok {
+			c, ok := compare(b, a)
+			return -c, ok
+		}
+		return strings.Compare(at, fmt.Sprint(b)), true
+	case bool:
+		bt, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case at == bt:
+			return 0, true
+		case !at:
+			return -1, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
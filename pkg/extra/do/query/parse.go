@@ -0,0 +1,388 @@
+package query
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp    // = != <> < <= > >=
+	tokPunct // ( ) , *
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// is tells if the token is the given keyword or punctuation.
+func (t token) is(s string) bool {
+	switch t.kind {
+	case tokIdent:
+		return strings.EqualFold(t.text, s)
+	case tokOp, tokPunct:
+		return t.text == s
+	}
+	return false
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	rs := []rune(src)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			start := i
+			var sb bytes.Buffer
+			for i++; ; i++ {
+				if i >= len(rs) {
+					return nil, fmt.Errorf("at %d: unterminated string", start)
+				}
+				if rs[i] == r {
+					// a doubled quote stands for the quote itself
+					if i+1 < len(rs) && rs[i+1] == r {
+						sb.WriteRune(r)
+						i++
+						continue
+					}
+					break
+				}
+				sb.WriteRune(rs[i])
+			}
+			i++
+			toks = append(toks, token{kind: tokString, text: sb.String(), pos: start})
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			start := i
+			for i++; i < len(rs) && (unicode.IsDigit(rs[i]) || rs[i] == '.'); i++ {
+			}
+			toks = append(toks, token{kind: tokNumber, text: string(rs[start:i]), pos: start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i++; i < len(rs) && (unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i]) || rs[i] == '_' || rs[i] == '.'); i++ {
+			}
+			toks = append(toks, token{kind: tokIdent, text: string(rs[start:i]), pos: start})
+		case strings.ContainsRune("=!<>", r):
+			start := i
+			op := string(r)
+			if i+1 < len(rs) && (rs[i+1] == '=' || (r == '<' && rs[i+1] == '>')) {
+				op += string(rs[i+1])
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("at %d: unexpected %q", start, op)
+			}
+			i += len(op)
+			toks = append(toks, token{kind: tokOp, text: op, pos: start})
+		case strings.ContainsRune("(),*", r):
+			toks = append(toks, token{kind: tokPunct, text: string(r), pos: i})
+			i++
+		default:
+			return nil, fmt.Errorf("at %d: unexpected %q", i, r)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(rs)}), nil
+}
+
+var keywords = map[string]bool{
+	"select": true, "from": true, "where": true, "and": true, "or": true,
+	"not": true, "in": true, "like": true, "order": true, "by": true,
+	"asc": true, "desc": true, "limit": true, "true": true, "false": true,
+	"null": true,
+}
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) accept(s string) bool {
+	if p.peek().is(s) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if t := p.next(); !t.is(s) {
+		return fmt.Errorf("at %d: expected %q, got %v", t.pos, s, t)
+	}
+	return nil
+}
+
+func (p *parser) ident(what string) (token, error) {
+	t := p.next()
+	if t.kind != tokIdent || keywords[strings.ToLower(t.text)] {
+		return t, fmt.Errorf("at %d: expected %s, got %v", t.pos, what, t)
+	}
+	return t, nil
+}
+
+// parseQuery parses:
+//
+//	[select <field>, ... from] <kind> [where <expr>]
+//	    [order by <field> [asc|desc], ...] [limit <n>]
+func (p *parser) parseQuery() (*Query, error) {
+	q := new(Query)
+	if p.accept("select") {
+		if !p.accept("*") {
+			for {
+				f, err := p.ident("a field")
+				if err != nil {
+					return nil, err
+				}
+				q.Fields = append(q.Fields, f.text)
+				if !p.accept(",") {
+					break
+				}
+			}
+		}
+		if err := p.expect("from"); err != nil {
+			return nil, err
+		}
+	}
+	kind, err := p.ident("a kind of resource")
+	if err != nil {
+		return nil, err
+	}
+	q.Kind = strings.ToLower(kind.text)
+
+	if p.accept("where") {
+		if q.where, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("order") {
+		if err := p.expect("by"); err != nil {
+			return nil, err
+		}
+		for {
+			f, err := p.ident("a field")
+			if err != nil {
+				return nil, err
+			}
+			key := orderKey{field: splitPath(f.text)}
+			if p.accept("desc") {
+				key.desc = true
+			} else {
+				p.accept("asc")
+			}
+			q.order = append(q.order, key)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if p.accept("limit") {
+		t := p.next()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != tokNumber || err != nil || n < 0 {
+			return nil, fmt.Errorf("at %d: expected a limit, got %v", t.pos, t)
+		}
+		q.Limit = n
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("at %d: unexpected %v", t.pos, t)
+	}
+	return q, nil
+}
+
+func (p *parser) parseOr() (expr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = orExpr{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = andExpr{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.accept("not") {
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{e}, nil
+	}
+	if p.accept("(") {
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (expr, error) {
+	l, err := p.parseOperand(false)
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	negate := false
+	if t.is("not") {
+		// as in `'web' not in tags`
+		p.next()
+		negate = true
+		if t = p.peek(); !t.is("in") && !t.is("like") {
+			return nil, fmt.Errorf("at %d: expected \"in\" or \"like\", got %v", t.pos, t)
+		}
+	}
+	var op string
+	switch {
+	case t.kind == tokOp:
+		op = t.text
+		if op == "<>" {
+			op = "!="
+		}
+	case t.is("in"), t.is("like"):
+		op = strings.ToLower(t.text)
+	default:
+		// a field on its own holds when it's true, as in `where locked`
+		f, ok := l.(fieldOperand)
+		if !ok {
+			return nil, fmt.Errorf("at %d: expected a comparison, got %v", t.pos, t)
+		}
+		return truthExpr{f}, nil
+	}
+	p.next()
+	r, err := p.parseOperand(op == "in")
+	if err != nil {
+		return nil, err
+	}
+	var e expr = cmpExpr{op: op, l: l, r: r}
+	if op == "like" {
+		lit, ok := r.(literalOperand)
+		pattern, isString := "", false
+		if ok && len(lit.vals) == 1 {
+			pattern, isString = lit.vals[0].(string)
+		}
+		if !isString {
+			return nil, fmt.Errorf("at %d: like needs a pattern like 'web-%%'", t.pos)
+		}
+		e = likeExpr{l: l, re: likePattern(pattern)}
+	}
+	if negate {
+		e = notExpr{e}
+	}
+	return e, nil
+}
+
+// parseOperand parses a field or a literal, or a list of literals like
+// `('nyc3', 'sfo2')` when list is set.
+func (p *parser) parseOperand(list bool) (operand, error) {
+	if list && p.accept("(") {
+		var lit literalOperand
+		for {
+			v, err := p.parseLiteral()
+			if err != nil {
+				return nil, err
+			}
+			lit.vals = append(lit.vals, v.vals...)
+			if !p.accept(",") {
+				break
+			}
+		}
+		return lit, p.expect(")")
+	}
+	t := p.peek()
+	if t.kind == tokIdent && !keywords[strings.ToLower(t.text)] {
+		p.next()
+		return fieldOperand{path: splitPath(t.text), pos: t.pos}, nil
+	}
+	return p.parseLiteral()
+}
+
+func (p *parser) parseLiteral() (literalOperand, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokString:
+		return literalOperand{vals: []interface{}{t.text}}, nil
+	case t.kind == tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return literalOperand{}, fmt.Errorf("at %d: invalid number %v", t.pos, t)
+		}
+		return literalOperand{vals: []interface{}{f}}, nil
+	case t.is("true"):
+		return literalOperand{vals: []interface{}{true}}, nil
+	case t.is("false"):
+		return literalOperand{vals: []interface{}{false}}, nil
+	case t.is("null"):
+		return literalOperand{null: true}, nil
+	}
+	return literalOperand{}, fmt.Errorf("at %d: expected a value, got %v", t.pos, t)
+}
+
+func splitPath(s string) []string { return strings.Split(s, ".") }
+
+// likePattern turns a SQL pattern, where `%` is any text and `_` any
+// character, into a regexp.
+func likePattern(pattern string) *regexp.Regexp {
+	var sb bytes.Buffer
+	sb.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
@@ -0,0 +1,414 @@
+// Package query filters the resources of an account with queries like:
+//
+//	droplets where region.slug = 'nyc3' and 'web' in tags and memory >= 2048 order by created_at
+//
+// A query names a kind of resource, like `droplets` or `load_balancers`, and
+// optionally:
+//
+//   - the fields to keep, as in `select name, region.slug from droplets`
+//   - a condition, made of comparisons (`=`, `!=`, `<`, `<=`, `>`, `>=`),
+//     `in`, `like` (with `%` and `_` wildcards), `and`, `or`, `not` and
+//     parentheses
+//   - an order, as in `order by size_gigabytes desc, name`
+//   - a limit, as in `limit 10`
+//
+// Fields are named as in the JSON form of resources, nested fields being
+// separated by dots. A comparison on a list, like `tags` or
+// `networks.v4.ip_address`, holds if it holds for any of its values.
+package query
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/digitalocean/godo"
+)
+
+// A Query over the resources of a kind.
+type Query struct {
+	// Kind of resources queried, like `droplets`.
+	Kind string
+	// Fields to keep, all of them if empty.
+	Fields []string
+	// Limit on the number of results, none if 0.
+	Limit int
+
+	where expr
+	order []orderKey
+	kind  *kind
+}
+
+type orderKey struct {
+	field []string
+	desc  bool
+}
+
+// Parse a query, making sure the fields it uses exist on the kind of
+// resources it queries.
+func Parse(src string) (*Query, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, fmt.Errorf("parsing query: %v", err)
+	}
+	p := &parser{toks: toks}
+	q, err := p.parseQuery()
+	if err != nil {
+		return nil, fmt.Errorf("parsing query: %v", err)
+	}
+	if err := q.check(); err != nil {
+		return nil, fmt.Errorf("parsing query: %v", err)
+	}
+	return q, nil
+}
+
+func (q *Query) check() error {
+	k, ok := kindByName(q.Kind)
+	if !ok {
+		return fmt.Errorf("unknown kind %q, want one of %s", q.Kind, strings.Join(Kinds(), ", "))
+	}
+	q.kind = k
+
+	var paths [][]string
+	for _, f := range q.Fields {
+		paths = append(paths, splitPath(f))
+	}
+	for _, o := range q.order {
+		paths = append(paths, o.field)
+	}
+	var walk func(e expr)
+	walk = func(e expr) {
+		switch e := e.(type) {
+		case andExpr:
+			walk(e.l)
+			walk(e.r)
+		case orExpr:
+			walk(e.l)
+			walk(e.r)
+		case notExpr:
+			walk(e.e)
+		case truthExpr:
+			paths = append(paths, e.f.path)
+		case cmpExpr:
+			for _, o := range []operand{e.l, e.r} {
+				if f, ok := o.(fieldOperand); ok {
+					paths = append(paths, f.path)
+				}
+			}
+		case likeExpr:
+			if f, ok := e.l.(fieldOperand); ok {
+				paths = append(paths, f.path)
+			}
+		}
+	}
+	if q.where != nil {
+		walk(q.where)
+	}
+	for _, path := range paths {
+		if err := checkPath(k.typ, path); err != nil {
+			return fmt.Errorf("%s have %v", q.Kind, err)
+		}
+	}
+	return nil
+}
+
+// Run lists the resources of the account and evaluates the query on them.
+func (q *Query) Run(ctx context.Context, client cloud.Client) ([]interface{}, error) {
+	items, err := q.kind.list(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %v", q.Kind, err)
+	}
+	return q.Eval(items), nil
+}
+
+// Eval filters, orders and limits items, which are resources of the kind of
+// the query like *godo.Droplet. When the query selects fields, the results are
+// maps of those fields to their value.
+func (q *Query) Eval(items []interface{}) []interface{} {
+	out := []interface{}{}
+	for _, item := range items {
+		if q.where == nil || q.where.eval(reflect.ValueOf(item)) {
+			out = append(out, item)
+		}
+	}
+	if len(q.order) > 0 {
+		sort.SliceStable(out, func(i, j int) bool { return q.less(out[i], out[j]) })
+	}
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	if len(q.Fields) > 0 {
+		for i, item := range out {
+			row := make(map[string]interface{}, len(q.Fields))
+			for _, f := range q.Fields {
+				row[f] = Field(item, f)
+			}
+			out[i] = row
+		}
+	}
+	return out
+}
+
+func (q *Query) less(a, b interface{}) bool {
+	for _, key := range q.order {
+		av := firstValue(a, key.field)
+		bv := firstValue(b, key.field)
+		var c int
+		switch {
+		case av == nil && bv == nil:
+			continue
+		case av == nil:
+			c = -1
+		case bv == nil:
+			c = 1
+		default:
+			c, _ = compare(av, bv)
+		}
+		if c == 0 {
+			continue
+		}
+		if key.desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	return false
+}
+
+func firstValue(item interface{}, path []string) interface{} {
+	vals := fieldOperand{path: path}.values(reflect.ValueOf(item))
+	if len(vals) == 0 {
+		return nil
+	}
+	return vals[0]
+}
+
+// Field is the value of a field of a result, nil if it has none. Fields
+// inside lists are gathered in a list.
+func Field(item interface{}, field string) interface{} {
+	if row, ok := item.(map[string]interface{}); ok {
+		if v, ok := row[field]; ok {
+			return v
+		}
+	}
+	path := splitPath(field)
+	vals := lookup(reflect.ValueOf(item), path, false)
+	if !crossesList(reflect.TypeOf(item), path) {
+		if len(vals) == 0 {
+			return nil
+		}
+		return vals[0].Interface()
+	}
+	out := make([]interface{}, 0, len(vals))
+	for _, v := range vals {
+		out = append(out, v.Interface())
+	}
+	return out
+}
+
+// crossesList tells if a list is found on the way to the end of path.
+func crossesList(t reflect.Type, path []string) bool {
+	for t != nil && len(path) > 0 {
+		switch t.Kind() {
+		case reflect.Ptr:
+			t = t.Elem()
+		case reflect.Slice, reflect.Array:
+			return true
+		case reflect.Struct:
+			sf, ok := structFieldByJSONName(t, path[0])
+			if !ok {
+				return false
+			}
+			t, path = sf.Type, path[1:]
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+type kind struct {
+	name string
+	typ  reflect.Type
+	list func(context.Context, cloud.Client) ([]interface{}, error)
+}
+
+var kinds = []*kind{
+	{
+		name: "droplets",
+		typ:  reflect.TypeOf(godo.Droplet{}),
+		list: func(ctx context.Context, client cloud.Client) ([]interface{}, error) {
+			var out []interface{}
+			itemc, errc := client.Droplets().List(ctx)
+			for item := range itemc {
+				out = append(out, item.Struct())
+			}
+			return out, <-errc
+		},
+	},
+	{
+		name: "volumes",
+		typ:  reflect.TypeOf(godo.Volume{}),
+		list: func(ctx context.Context, client cloud.Client) ([]interface{}, error) {
+			var out []interface{}
+			itemc, errc := client.Volumes().ListVolumes(ctx)
+			for item := range itemc {
+				out = append(out, item.Struct())
+			}
+			return out, <-errc
+		},
+	},
+	{
+		name: "snapshots",
+		typ:  reflect.TypeOf(godo.Snapshot{}),
+		list: func(ctx context.Context, client cloud.Client) ([]interface{}, error) {
+			var out []interface{}
+			itemc, errc := client.Snapshots().List(ctx)
+			for item := range itemc {
+				out = append(out, item.Struct())
+			}
+			return out, <-errc
+		},
+	},
+	{
+		name: "images",
+		typ:  reflect.TypeOf(godo.Image{}),
+		list: func(ctx context.Context, client cloud.Client) ([]interface{}, error) {
+			var out []interface{}
+			itemc, errc := client.Images().List(ctx)
+			for item := range itemc {
+				out = append(out, item.Struct())
+			}
+			return out, <-errc
+		},
+	},
+	{
+		name: "domains",
+		typ:  reflect.TypeOf(godo.Domain{}),
+		list: func(ctx context.Context, client cloud.Client) ([]interface{}, error) {
+			var out []interface{}
+			itemc, errc := client.Domains().List(ctx)
+			for item := range itemc {
+				out = append(out, item.Struct())
+			}
+			return out, <-errc
+		},
+	},
+	{
+		name: "floating_ips",
+		typ:  reflect.TypeOf(godo.FloatingIP{}),
+		list: func(ctx context.Context, client cloud.Client) ([]interface{}, error) {
+			var out []interface{}
+			itemc, errc := client.FloatingIPs().List(ctx)
+			for item := range itemc {
+				out = append(out, item.Struct())
+			}
+			return out, <-errc
+		},
+	},
+	{
+		name: "keys",
+		typ:  reflect.TypeOf(godo.Key{}),
+		list: func(ctx context.Context, client cloud.Client) ([]interface{}, error) {
+			var out []interface{}
+			itemc, errc := client.Keys().List(ctx)
+			for item := range itemc {
+				out = append(out, item.Struct())
+			}
+			return out, <-errc
+		},
+	},
+	{
+		name: "tags",
+		typ:  reflect.TypeOf(godo.Tag{}),
+		list: func(ctx context.Context, client cloud.Client) ([]interface{}, error) {
+			var out []interface{}
+			itemc, errc := client.Tags().List(ctx)
+			for item := range itemc {
+				out = append(out, item.Struct())
+			}
+			return out, <-errc
+		},
+	},
+	{
+		name: "load_balancers",
+		typ:  reflect.TypeOf(godo.LoadBalancer{}),
+		list: func(ctx context.Context, client cloud.Client) ([]interface{}, error) {
+			var out []interface{}
+			itemc, errc := client.LoadBalancers().List(ctx)
+			for item := range itemc {
+				out = append(out, item.Struct())
+			}
+			return out, <-errc
+		},
+	},
+	{
+		name: "firewalls",
+		typ:  reflect.TypeOf(godo.Firewall{}),
+		list: func(ctx context.Context, client cloud.Client) ([]interface{}, error) {
+			var out []interface{}
+			itemc, errc := client.Firewalls().List(ctx)
+			for item := range itemc {
+				out = append(out, item.Struct())
+			}
+			return out, <-errc
+		},
+	},
+	{
+		name: "regions",
+		typ:  reflect.TypeOf(godo.Region{}),
+		list: func(ctx context.Context, client cloud.Client) ([]interface{}, error) {
+			var out []interface{}
+			itemc, errc := client.Regions().List(ctx)
+			for item := range itemc {
+				out = append(out, item.Struct())
+			}
+			return out, <-errc
+		},
+	},
+	{
+		name: "sizes",
+		typ:  reflect.TypeOf(godo.Size{}),
+		list: func(ctx context.Context, client cloud.Client) ([]interface{}, error) {
+			var out []interface{}
+			itemc, errc := client.Sizes().List(ctx)
+			for item := range itemc {
+				out = append(out, item.Struct())
+			}
+			return out, <-errc
+		},
+	},
+	{
+		name: "actions",
+		typ:  reflect.TypeOf(godo.Action{}),
+		list: func(ctx context.Context, client cloud.Client) ([]interface{}, error) {
+			var out []interface{}
+			itemc, errc := client.Actions().List(ctx)
+			for item := range itemc {
+				out = append(out, item.Struct())
+			}
+			return out, <-errc
+		},
+	},
+}
+
+func kindByName(name string) (*kind, bool) {
+	for _, k := range kinds {
+		if k.name == name {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// Kinds are the kinds of resources that can be queried.
+func Kinds() []string {
+	var names []string
+	for _, k := range kinds {
+		names = append(names, k.name)
+	}
+	return names
+}
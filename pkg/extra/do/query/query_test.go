@@ -0,0 +1,104 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/digitalocean/godo"
+)
+
+var droplets = []interface{}{
+	&godo.Droplet{ID: 1, Name: "web-1", Memory: 2048, Region: &godo.Region{Slug: "nyc3"}, Tags: []string{"web"}, Created: "2017-01-03T00:00:00Z",
+		Networks: &godo.Networks{V4: []godo.NetworkV4{{IPAddress: "10.0.0.1", Type: "public"}}}},
+	&godo.Droplet{ID: 2, Name: "web-2", Memory: 1024, Region: &godo.Region{Slug: "nyc3"}, Tags: []string{"web"}, Created: "2017-01-01T00:00:00Z"},
+	&godo.Droplet{ID: 3, Name: "db-1", Memory: 4096, Region: &godo.Region{Slug: "sfo2"}, Tags: []string{"db"}, Created: "2017-01-02T00:00:00Z", Locked: true},
+	&godo.Droplet{ID: 4, Name: "web-3", Memory: 4096, Region: &godo.Region{Slug: "nyc3"}, Tags: []string{"web", "canary"}, Created: "2017-01-04T00:00:00Z"},
+}
+
+func names(results []interface{}) string {
+	var out []string
+	for _, r := range results {
+		out = append(out, r.(*godo.Droplet).Name)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestEval(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want string
+	}{
+		{"droplets", "web-1,web-2,db-1,web-3"},
+		{"droplets where region = 'nyc3' and 'web' in tags and memory >= 2048 order by created_at", "web-1,web-3"},
+		{"droplets where region.slug in ('sfo2', 'ams3') or name = 'web-2'", "web-2,db-1"},
+		{"droplets where name like 'web-%' and not 'canary' in tags", "web-1,web-2"},
+		{"droplets where 'canary' not in tags and memory > 1024", "web-1,db-1"},
+		{"droplets where locked", "db-1"},
+		{"droplets where networks.v4.ip_address = '10.0.0.1'", "web-1"},
+		{"droplets where networks = null", "web-2,db-1,web-3"},
+		{"droplets where id = '3' or id <> id", "db-1"},
+		{"droplets order by memory desc, name limit 3", "db-1,web-3,web-1"},
+		{"DROPLETS WHERE (memory < 2048 OR memory > 2048) AND region != 'sfo2'", "web-2,web-3"},
+	} {
+		q, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("%s: %v", tt.src, err)
+			continue
+		}
+		if got := names(q.Eval(droplets)); got != tt.want {
+			t.Errorf("%s: want %q, got %q", tt.src, tt.want, got)
+		}
+	}
+}
+
+func TestLikeNumbers(t *testing.T) {
+	volumes := []interface{}{
+		&godo.Volume{ID: "a", Name: "small", SizeGigaBytes: 100},
+		&godo.Volume{ID: "b", Name: "large", SizeGigaBytes: 1048576},
+	}
+	q, err := Parse("volumes where size_gigabytes like '1048%'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := q.Eval(volumes)
+	if len(got) != 1 || got[0].(*godo.Volume).Name != "large" {
+		t.Fatalf("want the large volume, got %v", got)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	q, err := Parse("select name, region.slug, tags from droplets where id = 4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{map[string]interface{}{
+		"name": "web-3", "region.slug": "nyc3", "tags": []string{"web", "canary"},
+	}}
+	if got := q.Eval(droplets); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want string
+	}{
+		{"", "expected a kind of resource"},
+		{"dropplets", `unknown kind "dropplets"`},
+		{"droplets where nmae = 'web'", `droplets have no field "nmae"`},
+		{"droplets where region.slugg = 'nyc3'", `no field "slugg"`},
+		{"droplets order by nope", `no field "nope"`},
+		{"droplets where name = 'web", "unterminated string"},
+		{"droplets where name like 3", "like needs a pattern"},
+		{"droplets where (name = 'a'", `expected ")"`},
+		{"droplets limit ten", "expected a limit"},
+		{"droplets where name = 'a' extra", `unexpected "extra"`},
+	} {
+		_, err := Parse(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: want an error with %q, got %v", tt.src, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Package eventloop runs slow calls in the background and delivers their
+// results on the goroutine of an otto VM.
+//
+// otto is single-threaded: a background call can't touch the VM, so it
+// queues its completion on a Loop instead. Whoever drives the VM, like the
+// REPL or a script runner, runs the queued completions when the VM is idle.
+package eventloop
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/robertkrimen/otto"
+)
+
+// A Result converts the result of a Task into a JS value. It's called on the
+// goroutine of the VM. A nil Result is undefined.
+type Result func(vm *otto.Otto) otto.Value
+
+// A Task does the blocking part of a call, on its own goroutine.
+type Task func() (Result, error)
+
+// A Loop queues work that must run on the goroutine of a VM.
+type Loop struct {
+	mu      sync.Mutex
+	pending int // tasks still running and queued work
+	queue   []func() error
+	ready   chan struct{}
+}
+
+// New creates an empty Loop.
+func New() *Loop {
+	return &Loop{ready: make(chan struct{}, 1)}
+}
+
+var loops = struct {
+	sync.Mutex
+	byVM map[*otto.Otto]*Loop
+}{byVM: make(map[*otto.Otto]*Loop)}
+
+// Use makes the handles created in vm complete on l. A nil Loop forgets
+// about vm.
+func Use(vm *otto.Otto, l *Loop) {
+	loops.Lock()
+	defer loops.Unlock()
+	if l == nil {
+		delete(loops.byVM, vm)
+		return
+	}
+	loops.byVM[vm] = l
+}
+
+// For returns the Loop used by vm, or nil if it has none, in which case
+// nothing would ever run the completions of its handles.
+func For(vm *otto.Otto) *Loop {
+	loops.Lock()
+	defer loops.Unlock()
+	return loops.byVM[vm]
+}
+
+// Ready is signaled when work is queued.
+func (l *Loop) Ready() <-chan struct{} { return l.ready }
+
+// Pending tells how many tasks are running or completions are queued.
+func (l *Loop) Pending() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.pending
+}
+
+func (l *Loop) add(n int) {
+	l.mu.Lock()
+	l.pending += n
+	l.mu.Unlock()
+	if n < 0 {
+		l.signal()
+	}
+}
+
+func (l *Loop) signal() {
+	select {
+	case l.ready <- struct{}{}:
+	default:
+	}
+}
+
+// post queues fn to run on the goroutine of the VM.
+func (l *Loop) post(fn func() error) {
+	l.mu.Lock()
+	l.pending++
+	l.queue = append(l.queue, fn)
+	l.mu.Unlock()
+	l.signal()
+}
+
+// RunPending runs the work queued so far. It must be called on the goroutine
+// of the VM. It stops at the first error, which is usually an exception
+// thrown by a callback, leaving the rest queued.
+func (l *Loop) RunPending() error {
+	for {
+		l.mu.Lock()
+		if len(l.queue) == 0 {
+			l.mu.Unlock()
+			return nil
+		}
+		fn := l.queue[0]
+		l.queue = l.queue[1:]
+		l.mu.Unlock()
+
+		err := fn()
+		l.add(-1)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Wait runs queued work until no task is running and nothing is queued. It
+// must be called on the goroutine of the VM.
+func (l *Loop) Wait(ctx context.Context) error {
+	for {
+		if err := l.RunPending(); err != nil {
+			return err
+		}
+		if l.Pending() == 0 {
+			return nil
+		}
+		select {
+		case <-l.ready:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Go runs task in the background and returns a handle on it to vm.
+func (l *Loop) Go(vm *otto.Otto, task Task) otto.Value {
+	h := &handle{loop: l, vm: vm, done: make(chan struct{})}
+	l.add(1)
+	go func() {
+		defer l.add(-1)
+		h.result, h.err = task()
+		// queued before done is closed, such that waiting on the handle
+		// also runs its callbacks
+		l.post(h.settle)
+		close(h.done)
+	}()
+	return h.toVM()
+}
+
+// Bind sets the methods name and name_async on obj. prepare reads the
+// arguments of a call and returns the Task doing the work: name runs it
+// right away, while name_async runs it in the background and returns a
+// handle on it.
+func Bind(obj *otto.Object, name string, prepare func(otto.FunctionCall) Task) error {
+	if err := obj.Set(name, run(prepare)); err != nil {
+		return err
+	}
+	return obj.Set(name+"_async", runAsync(prepare))
+}
+
+// Methods sets methods on obj like ottoutil.SetMethods does, along with
+// their _async variants, as Bind does.
+func Methods(vm *otto.Otto, obj otto.Value, methods map[string]func(otto.FunctionCall) Task) otto.Value {
+	fns := make(map[string]func(otto.FunctionCall) otto.Value, 2*len(methods))
+	for name, prepare := range methods {
+		fns[name] = run(prepare)
+		fns[name+"_async"] = runAsync(prepare)
+	}
+	return ottoutil.SetMethods(vm, obj, fns)
+}
+
+func run(prepare func(otto.FunctionCall) Task) func(otto.FunctionCall) otto.Value {
+	return func(all otto.FunctionCall) otto.Value {
+		res, err := prepare(all)()
+		if err != nil {
+			ottoutil.Throw(all.Otto, err.Error())
+		}
+		if res == nil {
+			return q
+		}
+		return res(all.Otto)
+	}
+}
+
+func runAsync(prepare func(otto.FunctionCall) Task) func(otto.FunctionCall) otto.Value {
+	return func(all otto.FunctionCall) otto.Value {
+		l := For(all.Otto)
+		if l == nil {
+			ottoutil.Throw(all.Otto, "can't run in the background: nothing runs the event loop of this VM")
+		}
+		return l.Go(all.Otto, prepare(all))
+	}
+}
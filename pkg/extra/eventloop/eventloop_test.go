@@ -0,0 +1,105 @@
+package eventloop
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+func TestLoopWait(t *testing.T) {
+	vm := otto.New()
+	loop := New()
+	Use(vm, loop)
+	defer Use(vm, nil)
+
+	for i, d := range []time.Duration{20 * time.Millisecond, time.Millisecond} {
+		i, d := i, d
+		h := loop.Go(vm, func() (Result, error) {
+			time.Sleep(d)
+			return func(vm *otto.Otto) otto.Value {
+				v, _ := vm.ToValue(i)
+				return v
+			}, nil
+		})
+		if err := vm.Set("h", h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := vm.Run(`h.then(function(i) { done = (typeof done === "undefined" ? [] : done).concat([i]); })`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := loop.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := loop.Pending(); n != 0 {
+		t.Fatalf("want nothing pending, got %d", n)
+	}
+	v, err := vm.Run(`done.join(",")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := v.String(), "1,0"; got != want {
+		t.Fatalf("want callbacks in order of completion %q, got %q", want, got)
+	}
+}
+
+func TestLoopWaitUnhandledError(t *testing.T) {
+	vm := otto.New()
+	loop := New()
+
+	h := loop.Go(vm, func() (Result, error) {
+		return nil, errors.New("failed")
+	})
+	if err := vm.Set("h", h); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.Run(`h.then(function() {})`); err != nil {
+		t.Fatal(err)
+	}
+	if err := loop.Wait(context.Background()); err == nil || err.Error() != "failed" {
+		t.Fatalf("want the error of the task, got %v", err)
+	}
+}
+
+func TestMethods(t *testing.T) {
+	vm := otto.New()
+	obj, err := vm.Object(`({})`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Methods(vm, obj.Value(), map[string]func(otto.FunctionCall) Task{
+		"double": func(all otto.FunctionCall) Task {
+			n, _ := all.Argument(0).ToInteger()
+			return func() (Result, error) {
+				return func(vm *otto.Otto) otto.Value {
+					v, _ := vm.ToValue(2 * n)
+					return v
+				}, nil
+			}
+		},
+	})
+	if err := vm.Set("obj", obj); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := vm.Run(`obj.double(2)`); err != nil || v.String() != "4" {
+		t.Fatalf("want 4, got %v (%v)", v, err)
+	}
+	_, err = vm.Run(`obj.double_async(2)`)
+	if err == nil || !strings.Contains(err.Error(), "nothing runs the event loop") {
+		t.Fatalf("want handles refused without a loop, got %v", err)
+	}
+
+	Use(vm, New())
+	defer Use(vm, nil)
+	if v, err := vm.Run(`obj.double_async(3).wait("1s")`); err != nil || v.String() != "6" {
+		t.Fatalf("want 6, got %v (%v)", v, err)
+	}
+	if v, err := vm.Run(`Object.keys(obj).length`); err != nil || v.String() != "0" {
+		t.Fatalf("want methods not enumerable, got %v keys (%v)", v, err)
+	}
+}
@@ -0,0 +1,167 @@
+package eventloop
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/robertkrimen/otto"
+)
+
+var q = otto.Value{}
+
+// A handle tracks a Task from JS:
+//
+//	h.done()          // true once the task is over
+//	h.status()        // "pending", "done" or "errored"
+//	h.wait("5m")      // the result of the task, waiting at most 5m for it
+//	h.then(fn, onErr) // calls fn(result) or onErr(error) once the task is over
+type handle struct {
+	loop *Loop
+	vm   *otto.Otto
+
+	// set before done is closed
+	done   chan struct{}
+	result Result
+	err    error
+
+	// only used on the goroutine of the VM
+	settled   bool
+	converted bool
+	value     otto.Value
+	callbacks []callback
+}
+
+type callback struct {
+	onValue otto.Value
+	onError otto.Value
+}
+
+func (h *handle) isDone() bool {
+	select {
+	case <-h.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// get converts the result of the task once it's done.
+func (h *handle) get() (otto.Value, error) {
+	if h.err != nil {
+		return q, h.err
+	}
+	if !h.converted {
+		h.converted = true
+		h.value = otto.UndefinedValue()
+		if h.result != nil {
+			h.value = h.result(h.vm)
+		}
+	}
+	return h.value, nil
+}
+
+// settle queues the callbacks registered while the task was running.
+func (h *handle) settle() error {
+	h.settled = true
+	for _, cb := range h.callbacks {
+		h.schedule(cb)
+	}
+	h.callbacks = nil
+	return nil
+}
+
+func (h *handle) schedule(cb callback) {
+	h.loop.post(func() error { return h.run(cb) })
+}
+
+// run calls a callback with the outcome of the task. An error without an
+// error callback is returned, so it isn't lost.
+func (h *handle) run(cb callback) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	v, err := h.get()
+	if err != nil {
+		if !cb.onError.IsFunction() {
+			return err
+		}
+		_, err = cb.onError.Call(otto.NullValue(), err.Error())
+		return err
+	}
+	if cb.onValue.IsFunction() {
+		_, err = cb.onValue.Call(otto.NullValue(), v)
+	}
+	return err
+}
+
+func (h *handle) toVM() otto.Value {
+	vm := h.vm
+	obj, err := vm.Object(`({})`)
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	return ottoutil.SetMethods(vm, obj.Value(), map[string]func(otto.FunctionCall) otto.Value{
+		"done": func(all otto.FunctionCall) otto.Value {
+			return ottoutil.ToValue(all.Otto, h.isDone())
+		},
+		"status": func(all otto.FunctionCall) otto.Value {
+			status := "pending"
+			if h.isDone() {
+				status = "done"
+				if h.err != nil {
+					status = "errored"
+				}
+			}
+			return ottoutil.ToValue(all.Otto, status)
+		},
+		"wait": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			var timeout <-chan time.Time
+			var d time.Duration
+			if arg := all.Argument(0); arg.IsDefined() {
+				d = ottoutil.Duration(vm, arg)
+				t := time.NewTimer(d)
+				defer t.Stop()
+				timeout = t.C
+			}
+			// other handles complete while this one is waited on
+			for {
+				select {
+				case <-h.done:
+					if err := h.loop.RunPending(); err != nil {
+						ottoutil.Throw(vm, err.Error())
+					}
+					v, err := h.get()
+					if err != nil {
+						ottoutil.Throw(vm, err.Error())
+					}
+					return v
+				case <-h.loop.Ready():
+					if err := h.loop.RunPending(); err != nil {
+						ottoutil.Throw(vm, err.Error())
+					}
+				case <-timeout:
+					ottoutil.Throw(vm, "timed out after %v", d)
+				}
+			}
+		},
+		"then": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			cb := callback{onValue: all.Argument(0), onError: all.Argument(1)}
+			for _, fn := range []otto.Value{cb.onValue, cb.onError} {
+				if fn.IsDefined() && !fn.IsFunction() {
+					ottoutil.Throw(vm, "argument must be a function, got a %q", fn.Class())
+				}
+			}
+			if h.settled {
+				h.schedule(cb)
+			} else {
+				h.callbacks = append(h.callbacks, cb)
+			}
+			return all.This
+		},
+	})
+}
@@ -0,0 +1,267 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Column of a table, showing the value found at a path in every row.
+type Column struct {
+	Header string
+	// Path to the value, like `region.slug`. Lists along the path are walked
+	// through, such that `networks.v4.ip_address` is every IPv4 address.
+	Path string
+}
+
+// ParseColumns parses a column spec like `id,name,ip=networks.v4.ip_address`,
+// where each column is a path with an optional header before `=`.
+func ParseColumns(spec string) ([]Column, error) {
+	var cols []Column
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		header, path := part, part
+		if i := strings.Index(part, "="); i >= 0 {
+			header, path = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+		}
+		if header == "" || path == "" {
+			return nil, fmt.Errorf("invalid column %q, want `path` or `header=path`", part)
+		}
+		cols = append(cols, Column{Header: strings.ToUpper(header), Path: path})
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("no columns in %q", spec)
+	}
+	return cols, nil
+}
+
+func mustParseColumns(spec string) []Column {
+	cols, err := ParseColumns(spec)
+	if err != nil {
+		panic(err)
+	}
+	return cols
+}
+
+// Value of the column in a row, in the JSON form of the row. A path can
+// have alternatives, like `size_gigabytes|size`, the first one found being
+// used.
+func (col Column) Value(row interface{}) interface{} {
+	if col.Path == "" {
+		return row
+	}
+	obj, ok := row.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for _, path := range strings.Split(col.Path, "|") {
+		// rows of query results have fields named after paths
+		if v, ok := obj[path]; ok {
+			return v
+		}
+		if fn, ok := computed[path]; ok {
+			if v := fn(obj); v != nil {
+				return v
+			}
+			continue
+		}
+		vals, list := lookup(row, strings.Split(path, "."))
+		if list {
+			return vals
+		}
+		if len(vals) > 0 {
+			return vals[0]
+		}
+	}
+	return nil
+}
+
+// lookup finds the values at path in v, telling if it went through lists.
+func lookup(v interface{}, path []string) ([]interface{}, bool) {
+	if len(path) == 0 {
+		return []interface{}{v}, false
+	}
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		el, ok := tv[path[0]]
+		if !ok {
+			return nil, false
+		}
+		return lookup(el, path[1:])
+	case []interface{}:
+		out := []interface{}{}
+		for _, el := range tv {
+			vals, _ := lookup(el, path)
+			out = append(out, vals...)
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// computed columns are values that aren't found at a single path.
+var computed = map[string]func(obj map[string]interface{}) interface{}{
+	"public_ipv4":  func(obj map[string]interface{}) interface{} { return address(obj, "v4", "public") },
+	"private_ipv4": func(obj map[string]interface{}) interface{} { return address(obj, "v4", "private") },
+	"public_ipv6":  func(obj map[string]interface{}) interface{} { return address(obj, "v6", "public") },
+}
+
+// address finds the first address of a droplet of the given network type.
+func address(obj map[string]interface{}, version, typ string) interface{} {
+	nets, _ := lookup(obj, []string{"networks", version})
+	for _, net := range nets {
+		var list []interface{}
+		switch tnet := net.(type) {
+		case []interface{}:
+			list = tnet
+		case map[string]interface{}:
+			// as JS droplets have them, by index
+			for _, key := range sortedKeys(tnet) {
+				list = append(list, tnet[key])
+			}
+		}
+		for _, n := range list {
+			m, _ := n.(map[string]interface{})
+			if m["type"] == typ {
+				return m["ip_address"]
+			}
+		}
+	}
+	return nil
+}
+
+// the kinds of resources with default columns. A kind is told apart by the
+// fields its objects have.
+var kinds = []struct {
+	name   string
+	fields []string
+	table  []Column
+	wide   []Column
+}{
+	{
+		name:   "droplet",
+		fields: []string{"size_slug", "vcpus"},
+		table:  mustParseColumns("id,name,region=region.slug,size=size_slug,status,public_ipv4"),
+		wide:   mustParseColumns("id,name,region=region.slug,size=size_slug,status,public_ipv4,private_ipv4,memory,vcpus,disk,image=image.slug,tags,created=created_at"),
+	},
+	{
+		name:   "volume",
+		fields: []string{"filesystem_type", "droplet_ids"},
+		table:  mustParseColumns("id,name,region=region.slug,size=size_gigabytes|size,droplets=droplet_ids"),
+		wide:   mustParseColumns("id,name,region=region.slug,size=size_gigabytes|size,droplets=droplet_ids,filesystem_type,description,created=created_at"),
+	},
+	{
+		name:   "snapshot",
+		fields: []string{"resource_type", "min_disk_size"},
+		table:  mustParseColumns("id,name,resource_type,resource=resource_id|volume_id,size=size_gigabytes|size"),
+		wide:   mustParseColumns("id,name,resource_type,resource=resource_id|volume_id,size=size_gigabytes|size,min_disk_size,regions,created=created_at"),
+	},
+	{
+		name:   "image",
+		fields: []string{"distribution"},
+		table:  mustParseColumns("id,name,distribution,slug,public"),
+		wide:   mustParseColumns("id,name,distribution,slug,public,type,min_disk_size,regions,created=created_at"),
+	},
+	{
+		name:   "load balancer",
+		fields: []string{"forwarding_rules"},
+		table:  mustParseColumns("id,name,ip,status,region=region.slug"),
+		wide:   mustParseColumns("id,name,ip,status,region=region.slug,algorithm,tag,droplets=droplet_ids,created=created_at"),
+	},
+	{
+		name:   "firewall",
+		fields: []string{"inbound_rules"},
+		table:  mustParseColumns("id,name,status,tags,droplets=droplet_ids"),
+		wide:   mustParseColumns("id,name,status,tags,droplets=droplet_ids,created=created_at"),
+	},
+	{
+		name:   "action",
+		fields: []string{"resource_type", "started_at"},
+		table:  mustParseColumns("id,type,status,resource_type,resource_id,started=started_at"),
+		wide:   mustParseColumns("id,type,status,resource_type,resource_id,started=started_at,completed=completed_at,region=region_slug"),
+	},
+	{
+		name:   "key",
+		fields: []string{"fingerprint"},
+		table:  mustParseColumns("id,name,fingerprint"),
+		wide:   mustParseColumns("id,name,fingerprint,public_key"),
+	},
+	{
+		name:   "size",
+		fields: []string{"price_monthly"},
+		table:  mustParseColumns("slug,memory,vcpus,disk,price_monthly"),
+		wide:   mustParseColumns("slug,memory,vcpus,disk,transfer,price_monthly,price_hourly,regions"),
+	},
+	{
+		name:   "region",
+		fields: []string{"features", "sizes"},
+		table:  mustParseColumns("slug,name,available"),
+		wide:   mustParseColumns("slug,name,available,features,sizes"),
+	},
+	{
+		name:   "domain record",
+		fields: []string{"data", "type"},
+		table:  mustParseColumns("id,type,name,data"),
+		wide:   mustParseColumns("id,type,name,data,priority,port,weight"),
+	},
+	{
+		name:   "domain",
+		fields: []string{"zone_file"},
+		table:  mustParseColumns("name,ttl"),
+		wide:   mustParseColumns("name,ttl,zone_file"),
+	},
+	{
+		name:   "floating IP",
+		fields: []string{"ip", "droplet"},
+		table:  mustParseColumns("ip,region=region.slug,droplet=droplet.id"),
+		wide:   mustParseColumns("ip,region=region.slug,droplet=droplet.id,droplet_name=droplet.name"),
+	},
+	{
+		name:   "tag",
+		fields: []string{"resources"},
+		table:  mustParseColumns("name,droplets=resources.droplets.count"),
+		wide:   mustParseColumns("name,droplets=resources.droplets.count,last_tagged=resources.droplets.last_tagged.name"),
+	},
+	{
+		name:   "account",
+		fields: []string{"droplet_limit"},
+		table:  mustParseColumns("email,status,droplet_limit"),
+		wide:   mustParseColumns("email,uuid,status,droplet_limit,floating_ip_limit,email_verified"),
+	},
+}
+
+// DefaultColumns are the columns that suit rows, given the kind of the first
+// one. Rows of an unknown kind get a column per field.
+func DefaultColumns(rows []interface{}, wide bool) []Column {
+	if len(rows) == 0 {
+		return []Column{}
+	}
+	first, ok := rows[0].(map[string]interface{})
+	if !ok {
+		return []Column{{Header: "VALUE"}}
+	}
+	for _, k := range kinds {
+		if hasFields(first, k.fields) {
+			if wide {
+				return k.wide
+			}
+			return k.table
+		}
+	}
+	var cols []Column
+	for _, key := range sortedKeys(first) {
+		cols = append(cols, Column{Header: strings.ToUpper(key), Path: key})
+	}
+	return cols
+}
+
+func hasFields(obj map[string]interface{}, fields []string) bool {
+	for _, f := range fields {
+		if _, ok := obj[f]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,228 @@
+// Package format prints values as JSON, YAML, tables or CSV. Resources, like
+// droplets, are shown in tables with columns that suit their kind.
+package format
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// A Format to print values in.
+type Format string
+
+// The formats values can be printed in.
+const (
+	JSON  Format = "json"
+	YAML  Format = "yaml"
+	Table Format = "table"
+	CSV   Format = "csv"
+	// Wide is a table with more columns.
+	Wide Format = "wide"
+)
+
+// Formats are all the formats values can be printed in.
+var Formats = []Format{JSON, YAML, Table, CSV, Wide}
+
+// Parse the name of a format.
+func Parse(name string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == strings.ToLower(name) {
+			return f, nil
+		}
+	}
+	var names []string
+	for _, f := range Formats {
+		names = append(names, string(f))
+	}
+	return "", fmt.Errorf("unknown format %q, want one of %s", name, strings.Join(names, ", "))
+}
+
+// A Printer prints values in a format.
+type Printer struct {
+	Format Format
+	// Columns of tables and CSV. When empty, the columns suit the kind of
+	// the values printed.
+	Columns []Column
+}
+
+// Print v. Values can be anything that can be marshalled as JSON, like those
+// exported from JS or godo structs.
+func (p *Printer) Print(w io.Writer, v interface{}) error {
+	switch p.Format {
+	case JSON, "":
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	case YAML:
+		data, err := MarshalYAML(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case Table, Wide, CSV:
+		gv, err := generic(v)
+		if err != nil {
+			return err
+		}
+		rows, ok := gv.([]interface{})
+		if !ok {
+			if _, isObject := gv.(map[string]interface{}); !isObject {
+				// a plain value isn't worth a table
+				_, err := fmt.Fprintln(w, Cell(gv))
+				return err
+			}
+			rows = []interface{}{gv}
+		}
+		cols := p.Columns
+		if len(cols) == 0 {
+			cols = DefaultColumns(rows, p.Format == Wide)
+		}
+		if p.Format == CSV {
+			return writeCSV(w, cols, rows)
+		}
+		return writeTable(w, cols, rows)
+	}
+	return fmt.Errorf("unknown format %q", p.Format)
+}
+
+func writeTable(w io.Writer, cols []Column, rows []interface{}) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	headers := make([]string, len(cols))
+	for i, col := range cols {
+		headers[i] = col.Header
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		cells := make([]string, len(cols))
+		for i, col := range cols {
+			// tabs and newlines would break the table
+			cells[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(Cell(col.Value(row)))
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, cols []Column, rows []interface{}) error {
+	cw := csv.NewWriter(w)
+	headers := make([]string, len(cols))
+	for i, col := range cols {
+		headers[i] = strings.ToLower(col.Header)
+	}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		cells := make([]string, len(cols))
+		for i, col := range cols {
+			cells[i] = Cell(col.Value(row))
+		}
+		if err := cw.Write(cells); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Cell formats a value for a table: lists of plain values are joined by
+// commas, and objects are shown as JSON.
+func Cell(v interface{}) string {
+	switch tv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return tv
+	case json.Number:
+		return tv.String()
+	case float64:
+		return strconv.FormatFloat(tv, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, len(tv))
+		for i, el := range tv {
+			if _, ok := el.(map[string]interface{}); ok {
+				data, _ := json.Marshal(tv)
+				return string(data)
+			}
+			parts[i] = Cell(el)
+		}
+		return strings.Join(parts, ",")
+	case map[string]interface{}:
+		data, _ := json.Marshal(tv)
+		return string(data)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct, reflect.Ptr:
+		gv, err := generic(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return Cell(gv)
+	}
+	return fmt.Sprint(v)
+}
+
+// generic turns v into its JSON form: maps, lists, strings, numbers, bools
+// and nil.
+func generic(v interface{}) (interface{}, error) {
+	switch v.(type) {
+	case nil, string, bool, float64, json.Number, map[string]interface{}, []interface{}:
+		if !hasTyped(v) {
+			return v, nil
+		}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var gv interface{}
+	return gv, dec.Decode(&gv)
+}
+
+// hasTyped tells if v holds values other than those of the JSON form.
+func hasTyped(v interface{}) bool {
+	switch tv := v.(type) {
+	case nil, string, bool, float64, json.Number:
+		return false
+	case map[string]interface{}:
+		for _, el := range tv {
+			if hasTyped(el) {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		for _, el := range tv {
+			if hasTyped(el) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// sortedKeys of an object.
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,180 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/digitalocean/godo"
+)
+
+var droplets = []*godo.Droplet{
+	{ID: 1, Name: "web-1", Vcpus: 1, SizeSlug: "s-1vcpu-1gb", Status: "active", Region: &godo.Region{Slug: "nyc3"},
+		Networks: &godo.Networks{V4: []godo.NetworkV4{
+			{IPAddress: "10.0.0.1", Type: "private"},
+			{IPAddress: "192.0.2.1", Type: "public"},
+		}}},
+	{ID: 2, Name: "web-2", Vcpus: 1, SizeSlug: "s-1vcpu-1gb", Status: "new", Region: &godo.Region{Slug: "nyc3"}},
+}
+
+func print(t *testing.T, p *Printer, v interface{}) string {
+	buf := bytes.NewBuffer(nil)
+	if err := p.Print(buf, v); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestTable(t *testing.T) {
+	want := `ID  NAME   REGION  SIZE         STATUS  PUBLIC_IPV4
+1   web-1  nyc3    s-1vcpu-1gb  active  192.0.2.1
+2   web-2  nyc3    s-1vcpu-1gb  new     
+`
+	if got := print(t, &Printer{Format: Table}, droplets); got != want {
+		t.Fatalf("want\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestCustomColumns(t *testing.T) {
+	cols, err := ParseColumns("name, ips=networks.v4.ip_address")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "name,ips\nweb-1,\"10.0.0.1,192.0.2.1\"\nweb-2,\n"
+	if got := print(t, &Printer{Format: CSV, Columns: cols}, droplets); got != want {
+		t.Fatalf("want\n%s\ngot\n%s", want, got)
+	}
+	if _, err := ParseColumns("name,=id"); err == nil {
+		t.Fatal("want an error for a column without header")
+	}
+}
+
+func TestUnknownKind(t *testing.T) {
+	v := []interface{}{
+		map[string]interface{}{"b": 1.0, "a": []interface{}{"x", "y"}},
+	}
+	want := "A    B\nx,y  1\n"
+	if got := print(t, &Printer{Format: Table}, v); got != want {
+		t.Fatalf("want\n%s\ngot\n%s", want, got)
+	}
+	if got := print(t, &Printer{Format: Table}, "hello"); got != "hello\n" {
+		t.Fatalf("want a plain value, got %q", got)
+	}
+}
+
+func TestYAML(t *testing.T) {
+	v := map[string]interface{}{
+		"name":     "web-1",
+		"id":       1.0,
+		"tags":     []interface{}{"web", "true"},
+		"empty":    []interface{}{},
+		"region":   map[string]interface{}{"slug": "nyc3"},
+		"runcmd":   []interface{}{[]interface{}{"echo", "hi: there"}, map[string]interface{}{"a": nil, "b": false}},
+		"script":   "#!/bin/sh\necho hi\n",
+		"password": "",
+	}
+	want := `empty: []
+id: 1
+name: web-1
+password: ""
+region:
+  slug: nyc3
+runcmd:
+  - - echo
+    - "hi: there"
+  - a: null
+    b: false
+script: |
+  #!/bin/sh
+  echo hi
+tags:
+  - web
+  - "true"
+`
+	data, err := MarshalYAML(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != want {
+		t.Fatalf("want\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestYAMLStrings(t *testing.T) {
+	for _, tt := range []struct {
+		s      string
+		quoted bool
+	}{
+		{"web-1", false},
+		{"ubuntu-16-04-x64", false},
+		{"1.2.3", false},
+		{"nyc3", false},
+		{"0x1F", true},
+		{"0o17", true},
+		{"017", true},
+		{"0b101", true},
+		{"1_000", true},
+		{"190:20:30", true},
+		{"+12", true},
+		{".inf", true},
+		{"-.Inf", true},
+		{".NaN", true},
+		{"1.5e3", true},
+		{"2017-01-01", true},
+		{"2017-01-01T10:11:12Z", true},
+		{"2017-01-01T10:11:12.5+02:00", true},
+		{"2017-01-01 10:11:12 -5", true},
+		{"Yes", true},
+		{"OFF", true},
+		{"<<", true},
+		{"a\rb", true},
+		{"a\u2028b", true},
+	} {
+		data, err := MarshalYAML(map[string]interface{}{"v": tt.s})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := strings.TrimSuffix(strings.TrimPrefix(string(data), "v: "), "\n")
+		if !tt.quoted {
+			if got != tt.s {
+				t.Errorf("%q: want a plain scalar, got %q", tt.s, got)
+			}
+			continue
+		}
+		var back string
+		if err := json.Unmarshal([]byte(got), &back); err != nil || back != tt.s {
+			t.Errorf("%q: want a quoted scalar, got %q", tt.s, got)
+		}
+	}
+}
+
+func TestYAMLBlocks(t *testing.T) {
+	for _, tt := range []struct {
+		s     string
+		block bool
+	}{
+		{"line 1\nline 2\n", true},
+		{"line 1\n\tindented\n", true},
+		{"line 1\r\nline 2\r\n", false},
+		{"line 1\nbell \a\n", false},
+		{"line 1\u0085line 2\n", false},
+	} {
+		data, err := MarshalYAML(map[string]interface{}{"v": tt.s})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := string(data)
+		if isBlock := strings.HasPrefix(got, "v: |"); isBlock != tt.block {
+			t.Errorf("%q: want a block %v, got %q", tt.s, tt.block, got)
+			continue
+		}
+		if tt.block {
+			continue
+		}
+		var back string
+		if err := json.Unmarshal([]byte(strings.TrimSuffix(strings.TrimPrefix(got, "v: "), "\n")), &back); err != nil || back != tt.s {
+			t.Errorf("%q: want a quoted scalar, got %q", tt.s, got)
+		}
+	}
+}
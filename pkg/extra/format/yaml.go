@@ -0,0 +1,199 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MarshalYAML writes v as a YAML document. Values go through their JSON form
+// first, such that they're written with the same field names. Keys are
+// sorted, and strings of many lines are written as literal blocks.
+func MarshalYAML(v interface{}) ([]byte, error) {
+	gv, err := generic(v)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(nil)
+	writeYAML(buf, gv, 0, "")
+	return buf.Bytes(), nil
+}
+
+// writeYAML writes v at the start of a line, indented by indent spaces. The
+// first line starts with lead instead of the indent when it's set, as when v
+// is an item of a list.
+func writeYAML(b *bytes.Buffer, v interface{}, indent int, lead string) {
+	pad := strings.Repeat(" ", indent)
+	prefix := func(i int) string {
+		if i == 0 && lead != "" {
+			return lead
+		}
+		return pad
+	}
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		if len(tv) == 0 {
+			b.WriteString(prefix(0) + "{}\n")
+			return
+		}
+		for i, k := range sortedKeys(tv) {
+			b.WriteString(prefix(i) + yamlString(k) + ":")
+			writeYAMLValue(b, tv[k], indent+2)
+		}
+	case []interface{}:
+		if len(tv) == 0 {
+			b.WriteString(prefix(0) + "[]\n")
+			return
+		}
+		for i, el := range tv {
+			item := prefix(i) + "- "
+			switch tel := el.(type) {
+			case map[string]interface{}:
+				if len(tel) > 0 {
+					writeYAML(b, tel, indent+2, item)
+					continue
+				}
+			case []interface{}:
+				if len(tel) > 0 {
+					writeYAML(b, tel, indent+2, item)
+					continue
+				}
+			}
+			b.WriteString(strings.TrimSuffix(item, " "))
+			writeYAMLValue(b, el, indent+2)
+		}
+	default:
+		b.WriteString(prefix(0) + yamlScalar(v) + "\n")
+	}
+}
+
+// writeYAMLValue writes v after a key or a list marker, up to the end of its
+// last line.
+func writeYAMLValue(b *bytes.Buffer, v interface{}, indent int) {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		if len(tv) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAML(b, tv, indent, "")
+	case []interface{}:
+		if len(tv) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAML(b, tv, indent, "")
+	case string:
+		if block, ok := yamlBlock(tv, indent); ok {
+			b.WriteString(" " + block)
+			return
+		}
+		b.WriteString(" " + yamlString(tv) + "\n")
+	default:
+		b.WriteString(" " + yamlScalar(v) + "\n")
+	}
+}
+
+func yamlScalar(v interface{}) string {
+	switch tv := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(tv)
+	case json.Number:
+		return tv.String()
+	case float64:
+		return strconv.FormatFloat(tv, 'f', -1, 64)
+	case string:
+		return yamlString(tv)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// yamlBlock writes a string of many lines as a literal block, unless its
+// indentation couldn't be told apart from that of the block, or it has
+// characters that can only be written escaped.
+func yamlBlock(s string, indent int) (string, bool) {
+	if !strings.Contains(strings.TrimSuffix(s, "\n"), "\n") {
+		return "", false
+	}
+	for _, r := range s {
+		if r != '\n' && r != '\t' && isControl(r) {
+			return "", false
+		}
+	}
+	if strings.HasPrefix(s, " ") || strings.HasPrefix(s, "\t") || strings.HasSuffix(s, "\n\n") {
+		return "", false
+	}
+	header := "|-"
+	if strings.HasSuffix(s, "\n") {
+		header = "|"
+	}
+	pad := strings.Repeat(" ", indent)
+	var b bytes.Buffer
+	b.WriteString(header + "\n")
+	for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		if line == "" {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(pad + line + "\n")
+	}
+	return b.String(), true
+}
+
+// yamlString quotes s when it would otherwise be read as something else.
+func yamlString(s string) string {
+	if needsQuotes(s) {
+		data, _ := json.Marshal(s)
+		return string(data)
+	}
+	return s
+}
+
+// plain scalars that YAML 1.1 parsers read as something other than strings,
+// like octal and sexagesimal ints, or dates
+var (
+	yamlInt       = regexp.MustCompile(`^[-+]?(0b[01_]+|0o?[0-7_]+|0x[0-9a-fA-F_]+|[0-9][0-9_]*|[1-9][0-9_]*(:[0-5]?[0-9])+)$`)
+	yamlFloat     = regexp.MustCompile(`^([-+]?([0-9][0-9_]*)?\.[0-9_]*([eE][-+]?[0-9]+)?|[-+]?[0-9][0-9_]*(:[0-5]?[0-9])+\.[0-9_]*|[-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+	yamlTimestamp = regexp.MustCompile(`^[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}(([Tt]|[ \t]+)[0-9]{1,2}:[0-9]{2}:[0-9]{2}(\.[0-9]*)?([ \t]*(Z|[-+][0-9]{1,2}(:[0-9]{2})?))?)?$`)
+)
+
+func needsQuotes(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null", "~", "<<", "=":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if yamlInt.MatchString(s) || yamlFloat.MatchString(s) || yamlTimestamp.MatchString(s) {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return true
+	}
+	for _, r := range s {
+		if isControl(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// isControl tells if r can't be written as is in a YAML scalar, including
+// the line breaks YAML 1.1 knows besides \n.
+func isControl(r rune) bool {
+	return r < ' ' || (r >= 0x7f && r <= 0x9f) || r == 0x2028 || r == 0x2029 || r == 0xfeff
+}
@@ -1,6 +1,7 @@
 package godojs
 
 import (
+	"context"
 	"strconv"
 	"strings"
 	"time"
@@ -40,11 +41,11 @@ func ArgDomainCreateRequest(vm *otto.Otto, v otto.Value) *godo.DomainCreateReque
 	}
 }
 
-func ArgDomainName(vm *otto.Otto, v otto.Value) string {
+func ArgDomainName(ctx context.Context, vm *otto.Otto, v otto.Value) string {
 	var name string
 	switch {
 	case v.IsString():
-		name = ottoutil.String(vm, v)
+		name = resolveDomainName(ctx, vm, ottoutil.String(vm, v))
 	case v.IsObject():
 		name = ottoutil.String(vm, ottoutil.GetObject(vm, v, "name", false))
 	default:
@@ -111,11 +112,15 @@ func ArgDroplet(vm *otto.Otto, v otto.Value) *godo.Droplet {
 	}
 }
 
-func ArgDropletID(vm *otto.Otto, v otto.Value) int {
+func ArgDropletID(ctx context.Context, vm *otto.Otto, v otto.Value) int {
 	var did int
 	switch {
 	case v.IsNumber():
 		did = ottoutil.Int(vm, v)
+	case v.IsString():
+		did = resolveIntName(ctx, vm, ottoutil.String(vm, v), "argument must be a Droplet or a DropletID", func(ctx context.Context, r Resolver, name string) (int, error) {
+			return r.DropletID(ctx, name)
+		})
 	case v.IsObject():
 		did = ArgDroplet(vm, v).ID
 	default:
@@ -162,36 +167,36 @@ func ArgTagUntagResourcesRequest(vm *otto.Otto, v otto.Value) *godo.UntagResourc
 	return req
 }
 
-func ArgFirewallCreate(vm *otto.Otto, v otto.Value) *godo.FirewallRequest {
+func ArgFirewallCreate(ctx context.Context, vm *otto.Otto, v otto.Value) *godo.FirewallRequest {
 	req := &godo.FirewallRequest{
 		Name:          ottoutil.String(vm, ottoutil.GetObject(vm, v, "name", true)),
-		InboundRules:  ArgInboundRules(vm, ottoutil.GetObject(vm, v, "inbound_rules", true)),
-		OutboundRules: ArgOutboundRules(vm, ottoutil.GetObject(vm, v, "outbound_rules", true)),
-		DropletIDs:    ArgDropletIDs(vm, ottoutil.GetObject(vm, v, "droplet_ids", false)),
+		InboundRules:  ArgInboundRules(ctx, vm, ottoutil.GetObject(vm, v, "inbound_rules", true)),
+		OutboundRules: ArgOutboundRules(ctx, vm, ottoutil.GetObject(vm, v, "outbound_rules", true)),
+		DropletIDs:    ArgDropletIDs(ctx, vm, ottoutil.GetObject(vm, v, "droplet_ids", false)),
 		Tags:          ottoutil.StringSlice(vm, ottoutil.GetObject(vm, v, "tags", false)),
 	}
 
 	return req
 }
 
-func ArgFirewallUpdate(vm *otto.Otto, v otto.Value) *godo.FirewallRequest {
+func ArgFirewallUpdate(ctx context.Context, vm *otto.Otto, v otto.Value) *godo.FirewallRequest {
 	req := &godo.FirewallRequest{
 		Name:          ottoutil.String(vm, ottoutil.GetObject(vm, v, "name", true)),
-		InboundRules:  ArgInboundRules(vm, ottoutil.GetObject(vm, v, "inbound_rules", true)),
-		OutboundRules: ArgOutboundRules(vm, ottoutil.GetObject(vm, v, "outbound_rules", true)),
-		DropletIDs:    ArgDropletIDs(vm, ottoutil.GetObject(vm, v, "droplet_ids", false)),
+		InboundRules:  ArgInboundRules(ctx, vm, ottoutil.GetObject(vm, v, "inbound_rules", true)),
+		OutboundRules: ArgOutboundRules(ctx, vm, ottoutil.GetObject(vm, v, "outbound_rules", true)),
+		DropletIDs:    ArgDropletIDs(ctx, vm, ottoutil.GetObject(vm, v, "droplet_ids", false)),
 		Tags:          ottoutil.StringSlice(vm, ottoutil.GetObject(vm, v, "tags", false)),
 	}
 
 	return req
 }
 
-func ArgLoadBalancerCreateRequest(vm *otto.Otto, v otto.Value) *godo.LoadBalancerRequest {
+func ArgLoadBalancerCreateRequest(ctx context.Context, vm *otto.Otto, v otto.Value) *godo.LoadBalancerRequest {
 	req := &godo.LoadBalancerRequest{
 		Name:                ottoutil.String(vm, ottoutil.GetObject(vm, v, "name", true)),
 		Algorithm:           ottoutil.String(vm, ottoutil.GetObject(vm, v, "algorithm", false)),
 		Region:              ArgRegionSlug(vm, ottoutil.GetObject(vm, v, "region", true)),
-		DropletIDs:          ArgDropletIDs(vm, ottoutil.GetObject(vm, v, "droplet_ids", false)),
+		DropletIDs:          ArgDropletIDs(ctx, vm, ottoutil.GetObject(vm, v, "droplet_ids", false)),
 		HealthCheck:         ArgHealthCheck(vm, ottoutil.GetObject(vm, v, "health_check", false)),
 		StickySessions:      ArgStickySessions(vm, ottoutil.GetObject(vm, v, "sticky_sessions", false)),
 		ForwardingRules:     ArgForwardingRules(vm, ottoutil.GetObject(vm, v, "forwarding_rules", true)),
@@ -202,12 +207,12 @@ func ArgLoadBalancerCreateRequest(vm *otto.Otto, v otto.Value) *godo.LoadBalance
 	return req
 }
 
-func ArgLoadBalancerUpdate(vm *otto.Otto, v otto.Value) *godo.LoadBalancerRequest {
+func ArgLoadBalancerUpdate(ctx context.Context, vm *otto.Otto, v otto.Value) *godo.LoadBalancerRequest {
 	return &godo.LoadBalancerRequest{
 		Name:                ottoutil.String(vm, ottoutil.GetObject(vm, v, "name", true)),
 		Algorithm:           ottoutil.String(vm, ottoutil.GetObject(vm, v, "algorithm", false)),
 		Region:              ArgRegionSlug(vm, ottoutil.GetObject(vm, v, "region", true)),
-		DropletIDs:          ArgDropletIDs(vm, ottoutil.GetObject(vm, v, "droplet_ids", false)),
+		DropletIDs:          ArgDropletIDs(ctx, vm, ottoutil.GetObject(vm, v, "droplet_ids", false)),
 		HealthCheck:         ArgHealthCheck(vm, ottoutil.GetObject(vm, v, "health_check", false)),
 		StickySessions:      ArgStickySessions(vm, ottoutil.GetObject(vm, v, "sticky_sessions", false)),
 		ForwardingRules:     ArgForwardingRules(vm, ottoutil.GetObject(vm, v, "forwarding_rules", true)),
@@ -226,20 +231,33 @@ func ArgTags(vm *otto.Otto, v otto.Value) []string {
 	return tags
 }
 
-func ArgDropletIDs(vm *otto.Otto, v otto.Value) []int {
+func ArgDropletIDs(ctx context.Context, vm *otto.Otto, v otto.Value) []int {
 	ids := make([]int, 0)
 	ottoutil.LoadArray(vm, v, func(v otto.Value) {
-		did := ArgDropletID(vm, v)
+		did := ArgDropletID(ctx, vm, v)
 		ids = append(ids, did)
 	})
 
 	return ids
 }
 
-func ArgInboundRules(vm *otto.Otto, v otto.Value) []godo.InboundRule {
+// ArgDropletIDList accepts any mix of Droplets, DropletIDs and arrays of them.
+func ArgDropletIDList(ctx context.Context, vm *otto.Otto, vs ...otto.Value) []int {
+	ids := make([]int, 0, len(vs))
+	for _, v := range vs {
+		if v.Class() == "Array" {
+			ids = append(ids, ArgDropletIDs(ctx, vm, v)...)
+		} else {
+			ids = append(ids, ArgDropletID(ctx, vm, v))
+		}
+	}
+	return ids
+}
+
+func ArgInboundRules(ctx context.Context, vm *otto.Otto, v otto.Value) []godo.InboundRule {
 	var rules = make([]godo.InboundRule, 0)
 	ottoutil.LoadArray(vm, v, func(v otto.Value) {
-		rule := ArgInboundRule(vm, v)
+		rule := ArgInboundRule(ctx, vm, v)
 		rules = append(rules, godo.InboundRule{
 			Protocol:  rule.Protocol,
 			PortRange: rule.PortRange,
@@ -250,7 +268,7 @@ func ArgInboundRules(vm *otto.Otto, v otto.Value) []godo.InboundRule {
 	return rules
 }
 
-func ArgInboundRule(vm *otto.Otto, v otto.Value) *godo.InboundRule {
+func ArgInboundRule(ctx context.Context, vm *otto.Otto, v otto.Value) *godo.InboundRule {
 	if !v.IsDefined() || v.IsNull() {
 		return nil
 	}
@@ -270,11 +288,11 @@ func ArgInboundRule(vm *otto.Otto, v otto.Value) *godo.InboundRule {
 	return &godo.InboundRule{
 		Protocol:  protocol,
 		PortRange: ottoutil.String(vm, ottoutil.GetObject(vm, v, "ports", portsRequired)),
-		Sources:   ArgSources(vm, ottoutil.GetObject(vm, v, "sources", true)),
+		Sources:   ArgSources(ctx, vm, ottoutil.GetObject(vm, v, "sources", true)),
 	}
 }
 
-func ArgSources(vm *otto.Otto, v otto.Value) *godo.Sources {
+func ArgSources(ctx context.Context, vm *otto.Otto, v otto.Value) *godo.Sources {
 	if !v.IsDefined() || v.IsNull() {
 		return nil
 	}
@@ -285,25 +303,25 @@ func ArgSources(vm *otto.Otto, v otto.Value) *godo.Sources {
 	return &godo.Sources{
 		Addresses:        ottoutil.StringSlice(vm, ottoutil.GetObject(vm, v, "addresses", false)),
 		Tags:             ottoutil.StringSlice(vm, ottoutil.GetObject(vm, v, "tags", false)),
-		DropletIDs:       ArgDropletIDs(vm, ottoutil.GetObject(vm, v, "droplet_ids", false)),
-		LoadBalancerUIDs: ArgLoadBalancerUIDs(vm, ottoutil.GetObject(vm, v, "load_balancer_uids", false)),
+		DropletIDs:       ArgDropletIDs(ctx, vm, ottoutil.GetObject(vm, v, "droplet_ids", false)),
+		LoadBalancerUIDs: ArgLoadBalancerUIDs(ctx, vm, ottoutil.GetObject(vm, v, "load_balancer_uids", false)),
 	}
 }
 
-func ArgLoadBalancerUIDs(vm *otto.Otto, v otto.Value) []string {
+func ArgLoadBalancerUIDs(ctx context.Context, vm *otto.Otto, v otto.Value) []string {
 	ids := make([]string, 0)
 	ottoutil.LoadArray(vm, v, func(v otto.Value) {
-		lbID := ArgLoadBalancerID(vm, v)
+		lbID := ArgLoadBalancerID(ctx, vm, v)
 		ids = append(ids, lbID)
 	})
 
 	return ids
 }
 
-func ArgOutboundRules(vm *otto.Otto, v otto.Value) []godo.OutboundRule {
+func ArgOutboundRules(ctx context.Context, vm *otto.Otto, v otto.Value) []godo.OutboundRule {
 	var rules = make([]godo.OutboundRule, 0)
 	ottoutil.LoadArray(vm, v, func(v otto.Value) {
-		rule := ArgOutboundRule(vm, v)
+		rule := ArgOutboundRule(ctx, vm, v)
 		rules = append(rules, godo.OutboundRule{
 			Protocol:     rule.Protocol,
 			PortRange:    rule.PortRange,
@@ -314,7 +332,7 @@ func ArgOutboundRules(vm *otto.Otto, v otto.Value) []godo.OutboundRule {
 	return rules
 }
 
-func ArgOutboundRule(vm *otto.Otto, v otto.Value) *godo.OutboundRule {
+func ArgOutboundRule(ctx context.Context, vm *otto.Otto, v otto.Value) *godo.OutboundRule {
 	if !v.IsDefined() || v.IsNull() {
 		return nil
 	}
@@ -334,11 +352,11 @@ func ArgOutboundRule(vm *otto.Otto, v otto.Value) *godo.OutboundRule {
 	return &godo.OutboundRule{
 		Protocol:     protocol,
 		PortRange:    ottoutil.String(vm, ottoutil.GetObject(vm, v, "ports", portsRequired)),
-		Destinations: ArgDestinations(vm, ottoutil.GetObject(vm, v, "destinations", true)),
+		Destinations: ArgDestinations(ctx, vm, ottoutil.GetObject(vm, v, "destinations", true)),
 	}
 }
 
-func ArgDestinations(vm *otto.Otto, v otto.Value) *godo.Destinations {
+func ArgDestinations(ctx context.Context, vm *otto.Otto, v otto.Value) *godo.Destinations {
 	if !v.IsDefined() || v.IsNull() {
 		return nil
 	}
@@ -349,8 +367,8 @@ func ArgDestinations(vm *otto.Otto, v otto.Value) *godo.Destinations {
 	return &godo.Destinations{
 		Addresses:        ottoutil.StringSlice(vm, ottoutil.GetObject(vm, v, "addresses", false)),
 		Tags:             ottoutil.StringSlice(vm, ottoutil.GetObject(vm, v, "tags", false)),
-		DropletIDs:       ArgDropletIDs(vm, ottoutil.GetObject(vm, v, "droplet_ids", false)),
-		LoadBalancerUIDs: ArgLoadBalancerUIDs(vm, ottoutil.GetObject(vm, v, "load_balancer_uids", false)),
+		DropletIDs:       ArgDropletIDs(ctx, vm, ottoutil.GetObject(vm, v, "droplet_ids", false)),
+		LoadBalancerUIDs: ArgLoadBalancerUIDs(ctx, vm, ottoutil.GetObject(vm, v, "load_balancer_uids", false)),
 	}
 }
 
@@ -371,7 +389,7 @@ func ArgForwardingRules(vm *otto.Otto, v otto.Value) []godo.ForwardingRule {
 	return rules
 }
 
-func ArgLoadBalancer(vm *otto.Otto, v otto.Value) *godo.LoadBalancer {
+func ArgLoadBalancer(ctx context.Context, vm *otto.Otto, v otto.Value) *godo.LoadBalancer {
 	if !v.IsDefined() || v.IsNull() {
 		return nil
 	}
@@ -390,18 +408,20 @@ func ArgLoadBalancer(vm *otto.Otto, v otto.Value) *godo.LoadBalancer {
 		StickySessions:      ArgStickySessions(vm, ottoutil.GetObject(vm, v, "sticky_sesions", false)),
 		Region:              ArgRegion(vm, ottoutil.GetObject(vm, v, "region", false)),
 		Tag:                 ottoutil.String(vm, ottoutil.GetObject(vm, v, "tag", false)),
-		DropletIDs:          ArgDropletIDs(vm, ottoutil.GetObject(vm, v, "droplet_ids", false)),
+		DropletIDs:          ArgDropletIDs(ctx, vm, ottoutil.GetObject(vm, v, "droplet_ids", false)),
 		RedirectHttpToHttps: ottoutil.Bool(vm, ottoutil.GetObject(vm, v, "redirect_http_to_https", false)),
 	}
 }
 
-func ArgLoadBalancerID(vm *otto.Otto, v otto.Value) string {
+func ArgLoadBalancerID(ctx context.Context, vm *otto.Otto, v otto.Value) string {
 	var lbID string
 	switch {
 	case v.IsString():
-		lbID = ottoutil.String(vm, v)
+		lbID = resolveName(ctx, vm, ottoutil.String(vm, v), func(ctx context.Context, r Resolver, name string) (string, error) {
+			return r.LoadBalancerID(ctx, name)
+		})
 	case v.IsObject():
-		lbID = ArgLoadBalancer(vm, v).ID
+		lbID = ArgLoadBalancer(ctx, vm, v).ID
 	default:
 		ottoutil.Throw(vm, "argument must be a LoadBalancer or LoadBalancerID")
 	}
@@ -409,13 +429,15 @@ func ArgLoadBalancerID(vm *otto.Otto, v otto.Value) string {
 	return lbID
 }
 
-func ArgFirewallID(vm *otto.Otto, v otto.Value) string {
+func ArgFirewallID(ctx context.Context, vm *otto.Otto, v otto.Value) string {
 	var fwID string
 	switch {
 	case v.IsString():
-		fwID = ottoutil.String(vm, v)
+		fwID = resolveName(ctx, vm, ottoutil.String(vm, v), func(ctx context.Context, r Resolver, name string) (string, error) {
+			return r.FirewallID(ctx, name)
+		})
 	case v.IsObject():
-		fwID = ArgFirewall(vm, v).ID
+		fwID = ArgFirewall(ctx, vm, v).ID
 	default:
 		ottoutil.Throw(vm, "argument must be a Firewall or FirewallID")
 	}
@@ -423,7 +445,7 @@ func ArgFirewallID(vm *otto.Otto, v otto.Value) string {
 	return fwID
 }
 
-func ArgFirewall(vm *otto.Otto, v otto.Value) *godo.Firewall {
+func ArgFirewall(ctx context.Context, vm *otto.Otto, v otto.Value) *godo.Firewall {
 	if !v.IsDefined() || v.IsNull() {
 		return nil
 	}
@@ -435,18 +457,18 @@ func ArgFirewall(vm *otto.Otto, v otto.Value) *godo.Firewall {
 		ID:             ottoutil.String(vm, ottoutil.GetObject(vm, v, "id", false)),
 		Name:           ottoutil.String(vm, ottoutil.GetObject(vm, v, "name", false)),
 		Status:         ottoutil.String(vm, ottoutil.GetObject(vm, v, "status", false)),
-		PendingChanges: ArgPendingChanges(vm, ottoutil.GetObject(vm, v, "pending_changes", false)),
-		InboundRules:   ArgInboundRules(vm, ottoutil.GetObject(vm, v, "inbound_rules", false)),
-		OutboundRules:  ArgOutboundRules(vm, ottoutil.GetObject(vm, v, "outbound_rules", false)),
-		DropletIDs:     ArgDropletIDs(vm, ottoutil.GetObject(vm, v, "droplet_ids", false)),
+		PendingChanges: ArgPendingChanges(ctx, vm, ottoutil.GetObject(vm, v, "pending_changes", false)),
+		InboundRules:   ArgInboundRules(ctx, vm, ottoutil.GetObject(vm, v, "inbound_rules", false)),
+		OutboundRules:  ArgOutboundRules(ctx, vm, ottoutil.GetObject(vm, v, "outbound_rules", false)),
+		DropletIDs:     ArgDropletIDs(ctx, vm, ottoutil.GetObject(vm, v, "droplet_ids", false)),
 		Tags:           ottoutil.StringSlice(vm, ottoutil.GetObject(vm, v, "tags", false)),
 	}
 }
 
-func ArgPendingChanges(vm *otto.Otto, v otto.Value) []godo.PendingChange {
+func ArgPendingChanges(ctx context.Context, vm *otto.Otto, v otto.Value) []godo.PendingChange {
 	var changes = make([]godo.PendingChange, 0)
 	ottoutil.LoadArray(vm, v, func(v otto.Value) {
-		change := ArgPendingChange(vm, v)
+		change := ArgPendingChange(ctx, vm, v)
 		changes = append(changes, godo.PendingChange{
 			DropletID: change.DropletID,
 			Removing:  change.Removing,
@@ -457,7 +479,7 @@ func ArgPendingChanges(vm *otto.Otto, v otto.Value) []godo.PendingChange {
 	return changes
 }
 
-func ArgPendingChange(vm *otto.Otto, v otto.Value) *godo.PendingChange {
+func ArgPendingChange(ctx context.Context, vm *otto.Otto, v otto.Value) *godo.PendingChange {
 	if !v.IsDefined() || v.IsNull() {
 		return nil
 	}
@@ -467,7 +489,7 @@ func ArgPendingChange(vm *otto.Otto, v otto.Value) *godo.PendingChange {
 	}
 
 	return &godo.PendingChange{
-		DropletID: ArgDropletID(vm, ottoutil.GetObject(vm, v, "droplet_id", false)),
+		DropletID: ArgDropletID(ctx, vm, ottoutil.GetObject(vm, v, "droplet_id", false)),
 		Removing:  ottoutil.Bool(vm, ottoutil.GetObject(vm, v, "removing", false)),
 		Status:    ottoutil.String(vm, ottoutil.GetObject(vm, v, "status", false)),
 	}
@@ -624,11 +646,15 @@ func ArgImage(vm *otto.Otto, v otto.Value) *godo.Image {
 	}
 }
 
-func ArgImageID(vm *otto.Otto, v otto.Value) int {
+func ArgImageID(ctx context.Context, vm *otto.Otto, v otto.Value) int {
 	var imgID int
 	switch {
 	case v.IsNumber():
 		imgID = ottoutil.Int(vm, v)
+	case v.IsString():
+		imgID = resolveIntName(ctx, vm, ottoutil.String(vm, v), "argument must be an Image or a ImageID", func(ctx context.Context, r Resolver, name string) (int, error) {
+			return r.ImageID(ctx, name)
+		})
 	case v.IsObject():
 		imgID = ArgImage(vm, v).ID
 	default:
@@ -691,6 +717,9 @@ func ArgKernelID(vm *otto.Otto, v otto.Value) int {
 }
 
 func ArgNetworks(vm *otto.Otto, v otto.Value) *godo.Networks {
+	if !v.IsDefined() || v.IsNull() {
+		return nil
+	}
 	net := &godo.Networks{}
 	if v4Arg := ottoutil.GetObject(vm, v, "v4", false); v4Arg.IsDefined() {
 		ottoutil.LoadArray(vm, v4Arg, func(v otto.Value) {
@@ -812,11 +841,13 @@ func ArgVolumeCreateRequest(vm *otto.Otto, v otto.Value) *godo.VolumeCreateReque
 	}
 }
 
-func ArgVolumeID(vm *otto.Otto, v otto.Value) string {
+func ArgVolumeID(ctx context.Context, vm *otto.Otto, v otto.Value) string {
 	var volumeID string
 	switch {
 	case v.IsString():
-		volumeID = ottoutil.String(vm, v)
+		volumeID = resolveName(ctx, vm, ottoutil.String(vm, v), func(ctx context.Context, r Resolver, name string) (string, error) {
+			return r.VolumeID(ctx, name)
+		})
 	case v.IsObject():
 		volumeID = ArgVolume(vm, v).ID
 	default:
@@ -825,7 +856,7 @@ func ArgVolumeID(vm *otto.Otto, v otto.Value) string {
 	return volumeID
 }
 
-func ArgSnapshotCreateRequest(vm *otto.Otto, v otto.Value) *godo.SnapshotCreateRequest {
+func ArgSnapshotCreateRequest(ctx context.Context, vm *otto.Otto, v otto.Value) *godo.SnapshotCreateRequest {
 	if !v.IsDefined() || v.IsNull() {
 		ottoutil.Throw(vm, "argument must be a Snapshot create request, got nothing")
 	}
@@ -833,7 +864,7 @@ func ArgSnapshotCreateRequest(vm *otto.Otto, v otto.Value) *godo.SnapshotCreateR
 		ottoutil.Throw(vm, "argument must be a Snapshot, got a %q", v.Class())
 	}
 	return &godo.SnapshotCreateRequest{
-		VolumeID:    ArgVolumeID(vm, ottoutil.GetObject(vm, v, "volume", true)),
+		VolumeID:    ArgVolumeID(ctx, vm, ottoutil.GetObject(vm, v, "volume", true)),
 		Name:        ottoutil.String(vm, ottoutil.GetObject(vm, v, "name", true)),
 		Description: ottoutil.String(vm, ottoutil.GetObject(vm, v, "desc", false)),
 	}
@@ -857,11 +888,13 @@ func ArgSnapshot(vm *otto.Otto, v otto.Value) *godo.Snapshot {
 	}
 }
 
-func ArgSnapshotID(vm *otto.Otto, v otto.Value) string {
+func ArgSnapshotID(ctx context.Context, vm *otto.Otto, v otto.Value) string {
 	var id string
 	switch {
 	case v.IsString():
-		id = ottoutil.String(vm, v)
+		id = resolveName(ctx, vm, ottoutil.String(vm, v), func(ctx context.Context, r Resolver, name string) (string, error) {
+			return r.SnapshotID(ctx, name)
+		})
 	case v.IsObject():
 		id = ArgSnapshot(vm, v).ID
 	default:
@@ -870,7 +903,7 @@ func ArgSnapshotID(vm *otto.Otto, v otto.Value) string {
 	return id
 }
 
-func ArgFloatingIPCreateRequest(vm *otto.Otto, v otto.Value) *godo.FloatingIPCreateRequest {
+func ArgFloatingIPCreateRequest(ctx context.Context, vm *otto.Otto, v otto.Value) *godo.FloatingIPCreateRequest {
 	if !v.IsDefined() || v.IsNull() {
 		return nil
 	}
@@ -882,7 +915,7 @@ func ArgFloatingIPCreateRequest(vm *otto.Otto, v otto.Value) *godo.FloatingIPCre
 	}
 
 	if v := ottoutil.GetObject(vm, v, "droplet", false); v.IsDefined() {
-		req.DropletID = ArgDropletID(vm, ottoutil.GetObject(vm, v, "droplet", false))
+		req.DropletID = ArgDropletID(ctx, vm, ottoutil.GetObject(vm, v, "droplet", false))
 	}
 	return req
 }
@@ -901,11 +934,11 @@ func ArgFloatingIP(vm *otto.Otto, v otto.Value) *godo.FloatingIP {
 	}
 }
 
-func ArgFloatingIPActualIP(vm *otto.Otto, v otto.Value) string {
+func ArgFloatingIPActualIP(ctx context.Context, vm *otto.Otto, v otto.Value) string {
 	var ip string
 	switch {
 	case v.IsString():
-		ip = ottoutil.String(vm, v)
+		ip = resolveFloatingIP(ctx, vm, ottoutil.String(vm, v))
 	case v.IsObject():
 		ip = ArgFloatingIP(vm, v).IP
 	default:
@@ -929,11 +962,15 @@ func ArgKey(vm *otto.Otto, v otto.Value) *godo.Key {
 	}
 }
 
-func ArgKeyID(vm *otto.Otto, v otto.Value) int {
+func ArgKeyID(ctx context.Context, vm *otto.Otto, v otto.Value) int {
 	var id int
 	switch {
 	case v.IsNumber():
 		id = ottoutil.Int(vm, v)
+	case v.IsString():
+		id = resolveIntName(ctx, vm, ottoutil.String(vm, v), "argument must be a Key or a KeyID", func(ctx context.Context, r Resolver, name string) (int, error) {
+			return r.KeyID(ctx, name)
+		})
 	case v.IsObject():
 		id = ArgKey(vm, v).ID
 	default:
@@ -991,12 +1028,19 @@ func ActionToVM(vm *otto.Otto, g *godo.Action) otto.Value {
 	if g == nil {
 		return otto.NullValue()
 	}
+	// actions in progress have no completion time
+	formatTimestamp := func(t *godo.Timestamp) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339Nano)
+	}
 	return ottoutil.ToPkg(vm, map[string]interface{}{
 		"id":            int64(g.ID),
 		"status":        g.Status,
 		"type":          g.Type,
-		"started_at":    g.StartedAt.Format(time.RFC3339Nano),
-		"completed_at":  g.CompletedAt.Format(time.RFC3339Nano),
+		"started_at":    formatTimestamp(g.StartedAt),
+		"completed_at":  formatTimestamp(g.CompletedAt),
 		"resource_id":   int64(g.ResourceID),
 		"resource_type": g.ResourceType,
 		"region_slug":   g.RegionSlug,
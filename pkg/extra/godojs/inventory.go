@@ -0,0 +1,45 @@
+package godojs
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/inventory"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/robertkrimen/otto"
+)
+
+// ArgInventory reads an inventory, given as an object or as JSON.
+func ArgInventory(vm *otto.Otto, v otto.Value) *inventory.Inventory {
+	var data string
+	if v.IsString() {
+		data = ottoutil.String(vm, v)
+	} else {
+		if !v.IsObject() {
+			ottoutil.Throw(vm, "argument must be an inventory, got a %q", v.Class())
+		}
+		js, err := vm.Call("JSON.stringify", nil, v)
+		if err != nil {
+			ottoutil.Throw(vm, err.Error())
+		}
+		data = js.String()
+	}
+	inv, err := inventory.Load(strings.NewReader(data))
+	if err != nil {
+		ottoutil.Throw(vm, "invalid inventory: %v", err)
+	}
+	return inv
+}
+
+// JSONToVM converts v to a JS value through its JSON form.
+func JSONToVM(vm *otto.Otto, v interface{}) otto.Value {
+	data, err := json.Marshal(v)
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	out, err := vm.Call("JSON.parse", nil, string(data))
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	return out
+}
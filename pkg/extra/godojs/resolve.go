@@ -0,0 +1,134 @@
+package godojs
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strconv"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/robertkrimen/otto"
+)
+
+// A Resolver finds the ID of resources given their name. A *cloud.Resolver
+// is one.
+type Resolver interface {
+	DropletID(ctx context.Context, name string) (int, error)
+	VolumeID(ctx context.Context, name string) (string, error)
+	SnapshotID(ctx context.Context, name string) (string, error)
+	FirewallID(ctx context.Context, name string) (string, error)
+	LoadBalancerID(ctx context.Context, name string) (string, error)
+	ImageID(ctx context.Context, name string) (int, error)
+	KeyID(ctx context.Context, name string) (int, error)
+	FloatingIP(ctx context.Context, dropletName string) (string, error)
+	DomainName(ctx context.Context, name string) (string, error)
+
+	// Invalidate forgets the names of kinds, or of all kinds if none
+	// are given.
+	Invalidate(kinds ...string)
+}
+
+type resolverKey struct{}
+
+// WithResolver returns a copy of ctx with which the Arg*ID helpers accept
+// resource names, such that `cloud.droplets.get("web-1")` works when ctx is
+// given to Apply. Without a resolver, only IDs and objects are accepted.
+func WithResolver(ctx context.Context, r Resolver) context.Context {
+	return context.WithValue(ctx, resolverKey{}, r)
+}
+
+func resolverFrom(ctx context.Context) (Resolver, bool) {
+	r, ok := ctx.Value(resolverKey{}).(Resolver)
+	return r, ok && r != nil
+}
+
+// Invalidate makes the resolver of ctx, if any, forget the names of kinds
+// once resources of theirs were created, deleted or renamed.
+func Invalidate(ctx context.Context, kinds ...string) {
+	if r, ok := resolverFrom(ctx); ok {
+		r.Invalidate(kinds...)
+	}
+}
+
+// Fresh returns a copy of ctx with which names are resolved from a new
+// listing, for calls such as delete that shouldn't act on a stale ID.
+func Fresh(ctx context.Context) context.Context {
+	return cloud.WithFreshNames(ctx)
+}
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// looksLikeID tells if s should be used as an ID rather than as a name.
+func looksLikeID(s string) bool {
+	if uuidRegexp.MatchString(s) {
+		return true
+	}
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// resolveName uses the resolver of ctx to turn name into an ID. If ctx has
+// no resolver, name is assumed to be an ID.
+func resolveName(ctx context.Context, vm *otto.Otto, name string, fn func(context.Context, Resolver, string) (string, error)) string {
+	if looksLikeID(name) {
+		return name
+	}
+	r, ok := resolverFrom(ctx)
+	if !ok {
+		return name
+	}
+	id, err := fn(ctx, r, name)
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	return id
+}
+
+// resolveIntName is like resolveName, for resources with numeric IDs. Names
+// can't be used as such, so msg is thrown if ctx has no resolver.
+func resolveIntName(ctx context.Context, vm *otto.Otto, name, msg string, fn func(context.Context, Resolver, string) (int, error)) int {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id
+	}
+	r, ok := resolverFrom(ctx)
+	if !ok {
+		ottoutil.Throw(vm, msg)
+	}
+	id, err := fn(ctx, r, name)
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	return id
+}
+
+// resolveFloatingIP turns the name of a droplet into the floating IP
+// assigned to it. IPs are used as they are.
+func resolveFloatingIP(ctx context.Context, vm *otto.Otto, name string) string {
+	if net.ParseIP(name) != nil {
+		return name
+	}
+	r, ok := resolverFrom(ctx)
+	if !ok {
+		return name
+	}
+	ip, err := r.FloatingIP(ctx, name)
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	return ip
+}
+
+// resolveDomainName finds the domain called name, ignoring its case and
+// trailing dot. If ctx has no resolver, name is used as it is.
+func resolveDomainName(ctx context.Context, vm *otto.Otto, name string) string {
+	r, ok := resolverFrom(ctx)
+	if !ok {
+		return name
+	}
+	domain, err := r.DomainName(ctx, name)
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	return domain
+}
@@ -0,0 +1,27 @@
+package godojs
+
+import (
+	"github.com/aybabtme/godotto/pkg/extra/godoutil"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/robertkrimen/otto"
+)
+
+// ArgWaitOptions reads options like `{timeout: "5m", interval: "10s"}`. A
+// timeout stops waiting after a while, and an interval polls at a steady
+// pace instead of backing off.
+func ArgWaitOptions(vm *otto.Otto, v otto.Value) []godoutil.WaitOption {
+	if !v.IsDefined() || v.IsNull() {
+		return nil
+	}
+	if !v.IsObject() {
+		ottoutil.Throw(vm, "argument must be an object, got a %q", v.Class())
+	}
+	var opts []godoutil.WaitOption
+	if timeout := ottoutil.GetObject(vm, v, "timeout", false); timeout.IsDefined() {
+		opts = append(opts, godoutil.WaitMaxDuration(ottoutil.Duration(vm, timeout)))
+	}
+	if interval := ottoutil.GetObject(vm, v, "interval", false); interval.IsDefined() {
+		opts = append(opts, godoutil.WaitInterval(ottoutil.Duration(vm, interval)))
+	}
+	return opts
+}
@@ -2,26 +2,151 @@ package godoutil
 
 import (
 	"context"
+	"net/url"
+	"strconv"
 
 	"github.com/digitalocean/godo"
 )
 
-func IterateList(ctx context.Context, fn func(context.Context, *godo.ListOptions) (*godo.Response, error)) error {
-	opt := &godo.ListOptions{Page: 1, PerPage: 200}
+// A PageFunc fetches one page of a listing. It returns a func that emits the
+// items of the page, which IterateList calls in page order.
+type PageFunc func(context.Context, *godo.ListOptions) (emit func(), resp *godo.Response, err error)
+
+type iterateOpts struct {
+	perPage     int
+	concurrency int
+}
+
+// IterateOpt is an option to configure IterateList.
+type IterateOpt func(*iterateOpts)
+
+// PageConcurrency sets how many pages can be fetched at the same time once
+// the number of pages is known. A concurrency of 1 fetches pages one after
+// another.
+func PageConcurrency(n int) IterateOpt {
+	return func(opt *iterateOpts) {
+		if n > 0 {
+			opt.concurrency = n
+		}
+	}
+}
+
+// IterateList fetches all the pages of a listing. The first page tells how
+// many pages there are, after which the remaining pages are fetched
+// concurrently. The items are still emitted in order: a page is emitted once
+// all the pages before it have been. The first error stops the listing, as
+// does ctx being done, in which case the error of ctx is returned.
+func IterateList(ctx context.Context, fn PageFunc, opts ...IterateOpt) error {
+	opt := &iterateOpts{perPage: 200, concurrency: 4}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	page := 1
+	emit, resp, err := fn(ctx, &godo.ListOptions{Page: page, PerPage: opt.perPage})
+	if err != nil {
+		return err
+	}
+	emit()
+
+	if last, ok := lastPage(resp); ok && opt.concurrency > 1 {
+		if resp, err = fetchPages(ctx, fn, page+1, last, opt); err != nil {
+			return err
+		}
+		page = last
+	}
+
+	// pages that are added while listing, or listings that don't say how
+	// many pages they have, are fetched one after another
 	for {
-		resp, err := fn(ctx, opt)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if resp == nil || resp.Links == nil || resp.Links.IsLastPage() {
+			return nil
+		}
+		page++
+		emit, resp, err = fn(ctx, &godo.ListOptions{Page: page, PerPage: opt.perPage})
 		if err != nil {
 			return err
 		}
+		emit()
+	}
+}
+
+type pageResult struct {
+	emit func()
+	resp *godo.Response
+	err  error
+}
+
+// fetchPages fetches pages from first to last with bounded concurrency, and
+// emits them in order. It returns the response of the last page.
+func fetchPages(ctx context.Context, fn PageFunc, first, last int, opt *iterateOpts) (*godo.Response, error) {
+	if first > last {
+		return nil, nil
+	}
+	results := make([]chan pageResult, last-first+1)
+	for i := range results {
+		results[i] = make(chan pageResult, 1)
+	}
+
+	// a slot is taken before a page is fetched and given back once the page
+	// is emitted, so at most `concurrency` pages are held in memory
+	slots := make(chan struct{}, opt.concurrency)
+	go func() {
+		for i := range results {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(i int) {
+				lopt := &godo.ListOptions{Page: first + i, PerPage: opt.perPage}
+				emit, resp, err := fn(ctx, lopt)
+				results[i] <- pageResult{emit: emit, resp: resp, err: err}
+			}(i)
+		}
+	}()
+
+	var resp *godo.Response
+	for i := range results {
+		var res pageResult
 		select {
+		case res = <-results[i]:
 		case <-ctx.Done():
-			return nil
-		default:
+			return nil, ctx.Err()
 		}
-		if resp.Links != nil && !resp.Links.IsLastPage() {
-			opt.Page++
-		} else {
-			return nil
+		if err := ctx.Err(); err != nil {
+			// the listing was abandoned, errors of the page are a
+			// consequence of it
+			return nil, err
 		}
+		if res.err != nil {
+			return nil, res.err
+		}
+		res.emit()
+		resp = res.resp
+		<-slots
+	}
+	return resp, nil
+}
+
+// lastPage finds the number of the last page of a listing.
+func lastPage(resp *godo.Response) (int, bool) {
+	if resp == nil || resp.Links == nil || resp.Links.Pages == nil || resp.Links.Pages.Last == "" {
+		return 0, false
+	}
+	u, err := url.Parse(resp.Links.Pages.Last)
+	if err != nil {
+		return 0, false
+	}
+	last, err := strconv.Atoi(u.Query().Get("page"))
+	if err != nil {
+		return 0, false
 	}
+	return last, true
 }
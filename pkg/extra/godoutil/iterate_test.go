@@ -0,0 +1,115 @@
+package godoutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/godo"
+)
+
+// fakePages serves `pages` pages of `perPage` consecutive integers, taking a
+// random amount of time for each page.
+func fakePages(pages int, failPage int, emitted *[]int) (PageFunc, func() int) {
+	var (
+		mu       sync.Mutex
+		inflight int
+		maxSeen  int
+	)
+	fn := func(ctx context.Context, opt *godo.ListOptions) (func(), *godo.Response, error) {
+		mu.Lock()
+		inflight++
+		if inflight > maxSeen {
+			maxSeen = inflight
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inflight--
+			mu.Unlock()
+		}()
+
+		time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+		if opt.Page == failPage {
+			return nil, nil, errors.New("failed page")
+		}
+		resp := &godo.Response{Links: &godo.Links{Pages: &godo.Pages{}}}
+		if opt.Page < pages {
+			resp.Links.Pages.Next = fmt.Sprintf("https://api/v2/things?page=%d", opt.Page+1)
+			resp.Links.Pages.Last = fmt.Sprintf("https://api/v2/things?page=%d", pages)
+		}
+		page := opt.Page
+		return func() {
+			*emitted = append(*emitted, page)
+		}, resp, nil
+	}
+	return fn, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return maxSeen
+	}
+}
+
+func TestIterateListInOrder(t *testing.T) {
+	var emitted []int
+	fn, maxInflight := fakePages(20, -1, &emitted)
+
+	err := IterateList(context.Background(), fn, PageConcurrency(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(emitted) != 20 {
+		t.Fatalf("want %d pages, got %d: %v", 20, len(emitted), emitted)
+	}
+	for i, page := range emitted {
+		if page != i+1 {
+			t.Fatalf("want pages in order, got %v", emitted)
+		}
+	}
+	if max := maxInflight(); max > 3 {
+		t.Fatalf("want at most %d pages fetched at once, got %d", 3, max)
+	}
+}
+
+func TestIterateListError(t *testing.T) {
+	var emitted []int
+	fn, _ := fakePages(20, 7, &emitted)
+
+	err := IterateList(context.Background(), fn)
+	if err == nil || err.Error() != "failed page" {
+		t.Fatalf("want the page's error, got %v", err)
+	}
+	if len(emitted) != 6 {
+		t.Fatalf("want the pages before the failure, got %v", emitted)
+	}
+}
+
+func TestIterateListCancel(t *testing.T) {
+	for _, concurrency := range []int{1, 4} {
+		t.Run(fmt.Sprintf("concurrency %d", concurrency), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			var emitted []int
+			fn, _ := fakePages(20, -1, &emitted)
+
+			err := IterateList(ctx, func(ctx context.Context, opt *godo.ListOptions) (func(), *godo.Response, error) {
+				emit, resp, err := fn(ctx, opt)
+				return func() {
+					emit()
+					if len(emitted) == 5 {
+						cancel()
+					}
+				}, resp, err
+			}, PageConcurrency(concurrency))
+			if err != context.Canceled {
+				t.Fatalf("want the error of the context, got %v", err)
+			}
+			if len(emitted) != 5 {
+				t.Fatalf("want no pages after cancellation, got %v", emitted)
+			}
+		})
+	}
+}
@@ -6,13 +6,90 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/digitalocean/godo"
 )
 
+// WaitPolicy tells how to wait for actions to complete. Actions are polled
+// after a random delay of up to Base*Factor^attempt, never more than Cap, or
+// exactly that long if NoJitter is set.
+type WaitPolicy struct {
+	Base     time.Duration
+	Cap      time.Duration
+	Factor   float64
+	NoJitter bool
+	// MaxDuration is how long to wait before giving up, forever if 0.
+	MaxDuration time.Duration
+	// Progress is called after every poll with the current state of the
+	// action, and how long it's been waited on.
+	Progress func(action *godo.Action, elapsed time.Duration)
+}
+
+// WaitOption is an option to configure how actions are waited on.
+type WaitOption func(*WaitPolicy)
+
+// WaitBackoff sets the backoff between polls.
+func WaitBackoff(base, cap time.Duration, factor float64) WaitOption {
+	return func(p *WaitPolicy) {
+		p.Base, p.Cap, p.Factor, p.NoJitter = base, cap, factor, false
+	}
+}
+
+// WaitInterval polls every d, without backing off nor jitter.
+func WaitInterval(d time.Duration) WaitOption {
+	return func(p *WaitPolicy) {
+		p.Base, p.Cap, p.Factor, p.NoJitter = d, d, 1, true
+	}
+}
+
+// WaitMaxDuration sets how long to wait before giving up.
+func WaitMaxDuration(d time.Duration) WaitOption {
+	return func(p *WaitPolicy) { p.MaxDuration = d }
+}
+
+// WaitProgress sets a func to call after every poll.
+func WaitProgress(fn func(action *godo.Action, elapsed time.Duration)) WaitOption {
+	return func(p *WaitPolicy) { p.Progress = fn }
+}
+
+type waitOptsKey struct{}
+
+// WithWaitOptions returns a context under which actions are waited on with
+// opts, unless overridden by the options given to WaitForAction.
+func WithWaitOptions(ctx context.Context, opts ...WaitOption) context.Context {
+	prev, _ := ctx.Value(waitOptsKey{}).([]WaitOption)
+	all := append(append([]WaitOption{}, prev...), opts...)
+	return context.WithValue(ctx, waitOptsKey{}, all)
+}
+
+func waitPolicy(ctx context.Context, opts []WaitOption) *WaitPolicy {
+	p := &WaitPolicy{Base: 4 * time.Second, Cap: 30 * time.Second, Factor: 1.5}
+	ctxOpts, _ := ctx.Value(waitOptsKey{}).([]WaitOption)
+	for _, opt := range ctxOpts {
+		opt(p)
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// the jitter of every wait comes from the same source
+var jitter = struct {
+	sync.Mutex
+	r *rand.Rand
+}{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+func jitterFloat64() float64 {
+	jitter.Lock()
+	defer jitter.Unlock()
+	return jitter.r.Float64()
+}
+
 // WaitForActions loops through each actions in godo links and wait until they finish
-func WaitForActions(ctx context.Context, cloud *godo.Client, links *godo.Links) error {
+func WaitForActions(ctx context.Context, cloud *godo.Client, links *godo.Links, opts ...WaitOption) error {
 	if links == nil {
 		return nil
 	}
@@ -25,7 +102,7 @@ func WaitForActions(ctx context.Context, cloud *godo.Client, links *godo.Links)
 		if err != nil {
 			return err
 		}
-		if err := WaitForAction(ctx, cloud, action); err != nil {
+		if err := WaitForAction(ctx, cloud, action, opts...); err != nil {
 			return err
 		}
 	}
@@ -33,34 +110,80 @@ func WaitForActions(ctx context.Context, cloud *godo.Client, links *godo.Links)
 }
 
 // WaitForAction waits for a single action to finish.
-func WaitForAction(ctx context.Context, cloud *godo.Client, action *godo.Action) error {
+func WaitForAction(ctx context.Context, cloud *godo.Client, action *godo.Action, opts ...WaitOption) error {
 	if action == nil {
 		return nil
 	}
+	p := waitPolicy(ctx, opts)
 
-	base := (4 * time.Second).Seconds()
-	cap := (30 * time.Second).Seconds()
-	factor := 1.5
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-
-	for attempt := 0.0; ; attempt += 1.0 {
-
+	start := time.Now()
+	err := WaitFor(ctx, func() (bool, error) {
 		var err error
 		action, _, err = cloud.Actions.Get(ctx, action.ID)
 		if err != nil {
-			return err
+			return false, err
+		}
+		if p.Progress != nil {
+			p.Progress(action, time.Since(start))
 		}
 		if action.Status == "errored" {
-			return errors.New(action.String())
+			return false, errors.New(action.String())
+		}
+		return action.CompletedAt != nil || action.Status == "completed" || action.Status == "done", nil
+	}, opts...)
+	switch err.(type) {
+	case nil:
+		return nil
+	case *WaitTimeoutError:
+		return fmt.Errorf("timedout waiting for action %d to complete after %v", action.ID, p.MaxDuration)
+	}
+	if err == ctx.Err() {
+		return fmt.Errorf("timedout waiting for action %d to complete", action.ID)
+	}
+	return err
+}
+
+// WaitTimeoutError is returned when a wait lasts longer than its
+// MaxDuration.
+type WaitTimeoutError struct {
+	After time.Duration
+}
+
+func (err *WaitTimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %v", err.After)
+}
+
+// WaitFor polls until poll is done or fails, backing off between polls. It
+// returns ctx.Err() if ctx is done first, and a *WaitTimeoutError if the
+// MaxDuration of the policy is reached.
+func WaitFor(ctx context.Context, poll func() (done bool, err error), opts ...WaitOption) error {
+	p := waitPolicy(ctx, opts)
+
+	var deadline <-chan time.Time
+	if p.MaxDuration > 0 {
+		t := time.NewTimer(p.MaxDuration)
+		defer t.Stop()
+		deadline = t.C
+	}
+
+	base := p.Base.Seconds()
+	cap := p.Cap.Seconds()
+
+	for attempt := 0.0; ; attempt += 1.0 {
+		done, err := poll()
+		if err != nil || done {
+			return err
 		}
-		if action.CompletedAt != nil || action.Status == "done" {
-			return nil
+		sleepSeconds := math.Min(cap, base*math.Pow(p.Factor, attempt))
+		if !p.NoJitter {
+			sleepSeconds *= jitterFloat64()
 		}
-		sleepSeconds := r.Float64() * math.Min(cap, base*math.Pow(factor, attempt))
 		sleep := time.Duration(sleepSeconds * float64(time.Second))
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("timedout waiting for action %d to complete", action.ID)
+			return ctx.Err()
+		case <-deadline:
+			return &WaitTimeoutError{After: p.MaxDuration}
 		case <-time.After(sleep):
 		}
 	}
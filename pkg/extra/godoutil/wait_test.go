@@ -0,0 +1,94 @@
+package godoutil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/godo"
+)
+
+// fakeActions serves an action that completes after `polls` polls.
+func fakeActions(t *testing.T, polls int) (*godo.Client, func()) {
+	var (
+		mu    sync.Mutex
+		count int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		count++
+		status := "in-progress"
+		if count >= polls {
+			status = "completed"
+		}
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"action": map[string]interface{}{
+				"id": 1, "status": status, "type": "resize",
+				"resource_id": 123, "resource_type": "droplet",
+			},
+		})
+	}))
+	client, err := godo.New(nil, godo.SetBaseURL(srv.URL+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, srv.Close
+}
+
+func TestWaitForActionProgress(t *testing.T) {
+	client, done := fakeActions(t, 3)
+	defer done()
+
+	var statuses []string
+	ctx := WithWaitOptions(context.Background(),
+		WaitBackoff(time.Millisecond, time.Millisecond, 1),
+		WaitProgress(func(action *godo.Action, _ time.Duration) {
+			statuses = append(statuses, action.Status)
+		}),
+	)
+	if err := WaitForAction(ctx, client, &godo.Action{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(statuses, ","), "in-progress,in-progress,completed"; got != want {
+		t.Fatalf("want progress %q, got %q", want, got)
+	}
+}
+
+func TestWaitForActionMaxDuration(t *testing.T) {
+	client, done := fakeActions(t, 1000)
+	defer done()
+
+	err := WaitForAction(context.Background(), client, &godo.Action{ID: 1},
+		WaitBackoff(time.Millisecond, time.Millisecond, 1),
+		WaitMaxDuration(20*time.Millisecond),
+	)
+	if err == nil || !strings.Contains(err.Error(), "after 20ms") {
+		t.Fatalf("want a timeout, got %v", err)
+	}
+}
+
+func TestWaitForInterval(t *testing.T) {
+	const interval = 30 * time.Millisecond
+	var polls []time.Time
+	err := WaitFor(context.Background(), func() (bool, error) {
+		polls = append(polls, time.Now())
+		return len(polls) == 5, nil
+	}, WaitInterval(interval))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(polls); i++ {
+		if gap := polls[i].Sub(polls[i-1]); gap < interval {
+			t.Errorf("poll %d came %v after the previous one, want at least %v", i, gap, interval)
+		}
+	}
+	if total, max := polls[len(polls)-1].Sub(polls[0]), 4*interval*2; total > max {
+		t.Errorf("want polls about %v apart, took %v for 4 of them", interval, total)
+	}
+}
@@ -0,0 +1,176 @@
+package ottoutil
+
+import (
+	"runtime"
+
+	"github.com/robertkrimen/otto"
+)
+
+// WantCursor tells if the options of a list call ask for a cursor, as in
+// `cloud.droplets.list({cursor: true})`.
+func WantCursor(vm *otto.Otto, opts otto.Value) bool {
+	if !opts.IsObject() {
+		return false
+	}
+	return Bool(vm, GetObject(vm, opts, "cursor", false))
+}
+
+// List returns the values yielded by next, either all at once in an array,
+// or lazily with a cursor. next returns false once there are no more values,
+// with the error that ended the listing, if any. cancel is called when the
+// listing is over, when the script closes the cursor, or once the cursor is
+// garbage collected after the script abandoned it.
+func List(vm *otto.Otto, cursor bool, next func() (otto.Value, bool, error), cancel func()) otto.Value {
+	if cursor {
+		return Cursor(vm, next, cancel)
+	}
+	defer cancel()
+	values := make([]otto.Value, 0)
+	for {
+		v, more, err := next()
+		if err != nil {
+			Throw(vm, err.Error())
+		}
+		if !more {
+			break
+		}
+		values = append(values, v)
+	}
+	v, err := vm.ToValue(values)
+	if err != nil {
+		Throw(vm, err.Error())
+	}
+	return v
+}
+
+// Cursor creates a JS object that yields the values of next on demand:
+//
+//	c.next()      // the next value, or null when there are no more
+//	c.take(n)     // an array of at most n values, or of all of them
+//	c.each(fn)    // calls fn(v, i) on every value, until fn returns false
+//	c.filter(fn)  // a cursor on the values for which fn returns true
+//	c.close()     // stops the listing
+//
+// Cursors that aren't read to the end should be closed. The listing of a
+// cursor that's dropped without being closed only stops when the Go garbage
+// collector notices, which can take a while.
+func Cursor(vm *otto.Otto, next func() (otto.Value, bool, error), cancel func()) otto.Value {
+	c := &cursor{next: next, cancel: cancel}
+	runtime.SetFinalizer(c, (*cursor).close)
+	return c.toVM(vm)
+}
+
+type cursor struct {
+	next   func() (otto.Value, bool, error)
+	cancel func()
+	done   bool
+}
+
+func (c *cursor) pull(vm *otto.Otto) (otto.Value, bool) {
+	if c.done {
+		return otto.NullValue(), false
+	}
+	v, more, err := c.next()
+	if err != nil || !more {
+		c.close()
+	}
+	if err != nil {
+		Throw(vm, err.Error())
+	}
+	return v, more
+}
+
+func (c *cursor) close() {
+	if !c.done {
+		c.done = true
+		c.cancel()
+	}
+}
+
+// closeOnPanic stops the listing when a JS callback throws.
+func (c *cursor) closeOnPanic() {
+	if r := recover(); r != nil {
+		c.close()
+		panic(r)
+	}
+}
+
+func (c *cursor) toVM(vm *otto.Otto) otto.Value {
+	obj, err := vm.Object(`({})`)
+	if err != nil {
+		Throw(vm, err.Error())
+	}
+	return SetMethods(vm, obj.Value(), map[string]func(otto.FunctionCall) otto.Value{
+		"next": func(all otto.FunctionCall) otto.Value {
+			v, more := c.pull(all.Otto)
+			if !more {
+				return otto.NullValue()
+			}
+			return v
+		},
+		"take": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			n := -1
+			if arg := all.Argument(0); arg.IsDefined() {
+				n = Int(vm, arg)
+			}
+			values := make([]otto.Value, 0)
+			for n < 0 || len(values) < n {
+				v, more := c.pull(vm)
+				if !more {
+					break
+				}
+				values = append(values, v)
+			}
+			v, err := vm.ToValue(values)
+			if err != nil {
+				Throw(vm, err.Error())
+			}
+			return v
+		},
+		"each": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			fn := all.Argument(0)
+			if !fn.IsFunction() {
+				Throw(vm, "argument must be a function, got a %q", fn.Class())
+			}
+			defer c.closeOnPanic()
+			for i := 0; ; i++ {
+				v, more := c.pull(vm)
+				if !more {
+					return otto.UndefinedValue()
+				}
+				ret := Call(vm, fn, nil, v, i)
+				if ret.IsBoolean() && !Bool(vm, ret) {
+					c.close()
+					return otto.UndefinedValue()
+				}
+			}
+		},
+		"filter": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			fn := all.Argument(0)
+			if !fn.IsFunction() {
+				Throw(vm, "argument must be a function, got a %q", fn.Class())
+			}
+			filtered := &cursor{cancel: c.close}
+			filtered.next = func() (otto.Value, bool, error) {
+				defer c.closeOnPanic()
+				for {
+					v, more := c.pull(vm)
+					if !more {
+						return otto.NullValue(), false, nil
+					}
+					if Bool(vm, Call(vm, fn, nil, v)) {
+						return v, true, nil
+					}
+				}
+			}
+			return filtered.toVM(vm)
+		},
+		"close": func(all otto.FunctionCall) otto.Value {
+			c.close()
+			return otto.UndefinedValue()
+		},
+	})
+}
@@ -0,0 +1,53 @@
+package ottoutil
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+func TestCursorAbandoned(t *testing.T) {
+	vm := otto.New()
+	cancelled := make(chan struct{})
+	n := 0
+	next := func() (otto.Value, bool, error) {
+		n++
+		v, _ := vm.ToValue(n)
+		return v, true, nil
+	}
+	if err := vm.Set("c", Cursor(vm, next, func() { close(cancelled) })); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.Run(`c.take(3); c = null;`); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.After(5 * time.Second)
+	for {
+		runtime.GC()
+		select {
+		case <-cancelled:
+			return
+		case <-deadline:
+			t.Fatal("want abandoned cursors to cancel their listing")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestCursorClose(t *testing.T) {
+	vm := otto.New()
+	var cancels int
+	next := func() (otto.Value, bool, error) { return otto.NullValue(), false, nil }
+	if err := vm.Set("c", Cursor(vm, next, func() { cancels++ })); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.Run(`c.close(); c.close(); c.next();`); err != nil {
+		t.Fatal(err)
+	}
+	if cancels != 1 {
+		t.Fatalf("want the listing cancelled once, got %d", cancels)
+	}
+}
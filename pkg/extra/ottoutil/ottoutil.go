@@ -45,6 +45,99 @@ func ToAnonFunc(vm *otto.Otto, fn func(otto.FunctionCall) otto.Value) otto.Value
 	return outfn
 }
 
+// SetMethods defines the methods on obj as non-enumerable properties. They can
+// be called from JS but don't show up when obj is exported or printed.
+func SetMethods(vm *otto.Otto, obj otto.Value, methods map[string]func(otto.FunctionCall) otto.Value) otto.Value {
+	if !obj.IsObject() {
+		Throw(vm, "can't set methods on a %q", obj.Class())
+	}
+	for name, fn := range methods {
+		desc := ToPkg(vm, map[string]interface{}{
+			"value":        fn,
+			"enumerable":   false,
+			"writable":     true,
+			"configurable": true,
+		})
+		if _, err := vm.Call("Object.defineProperty", nil, obj, name, desc); err != nil {
+			Throw(vm, "can't set method %q, %v", name, err)
+		}
+	}
+	return obj
+}
+
+// Assign replaces the enumerable fields of dst with those of src, leaving the
+// non-enumerable ones (like methods) untouched. Fields src doesn't have are
+// deleted from dst.
+func Assign(vm *otto.Otto, dst, src otto.Value) otto.Value {
+	dobj, sobj := dst.Object(), src.Object()
+	if dobj == nil || sobj == nil {
+		Throw(vm, "can only assign objects, not a %q to a %q", src.Class(), dst.Class())
+	}
+	keep := make(map[string]bool)
+	for _, key := range sobj.Keys() {
+		keep[key] = true
+	}
+	var stale []string
+	for _, key := range dobj.Keys() {
+		if !keep[key] {
+			stale = append(stale, key)
+		}
+	}
+	if len(stale) > 0 {
+		// otto can only delete fields from JavaScript
+		del, err := vm.Run(`(function(obj, key) { delete obj[key]; })`)
+		if err != nil {
+			Throw(vm, err.Error())
+		}
+		for _, key := range stale {
+			if _, err := del.Call(otto.NullValue(), dst, key); err != nil {
+				Throw(vm, "can't delete key %q: %v", key, err)
+			}
+		}
+	}
+	for _, key := range sobj.Keys() {
+		v, err := sobj.Get(key)
+		if err != nil {
+			Throw(vm, "can't get key %q: %v", key, err)
+		}
+		if err := dobj.Set(key, v); err != nil {
+			Throw(vm, "can't set key %q: %v", key, err)
+		}
+	}
+	return dst
+}
+
+// Call invokes fn from within a native function. Exceptions thrown by fn are
+// rethrown as they are, instead of being turned into Go errors.
+func Call(vm *otto.Otto, fn otto.Value, this interface{}, args ...interface{}) otto.Value {
+	if !fn.IsFunction() {
+		Throw(vm, "need to be a function, not a %q", fn.Class())
+	}
+	argv, err := vm.Object(`[]`)
+	if err != nil {
+		Throw(vm, err.Error())
+	}
+	for _, arg := range args {
+		if _, err := argv.Call("push", arg); err != nil {
+			Throw(vm, err.Error())
+		}
+	}
+	res, err := vm.Call(`(function(fn, self, args) {
+	try {
+		return { value: fn.apply(self, args) };
+	} catch (e) {
+		return { threw: true, error: e };
+	}
+})`, nil, fn, this, argv)
+	if err != nil {
+		Throw(vm, err.Error())
+	}
+	if Bool(vm, GetObject(vm, res, "threw", false)) {
+		panic(GetObject(vm, res, "error", false))
+	}
+	return GetObject(vm, res, "value", false)
+}
+
 func GetObject(vm *otto.Otto, obj otto.Value, name string, mandatory bool) otto.Value {
 	if !obj.IsObject() {
 		Throw(vm, "can't get field %q out of a %q", name, obj.Class())
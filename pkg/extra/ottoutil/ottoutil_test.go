@@ -0,0 +1,29 @@
+package ottoutil
+
+import (
+	"testing"
+
+	"github.com/robertkrimen/otto"
+)
+
+func TestAssign(t *testing.T) {
+	vm := otto.New()
+	if err := vm.Set("assign", func(all otto.FunctionCall) otto.Value {
+		return Assign(all.Otto, all.Argument(0), all.Argument(1))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	v, err := vm.Run(`
+var d = {id: 42, name: "web-1", locked: true};
+Object.defineProperty(d, "refresh", {value: function() {}, enumerable: false});
+assign(d, {id: 42, name: "web-2", status: "active"});
+[Object.keys(d).join(","), JSON.stringify(d), typeof d.refresh, "locked" in d].join(" ");
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `id,name,status {"id":42,"name":"web-2","status":"active"} function false`
+	if got := v.String(); got != want {
+		t.Fatalf("want %q got %q", want, got)
+	}
+}
@@ -0,0 +1,56 @@
+package repl
+
+import (
+	"io"
+
+	"github.com/aybabtme/godotto/pkg/extra/format"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/robertkrimen/otto"
+)
+
+// UseConsoleTable adds `console.table(data, [columns])` to vm, which prints
+// data to w as a table. Columns are paths like `region.slug`, or `header=path`;
+// they suit the kind of resources in data when omitted.
+func UseConsoleTable(vm *otto.Otto, w io.Writer) error {
+	console, err := vm.Get("console")
+	if err != nil {
+		return err
+	}
+	if !console.IsObject() {
+		obj, err := vm.Object(`console = {}`)
+		if err != nil {
+			return err
+		}
+		console = obj.Value()
+	}
+	return console.Object().Set("table", func(all otto.FunctionCall) otto.Value {
+		vm := all.Otto
+		p := &format.Printer{Format: format.Table}
+		if arg := all.Argument(1); arg.IsDefined() {
+			var spec string
+			if arg.IsString() {
+				spec = ottoutil.String(vm, arg)
+			} else {
+				for i, col := range ottoutil.StringSlice(vm, arg) {
+					if i > 0 {
+						spec += ","
+					}
+					spec += col
+				}
+			}
+			cols, err := format.ParseColumns(spec)
+			if err != nil {
+				ottoutil.Throw(vm, err.Error())
+			}
+			p.Columns = cols
+		}
+		data, err := ToGo(all.Argument(0))
+		if err != nil {
+			ottoutil.Throw(vm, err.Error())
+		}
+		if err := p.Print(w, data); err != nil {
+			ottoutil.Throw(vm, err.Error())
+		}
+		return otto.UndefinedValue()
+	})
+}
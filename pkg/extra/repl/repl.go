@@ -6,16 +6,55 @@
 package repl
 
 import (
-	"encoding/json"
+	"fmt"
 	"io"
 	"strings"
 
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/format"
 	"github.com/robertkrimen/otto"
 	"gopkg.in/readline.v1"
 )
 
+// An Option configures a REPL.
+type Option func(*options)
+
+type options struct {
+	loop    *eventloop.Loop
+	printer *format.Printer
+	stdin   io.Reader
+}
+
+// UseLoop makes the REPL run the completions queued on loop while it waits
+// for input.
+func UseLoop(loop *eventloop.Loop) Option {
+	return func(opts *options) { opts.loop = loop }
+}
+
+// UsePrinter prints the results of expressions with p. The format of p can
+// be changed from the REPL with `.format`.
+func UsePrinter(p *format.Printer) Option {
+	return func(opts *options) { opts.printer = p }
+}
+
+// UseStdin reads the lines from in rather than from os.Stdin, such that
+// interactive programs run from the REPL can take it over.
+func UseStdin(in *Stdin) Option {
+	return func(opts *options) { opts.stdin = in }
+}
+
+type input struct {
+	line string
+	err  error
+}
+
 // Run runs a REPL with the given prompt and prelude.
-func Run(vm *otto.Otto, prompt, prelude string) error {
+func Run(vm *otto.Otto, prompt, prelude string, opts ...Option) error {
+	opt := &options{loop: eventloop.New(), printer: &format.Printer{Format: format.JSON}}
+	for _, o := range opts {
+		o(opt)
+	}
+
 	if prompt == "" {
 		prompt = ">"
 	}
@@ -26,6 +65,7 @@ func Run(vm *otto.Otto, prompt, prelude string) error {
 	rl, err := readline.NewEx(&readline.Config{
 		Prompt:       prompt,
 		AutoComplete: &autoCompleter{vm},
+		Stdin:        opt.stdin,
 	})
 	if err != nil {
 		return err
@@ -39,10 +79,39 @@ func Run(vm *otto.Otto, prompt, prelude string) error {
 		rl.Refresh()
 	}
 
+	// lines are read in the background, such that completions can be run
+	// while waiting for the next one
+	inputs := make(chan input)
+	readNext := make(chan struct{}, 1)
+	go func() {
+		for range readNext {
+			l, err := rl.Readline()
+			inputs <- input{line: l, err: err}
+		}
+	}()
+	defer close(readNext)
+
 	var d []string
 
 	for {
-		l, err := rl.Readline()
+		readNext <- struct{}{}
+		var (
+			l   string
+			err error
+		)
+	wait:
+		for {
+			select {
+			case in := <-inputs:
+				l, err = in.line, in.err
+				break wait
+			case <-opt.loop.Ready():
+				if err := opt.loop.RunPending(); err != nil {
+					printErr(rl.Stdout(), err)
+				}
+				rl.Refresh()
+			}
+		}
 		if err != nil {
 			if err == readline.ErrInterrupt {
 				if d != nil {
@@ -64,6 +133,14 @@ func Run(vm *otto.Otto, prompt, prelude string) error {
 			continue
 		}
 
+		if cmd, ok := commandOf(l); d == nil && ok {
+			if err := cmd(rl.Stdout(), opt, strings.Fields(l)); err != nil {
+				fmt.Fprintln(rl.Stdout(), err)
+			}
+			rl.Refresh()
+			continue
+		}
+
 		d = append(d, l)
 
 		s, err := vm.Compile("repl", strings.Join(d, "\n"))
@@ -76,22 +153,18 @@ func Run(vm *otto.Otto, prompt, prelude string) error {
 
 			v, err := vm.Eval(s)
 			if err != nil {
-				if oerr, ok := err.(*otto.Error); ok {
-					io.Copy(rl.Stdout(), strings.NewReader(oerr.String()))
-				} else {
-					io.Copy(rl.Stdout(), strings.NewReader(err.Error()))
-				}
+				printErr(rl.Stdout(), err)
 			} else {
 
 				if !v.IsDefined() {
 				} else {
 
-					gov, err := toGo(v)
+					gov, err := ToGo(v)
+					if err == nil {
+						err = opt.printer.Print(rl.Stdout(), gov)
+					}
 					if err != nil {
 						io.Copy(rl.Stdout(), strings.NewReader(err.Error()))
-					} else {
-						data, _ := json.MarshalIndent(gov, "", "  ")
-						rl.Stdout().Write(append(data, "\n"...))
 					}
 				}
 
@@ -104,7 +177,61 @@ func Run(vm *otto.Otto, prompt, prelude string) error {
 	return rl.Close()
 }
 
-func toGo(v otto.Value) (interface{}, error) {
+// A command of the REPL, given the words of its line.
+type command func(w io.Writer, opt *options, args []string) error
+
+// commands are those of the REPL, like `.format table id,name`.
+var commands = map[string]command{
+	".format": formatCommand,
+	".help":   helpCommand,
+}
+
+// commandOf finds the command a line runs. Other lines, like `.5 * 2`, are
+// JavaScript.
+func commandOf(line string) (command, bool) {
+	name := strings.SplitN(line, " ", 2)[0]
+	cmd, ok := commands[name]
+	return cmd, ok
+}
+
+func formatCommand(w io.Writer, opt *options, args []string) error {
+	if len(args) == 1 {
+		fmt.Fprintf(w, "%s\n", opt.printer.Format)
+		return nil
+	}
+	f, err := format.Parse(args[1])
+	if err != nil {
+		return err
+	}
+	var cols []format.Column
+	if len(args) > 2 {
+		if cols, err = format.ParseColumns(strings.Join(args[2:], "")); err != nil {
+			return err
+		}
+	}
+	opt.printer.Format, opt.printer.Columns = f, cols
+	return nil
+}
+
+func helpCommand(w io.Writer, opt *options, args []string) error {
+	fmt.Fprint(w, `.format                       show the output format
+.format json|yaml|table|csv|wide [columns]
+                              change the output format, with columns like
+                              "id,name,ip=networks.v4.ip_address"
+`)
+	return nil
+}
+
+func printErr(w io.Writer, err error) {
+	if oerr, ok := err.(*otto.Error); ok {
+		io.Copy(w, strings.NewReader(oerr.String()))
+	} else {
+		io.Copy(w, strings.NewReader(err.Error()))
+	}
+}
+
+// ToGo exports v, along with the values it holds.
+func ToGo(v otto.Value) (interface{}, error) {
 	gov, err := v.Export()
 	if err != nil {
 		return nil, err
@@ -128,7 +255,7 @@ func toGo(v otto.Value) (interface{}, error) {
 		case []otto.Value:
 			var out []interface{}
 			for _, el := range tgov {
-				outel, err := toGo(el)
+				outel, err := ToGo(el)
 				if err != nil {
 					return nil, err
 				}
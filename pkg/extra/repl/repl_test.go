@@ -0,0 +1,51 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aybabtme/godotto/pkg/extra/format"
+)
+
+func TestCommandOf(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		want bool
+	}{
+		{".format", true},
+		{".format table id,name", true},
+		{".help", true},
+		// JavaScript
+		{".5 * 2", false},
+		{".formatted", false},
+		{".foo", false},
+	} {
+		if _, got := commandOf(tt.line); got != tt.want {
+			t.Errorf("%q: want %v got %v", tt.line, tt.want, got)
+		}
+	}
+}
+
+func TestFormatCommand(t *testing.T) {
+	opt := &options{printer: &format.Printer{Format: format.JSON}}
+	run := func(line string) string {
+		cmd, ok := commandOf(line)
+		if !ok {
+			t.Fatalf("want %q to be a command", line)
+		}
+		var buf bytes.Buffer
+		if err := cmd(&buf, opt, strings.Fields(line)); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	run(".format table id,name")
+	if opt.printer.Format != format.Table || len(opt.printer.Columns) != 2 {
+		t.Fatalf("want a table of 2 columns, got %v with %d columns", opt.printer.Format, len(opt.printer.Columns))
+	}
+	if got := run(".format"); got != "table\n" {
+		t.Fatalf("want the format shown, got %q", got)
+	}
+}
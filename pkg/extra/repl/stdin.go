@@ -0,0 +1,130 @@
+package repl
+
+import (
+	"io"
+	"sync"
+)
+
+// Stdin shares an input between the REPL and the interactive programs it
+// runs, like remote shells. The program that took it last gets what's read,
+// until it gives it back.
+type Stdin struct {
+	r     io.Reader
+	start sync.Once
+	base  *holder
+
+	mu      sync.Mutex
+	holders []*holder
+	changed chan struct{} // closed when the holder changes
+	err     error
+}
+
+type holder struct {
+	s        *Stdin
+	data     chan []byte
+	released chan struct{}
+	buf      []byte
+}
+
+// NewStdin shares r, which is only read once something reads from it.
+func NewStdin(r io.Reader) *Stdin {
+	s := &Stdin{r: r, changed: make(chan struct{})}
+	s.base = s.newHolder()
+	s.holders = []*holder{s.base}
+	return s
+}
+
+func (s *Stdin) newHolder() *holder {
+	return &holder{s: s, data: make(chan []byte), released: make(chan struct{})}
+}
+
+// Read what's typed while nothing else holds the input.
+func (s *Stdin) Read(p []byte) (int, error) {
+	s.start.Do(func() { go s.pump() })
+	return s.base.Read(p)
+}
+
+// Take the input until release is called, after which in reads nothing more.
+func (s *Stdin) Take() (in io.Reader, release func()) {
+	s.start.Do(func() { go s.pump() })
+	h := s.newHolder()
+	s.mu.Lock()
+	if s.err != nil {
+		close(h.data) // nothing more to read
+	}
+	s.holders = append(s.holders, h)
+	s.notify()
+	s.mu.Unlock()
+	var once sync.Once
+	return h, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for i, other := range s.holders {
+				if other == h {
+					s.holders = append(s.holders[:i], s.holders[i+1:]...)
+					break
+				}
+			}
+			close(h.released)
+			s.notify()
+		})
+	}
+}
+
+// notify the pump that the holder changed; s.mu must be held.
+func (s *Stdin) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Stdin) current() (*holder, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.holders[len(s.holders)-1], s.changed
+}
+
+// pump hands what's read to the current holder, or to the next one if it
+// changes before taking it.
+func (s *Stdin) pump() {
+	for {
+		p := make([]byte, 1024)
+		n, err := s.r.Read(p)
+		for delivered := n == 0; !delivered; {
+			h, changed := s.current()
+			select {
+			case h.data <- p[:n]:
+				delivered = true
+			case <-changed:
+			}
+		}
+		if err != nil {
+			s.mu.Lock()
+			s.err = err
+			for _, h := range s.holders {
+				close(h.data)
+			}
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (h *holder) Read(p []byte) (int, error) {
+	if len(h.buf) == 0 {
+		select {
+		case data, ok := <-h.data:
+			if !ok {
+				h.s.mu.Lock()
+				defer h.s.mu.Unlock()
+				return 0, h.s.err
+			}
+			h.buf = data
+		case <-h.released:
+			return 0, io.EOF
+		}
+	}
+	n := copy(p, h.buf)
+	h.buf = h.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,46 @@
+package repl
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestStdinTake(t *testing.T) {
+	r, w := io.Pipe()
+	stdin := NewStdin(r)
+	read := func(in io.Reader) string {
+		p := make([]byte, 16)
+		n, err := in.Read(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(p[:n])
+	}
+
+	go w.Write([]byte("1 + 1\n"))
+	if got := read(stdin); got != "1 + 1\n" {
+		t.Fatalf("want the REPL to read the input, got %q", got)
+	}
+
+	in, release := stdin.Take()
+	go w.Write([]byte("ls\n"))
+	if got := read(in); got != "ls\n" {
+		t.Fatalf("want the holder to read the input, got %q", got)
+	}
+	release()
+	if n, err := in.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Fatalf("want nothing more read once released, got %d, %v", n, err)
+	}
+
+	go func() {
+		w.Write([]byte("2 + 2\n"))
+		w.Close()
+	}()
+	if got := read(stdin); got != "2 + 2\n" {
+		t.Fatalf("want the REPL to read the input again, got %q", got)
+	}
+	if rest, err := ioutil.ReadAll(stdin); err != nil || len(rest) != 0 {
+		t.Fatalf("want the end of the input, got %q, %v", rest, err)
+	}
+}
@@ -0,0 +1,8 @@
+package shellutil
+
+import "strings"
+
+// Quote makes s a single word for sh, whatever it contains.
+func Quote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
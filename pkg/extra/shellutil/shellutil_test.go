@@ -0,0 +1,18 @@
+package shellutil
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	for _, s := range []string{"", "hello", "it's", "$HOME `id` \"x\" \\ *", "a\nb", "''"} {
+		out, err := exec.Command("sh", "-c", "printf %s "+Quote(s)).Output()
+		if err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		if got := string(out); got != s {
+			t.Errorf("want %q got %q", s, got)
+		}
+	}
+}
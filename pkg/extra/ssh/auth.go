@@ -0,0 +1,198 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// PassphraseFunc asks for the passphrase of the encrypted private key in a
+// file.
+type PassphraseFunc func(filename string) ([]byte, error)
+
+// LoadIdentity reads a private key from a file, asking for its passphrase
+// when it's encrypted. When a certificate is found next to the key, as
+// `id_rsa-cert.pub` for `id_rsa`, the key presents it.
+func LoadIdentity(filename string, passphrase PassphraseFunc) (ssh.Signer, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var signer ssh.Signer
+	block, _ := pem.Decode(data)
+	if block != nil && block.Type == "OPENSSH PRIVATE KEY" && encryptedOpenSSHKey(block.Bytes) {
+		return nil, fmt.Errorf("%s is encrypted in the OpenSSH format, which can't be decrypted here: "+
+			"add it to the agent with `ssh-add %s`, or convert it with `ssh-keygen -p -m PEM -f %s`", filename, filename, filename)
+	}
+	if block != nil && x509.IsEncryptedPEMBlock(block) {
+		if passphrase == nil {
+			return nil, fmt.Errorf("%s is encrypted and there's no way to ask for its passphrase", filename)
+		}
+		pass, perr := passphrase(filename)
+		if perr != nil {
+			return nil, perr
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(data, pass)
+	} else {
+		signer, err = ssh.ParsePrivateKey(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	certFile := filename + "-cert.pub"
+	if _, err := os.Stat(certFile); os.IsNotExist(err) {
+		return signer, nil
+	}
+	return certSigner(certFile, signer)
+}
+
+// encryptedOpenSSHKey tells if a key in the format of OpenSSH is encrypted,
+// which the ssh package can't decrypt.
+func encryptedOpenSSHKey(data []byte) bool {
+	magic := []byte("openssh-key-v1\x00")
+	if !bytes.HasPrefix(data, magic) {
+		return false
+	}
+	var header struct {
+		CipherName string
+		KdfName    string
+		Rest       []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(data[len(magic):], &header); err != nil {
+		return false
+	}
+	return header.KdfName != "none" || header.CipherName != "none"
+}
+
+// certSigner presents the certificate in a file along with the key of
+// signer.
+func certSigner(filename string, signer ssh.Signer) (ssh.Signer, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s isn't a certificate", filename)
+	}
+	if !bytes.Equal(cert.Key.Marshal(), signer.PublicKey().Marshal()) {
+		return nil, fmt.Errorf("%s isn't a certificate of the key", filename)
+	}
+	return ssh.NewCertSigner(cert, signer)
+}
+
+// identities loads the private keys of files once, such that passphrases are
+// asked only once.
+type identities struct {
+	passphrase PassphraseFunc
+
+	mu      sync.Mutex
+	signers map[string]ssh.Signer
+}
+
+func (ids *identities) load(filename string) (ssh.Signer, error) {
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+	if signer, ok := ids.signers[filename]; ok {
+		return signer, nil
+	}
+	signer, err := LoadIdentity(filename, ids.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if ids.signers == nil {
+		ids.signers = make(map[string]ssh.Signer)
+	}
+	ids.signers[filename] = signer
+	return signer, nil
+}
+
+// authMethods to try for a session. SSH clients only try one method of each
+// kind, so all the keys are offered by a single public key method: the
+// identities of the session first, then those of the agent. The default
+// identities are only loaded when the agent has no keys, so that passphrases
+// aren't asked for needlessly. Those that can't be loaded are skipped, and
+// told about by skipped, as when the host refuses the others.
+func (svc *sshSvc) authMethods(opts *connectOpts) (methods []ssh.AuthMethod, skipped func() []string, err error) {
+	var explicit []ssh.Signer
+	for _, filename := range opts.Identities {
+		signer, err := svc.ids.load(expandHome(filename))
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading identity: %v", err)
+		}
+		explicit = append(explicit, signer)
+	}
+	for _, filename := range opts.Certificates {
+		for _, signer := range explicit {
+			if cs, err := certSigner(expandHome(filename), signer); err == nil {
+				explicit = append([]ssh.Signer{cs}, explicit...)
+				break
+			}
+		}
+	}
+
+	var (
+		mu     sync.Mutex
+		failed []string
+	)
+	skipped = func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return failed
+	}
+	methods = append(methods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		signers := append([]ssh.Signer(nil), explicit...)
+		if svc.agent != nil {
+			if fromAgent, err := svc.agent.Signers(); err == nil {
+				signers = append(signers, fromAgent...)
+			}
+		}
+		if len(signers) > len(explicit) {
+			return signers, nil
+		}
+		for _, filename := range svc.defaultIdentities {
+			if _, err := os.Stat(filename); err != nil {
+				continue
+			}
+			// a key that can't be loaded shouldn't prevent others from
+			// being tried
+			signer, err := svc.ids.load(filename)
+			if err != nil {
+				mu.Lock()
+				failed = append(failed, err.Error())
+				mu.Unlock()
+				continue
+			}
+			signers = append(signers, signer)
+		}
+		return signers, nil
+	}))
+	if svc.auth != nil {
+		methods = append(methods, svc.auth)
+	}
+	if opts.Password != "" {
+		password := opts.Password
+		methods = append(methods,
+			ssh.Password(password),
+			// rescue images often ask for passwords this way
+			ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}),
+		)
+	}
+	return methods, skipped, nil
+}
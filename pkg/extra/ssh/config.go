@@ -0,0 +1,158 @@
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// sshConfig is what's understood of an OpenSSH client config file: the
+// User, Port, HostName, IdentityFile and CertificateFile of Host blocks.
+// Other keywords, and Match blocks, are ignored.
+type sshConfig struct {
+	blocks []configBlock
+}
+
+type configBlock struct {
+	patterns []string
+	settings [][2]string // keyword, in lower case, and value
+}
+
+// hostConfig are the settings found for a host.
+type hostConfig struct {
+	User         string
+	Port         string
+	HostName     string
+	Identities   []string
+	Certificates []string
+}
+
+func loadConfig(filename string) (*sshConfig, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return &sshConfig{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cfg, err := parseConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return cfg, nil
+}
+
+func parseConfig(r io.Reader) (*sshConfig, error) {
+	cfg := &sshConfig{}
+	// settings before the first Host block apply to all hosts
+	cur := &configBlock{patterns: []string{"*"}}
+	scan := bufio.NewScanner(r)
+	for lineno := 1; scan.Scan(); lineno++ {
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keyword, value := splitConfigLine(line)
+		if value == "" {
+			return nil, fmt.Errorf("line %d: no value for %q", lineno, keyword)
+		}
+		switch keyword {
+		case "host":
+			cfg.blocks = append(cfg.blocks, *cur)
+			cur = &configBlock{patterns: strings.Fields(value)}
+		case "match":
+			cfg.blocks = append(cfg.blocks, *cur)
+			cur = &configBlock{} // matches nothing
+		case "user", "port", "hostname", "identityfile", "certificatefile":
+			cur.settings = append(cur.settings, [2]string{keyword, unquote(value)})
+		}
+	}
+	cfg.blocks = append(cfg.blocks, *cur)
+	return cfg, scan.Err()
+}
+
+// splitConfigLine splits `Keyword value` and `Keyword=value`.
+func splitConfigLine(line string) (keyword, value string) {
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return strings.ToLower(line), ""
+	}
+	keyword, value = line[:i], strings.TrimSpace(line[i:])
+	value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+	return strings.ToLower(keyword), value
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// lookup the settings for a host known by any of names. As with OpenSSH, the
+// first value found for a keyword is used, except for identities and
+// certificates which add up.
+func (cfg *sshConfig) lookup(names ...string) hostConfig {
+	var hc hostConfig
+	for _, b := range cfg.blocks {
+		if !b.matches(names) {
+			continue
+		}
+		for _, kv := range b.settings {
+			switch v := kv[1]; kv[0] {
+			case "user":
+				if hc.User == "" {
+					hc.User = v
+				}
+			case "port":
+				if hc.Port == "" {
+					hc.Port = v
+				}
+			case "hostname":
+				if hc.HostName == "" {
+					hc.HostName = v
+				}
+			case "identityfile":
+				hc.Identities = append(hc.Identities, expandHome(v))
+			case "certificatefile":
+				hc.Certificates = append(hc.Certificates, expandHome(v))
+			}
+		}
+	}
+	return hc
+}
+
+// matches tells if a name matches a pattern of the block, and no negated
+// pattern.
+func (b *configBlock) matches(names []string) bool {
+	matched := false
+	for _, pattern := range b.patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+		for _, name := range names {
+			if name == "" {
+				continue
+			}
+			if ok, _ := path.Match(pattern, name); !ok {
+				continue
+			}
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+// expandHome replaces a leading `~` with the home directory.
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		return filepath.Join(os.Getenv("HOME"), p[1:])
+	}
+	return p
+}
@@ -0,0 +1,319 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/context"
+
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/aybabtme/godotto/pkg/extra/shellutil"
+	"github.com/robertkrimen/otto"
+)
+
+// execOpts tell how to run a command.
+type execOpts struct {
+	Cmd      string
+	Env      map[string]string
+	Stdin    *string
+	Timeout  time.Duration
+	Sudo     string // user to run as, none if empty
+	Stream   bool   // copy the output to the output of the process
+	Check    bool   // fail on non-zero exit codes
+	OnLine   otto.Value
+	StreamTo [2]io.Writer // stdout and stderr of the process
+	Prefix   string       // of the lines streamed
+}
+
+// execResult of a command.
+type execResult struct {
+	Stdout   string
+	Stderr   string
+	Code     int
+	Duration time.Duration
+}
+
+// outputLine is a line printed by a command, on "stdout" or "stderr".
+type outputLine struct {
+	Stream string
+	Text   string
+}
+
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (svc *sshSvc) execArgs(vm *otto.Otto, cmd, v otto.Value) *execOpts {
+	opts := &execOpts{
+		Cmd:      ottoutil.String(vm, cmd),
+		Check:    true,
+		StreamTo: [2]io.Writer{svc.stdout, svc.stderr},
+	}
+	if !v.IsDefined() {
+		return opts
+	}
+	if v.Object() == nil {
+		ottoutil.Throw(vm, "optional arguments must be an Object")
+	}
+	if env := ottoutil.GetObject(vm, v, "env", false); env.IsDefined() {
+		opts.Env = ottoutil.StringMap(vm, env)
+		for name := range opts.Env {
+			if !envName.MatchString(name) {
+				ottoutil.Throw(vm, "invalid environment variable name %q", name)
+			}
+		}
+	}
+	if stdin := ottoutil.GetObject(vm, v, "stdin", false); stdin.IsDefined() {
+		s := ottoutil.String(vm, stdin)
+		opts.Stdin = &s
+	}
+	if dur := ottoutil.GetObject(vm, v, "timeout", false); dur.IsDefined() {
+		opts.Timeout = ottoutil.Duration(vm, dur)
+	}
+	switch sudo := ottoutil.GetObject(vm, v, "sudo", false); {
+	case sudo.IsString():
+		opts.Sudo = ottoutil.String(vm, sudo)
+	case sudo.IsDefined() && ottoutil.Bool(vm, sudo):
+		opts.Sudo = "root"
+	}
+	if stream := ottoutil.GetObject(vm, v, "stream", false); stream.IsDefined() {
+		opts.Stream = ottoutil.Bool(vm, stream)
+	}
+	if check := ottoutil.GetObject(vm, v, "check", false); check.IsDefined() {
+		opts.Check = ottoutil.Bool(vm, check)
+	}
+	if onLine := ottoutil.GetObject(vm, v, "on_line", false); onLine.IsDefined() {
+		if !onLine.IsFunction() {
+			ottoutil.Throw(vm, "on_line must be a function")
+		}
+		opts.OnLine = onLine
+	}
+	return opts
+}
+
+// command to run in the remote shell, wrapped to set the environment, run as
+// another user and be killed once its timeout expires.
+//
+// The deadline is enforced remotely with `timeout -s KILL`, which kills the
+// command along with the processes it started, unless they left its process
+// group. Hosts without `timeout` only have the session closed on them, which
+// leaves the command running until it next writes its output.
+func (opts *execOpts) command() string {
+	if opts.Timeout <= 0 {
+		if len(opts.Env) == 0 && opts.Sudo == "" {
+			return opts.Cmd
+		}
+		return opts.wrap("")
+	}
+	secs := (opts.Timeout + time.Second - 1) / time.Second
+	deadline := fmt.Sprintf("timeout -s KILL %ds", secs)
+	return "sh -c " + shellutil.Quote("if command -v timeout >/dev/null 2>&1; then exec "+
+		opts.wrap(deadline)+"; else exec "+opts.wrap("")+"; fi")
+}
+
+// wrap the command in a shell, under the given deadline if there's one. It
+// runs within sudo, such that the whole command can be killed.
+func (opts *execOpts) wrap(deadline string) string {
+	var parts []string
+	if opts.Sudo != "" {
+		parts = append(parts, "sudo", "-n")
+		if opts.Sudo != "root" {
+			parts = append(parts, "-u", shellutil.Quote(opts.Sudo))
+		}
+	}
+	if deadline != "" {
+		parts = append(parts, deadline)
+	}
+	if len(opts.Env) > 0 {
+		names := make([]string, 0, len(opts.Env))
+		for name := range opts.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		parts = append(parts, "env")
+		for _, name := range names {
+			parts = append(parts, name+"="+shellutil.Quote(opts.Env[name]))
+		}
+	}
+	parts = append(parts, "sh", "-c", shellutil.Quote(opts.Cmd))
+	return strings.Join(parts, " ")
+}
+
+// run a command. Lines of output are sent on lines as they're printed, if
+// it's not nil, and it's closed when the command is done.
+func run(ctx context.Context, client *ssh.Client, opts *execOpts, lines chan<- outputLine) (*execResult, error) {
+	if lines != nil {
+		defer close(lines)
+	}
+	ss, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer ss.Close()
+
+	emit := func(stream, text string) {
+		if opts.Stream {
+			w := opts.StreamTo[0]
+			if stream == "stderr" {
+				w = opts.StreamTo[1]
+			}
+			fmt.Fprintln(w, opts.Prefix+text)
+		}
+		if lines != nil {
+			lines <- outputLine{Stream: stream, Text: text}
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	outw := &lineWriter{buf: &stdout, emit: func(text string) { emit("stdout", text) }}
+	errw := &lineWriter{buf: &stderr, emit: func(text string) { emit("stderr", text) }}
+	ss.Stdout, ss.Stderr = outw, errw
+	if opts.Stdin != nil {
+		ss.Stdin = strings.NewReader(*opts.Stdin)
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	if err := ss.Start(opts.command()); err != nil {
+		return nil, err
+	}
+	done := make(chan error, 1)
+	go func() { done <- ss.Wait() }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		_ = ss.Signal(ssh.SIGKILL)
+		_ = ss.Close()
+		<-done
+		if ctx.Err() == context.DeadlineExceeded && opts.Timeout > 0 {
+			return nil, fmt.Errorf("command timed out after %v", opts.Timeout)
+		}
+		return nil, ctx.Err()
+	}
+	outw.flush()
+	errw.flush()
+
+	res := &execResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(start),
+	}
+	switch e := err.(type) {
+	case nil:
+	case *ssh.ExitError:
+		res.Code = e.ExitStatus()
+	default:
+		return nil, err
+	}
+	return res, nil
+}
+
+// lineWriter keeps what's written to it, and emits it line by line.
+type lineWriter struct {
+	buf     *bytes.Buffer
+	partial []byte
+	emit    func(string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(strings.TrimSuffix(string(w.partial[:i]), "\r"))
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *lineWriter) flush() {
+	if len(w.partial) > 0 {
+		w.emit(string(w.partial))
+		w.partial = nil
+	}
+}
+
+// syncWriter serializes writes to a writer shared by the goroutines copying
+// the output of commands.
+type syncWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// exec runs a command from JS, calling its line callback from the goroutine
+// of the VM as lines are printed.
+func (svc *sshSvc) exec(vm *otto.Otto, client *ssh.Client, opts *execOpts) *execResult {
+	ctx, cancel := context.WithCancel(svc.ctx)
+	defer cancel()
+
+	var lines chan outputLine
+	if opts.OnLine.IsFunction() {
+		lines = make(chan outputLine, 64)
+		// if the callback throws, the command is killed and its output
+		// drained
+		defer func() {
+			cancel()
+			for range lines {
+			}
+		}()
+	}
+	type outcome struct {
+		res *execResult
+		err error
+	}
+	outc := make(chan outcome, 1)
+	go func() {
+		res, err := run(ctx, client, opts, lines)
+		outc <- outcome{res, err}
+	}()
+	if lines != nil {
+		for line := range lines {
+			ottoutil.Call(vm, opts.OnLine, nil, line.Text, line.Stream)
+		}
+	}
+	out := <-outc
+	if out.err != nil {
+		ottoutil.Throw(vm, out.err.Error())
+	}
+	if opts.Check && out.res.Code != 0 {
+		msg := strings.TrimSpace(out.res.Stderr)
+		if msg == "" {
+			msg = strings.TrimSpace(out.res.Stdout)
+		}
+		ottoutil.Throw(vm, "command exited with code %d: %s", out.res.Code, msg)
+	}
+	return out.res
+}
+
+// resultToVM is a result as seen from JS. It reads as its stdout when used
+// as a string, as the output of commands used to be.
+func resultToVM(vm *otto.Otto, res *execResult) otto.Value {
+	v := ottoutil.ToPkg(vm, map[string]interface{}{
+		"stdout":   res.Stdout,
+		"stderr":   res.Stderr,
+		"code":     res.Code,
+		"duration": res.Duration.String(),
+	})
+	return ottoutil.SetMethods(vm, v, map[string]func(otto.FunctionCall) otto.Value{
+		"toString": func(all otto.FunctionCall) otto.Value {
+			return ottoutil.ToValue(all.Otto, res.Stdout)
+		},
+	})
+}
@@ -0,0 +1,223 @@
+package ssh
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/robertkrimen/otto"
+)
+
+// forwardMethods of sessions, to reach the services of the network of a host
+// from local ports. Ports are forwarded until they're closed, or until their
+// session is.
+func (svc *sshSvc) forwardMethods(client *ssh.Client) map[string]interface{} {
+	return map[string]interface{}{
+		"forward": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			if len(all.ArgumentList) != 3 {
+				ottoutil.Throw(vm, "forward takes a local port, a remote host and a remote port")
+			}
+			local := ottoutil.Int(vm, all.Argument(0))
+			remote := net.JoinHostPort(
+				ottoutil.String(vm, all.Argument(1)),
+				strconv.Itoa(ottoutil.Int(vm, all.Argument(2))),
+			)
+			return svc.listen(vm, client, local, func(conn net.Conn) {
+				forwardConn(client, conn, remote)
+			})
+		},
+		"socks": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			var local int
+			if port := all.Argument(0); port.IsDefined() {
+				local = ottoutil.Int(vm, port)
+			}
+			return svc.listen(vm, client, local, func(conn net.Conn) {
+				serveSOCKS(client, conn)
+			})
+		},
+	}
+}
+
+// listen on a local port, 0 to pick any, handling each connection in its
+// own goroutine.
+func (svc *sshSvc) listen(vm *otto.Otto, client *ssh.Client, port int, handle func(net.Conn)) otto.Value {
+	if port < 0 || port > 65535 {
+		ottoutil.Throw(vm, "invalid port %d", port)
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	svc.closeWith(client, ln)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	v := ottoutil.ToPkg(vm, map[string]interface{}{
+		"port":    addr.Port,
+		"address": addr.String(),
+	})
+	return ottoutil.SetMethods(vm, v, map[string]func(otto.FunctionCall) otto.Value{
+		"close": func(all otto.FunctionCall) otto.Value {
+			_ = ln.Close()
+			return q
+		},
+	})
+}
+
+func forwardConn(client *ssh.Client, conn net.Conn, remote string) {
+	rconn, err := client.Dial("tcp", remote)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	pipe(conn, rconn)
+}
+
+// pipe copies between two connections until both are done.
+func pipe(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	copyHalf := func(dst, src net.Conn) {
+		_, _ = io.Copy(dst, src)
+		if cw, ok := dst.(interface {
+			CloseWrite() error
+		}); ok {
+			_ = cw.CloseWrite()
+		} else {
+			_ = dst.Close()
+		}
+		done <- struct{}{}
+	}
+	go copyHalf(a, b)
+	go copyHalf(b, a)
+	<-done
+	<-done
+	_ = a.Close()
+	_ = b.Close()
+}
+
+// SOCKS5, as in RFC 1928, without authentication and only for CONNECT.
+const (
+	socksVersion   = 5
+	socksNoAuth    = 0
+	socksNoMethods = 0xff
+	socksConnect   = 1
+
+	socksIPv4   = 1
+	socksDomain = 3
+	socksIPv6   = 4
+
+	socksSucceeded      = 0
+	socksUnreachable    = 4
+	socksNotSupported   = 7
+	socksBadAddressType = 8
+)
+
+// serveSOCKS connects a SOCKS client to where it asks, through a host.
+func serveSOCKS(client *ssh.Client, conn net.Conn) {
+	remote, err := socksRequest(conn)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	rconn, err := client.Dial("tcp", remote)
+	if err != nil {
+		_ = socksReply(conn, socksUnreachable)
+		_ = conn.Close()
+		return
+	}
+	if err := socksReply(conn, socksSucceeded); err != nil {
+		_ = conn.Close()
+		_ = rconn.Close()
+		return
+	}
+	pipe(conn, rconn)
+}
+
+// socksRequest negotiates with a SOCKS client, and reads the address it
+// wants to connect to.
+func socksRequest(rw io.ReadWriter) (string, error) {
+	var hdr [2]byte // version, number of methods
+	if _, err := io.ReadFull(rw, hdr[:]); err != nil {
+		return "", err
+	}
+	if hdr[0] != socksVersion {
+		return "", fmt.Errorf("unsupported SOCKS version %d", hdr[0])
+	}
+	methods := make([]byte, hdr[1])
+	if _, err := io.ReadFull(rw, methods); err != nil {
+		return "", err
+	}
+	method := byte(socksNoMethods)
+	for _, m := range methods {
+		if m == socksNoAuth {
+			method = socksNoAuth
+		}
+	}
+	if _, err := rw.Write([]byte{socksVersion, method}); err != nil {
+		return "", err
+	}
+	if method == socksNoMethods {
+		return "", errors.New("SOCKS client requires authentication")
+	}
+
+	var req [4]byte // version, command, reserved, address type
+	if _, err := io.ReadFull(rw, req[:]); err != nil {
+		return "", err
+	}
+	if req[1] != socksConnect {
+		_ = socksReply(rw, socksNotSupported)
+		return "", fmt.Errorf("unsupported SOCKS command %d", req[1])
+	}
+	var host string
+	switch req[3] {
+	case socksIPv4, socksIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if req[3] == socksIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(rw, ip); err != nil {
+			return "", err
+		}
+		host = ip.String()
+	case socksDomain:
+		var n [1]byte
+		if _, err := io.ReadFull(rw, n[:]); err != nil {
+			return "", err
+		}
+		name := make([]byte, n[0])
+		if _, err := io.ReadFull(rw, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		_ = socksReply(rw, socksBadAddressType)
+		return "", fmt.Errorf("unsupported SOCKS address type %d", req[3])
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(rw, port[:]); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))), nil
+}
+
+// socksReply tells a SOCKS client how its request went. The bound address
+// is left empty, as connections are made by the host.
+func socksReply(w io.Writer, status byte) error {
+	_, err := w.Write([]byte{socksVersion, status, 0, socksIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
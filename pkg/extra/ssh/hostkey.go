@@ -0,0 +1,295 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyPolicy tells how the host keys of servers are verified.
+type HostKeyPolicy string
+
+// The policies for host keys.
+const (
+	// Strict refuses servers whose key isn't known.
+	Strict HostKeyPolicy = "strict"
+	// TOFU trusts the key of servers seen for the first time, and remembers
+	// it. It refuses servers whose key changed.
+	TOFU HostKeyPolicy = "tofu"
+	// Insecure accepts any key.
+	Insecure HostKeyPolicy = "insecure"
+)
+
+// ParseHostKeyPolicy parses the name of a policy.
+func ParseHostKeyPolicy(s string) (HostKeyPolicy, error) {
+	switch p := HostKeyPolicy(s); p {
+	case Strict, TOFU, Insecure:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown host key policy %q, want one of %s, %s, %s", s, Strict, TOFU, Insecure)
+}
+
+// hostKeyFailure prefixes the errors of host key callbacks.
+const hostKeyFailure = "host key verification failed"
+
+func hostKeyError(format string, args ...interface{}) error {
+	return fmt.Errorf(hostKeyFailure+": "+format, args...)
+}
+
+// knownHosts are the keys of the servers that were seen, in a known_hosts
+// file if there's one and in memory otherwise.
+type knownHosts struct {
+	mu   sync.Mutex
+	path string
+	mem  map[string][]ssh.PublicKey // addresses to keys trusted in this process
+}
+
+func newKnownHosts(path string) *knownHosts {
+	return &knownHosts{path: path, mem: make(map[string][]ssh.PublicKey)}
+}
+
+// callback verifies host keys with the policy. When fingerprint is set, the
+// key must have it, whatever the policy, as when a script learned the key of
+// a droplet out-of-band.
+func (kh *knownHosts) callback(policy HostKeyPolicy, fingerprint string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if fingerprint != "" {
+			if got := ssh.FingerprintSHA256(key); got != fingerprint {
+				return hostKeyError("%s has fingerprint %s, want %s", hostname, got, fingerprint)
+			}
+			if policy == Insecure {
+				return nil
+			}
+			// the key is right, even if another one was known
+			if known, _ := kh.check(hostname, remote, key); known {
+				return nil
+			}
+			if err := kh.trust(hostname, key); err != nil {
+				return hostKeyError("remembering key: %v", err)
+			}
+			return nil
+		}
+		if policy == Insecure {
+			return nil
+		}
+		known, err := kh.check(hostname, remote, key)
+		switch {
+		case err != nil:
+			return err
+		case known:
+			return nil
+		case policy == TOFU:
+			if err := kh.trust(hostname, key); err != nil {
+				return hostKeyError("remembering key: %v", err)
+			}
+			return nil
+		}
+		return hostKeyError("%s is unknown (%s %s), add it to known_hosts or use another host_key policy",
+			hostname, key.Type(), ssh.FingerprintSHA256(key))
+	}
+}
+
+// check tells if the key of a host is known, or errors if the host is known
+// with another key of the same type.
+func (kh *knownHosts) check(hostname string, remote net.Addr, key ssh.PublicKey) (bool, error) {
+	kh.mu.Lock()
+	defer kh.mu.Unlock()
+	addr := knownhosts.Normalize(hostname)
+	for _, want := range kh.mem[addr] {
+		if want.Type() != key.Type() {
+			continue
+		}
+		if bytes.Equal(want.Marshal(), key.Marshal()) {
+			return true, nil
+		}
+		return false, mismatch(hostname, key)
+	}
+	known, err := kh.fromFile(hostname, remote, key)
+	if kerr, ok := err.(*knownhosts.KeyError); ok {
+		for _, want := range kerr.Want {
+			if want.Key.Type() == key.Type() {
+				return false, mismatch(hostname, key)
+			}
+		}
+		// only keys of other types are known
+		return false, nil
+	}
+	return known, err
+}
+
+// fromFile checks a key with the known_hosts file, if there's one.
+func (kh *knownHosts) fromFile(hostname string, remote net.Addr, key ssh.PublicKey) (bool, error) {
+	if kh.path == "" {
+		return false, nil
+	}
+	if _, err := os.Stat(kh.path); os.IsNotExist(err) {
+		return false, nil
+	}
+	cb, err := knownhosts.New(kh.path)
+	if err != nil {
+		return false, hostKeyError("reading known hosts: %v", err)
+	}
+	err = cb(hostname, remote, key)
+	return err == nil, err
+}
+
+// algorithms lists the types of the keys known for a host before the other
+// types, as OpenSSH does, such that the host is asked for a key that can be
+// checked. There are none for unknown hosts, which get the defaults.
+func (kh *knownHosts) algorithms(hostname string) []string {
+	kh.mu.Lock()
+	defer kh.mu.Unlock()
+	known := make(map[string]bool)
+	for _, key := range kh.mem[knownhosts.Normalize(hostname)] {
+		known[key.Type()] = true
+	}
+	// no key matches the probe, so all those of the host are wanted
+	_, err := kh.fromFile(hostname, &net.TCPAddr{IP: net.IPv4zero}, probeKey{})
+	if kerr, ok := err.(*knownhosts.KeyError); ok {
+		for _, want := range kerr.Want {
+			known[want.Key.Type()] = true
+		}
+	}
+	if len(known) == 0 {
+		return nil
+	}
+	var first, rest []string
+	for _, algo := range hostKeyAlgorithms {
+		if known[algo] {
+			first = append(first, algo)
+		} else {
+			rest = append(rest, algo)
+		}
+	}
+	return append(first, rest...)
+}
+
+// the host key algorithms of the ssh package, in its order of preference
+var hostKeyAlgorithms = []string{
+	ssh.CertAlgoRSAv01, ssh.CertAlgoDSAv01, ssh.CertAlgoECDSA256v01,
+	ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01, ssh.CertAlgoED25519v01,
+	ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+	ssh.KeyAlgoRSA, ssh.KeyAlgoDSA,
+	ssh.KeyAlgoED25519,
+}
+
+// probeKey is a key that no host has.
+type probeKey struct{}
+
+func (probeKey) Type() string    { return "probe" }
+func (probeKey) Marshal() []byte { return []byte("probe") }
+func (probeKey) Verify(data []byte, sig *ssh.Signature) error {
+	return fmt.Errorf("probe keys can't verify")
+}
+
+func mismatch(hostname string, key ssh.PublicKey) error {
+	return hostKeyError("key of %s changed to %s %s, refusing to connect (use ssh.forget if the host was replaced)",
+		hostname, key.Type(), ssh.FingerprintSHA256(key))
+}
+
+// trust remembers the key of a host, appending it to the known_hosts file.
+func (kh *knownHosts) trust(hostname string, key ssh.PublicKey) error {
+	kh.mu.Lock()
+	defer kh.mu.Unlock()
+	addr := knownhosts.Normalize(hostname)
+	kh.mem[addr] = append(kh.mem[addr], key)
+	if kh.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(kh.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(kh.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, knownhosts.Line([]string{addr}, key)); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// forget the keys of a host, as when the droplet that had its address was
+// destroyed. It tells how many keys were forgotten.
+func (kh *knownHosts) forget(hostname string) (int, error) {
+	kh.mu.Lock()
+	defer kh.mu.Unlock()
+	addr := knownhosts.Normalize(hostname)
+	n := len(kh.mem[addr])
+	delete(kh.mem, addr)
+	if kh.path == "" {
+		return n, nil
+	}
+	data, err := ioutil.ReadFile(kh.path)
+	if os.IsNotExist(err) {
+		return n, nil
+	} else if err != nil {
+		return n, err
+	}
+	var kept bytes.Buffer
+	removed := 0
+	scan := bufio.NewScanner(bytes.NewReader(data))
+	for scan.Scan() {
+		line := scan.Text()
+		if linesHost(line, addr) {
+			removed++
+			continue
+		}
+		kept.WriteString(line + "\n")
+	}
+	if err := scan.Err(); err != nil {
+		return n, err
+	}
+	if removed == 0 {
+		return n, nil
+	}
+	if err := ioutil.WriteFile(kh.path, kept.Bytes(), 0600); err != nil {
+		return n, err
+	}
+	// keys trusted in this process are also in the file
+	if removed > n {
+		n = removed
+	}
+	return n, nil
+}
+
+// linesHost tells if a line of a known_hosts file is about addr alone. Lines
+// with markers or about many hosts are left alone.
+func linesHost(line, addr string) bool {
+	marker, hosts, _, _, _, err := ssh.ParseKnownHosts([]byte(line))
+	if err != nil || marker != "" || len(hosts) != 1 {
+		return false
+	}
+	host := hosts[0]
+	if !strings.HasPrefix(host, "|1|") {
+		return host == addr
+	}
+	parts := strings.Split(host[len("|1|"):], "|")
+	if len(parts) != 2 {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	want, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(addr))
+	return hmac.Equal(mac.Sum(nil), want)
+}
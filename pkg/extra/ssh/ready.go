@@ -0,0 +1,222 @@
+package ssh
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/context"
+
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/aybabtme/godotto/pkg/extra/shellutil"
+	"github.com/digitalocean/godo"
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	readyTimeout  = 10 * time.Minute
+	readyLogLines = 20
+)
+
+// where cloud-init keeps its state, and its output
+var (
+	cloudInitDir = "/var/lib/cloud"
+	cloudInitLog = "/var/log/cloud-init-output.log"
+)
+
+// readyResult tells how cloud-init provisioned a droplet.
+type readyResult struct {
+	Status     string   `json:"status"` // done, error, or disabled without cloud-init
+	Errors     []string `json:"errors"`
+	Datasource string   `json:"datasource,omitempty"`
+	Log        string   `json:"log"` // the end of its output
+	Duration   string   `json:"duration"`
+}
+
+// waitReady waits until cloud-init is done provisioning a host, once it's
+// reachable. The host is reconnected to when the connection drops, as when
+// cloud-init reboots it, but not when it refuses the credentials.
+func (svc *sshSvc) waitReady(ctx context.Context, opts *connectOpts, timeout time.Duration, logLines int) (*readyResult, error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		client, err := svc.connect(ctx, opts)
+		if err == nil {
+			var res *readyResult
+			res, err = cloudInit(ctx, client, logLines)
+			_ = svc.release(client)
+			if err == nil {
+				res.Duration = time.Since(start).String()
+				return res, nil
+			}
+		}
+		if _, fatal := err.(*handshakeError); fatal {
+			// as rejected credentials or host keys
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			if err == ctx.Err() {
+				return nil, fmt.Errorf("not ready after %v", timeout)
+			}
+			return nil, fmt.Errorf("not ready after %v: %v", timeout, err)
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// cloudInit waits for cloud-init to finish, and tells how it went.
+func cloudInit(ctx context.Context, client *ssh.Client, logLines int) (*readyResult, error) {
+	sh := func(script string) (*execResult, error) {
+		// the login shell of the user may not be a POSIX one
+		return run(ctx, client, &execOpts{Cmd: "sh -c " + shellutil.Quote(script)}, nil)
+	}
+	out, err := sh(fmt.Sprintf(`if [ ! -d %[1]s ]; then echo disabled; exit 0; fi
+while [ ! -f %[1]s/instance/boot-finished ]; do sleep 1; done
+echo done`, shellutil.Quote(cloudInitDir)))
+	if err != nil {
+		return nil, err
+	}
+	if out.Code != 0 {
+		return nil, fmt.Errorf("waiting for cloud-init exited with code %d: %s", out.Code, strings.TrimSpace(out.Stderr))
+	}
+	res := &readyResult{Status: strings.TrimSpace(out.Stdout), Errors: []string{}}
+	switch res.Status {
+	case "done":
+	case "disabled":
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unexpected output waiting for cloud-init: %q", res.Status)
+	}
+
+	if out, err := sh("cat " + shellutil.Quote(cloudInitDir+"/data/result.json")); err == nil && out.Code == 0 {
+		var result struct {
+			V1 struct {
+				Datasource string   `json:"datasource"`
+				Errors     []string `json:"errors"`
+			} `json:"v1"`
+		}
+		if err := json.Unmarshal([]byte(out.Stdout), &result); err == nil {
+			res.Datasource = result.V1.Datasource
+			res.Errors = append(res.Errors, result.V1.Errors...)
+		}
+	}
+	if len(res.Errors) > 0 {
+		res.Status = "error"
+	}
+	// the log is often only readable by root
+	tail := "tail -n " + strconv.Itoa(logLines) + " " + shellutil.Quote(cloudInitLog)
+	if out, err := sh(tail + " 2>/dev/null || sudo -n " + tail); err == nil {
+		res.Log = out.Stdout
+	}
+	return res, nil
+}
+
+// waitReadyJS waits for a droplet to be provisioned, as in:
+//
+//	ssh.wait_ready(droplet, {timeout: "5m"})
+//
+// Options are those of sessions, and the number of lines of the output of
+// cloud-init to return.
+func (svc *sshSvc) waitReadyJS(all otto.FunctionCall) otto.Value {
+	vm := all.Otto
+	opts := svc.connectArgs(vm, all.Argument(0))
+	timeout, logLines := readyTimeout, readyLogLines
+	switch len(all.ArgumentList) {
+	case 1: // done
+	case 2: // provided options
+		v := all.Argument(1)
+		opts = svc.optionalConnectArgs(vm, opts, v)
+		if opts.Timeout != 0 {
+			timeout = opts.Timeout
+		}
+		if n := ottoutil.GetObject(vm, v, "log_lines", false); n.IsDefined() {
+			logLines = ottoutil.Int(vm, n)
+		}
+	default: // too many!
+		ottoutil.Throw(vm, "too many arguments")
+	}
+	res, err := svc.waitReady(svc.ctx, opts, timeout, logLines)
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	return godojs.JSONToVM(vm, res)
+}
+
+// A Provisioner lets Go code reach droplets like an applied ssh package
+// does, given to Apply with UseProvisioner. Its funcs fit
+// droplets.WithReady and volumes.WithRun.
+type Provisioner struct {
+	svc *sshSvc
+}
+
+// UseProvisioner makes p reach droplets with the package being applied.
+func UseProvisioner(p *Provisioner) Option {
+	return func(svc *sshSvc) { p.svc = svc }
+}
+
+// WaitReady waits for a droplet to be reachable and provisioned by
+// cloud-init. It fails when cloud-init reports errors.
+func (p *Provisioner) WaitReady(ctx context.Context, d *godo.Droplet) error {
+	svc := p.svc
+	if svc == nil {
+		return fmt.Errorf("waiting for droplets to be ready needs the ssh package")
+	}
+	opts, err := svc.dropletConnectArgs(d)
+	if err != nil {
+		return err
+	}
+	res, err := svc.waitReady(ctx, opts, readyTimeout, readyLogLines)
+	if err != nil {
+		return fmt.Errorf("droplet %q: %v", d.Name, err)
+	}
+	if res.Status == "error" {
+		return fmt.Errorf("droplet %q: cloud-init failed: %s", d.Name, strings.Join(res.Errors, "; "))
+	}
+	return nil
+}
+
+// RunScript runs a shell script as root on a droplet, and returns what it
+// printed. It fails when the script exits with a non-zero code.
+func (p *Provisioner) RunScript(ctx context.Context, d *godo.Droplet, script string) (string, error) {
+	svc := p.svc
+	if svc == nil {
+		return "", fmt.Errorf("running scripts on droplets needs the ssh package")
+	}
+	opts, err := svc.dropletConnectArgs(d)
+	if err != nil {
+		return "", err
+	}
+	client, err := svc.connect(ctx, opts)
+	if err != nil {
+		return "", fmt.Errorf("droplet %q: %v", d.Name, err)
+	}
+	defer func() { _ = svc.release(client) }()
+
+	eopts := &execOpts{Cmd: script}
+	if opts.Cfg.User != "root" {
+		eopts.Sudo = "root"
+	} else {
+		// the login shell of root may not be a POSIX one
+		eopts.Cmd = "sh -c " + shellutil.Quote(script)
+	}
+	res, err := run(ctx, client, eopts, nil)
+	if err != nil {
+		return "", fmt.Errorf("droplet %q: %v", d.Name, err)
+	}
+	if res.Code != 0 {
+		msg := strings.TrimSpace(res.Stderr)
+		if msg == "" {
+			msg = strings.TrimSpace(res.Stdout)
+		}
+		return "", fmt.Errorf("droplet %q: script exited with code %d: %s", d.Name, res.Code, msg)
+	}
+	return res.Stdout, nil
+}
@@ -0,0 +1,334 @@
+package ssh
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/droplets"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/digitalocean/godo"
+	"github.com/robertkrimen/otto"
+)
+
+// a target of run_all.
+type target struct {
+	name string
+	opts *connectOpts
+	err  error // why it can't be reached
+}
+
+// hostResult is the outcome of a command on a host.
+type hostResult struct {
+	Name     string `json:"name"`
+	Host     string `json:"host,omitempty"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	Code     int    `json:"code"`
+	Duration string `json:"duration,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Skipped  bool   `json:"skipped,omitempty"`
+}
+
+func (r *hostResult) failed() bool { return r.Error != "" || r.Code != 0 }
+
+// hostGroup are hosts that had the same outcome.
+type hostGroup struct {
+	Hosts  []string `json:"hosts"`
+	Count  int      `json:"count"`
+	Code   int      `json:"code"`
+	Stdout string   `json:"stdout"`
+	Stderr string   `json:"stderr"`
+	Error  string   `json:"error,omitempty"`
+}
+
+type runAllOpts struct {
+	Concurrency int
+	Timeout     time.Duration // for each host
+	MaxFailures int           // after which no more hosts are started, none if 0
+	Summary     bool
+}
+
+// runAll runs a command on many droplets at once, as in:
+//
+//	ssh.run_all("tag:web", "systemctl restart nginx", {concurrency: 5, timeout: "1m"})
+//
+// Droplets are given as droplet objects, names, or `tag:name` strings, or
+// arrays of them. The results are per host, and commands failing on some
+// hosts don't throw.
+func (svc *sshSvc) runAll(all otto.FunctionCall) otto.Value {
+	vm := all.Otto
+	targets := svc.targets(vm, all.Argument(0))
+	cmd := all.Argument(1)
+	if !cmd.IsString() {
+		ottoutil.Throw(vm, "command must be a string")
+	}
+	v := all.Argument(2)
+
+	opts := &runAllOpts{Concurrency: 10}
+	exec := svc.execArgs(vm, cmd, v)
+	exec.Check = false
+	if v.IsDefined() {
+		for _, t := range targets {
+			if t.opts != nil {
+				t.opts = svc.optionalConnectArgs(vm, t.opts, v)
+			}
+		}
+		opts.Timeout = exec.Timeout
+		if rolling := ottoutil.GetObject(vm, v, "rolling", false); rolling.IsDefined() && ottoutil.Bool(vm, rolling) {
+			// one host at a time, stopping at the first failure
+			opts.Concurrency, opts.MaxFailures = 1, 1
+		}
+		if n := ottoutil.GetObject(vm, v, "concurrency", false); n.IsDefined() {
+			opts.Concurrency = ottoutil.Int(vm, n)
+		}
+		if n := ottoutil.GetObject(vm, v, "max_failures", false); n.IsDefined() {
+			opts.MaxFailures = ottoutil.Int(vm, n)
+		}
+		if summary := ottoutil.GetObject(vm, v, "summary", false); summary.IsDefined() {
+			opts.Summary = ottoutil.Bool(vm, summary)
+		}
+	}
+	if opts.Concurrency < 1 {
+		ottoutil.Throw(vm, "concurrency must be at least 1")
+	}
+
+	var lines chan hostLine
+	if exec.OnLine.IsFunction() {
+		lines = make(chan hostLine, 64)
+	}
+	ctx, cancel := context.WithCancel(svc.ctx)
+	defer cancel()
+	done := make(chan []hostResult, 1)
+	go func() {
+		done <- svc.fanOut(ctx, targets, exec, opts, lines)
+		if lines != nil {
+			close(lines)
+		}
+	}()
+	if lines != nil {
+		// if the callback throws, the commands are killed and their
+		// output drained
+		defer func() {
+			cancel()
+			for range lines {
+			}
+		}()
+		for line := range lines {
+			ottoutil.Call(vm, exec.OnLine, nil, line.Text, line.Stream, line.Host)
+		}
+	}
+	results := <-done
+
+	out := map[string]interface{}{"results": results}
+	var ok, failed, skipped int
+	for i := range results {
+		switch r := &results[i]; {
+		case r.Skipped:
+			skipped++
+		case r.failed():
+			failed++
+		default:
+			ok++
+		}
+	}
+	out["ok"], out["failed"], out["skipped"] = ok, failed, skipped
+	if opts.Summary {
+		out["groups"] = groupResults(results)
+	}
+	return godojs.JSONToVM(vm, out)
+}
+
+// hostLine is a line printed by a command on a host.
+type hostLine struct {
+	outputLine
+	Host string
+}
+
+func (svc *sshSvc) fanOut(ctx context.Context, targets []*target, exec *execOpts, opts *runAllOpts, lines chan<- hostLine) []hostResult {
+	results := make([]hostResult, len(targets))
+	var (
+		mu       sync.Mutex
+		failures int
+		wg       sync.WaitGroup
+	)
+	sem := make(chan struct{}, opts.Concurrency)
+	for i, t := range targets {
+		sem <- struct{}{}
+		mu.Lock()
+		stop := opts.MaxFailures > 0 && failures >= opts.MaxFailures
+		mu.Unlock()
+		if stop || ctx.Err() != nil {
+			<-sem
+			results[i] = hostResult{Name: t.name, Skipped: true}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, t *target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res := svc.runOn(ctx, t, exec, opts.Timeout, lines)
+			mu.Lock()
+			if res.failed() {
+				failures++
+			}
+			results[i] = res
+			mu.Unlock()
+		}(i, t)
+	}
+	wg.Wait()
+	return results
+}
+
+func (svc *sshSvc) runOn(ctx context.Context, t *target, exec *execOpts, timeout time.Duration, lines chan<- hostLine) hostResult {
+	res := hostResult{Name: t.name, Code: -1}
+	if t.err != nil {
+		res.Error = t.err.Error()
+		return res
+	}
+	res.Host = t.opts.Hostname
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	fail := func(err error) hostResult {
+		if ctx.Err() == context.DeadlineExceeded {
+			res.Error = fmt.Sprintf("timed out after %v", timeout)
+		} else {
+			res.Error = err.Error()
+		}
+		return res
+	}
+	client, err := svc.connect(ctx, t.opts)
+	if err != nil {
+		return fail(err)
+	}
+	defer func() { _ = svc.release(client) }()
+
+	opts := *exec
+	// the deadline of ctx, which the host enforces too
+	opts.Timeout = 0
+	if deadline, ok := ctx.Deadline(); ok {
+		opts.Timeout = deadline.Sub(time.Now())
+	}
+	opts.Prefix = "[" + t.name + "] "
+	var hostLines chan outputLine
+	forwarded := make(chan struct{})
+	if lines != nil {
+		hostLines = make(chan outputLine)
+		go func() {
+			defer close(forwarded)
+			for line := range hostLines {
+				lines <- hostLine{outputLine: line, Host: t.name}
+			}
+		}()
+	} else {
+		close(forwarded)
+	}
+	out, err := run(ctx, client, &opts, hostLines)
+	<-forwarded
+	if err != nil {
+		return fail(err)
+	}
+	res.Stdout, res.Stderr, res.Code = out.Stdout, out.Stderr, out.Code
+	res.Duration = out.Duration.String()
+	return res
+}
+
+// groupResults groups the hosts that had the same outcome, the largest
+// groups first.
+func groupResults(results []hostResult) []hostGroup {
+	var groups []hostGroup
+	index := make(map[string]int)
+	for _, r := range results {
+		if r.Skipped {
+			continue
+		}
+		key := fmt.Sprintf("%d\x00%s\x00%s\x00%s", r.Code, r.Stdout, r.Stderr, r.Error)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, hostGroup{
+				Hosts:  []string{},
+				Code:   r.Code,
+				Stdout: r.Stdout,
+				Stderr: r.Stderr,
+				Error:  r.Error,
+			})
+		}
+		groups[i].Hosts = append(groups[i].Hosts, r.Name)
+		groups[i].Count++
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Count > groups[j].Count })
+	return groups
+}
+
+// targets finds the droplets that v designates.
+func (svc *sshSvc) targets(vm *otto.Otto, v otto.Value) []*target {
+	var out []*target
+	var all []*godo.Droplet // listed once, if names are used
+	add := func(d *godo.Droplet) {
+		opts, err := svc.dropletConnectArgs(d)
+		out = append(out, &target{name: d.Name, opts: opts, err: err})
+	}
+	var addOne func(v otto.Value)
+	addOne = func(v otto.Value) {
+		switch {
+		case v.IsString():
+			s := ottoutil.String(vm, v)
+			if strings.HasPrefix(s, "tag:") {
+				tagged, err := svc.listDroplets(droplets.FilterTag(strings.TrimPrefix(s, "tag:")))
+				if err != nil {
+					ottoutil.Throw(vm, err.Error())
+				}
+				for _, d := range tagged {
+					add(d)
+				}
+				return
+			}
+			if all == nil {
+				var err error
+				if all, err = svc.listDroplets(); err != nil {
+					ottoutil.Throw(vm, err.Error())
+				}
+			}
+			found := false
+			for _, d := range all {
+				if d.Name == s {
+					add(d)
+					found = true
+				}
+			}
+			if !found {
+				ottoutil.Throw(vm, "no droplet named %q", s)
+			}
+		case v.Class() == "Array":
+			ottoutil.LoadArray(vm, v, addOne)
+		case v.IsObject():
+			add(godojs.ArgDroplet(vm, v))
+		default:
+			ottoutil.Throw(vm, "droplets must be droplets, names or tags like \"tag:web\"")
+		}
+	}
+	addOne(v)
+	return out
+}
+
+func (svc *sshSvc) listDroplets(opts ...droplets.ListOpt) ([]*godo.Droplet, error) {
+	if svc.cloud == nil {
+		return nil, fmt.Errorf("can't find droplets by name or tag without the cloud")
+	}
+	var out []*godo.Droplet
+	itemc, errc := svc.cloud.Droplets().List(svc.ctx, opts...)
+	for item := range itemc {
+		out = append(out, item.Struct())
+	}
+	return out, <-errc
+}
@@ -0,0 +1,443 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/context"
+
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/aybabtme/godotto/pkg/extra/shellutil"
+	"github.com/robertkrimen/otto"
+)
+
+// Files are copied with the scp protocol, which only needs the remote host
+// to have an scp binary, as about all of them have.
+
+// copyOpts tell how to copy files.
+type copyOpts struct {
+	Mode os.FileMode // of files, their own mode when 0
+	Sudo bool        // copy as root
+}
+
+func copyArgs(vm *otto.Otto, v otto.Value) *copyOpts {
+	opts := &copyOpts{}
+	if !v.IsDefined() {
+		return opts
+	}
+	if v.Object() == nil {
+		ottoutil.Throw(vm, "optional arguments must be an Object")
+	}
+	switch mode := ottoutil.GetObject(vm, v, "mode", false); {
+	case mode.IsString():
+		m, err := strconv.ParseUint(ottoutil.String(vm, mode), 8, 32)
+		if err != nil {
+			ottoutil.Throw(vm, "mode must be in octal, like \"0644\": %v", err)
+		}
+		opts.Mode = os.FileMode(m)
+	case mode.IsNumber():
+		opts.Mode = os.FileMode(ottoutil.Int(vm, mode))
+	case mode.IsDefined():
+		ottoutil.Throw(vm, "mode must be a string or a number")
+	}
+	if opts.Mode&^os.ModePerm != 0 {
+		ottoutil.Throw(vm, "invalid mode %o", opts.Mode)
+	}
+	if sudo := ottoutil.GetObject(vm, v, "sudo", false); sudo.IsDefined() {
+		opts.Sudo = ottoutil.Bool(vm, sudo)
+	}
+	return opts
+}
+
+func (opts *copyOpts) command(args ...string) string {
+	cmd := "scp " + strings.Join(args, " ")
+	if opts.Sudo {
+		cmd = "sudo -n " + cmd
+	}
+	return cmd
+}
+
+// scpSession is a running scp command.
+type scpSession struct {
+	ss     *ssh.Session
+	in     io.WriteCloser
+	out    *bufio.Reader
+	stderr bytes.Buffer
+	stop   chan struct{}
+}
+
+func startSCP(ctx context.Context, client *ssh.Client, cmd string) (*scpSession, error) {
+	ss, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	s := &scpSession{ss: ss, stop: make(chan struct{})}
+	ss.Stderr = &s.stderr
+	if s.in, err = ss.StdinPipe(); err != nil {
+		_ = ss.Close()
+		return nil, err
+	}
+	out, err := ss.StdoutPipe()
+	if err != nil {
+		_ = ss.Close()
+		return nil, err
+	}
+	s.out = bufio.NewReader(out)
+	if err := ss.Start(cmd); err != nil {
+		_ = ss.Close()
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = ss.Close()
+		case <-s.stop:
+		}
+	}()
+	return s, nil
+}
+
+// readAck reads the answer to a message: a 0, or an error.
+func (s *scpSession) readAck() error {
+	b, err := s.out.ReadByte()
+	if err != nil {
+		return s.failed(err)
+	}
+	if b == 0 {
+		return nil
+	}
+	msg, _ := s.out.ReadString('\n')
+	return fmt.Errorf("scp: %s", strings.TrimSpace(msg))
+}
+
+func (s *scpSession) ack() error {
+	_, err := s.in.Write([]byte{0})
+	return err
+}
+
+// failed explains an error with what scp printed, as it's often the reason.
+func (s *scpSession) failed(err error) error {
+	if msg := strings.TrimSpace(s.stderr.String()); msg != "" {
+		return fmt.Errorf("scp: %s", msg)
+	}
+	return err
+}
+
+// finish waits for scp to exit, telling why it failed if it did.
+func (s *scpSession) finish(err error) error {
+	defer close(s.stop)
+	_ = s.in.Close()
+	werr := s.ss.Wait()
+	_ = s.ss.Close()
+	if err != nil {
+		return err
+	}
+	if werr != nil {
+		return s.failed(werr)
+	}
+	return nil
+}
+
+// uploadContent writes content to a remote file.
+func uploadContent(ctx context.Context, client *ssh.Client, content []byte, remote string, opts *copyOpts) error {
+	s, err := startSCP(ctx, client, opts.command("-t", shellutil.Quote(remote)))
+	if err != nil {
+		return err
+	}
+	err = s.readAck()
+	if err == nil {
+		mode := opts.Mode
+		if mode == 0 {
+			mode = 0644
+		}
+		err = s.sendFile(path.Base(remote), mode, int64(len(content)), bytes.NewReader(content))
+	}
+	return s.finish(err)
+}
+
+// upload copies a local file, or a directory and all it has, to a remote
+// path. As with scp, a file or a directory copied into an existing remote
+// directory goes inside it.
+func upload(ctx context.Context, client *ssh.Client, local, remote string, opts *copyOpts) error {
+	fi, err := os.Stat(local)
+	if os.IsNotExist(err) {
+		// strings are easily mistaken for the content to upload
+		return fmt.Errorf("no such local file %q; pass {content: ...} to upload a string", local)
+	}
+	if err != nil {
+		return err
+	}
+	args := []string{"-t", shellutil.Quote(remote)}
+	if fi.IsDir() {
+		args = append([]string{"-r"}, args...)
+	}
+	s, err := startSCP(ctx, client, opts.command(args...))
+	if err != nil {
+		return err
+	}
+	err = s.readAck()
+	if err == nil {
+		err = s.send(local, fi, opts)
+	}
+	return s.finish(err)
+}
+
+func (s *scpSession) send(local string, fi os.FileInfo, opts *copyOpts) error {
+	if !fi.IsDir() {
+		f, err := os.Open(local)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		mode := opts.Mode
+		if mode == 0 {
+			mode = fi.Mode() & os.ModePerm
+		}
+		return s.sendFile(fi.Name(), mode, fi.Size(), f)
+	}
+	if _, err := fmt.Fprintf(s.in, "D%04o 0 %s\n", fi.Mode()&os.ModePerm, fi.Name()); err != nil {
+		return err
+	}
+	if err := s.readAck(); err != nil {
+		return err
+	}
+	entries, err := ioutil.ReadDir(local)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() && !entry.IsDir() {
+			continue // like scp, skip links, sockets and such
+		}
+		if err := s.send(filepath.Join(local, entry.Name()), entry, opts); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(s.in, "E\n"); err != nil {
+		return err
+	}
+	return s.readAck()
+}
+
+func (s *scpSession) sendFile(name string, mode os.FileMode, size int64, r io.Reader) error {
+	if _, err := fmt.Fprintf(s.in, "C%04o %d %s\n", mode, size, name); err != nil {
+		return err
+	}
+	if err := s.readAck(); err != nil {
+		return err
+	}
+	if n, err := io.CopyN(s.in, r, size); err != nil {
+		return fmt.Errorf("sent %d of %d bytes of %s: %v", n, size, name, err)
+	}
+	if err := s.ack(); err != nil {
+		return err
+	}
+	return s.readAck()
+}
+
+// download copies a remote file, or a directory and all it has, to a local
+// path. As with scp, what's copied into an existing local directory goes
+// inside it.
+func download(ctx context.Context, client *ssh.Client, remote, local string, opts *copyOpts) error {
+	s, err := startSCP(ctx, client, opts.command("-r", "-f", shellutil.Quote(remote)))
+	if err != nil {
+		return err
+	}
+	return s.finish(s.receive(local, opts))
+}
+
+func (s *scpSession) receive(local string, opts *copyOpts) error {
+	var dirs []string // being received
+	target := func(name string) string {
+		if len(dirs) > 0 {
+			return filepath.Join(dirs[len(dirs)-1], name)
+		}
+		if fi, err := os.Stat(local); err == nil && fi.IsDir() {
+			return filepath.Join(local, name)
+		}
+		return local
+	}
+	if err := s.ack(); err != nil {
+		return err
+	}
+	received := false
+	for {
+		line, err := s.out.ReadString('\n')
+		if err == io.EOF && line == "" {
+			break
+		} else if err != nil {
+			return s.failed(err)
+		}
+		switch line[0] {
+		case 1, 2:
+			return fmt.Errorf("scp: %s", strings.TrimSpace(line[1:]))
+		case 'T': // times, which aren't kept
+		case 'E':
+			if len(dirs) == 0 {
+				return fmt.Errorf("scp: unexpected end of directory")
+			}
+			dirs = dirs[:len(dirs)-1]
+		case 'C', 'D':
+			mode, size, name, err := parseHeader(line)
+			if err != nil {
+				return err
+			}
+			if opts.Mode != 0 && line[0] == 'C' {
+				mode = opts.Mode
+			}
+			dst := target(name)
+			if line[0] == 'D' {
+				if err := os.MkdirAll(dst, mode|0700); err != nil {
+					return err
+				}
+				dirs = append(dirs, dst)
+				break
+			}
+			if err := s.ack(); err != nil {
+				return err
+			}
+			if err := s.receiveFile(dst, mode, size); err != nil {
+				return err
+			}
+			received = true
+			continue
+		default:
+			return fmt.Errorf("scp: unexpected message %q", strings.TrimSpace(line))
+		}
+		if err := s.ack(); err != nil {
+			return err
+		}
+		received = true
+	}
+	if !received {
+		return s.failed(fmt.Errorf("scp: nothing was received"))
+	}
+	return nil
+}
+
+func (s *scpSession) receiveFile(dst string, mode os.FileMode, size int64) error {
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(f, s.out, size); err != nil {
+		_ = f.Close()
+		return s.failed(err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := s.readAck(); err != nil {
+		return err
+	}
+	return s.ack()
+}
+
+// parseHeader parses `C0644 12 name` and `D0755 0 name`.
+func parseHeader(line string) (os.FileMode, int64, string, error) {
+	parts := strings.SplitN(strings.TrimSuffix(line[1:], "\n"), " ", 3)
+	if len(parts) != 3 {
+		return 0, 0, "", fmt.Errorf("scp: invalid header %q", strings.TrimSpace(line))
+	}
+	mode, err := strconv.ParseUint(parts[0], 8, 32)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("scp: invalid mode in %q", strings.TrimSpace(line))
+	}
+	size, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || size < 0 {
+		return 0, 0, "", fmt.Errorf("scp: invalid size in %q", strings.TrimSpace(line))
+	}
+	name := parts[2]
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+		return 0, 0, "", fmt.Errorf("scp: refusing to write file named %q", name)
+	}
+	return os.FileMode(mode) & os.ModePerm, size, name, nil
+}
+
+// renderTemplate executes a text/template with vars.
+func renderTemplate(tmpl string, vars interface{}) ([]byte, error) {
+	t, err := template.New("template").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fileMethods are the methods of sessions that copy files. The source of
+// `upload` is always a local path when it's a string; content is uploaded
+// with `upload({content: "..."}, remote)`.
+func (svc *sshSvc) fileMethods(client *ssh.Client) map[string]interface{} {
+	return map[string]interface{}{
+		"upload": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			src, dst := all.Argument(0), ottoutil.String(vm, all.Argument(1))
+			if dst == "" {
+				ottoutil.Throw(vm, "no remote path provided")
+			}
+			opts := copyArgs(vm, all.Argument(2))
+			var err error
+			switch {
+			case src.IsString():
+				err = upload(svc.ctx, client, expandHome(ottoutil.String(vm, src)), dst, opts)
+			case src.IsObject():
+				content := ottoutil.String(vm, ottoutil.GetObject(vm, src, "content", true))
+				err = uploadContent(svc.ctx, client, []byte(content), dst, opts)
+			default:
+				ottoutil.Throw(vm, "source must be a local path or {content: \"...\"}")
+			}
+			if err != nil {
+				ottoutil.Throw(vm, err.Error())
+			}
+			return q
+		},
+		"download": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			src, dst := ottoutil.String(vm, all.Argument(0)), ottoutil.String(vm, all.Argument(1))
+			if src == "" || dst == "" {
+				ottoutil.Throw(vm, "need a remote and a local path")
+			}
+			opts := copyArgs(vm, all.Argument(2))
+			if err := download(svc.ctx, client, src, expandHome(dst), opts); err != nil {
+				ottoutil.Throw(vm, err.Error())
+			}
+			return q
+		},
+		"write_template": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			dst, tmpl := ottoutil.String(vm, all.Argument(0)), ottoutil.String(vm, all.Argument(1))
+			if dst == "" {
+				ottoutil.Throw(vm, "no remote path provided")
+			}
+			var vars interface{}
+			if v := all.Argument(2); v.IsDefined() {
+				var err error
+				if vars, err = v.Export(); err != nil {
+					ottoutil.Throw(vm, err.Error())
+				}
+			}
+			opts := copyArgs(vm, all.Argument(3))
+			content, err := renderTemplate(tmpl, vars)
+			if err != nil {
+				ottoutil.Throw(vm, err.Error())
+			}
+			if err := uploadContent(svc.ctx, client, content, dst, opts); err != nil {
+				ottoutil.Throw(vm, err.Error())
+			}
+			return q
+		},
+	}
+}
@@ -0,0 +1,137 @@
+package ssh
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/robertkrimen/otto"
+)
+
+// Console is the terminal that interactive shells take over.
+type Console interface {
+	// Take the input of the terminal until release is called.
+	Take() (in io.Reader, release func())
+}
+
+// stdinConsole is the terminal of the process, when no REPL reads from it.
+type stdinConsole struct{}
+
+func (stdinConsole) Take() (io.Reader, func()) { return os.Stdin, func() {} }
+
+// shell opens an interactive shell on a host, in the terminal of the
+// process, and returns its exit code when it exits:
+//
+//	ssh.shell(droplet, {user: "deploy", command: "htop"})
+//
+// Options are those of sessions, and a command to run rather than the
+// login shell.
+func (svc *sshSvc) shell(all otto.FunctionCall) otto.Value {
+	vm := all.Otto
+	opts := svc.connectArgs(vm, all.Argument(0))
+	var command string
+	switch len(all.ArgumentList) {
+	case 1: // done
+	case 2: // provided options
+		v := all.Argument(1)
+		opts = svc.optionalConnectArgs(vm, opts, v)
+		command = ottoutil.String(vm, ottoutil.GetObject(vm, v, "command", false))
+	default: // too many!
+		ottoutil.Throw(vm, "too many arguments")
+	}
+	if !terminal.IsTerminal(svc.termFd) {
+		ottoutil.Throw(vm, "shells need a terminal")
+	}
+	client, err := svc.connect(svc.ctx, opts)
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	defer func() { _ = svc.release(client) }()
+
+	code, err := svc.interact(client, command)
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	return ottoutil.ToValue(vm, code)
+}
+
+// interact runs a shell, or a command, in a PTY sized as the terminal.
+func (svc *sshSvc) interact(client *ssh.Client, command string) (int, error) {
+	ss, err := client.NewSession()
+	if err != nil {
+		return 0, err
+	}
+	defer ss.Close()
+
+	width, height, err := terminal.GetSize(svc.termFd)
+	if err != nil {
+		return 0, err
+	}
+	term := os.Getenv("TERM")
+	if term == "" {
+		term = "xterm-256color"
+	}
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	if err := ss.RequestPty(term, height, width, modes); err != nil {
+		return 0, err
+	}
+
+	state, err := terminal.MakeRaw(svc.termFd)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = terminal.Restore(svc.termFd, state) }()
+	in, release := svc.console.Take()
+	defer release()
+	ss.Stdin, ss.Stdout, ss.Stderr = in, svc.stdout, svc.stderr
+
+	if command != "" {
+		err = ss.Start(command)
+	} else {
+		err = ss.Shell()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- ss.Wait() }()
+	// the size of the terminal is polled, as there's no portable way to be
+	// told it changed
+	tick := time.NewTicker(250 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case err := <-done:
+			switch e := err.(type) {
+			case nil:
+				return 0, nil
+			case *ssh.ExitError:
+				return e.ExitStatus(), nil
+			case *ssh.ExitMissingError:
+				// as when the connection drops
+				return -1, nil
+			default:
+				return 0, err
+			}
+		case <-svc.ctx.Done():
+			_ = ss.Close()
+			<-done
+			return 0, svc.ctx.Err()
+		case <-tick.C:
+			w, h, err := terminal.GetSize(svc.termFd)
+			if err == nil && (w != width || h != height) {
+				width, height = w, h
+				_ = ss.WindowChange(height, width)
+			}
+		}
+	}
+}
@@ -2,23 +2,84 @@ package ssh
 
 import (
 	"fmt"
+	"io"
 	"net"
 	"net/url"
+	"os"
 	"strings"
 	"sync"
 	"time"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/net/context"
 
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/godojs"
 	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/digitalocean/godo"
 	"github.com/robertkrimen/otto"
 )
 
 var q = otto.Value{}
 
-func Apply(ctx context.Context, vm *otto.Otto, auth ssh.AuthMethod) (v otto.Value, cleanup func(), err error) {
+// Option configures the ssh package.
+type Option func(*sshSvc)
+
+// UseKnownHosts verifies host keys with a known_hosts file, where the keys
+// trusted on first use are added. Without it, keys are only known for the
+// life of the process.
+func UseKnownHosts(path string) Option {
+	return func(svc *sshSvc) { svc.known = newKnownHosts(path) }
+}
+
+// UseAgent offers the keys of an agent.
+func UseAgent(a agent.Agent) Option {
+	return func(svc *sshSvc) { svc.agent = a }
+}
+
+// UseIdentities are private key files offered when the agent has no keys,
+// like `~/.ssh/id_rsa`. Files that don't exist are skipped.
+func UseIdentities(filenames ...string) Option {
+	return func(svc *sshSvc) { svc.defaultIdentities = filenames }
+}
+
+// UsePassphrase asks for the passphrases of encrypted private keys.
+func UsePassphrase(fn PassphraseFunc) Option {
+	return func(svc *sshSvc) { svc.ids.passphrase = fn }
+}
+
+// UseConfig reads the user, port, host name and identities of hosts from an
+// OpenSSH config file, like `~/.ssh/config`. Droplets are matched by name and
+// by IP.
+func UseConfig(filename string) Option {
+	return func(svc *sshSvc) { svc.configFile = filename }
+}
+
+// UseOutput is where the output of commands is streamed, os.Stdout and
+// os.Stderr by default.
+func UseOutput(stdout, stderr io.Writer) Option {
+	return func(svc *sshSvc) { svc.stdout, svc.stderr = stdout, stderr }
+}
+
+// UseCloud finds droplets by name and tag, for `run_all`.
+func UseCloud(client cloud.Client) Option {
+	return func(svc *sshSvc) { svc.cloud = client }
+}
+
+// UseConsole is the terminal taken over by shells, shared with a REPL. By
+// default, shells read from os.Stdin.
+func UseConsole(c Console) Option {
+	return func(svc *sshSvc) { svc.console = c }
+}
+
+// UseHostKeyPolicy is the policy of the sessions that don't choose one,
+// TOFU by default.
+func UseHostKeyPolicy(policy HostKeyPolicy) Option {
+	return func(svc *sshSvc) { svc.policy = policy }
+}
+
+func Apply(ctx context.Context, vm *otto.Otto, auth ssh.AuthMethod, opts ...Option) (v otto.Value, cleanup func(), err error) {
 	var qdn = func() {}
 	root, err := vm.Object(`({})`)
 	if err != nil {
@@ -28,7 +89,28 @@ func Apply(ctx context.Context, vm *otto.Otto, auth ssh.AuthMethod) (v otto.Valu
 	svc := sshSvc{
 		ctx:    ctx,
 		auth:   auth,
-		opened: make(map[*ssh.Client]struct{}),
+		opened: make(map[*ssh.Client][]io.Closer),
+		known:  newKnownHosts(""),
+		policy: TOFU,
+		ids:    &identities{},
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		config: &sshConfig{},
+
+		console: stdinConsole{},
+		termFd:  int(os.Stdin.Fd()),
+	}
+	for _, opt := range opts {
+		opt(&svc)
+	}
+	// commands on many hosts print at once
+	var outMu sync.Mutex
+	svc.stdout = &syncWriter{mu: &outMu, w: svc.stdout}
+	svc.stderr = &syncWriter{mu: &outMu, w: svc.stderr}
+	if svc.configFile != "" {
+		if svc.config, err = loadConfig(svc.configFile); err != nil {
+			return q, qdn, err
+		}
 	}
 
 	for _, applier := range []struct {
@@ -36,6 +118,10 @@ func Apply(ctx context.Context, vm *otto.Otto, auth ssh.AuthMethod) (v otto.Valu
 		Method func(otto.FunctionCall) otto.Value
 	}{
 		{"session", svc.session},
+		{"forget", svc.forget},
+		{"run_all", svc.runAll},
+		{"shell", svc.shell},
+		{"wait_ready", svc.waitReadyJS},
 	} {
 		if err := root.Set(applier.Name, applier.Method); err != nil {
 			return q, qdn, fmt.Errorf("preparing method %q, %v", applier.Name, err)
@@ -43,9 +129,13 @@ func Apply(ctx context.Context, vm *otto.Otto, auth ssh.AuthMethod) (v otto.Valu
 	}
 	cleanup = func() {
 		svc.mu.Lock()
-		defer svc.mu.Unlock()
+		clients := make([]*ssh.Client, 0, len(svc.opened))
 		for client := range svc.opened {
-			_ = client.Close()
+			clients = append(clients, client)
+		}
+		svc.mu.Unlock()
+		for _, client := range clients {
+			_ = svc.release(client)
 		}
 	}
 
@@ -56,57 +146,122 @@ type sshSvc struct {
 	ctx  context.Context
 	auth ssh.AuthMethod
 
+	known  *knownHosts
+	policy HostKeyPolicy
+
+	stdout, stderr io.Writer
+	console        Console
+	termFd         int // of the terminal of shells
+
+	agent             agent.Agent
+	ids               *identities
+	defaultIdentities []string
+	configFile        string
+	config            *sshConfig
+	cloud             cloud.Client
+
 	mu     sync.Mutex
-	opened map[*ssh.Client]struct{}
+	opened map[*ssh.Client][]io.Closer // with what's closed along
 }
 
 type connectOpts struct {
 	Hostname string
+	Private  string // IPv4 of droplets, used through bastions
 	Port     string
 	Timeout  time.Duration
 	Cfg      *ssh.ClientConfig
+	Via      *connectOpts // bastion to go through
+
+	HostKey     HostKeyPolicy
+	Fingerprint string
+
+	Identities   []string
+	Certificates []string
+	Password     string
 }
 
 func (svc *sshSvc) connectArgs(vm *otto.Otto, v otto.Value) *connectOpts {
-	var (
-		host string
-		user = "root"
-	)
 	switch {
 	case v.IsString():
-		host, _ = v.ToString()
+		host, _ := v.ToString()
 		if host == "" {
 			ottoutil.Throw(vm, "no hostname provided")
 		}
+		return svc.hostConnectArgs(host)
 	case v.IsObject():
-		droplet := godojs.ArgDroplet(vm, v)
-		var err error
-		host, err = droplet.PublicIPv4()
+		opts, err := svc.dropletConnectArgs(godojs.ArgDroplet(vm, v))
 		if err != nil {
 			ottoutil.Throw(vm, err.Error())
 		}
-		if host == "" {
-			ottoutil.Throw(vm, "provided Droplet has no public IPv4")
-		}
-		slug := droplet.Image.Slug
-		switch {
-		case strings.Contains(slug, "coreos"):
-			user = "core"
-		case strings.Contains(slug, "freebsd"):
-			user = "freebsd"
-		}
-	default:
-		ottoutil.Throw(vm, "argument must be a string or a Droplet")
+		return opts
+	}
+	ottoutil.Throw(vm, "argument must be a string or a Droplet")
+	return nil
+}
+
+func (svc *sshSvc) hostConnectArgs(host string) *connectOpts {
+	opts := svc.configure(host, "root", host)
+	if hc := svc.config.lookup(host); hc.HostName != "" {
+		opts.Hostname = hc.HostName
+	}
+	return opts
+}
+
+// dropletConnectArgs connects to the public IPv4 of droplets, or to their
+// private one when they have none.
+func (svc *sshSvc) dropletConnectArgs(droplet *godo.Droplet) (*connectOpts, error) {
+	public, err := droplet.PublicIPv4()
+	if err != nil {
+		return nil, err
 	}
-	return &connectOpts{
+	private, _ := droplet.PrivateIPv4()
+	host := public
+	if host == "" {
+		host = private
+	}
+	if host == "" {
+		return nil, fmt.Errorf("droplet %q has no IPv4", droplet.Name)
+	}
+	user := "root"
+	var slug string
+	if droplet.Image != nil {
+		slug = droplet.Image.Slug
+	}
+	switch {
+	case strings.Contains(slug, "coreos"):
+		user = "core"
+	case strings.Contains(slug, "freebsd"):
+		user = "freebsd"
+	}
+	opts := svc.configure(host, user, droplet.Name, public, private)
+	opts.Private = private
+	return opts, nil
+}
+
+// configure the connection to a host, known in the config by any of names.
+func (svc *sshSvc) configure(host, user string, names ...string) *connectOpts {
+	opts := &connectOpts{
 		Hostname: host,
 		Port:     "22",
-		Cfg: &ssh.ClientConfig{
-			User:            user,
-			Auth:            []ssh.AuthMethod{svc.auth},
-			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		},
+		Cfg:      &ssh.ClientConfig{User: user},
+		HostKey:  svc.policy,
+	}
+	hc := svc.config.lookup(names...)
+	if hc.User != "" {
+		opts.Cfg.User = hc.User
 	}
+	if hc.Port != "" {
+		opts.Port = hc.Port
+	}
+	for _, filename := range hc.Identities {
+		// as OpenSSH, identities of the config that don't exist are
+		// skipped
+		if _, err := os.Stat(filename); err == nil {
+			opts.Identities = append(opts.Identities, filename)
+		}
+	}
+	opts.Certificates = hc.Certificates
+	return opts
 }
 
 func (svc *sshSvc) optionalConnectArgs(vm *otto.Otto, opts *connectOpts, v otto.Value) *connectOpts {
@@ -124,6 +279,27 @@ func (svc *sshSvc) optionalConnectArgs(vm *otto.Otto, opts *connectOpts, v otto.
 			opts.Timeout = timeout
 		}
 	}
+	if policy := ottoutil.String(vm, ottoutil.GetObject(vm, v, "host_key", false)); policy != "" {
+		var err error
+		if opts.HostKey, err = ParseHostKeyPolicy(policy); err != nil {
+			ottoutil.Throw(vm, err.Error())
+		}
+	}
+	opts.Fingerprint = ottoutil.String(vm, ottoutil.GetObject(vm, v, "host_key_fingerprint", false))
+	switch identity := ottoutil.GetObject(vm, v, "identity", false); {
+	case identity.IsString():
+		opts.Identities = []string{ottoutil.String(vm, identity)}
+	case identity.IsDefined():
+		opts.Identities = ottoutil.StringSlice(vm, identity)
+	}
+	opts.Password = ottoutil.String(vm, ottoutil.GetObject(vm, v, "password", false))
+	if via := ottoutil.GetObject(vm, v, "via", false); via.IsDefined() {
+		opts.Via = svc.connectArgs(vm, via)
+		if opts.Private != "" {
+			// bastions are in the private network of the droplets
+			opts.Hostname = opts.Private
+		}
+	}
 	return opts
 }
 
@@ -136,6 +312,51 @@ func (svc *sshSvc) connect(ctx context.Context, opts *connectOpts) (*ssh.Client,
 	defer cancel()
 
 	addr := net.JoinHostPort(opts.Hostname, opts.Port)
+	auth, skipped, err := svc.authMethods(opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.Cfg.Auth = auth
+	opts.Cfg.HostKeyCallback = svc.known.callback(opts.HostKey, opts.Fingerprint)
+	if opts.HostKey != Insecure && opts.Fingerprint == "" {
+		// ask for a key that can be checked, rather than one of another
+		// type the host may also have
+		opts.Cfg.HostKeyAlgorithms = svc.known.algorithms(addr)
+	}
+
+	dial := func() (net.Conn, error) { return net.DialTimeout("tcp", addr, 2*time.Second) }
+	var bastion *ssh.Client
+	if opts.Via != nil {
+		if bastion, err = svc.connect(ctx, opts.Via); err != nil {
+			if _, fatal := err.(*handshakeError); fatal {
+				return nil, &handshakeError{fmt.Errorf("can't reach bastion, %v", err)}
+			}
+			return nil, fmt.Errorf("can't reach bastion, %v", err)
+		}
+		dial = func() (net.Conn, error) { return bastion.Dial("tcp", addr) }
+	}
+	client, err := svc.handshake(ctx, addr, opts.Cfg, dial)
+	if err != nil {
+		if bastion != nil {
+			_ = svc.release(bastion)
+		}
+		if herr, ok := err.(*handshakeError); ok && len(skipped()) > 0 && strings.Contains(err.Error(), "unable to authenticate") {
+			return nil, &handshakeError{fmt.Errorf("%v (skipped identities: %s)", herr.err, strings.Join(skipped(), "; "))}
+		}
+		return nil, err
+	}
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	svc.opened[client] = nil
+	if bastion != nil {
+		svc.opened[client] = append(svc.opened[client], closerFunc(func() error { return svc.release(bastion) }))
+	}
+	return client, nil
+}
+
+// handshake with a host, retrying until it's up. Failures of hosts that are
+// up, like rejected credentials or host keys, are a *handshakeError.
+func (svc *sshSvc) handshake(ctx context.Context, addr string, cfg *ssh.ClientConfig, dial func() (net.Conn, error)) (*ssh.Client, error) {
 	var err error
 	for {
 		// respect the cancelled contexts
@@ -144,23 +365,28 @@ func (svc *sshSvc) connect(ctx context.Context, opts *connectOpts) (*ssh.Client,
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		}
-		conn, derr := net.DialTimeout("tcp", addr, 2*time.Second)
+		conn, derr := dial()
 		if derr != nil {
-			if retryable(derr) {
+			if !retryable(derr) {
+				return nil, fmt.Errorf("unexpected network error: %v", derr)
+			}
+			err = derr
+			select {
+			case <-time.After(retryDelay):
 				continue
+			case <-ctx.Done():
+				return nil, err
 			}
-			return nil, fmt.Errorf("unexpected network error: %v", derr)
 		}
-		sconn, sc, rr, cerr := ssh.NewClientConn(conn, addr, opts.Cfg)
+		sconn, sc, rr, cerr := ssh.NewClientConn(conn, addr, cfg)
 		if cerr == nil {
-			client := ssh.NewClient(sconn, sc, rr)
-			svc.mu.Lock()
-			defer svc.mu.Unlock()
-			svc.opened[client] = struct{}{}
-			return client, nil
+			return ssh.NewClient(sconn, sc, rr), nil
 		}
 		_ = conn.Close()
 		err = fmt.Errorf("can't ssh into address %q, %v", addr, cerr)
+		if !handshakeRetryable(cerr) {
+			return nil, &handshakeError{err}
+		}
 		select {
 		case <-time.After(time.Second):
 		case <-ctx.Done():
@@ -169,6 +395,38 @@ func (svc *sshSvc) connect(ctx context.Context, opts *connectOpts) (*ssh.Client,
 	}
 }
 
+// retryDelay between dials of hosts that aren't up yet.
+const retryDelay = 100 * time.Millisecond
+
+// release closes a client, along with the bastion it goes through and the
+// ports it forwards.
+func (svc *sshSvc) release(client *ssh.Client) error {
+	svc.mu.Lock()
+	closers := svc.opened[client]
+	delete(svc.opened, client)
+	svc.mu.Unlock()
+	err := client.Close()
+	for i := len(closers) - 1; i >= 0; i-- {
+		_ = closers[i].Close()
+	}
+	return err
+}
+
+// closeWith closes c along with a client.
+func (svc *sshSvc) closeWith(client *ssh.Client, c io.Closer) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	if _, ok := svc.opened[client]; !ok {
+		_ = c.Close() // already closed
+		return
+	}
+	svc.opened[client] = append(svc.opened[client], c)
+}
+
+type closerFunc func() error
+
+func (fn closerFunc) Close() error { return fn() }
+
 func (svc *sshSvc) session(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
 	opts := svc.connectArgs(vm, all.Argument(0))
@@ -184,38 +442,67 @@ func (svc *sshSvc) session(all otto.FunctionCall) otto.Value {
 		ottoutil.Throw(vm, err.Error())
 	}
 
-	return ottoutil.ToPkg(vm, map[string]interface{}{
+	methods := map[string]interface{}{
 		"exec": func(all otto.FunctionCall) otto.Value {
 			vm := all.Otto
-			cmd := ottoutil.String(vm, all.Argument(0))
-
-			ss, err := client.NewSession()
-			if err != nil {
-				_ = client.Close()
-				ottoutil.Throw(vm, err.Error())
-			}
-			out, err := ss.CombinedOutput(cmd)
-			if err != nil {
-				ottoutil.Throw(vm, "%v: %s", err, string(out))
-			}
-			_ = ss.Close()
-			return ottoutil.ToValue(vm, string(out))
+			opts := svc.execArgs(vm, all.Argument(0), all.Argument(1))
+			return resultToVM(vm, svc.exec(vm, client, opts))
 		},
 		"close": func(all otto.FunctionCall) otto.Value {
 			vm := all.Otto
-			if err := client.Close(); err != nil {
+			if err := svc.release(client); err != nil {
 				ottoutil.Throw(vm, err.Error())
 			}
-			svc.mu.Lock()
-			defer svc.mu.Unlock()
-			delete(svc.opened, client)
 			return q
 		},
-	})
+	}
+	for name, method := range svc.fileMethods(client) {
+		methods[name] = method
+	}
+	for name, method := range svc.forwardMethods(client) {
+		methods[name] = method
+	}
+	return ottoutil.ToPkg(vm, methods)
+}
+
+func (svc *sshSvc) forget(all otto.FunctionCall) otto.Value {
+	vm := all.Otto
+	opts := svc.connectArgs(vm, all.Argument(0))
+	switch len(all.ArgumentList) {
+	case 1:
+	case 2:
+		opts = svc.optionalConnectArgs(vm, opts, all.Argument(1))
+	default:
+		ottoutil.Throw(vm, "too many arguments")
+	}
+	n, err := svc.known.forget(net.JoinHostPort(opts.Hostname, opts.Port))
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	return ottoutil.ToValue(vm, n)
 }
 
 // errors
 
+// handshakeError is a failed handshake that retrying won't fix.
+type handshakeError struct {
+	err error
+}
+
+func (e *handshakeError) Error() string { return e.err.Error() }
+
+// handshakeRetryable tells if a handshake failed as the host isn't up yet,
+// like when sshd closes or resets connections while it starts. The handshake
+// only passes its errors along as text.
+func handshakeRetryable(err error) bool {
+	s := err.Error()
+	return strings.HasSuffix(s, "EOF") ||
+		strings.HasSuffix(s, "connection reset by peer") ||
+		strings.HasSuffix(s, "broken pipe") ||
+		strings.HasSuffix(s, "i/o timeout") ||
+		hasRetryableSuffix(err)
+}
+
 var knownFailureSuffixes = []string{
 	"connection refused",
 	"connection reset by peer.",
@@ -256,6 +543,9 @@ func retryable(err error) bool {
 		return e.Retry()
 	case *url.Error:
 		return retryable(e.Err)
+	case *ssh.OpenChannelError:
+		// the bastion can't reach the host yet
+		return e.Reason == ssh.ConnectionFailed
 	default:
 		return false
 	}
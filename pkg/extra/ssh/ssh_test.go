@@ -1,22 +1,42 @@
 package ssh
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 	"golang.org/x/net/context"
+	"golang.org/x/net/proxy"
 
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/droplets"
+	"github.com/aybabtme/godotto/pkg/extra/do/mockcloud"
 	"github.com/aybabtme/godotto/pkg/extra/vmtest"
+	"github.com/digitalocean/godo"
 	"github.com/robertkrimen/otto"
 )
 
 func TestApply(t *testing.T) {
 
-	host, user, port, auth, done := server(t)
+	host, user, port, auth, _, done := server(t)
 	defer done()
 
 	src := fmt.Sprintf(`
@@ -51,30 +71,1043 @@ try {
 	})
 }
 
-func server(t testing.TB) (host, user, port string, auth ssh.AuthMethod, close func() error) {
-	user = "testuser"
-	password := "tiger"
-	auth = ssh.Password(password)
+func TestExec(t *testing.T) {
+	host, user, port, auth, _, done := server(t)
+	defer done()
 
-	config := &ssh.ServerConfig{
-		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
-			if c.User() == user && string(pass) == password {
-				return nil, nil
+	src := fmt.Sprintf(`
+var session = ssh.session(%[1]q, {"user": %[2]q, "port": %[3]q});
+try {
+	var res = session.exec("1");
+	equals('you sent "1"', res.stdout, "should have the output");
+	equals("", res.stderr, "should have no errors");
+	assert(res.code === 0, "should have exited with 0");
+	assert(typeof res.duration === "string", "should tell how long it took");
+
+	res = session.exec("echo $A", {"env": {"A": "it's"}, "sudo": "deploy"});
+	assert(res.stdout.indexOf("sudo -n -u 'deploy' env A=") >= 0, "should wrap the command: " + res.stdout);
+
+	try {
+		session.exec("fail boom");
+		assert(false, "should throw on non-zero exit codes");
+	} catch (e) {
+		assert(String(e).indexOf("command exited with code 3: boom") >= 0, "unexpected error: " + e);
+	}
+	res = session.exec("fail boom", {"check": false});
+	assert(res.code === 3, "should have the exit code");
+	equals("boom\n", res.stderr, "should have the errors");
+
+	equals("hello", session.exec("cat", {"stdin": "hello"}).stdout, "should have sent the input");
+
+	var seen = {"stdout": [], "stderr": []};
+	session.exec("lines 3", {"stream": true, "on_line": function(line, stream) {
+		seen[stream].push(line);
+	}});
+	equals(["line 1", "line 2", "line 3"], seen.stdout, "should have seen stdout line by line");
+	equals(["err 1", "err 2", "err 3"], seen.stderr, "should have seen stderr line by line");
+
+	try {
+		session.exec("sleep 10", {"timeout": "100ms"});
+		assert(false, "should time out");
+	} catch (e) {
+		assert(String(e).indexOf("timed out after 100ms") >= 0, "unexpected error: " + e);
+	}
+
+	try {
+		session.exec("lines 500", {"on_line": function() { throw new Error("enough") }});
+		assert(false, "should throw the error of the callback");
+	} catch (e) {
+		assert(String(e).indexOf("enough") >= 0, "unexpected error: " + e);
+	}
+
+	assert(session.exec("2") == 'you sent "2"', "should still be usable");
+} finally {
+	session.close();
+}
+`, host, user, port)
+
+	var out bytes.Buffer
+	vmtest.Run(t, nil, src, func(vm *otto.Otto) error {
+		pkg, _, err := Apply(context.Background(), vm, auth, UseOutput(&out, &out))
+		if err != nil {
+			return err
+		}
+		return vm.Set("ssh", pkg)
+	})
+	for _, want := range []string{"line 3\n", "err 3\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("want %q streamed, got %q", want, out.String())
+		}
+	}
+}
+
+func TestExecTimeoutKills(t *testing.T) {
+	if _, err := exec.LookPath("timeout"); err != nil {
+		t.Skip("timeout isn't installed")
+	}
+	host, user, port, auth, _, done := server(t)
+	defer done()
+	dir, err := ioutil.TempDir("", "godotto-ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	marker := filepath.Join(dir, "still-running")
+
+	src := fmt.Sprintf(`
+var session = ssh.session(%[1]q, {"user": %[2]q, "port": %[3]q});
+try {
+	session.exec("sleep 2; touch %[4]s", {"timeout": "100ms"});
+	assert(false, "should time out");
+} catch (e) {
+	assert(String(e).indexOf("timed out after 100ms") >= 0, "unexpected error: " + e);
+} finally {
+	session.close();
+}
+`, host, user, port, marker)
+
+	vmtest.Run(t, nil, src, func(vm *otto.Otto) error {
+		pkg, _, err := Apply(context.Background(), vm, auth)
+		if err != nil {
+			return err
+		}
+		return vm.Set("ssh", pkg)
+	})
+	time.Sleep(3 * time.Second)
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("the command should have been killed on the host")
+	}
+}
+
+func TestCommand(t *testing.T) {
+	for _, tt := range []struct {
+		opts execOpts
+		want string
+	}{
+		{execOpts{Cmd: "uptime"}, "uptime"},
+		{execOpts{Cmd: "id", Sudo: "root"}, "sudo -n sh -c 'id'"},
+		{
+			execOpts{Cmd: "echo $A $B", Sudo: "deploy", Env: map[string]string{"B": "2", "A": "it's"}},
+			`sudo -n -u 'deploy' env A='it'\''s' B='2' sh -c 'echo $A $B'`,
+		},
+		{
+			execOpts{Cmd: "make", Timeout: 1500 * time.Millisecond},
+			`sh -c 'if command -v timeout >/dev/null 2>&1; then exec timeout -s KILL 2s sh -c '\''make'\''; else exec sh -c '\''make'\''; fi'`,
+		},
+		{
+			execOpts{Cmd: "id", Sudo: "root", Timeout: time.Minute},
+			`sh -c 'if command -v timeout >/dev/null 2>&1; then exec sudo -n timeout -s KILL 60s sh -c '\''id'\''; else exec sudo -n sh -c '\''id'\''; fi'`,
+		},
+	} {
+		if got := tt.opts.command(); got != tt.want {
+			t.Errorf("want %s, got %s", tt.want, got)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	if _, err := exec.LookPath("scp"); err != nil {
+		t.Skip("scp isn't installed")
+	}
+	host, user, port, auth, _, done := server(t)
+	defer done()
+
+	dir, err := ioutil.TempDir("", "godotto_ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	local := filepath.Join(dir, "local")
+	remote := filepath.Join(dir, "remote")
+	for _, d := range []string{filepath.Join(local, "conf", "sites"), remote} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range map[string]string{
+		"conf/app.conf":        "port = 8080\n",
+		"conf/sites/site.conf": "server_name example.com;\n",
+		"script.sh":            "#!/bin/sh\necho hi\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(local, name), []byte(content), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src := fmt.Sprintf(`
+var local = %[4]q;
+var remote = %[5]q;
+var session = ssh.session(%[1]q, {"user": %[2]q, "port": %[3]q});
+try {
+	session.upload(local + "/script.sh", remote + "/run.sh", {"mode": "0755"});
+	session.upload({"content": "hello\n"}, remote + "/motd");
+	session.upload(local + "/conf", remote + "/etc");
+	session.write_template(remote + "/nginx.conf", "listen {{.port}};\n{{range .names}}server_name {{.}};\n{{end}}", {
+		"port": 80,
+		"names": ["a.example.com", "b.example.com"],
+	});
+	try {
+		session.write_template(remote + "/nope", "{{.missing}}", {});
+		assert(false, "should fail on missing variables");
+	} catch (e) {}
+	try {
+		session.upload(local + "/nope", remote + "/nope");
+		assert(false, "should fail on missing files");
+	} catch (e) {
+		assert(String(e).indexOf("pass {content: ...} to upload a string") >= 0, "unexpected error: " + e);
+	}
+
+	session.download(remote + "/etc", local + "/back");
+	session.download(remote + "/motd", local);
+	try {
+		session.download(remote + "/nope", local + "/nope");
+		assert(false, "should fail on missing remote files");
+	} catch (e) {
+		assert(String(e).indexOf("scp:") >= 0, "unexpected error: " + e);
+	}
+} finally {
+	session.close();
+}
+`, host, user, port, local, remote)
+
+	vmtest.Run(t, nil, src, func(vm *otto.Otto) error {
+		pkg, _, err := Apply(context.Background(), vm, auth)
+		if err != nil {
+			return err
+		}
+		return vm.Set("ssh", pkg)
+	})
+
+	for _, tt := range []struct {
+		path, content string
+		mode          os.FileMode
+	}{
+		{"remote/run.sh", "#!/bin/sh\necho hi\n", 0755},
+		{"remote/motd", "hello\n", 0644},
+		{"remote/etc/app.conf", "port = 8080\n", 0640},
+		{"remote/etc/sites/site.conf", "server_name example.com;\n", 0640},
+		{"remote/nginx.conf", "listen 80;\nserver_name a.example.com;\nserver_name b.example.com;\n", 0644},
+		{"local/back/sites/site.conf", "server_name example.com;\n", 0640},
+		{"local/motd", "hello\n", 0644},
+	} {
+		filename := filepath.Join(dir, tt.path)
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+		if string(data) != tt.content {
+			t.Errorf("%s: want %q, got %q", tt.path, tt.content, data)
+		}
+		if fi, err := os.Stat(filename); err == nil && fi.Mode().Perm() != tt.mode {
+			t.Errorf("%s: want mode %o, got %o", tt.path, tt.mode, fi.Mode().Perm())
+		}
+	}
+}
+
+func TestHostKeys(t *testing.T) {
+	host, user, port, auth, hostKey, done := server(t)
+	defer done()
+
+	dir, err := ioutil.TempDir("", "godotto_ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	knownHosts := filepath.Join(dir, "known_hosts")
+
+	other, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ssh.NewPublicKey(&other.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := fmt.Sprintf(`
+var host = %[1]q;
+var opts = {"user": %[2]q, "port": %[3]q, "timeout": "5s"};
+function connect(policy, fingerprint) {
+	var o = {"host_key": policy, "host_key_fingerprint": fingerprint};
+	for (var k in opts) { o[k] = opts[k] }
+	ssh.session(host, o).close();
+}
+function refused(policy, fingerprint) {
+	try {
+		connect(policy, fingerprint);
+	} catch (e) {
+		assert(String(e).indexOf("host key verification failed") >= 0, "unexpected error: " + e);
+		return true;
+	}
+	return false;
+}
+
+assert(refused("strict"), "strict should refuse unknown hosts");
+connect("tofu");
+connect("strict");
+assert(refused("strict", "SHA256:nope"), "should refuse keys without the fingerprint");
+connect("strict", %[4]q);
+
+assert(ssh.forget(host, opts) == 1, "should forget the key");
+assert(refused("strict"), "strict should refuse forgotten hosts");
+connect("insecure");
+
+try {
+	ssh.session(host, {"host_key": "maybe"});
+	assert(false, "should reject unknown policies");
+} catch (e) {}
+
+replace_key();
+assert(refused("tofu"), "tofu should refuse changed keys");
+assert(refused("strict"), "strict should refuse changed keys");
+`, host, user, port, ssh.FingerprintSHA256(hostKey))
+
+	vmtest.Run(t, nil, src, func(vm *otto.Otto) error {
+		pkg, _, err := Apply(context.Background(), vm, auth, UseKnownHosts(knownHosts))
+		if err != nil {
+			return err
+		}
+		return vm.Set("ssh", pkg)
+	}, func(vm *otto.Otto) error {
+		return vm.Set("replace_key", func(otto.FunctionCall) otto.Value {
+			line := knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort(host, port))}, otherKey)
+			if err := ioutil.WriteFile(knownHosts, []byte(line+"\n"), 0600); err != nil {
+				t.Fatal(err)
 			}
-			return nil, fmt.Errorf("password rejected for %q", c.User())
+			return otto.UndefinedValue()
+		})
+	})
+}
+
+func TestHostKeyTypes(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signers []ssh.Signer
+	for _, k := range []interface{}{rsaKey, ecdsaKey, otherKey} {
+		signer, err := ssh.NewSignerFromKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signers = append(signers, signer)
+	}
+	// the ECDSA key is the one the client prefers
+	host, user, port, auth, done := serverWithHostKeys(t, signers[:2])
+	defer done()
+
+	dir, err := ioutil.TempDir("", "godotto_ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	knownHosts := filepath.Join(dir, "known_hosts")
+
+	src := fmt.Sprintf(`
+var opts = {"user": %[2]q, "port": %[3]q, "timeout": "5s"};
+function connect(policy) {
+	var o = {"host_key": policy};
+	for (var k in opts) { o[k] = opts[k] }
+	ssh.session(%[1]q, o).close();
+}
+
+know(0);
+connect("strict");  // with the RSA key, though the ECDSA one is preferred
+
+know(2);
+try {
+	connect("strict");
+	assert(false, "strict should refuse keys of types that aren't known");
+} catch (e) {
+	assert(String(e).indexOf("is unknown") >= 0, "should not be taken for a changed key: " + e);
+}
+connect("tofu");
+connect("strict");
+`, host, user, port)
+
+	vmtest.Run(t, nil, src, func(vm *otto.Otto) error {
+		pkg, _, err := Apply(context.Background(), vm, auth, UseKnownHosts(knownHosts))
+		if err != nil {
+			return err
+		}
+		return vm.Set("ssh", pkg)
+	}, func(vm *otto.Otto) error {
+		return vm.Set("know", func(all otto.FunctionCall) otto.Value {
+			i, _ := all.Argument(0).ToInteger()
+			line := knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort(host, port))}, signers[i].PublicKey())
+			if err := ioutil.WriteFile(knownHosts, []byte(line+"\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			return otto.UndefinedValue()
+		})
+	})
+}
+
+func TestIdentities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godotto_ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	plain, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey := func(name string, k *rsa.PrivateKey, passphrase string) ssh.PublicKey {
+		block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}
+		if passphrase != "" {
+			block, err = x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte(passphrase), x509.PEMCipherAES256)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+		pub, err := ssh.NewPublicKey(&k.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pub
+	}
+	plainPub := writeKey("id_plain", plain, "")
+	encryptedPub := writeKey("id_encrypted", encrypted, "secret")
+	opensshKey := filepath.Join(dir, "id_openssh")
+	if err := ioutil.WriteFile(opensshKey, encryptedOpenSSH(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	host, user, port, _, _, done := server(t, plainPub, encryptedPub)
+	defer done()
+
+	config := fmt.Sprintf(`
+# the test server
+Host myhost !other
+	HostName %[1]s
+	Port=%[2]s
+	User %[3]s
+	IdentityFile %[5]s
+	IdentityFile "%[4]s"
+
+Host *
+	User nobody
+`, host, port, user, filepath.Join(dir, "id_encrypted"), filepath.Join(dir, "id_nowhere"))
+	if err := ioutil.WriteFile(filepath.Join(dir, "config"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	asked := 0
+	src := fmt.Sprintf(`
+var host = %[1]q;
+var user = %[2]q;
+var port = %[3]q;
+
+// the default identities, as the agent has no keys
+var session = ssh.session(host, {"user": user, "port": port});
+assert(session.exec("1") == 'you sent "1"', "should have authenticated with the default identity");
+session.close();
+
+// the identity of the config, which is encrypted
+for (var i = 0; i < 2; i++) {
+	var session = ssh.session("myhost");
+	assert(session.exec("2") == 'you sent "2"', "should have authenticated with the identity of the config");
+	session.close();
+}
+assert(passphrases_asked() == 1, "should ask for the passphrase once");
+
+try {
+	ssh.session(host, {"user": user, "port": port, "identity": %[4]q});
+	assert(false, "should fail without the identity file");
+} catch (e) {
+	assert(String(e).indexOf("loading identity") >= 0, "unexpected error: " + e);
+}
+`, host, user, port, filepath.Join(dir, "id_missing"))
+
+	vmtest.Run(t, nil, src, func(vm *otto.Otto) error {
+		pkg, _, err := Apply(context.Background(), vm, nil,
+			UseIdentities(filepath.Join(dir, "id_missing"), filepath.Join(dir, "id_plain")),
+			UseConfig(filepath.Join(dir, "config")),
+			UsePassphrase(func(filename string) ([]byte, error) {
+				asked++
+				return []byte("secret"), nil
+			}),
+		)
+		if err != nil {
+			return err
+		}
+		return vm.Set("ssh", pkg)
+	}, func(vm *otto.Otto) error {
+		return vm.Set("passphrases_asked", func(otto.FunctionCall) otto.Value {
+			v, _ := vm.ToValue(asked)
+			return v
+		})
+	})
+
+	// keys that can't be loaded are told about when the others are refused
+	src = fmt.Sprintf(`
+try {
+	ssh.session(%[1]q, {"user": %[2]q, "port": %[3]q, "timeout": "5s"});
+	assert(false, "should fail without a key the host accepts");
+} catch (e) {
+	assert(String(e).indexOf("skipped identities: " + %[4]q + " is encrypted in the OpenSSH format") >= 0, "unexpected error: " + e);
+}
+`, host, user, port, opensshKey)
+	vmtest.Run(t, nil, src, func(vm *otto.Otto) error {
+		pkg, _, err := Apply(context.Background(), vm, nil, UseIdentities(opensshKey))
+		if err != nil {
+			return err
+		}
+		return vm.Set("ssh", pkg)
+	})
+
+	// a rescue image, only accepting passwords
+	src = fmt.Sprintf(`
+var session = ssh.session(%[1]q, {"user": %[2]q, "port": %[3]q, "password": "tiger"});
+assert(session.exec("3") == 'you sent "3"', "should have authenticated with the password");
+session.close();
+`, host, user, port)
+	vmtest.Run(t, nil, src, func(vm *otto.Otto) error {
+		pkg, _, err := Apply(context.Background(), vm, nil)
+		if err != nil {
+			return err
+		}
+		return vm.Set("ssh", pkg)
+	})
+}
+
+// encryptedOpenSSH is a private key encrypted in the format of OpenSSH.
+func encryptedOpenSSH() []byte {
+	header := ssh.Marshal(struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{"aes256-ctr", "bcrypt", "", 1, nil, nil})
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: append([]byte("openssh-key-v1\x00"), header...),
+	})
+}
+
+func TestLoadIdentityOpenSSH(t *testing.T) {
+	f, err := ioutil.TempFile("", "godotto_ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Write(encryptedOpenSSH())
+	f.Close()
+
+	_, err = LoadIdentity(f.Name(), func(string) ([]byte, error) {
+		t.Fatal("should not ask for a passphrase it can't use")
+		return nil, nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "ssh-add "+f.Name()) || !strings.Contains(err.Error(), "ssh-keygen -p -m PEM -f "+f.Name()) {
+		t.Fatalf("want an error telling how to use the key, got %v", err)
+	}
+}
+
+func TestConfigLookup(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader(`
+User everyone
+Host web-* !web-db
+	Port 2222
+	IdentityFile ~/.ssh/web
+Host web-1
+	User deploy
+	Port 22
+	IdentityFile ~/.ssh/web1
+Match exec "true"
+	User matched
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := os.Getenv("HOME")
+	for _, tt := range []struct {
+		names []string
+		want  hostConfig
+	}{
+		{
+			names: []string{"web-1", "192.0.2.1"},
+			want: hostConfig{User: "everyone", Port: "2222", Identities: []string{
+				filepath.Join(home, ".ssh/web"), filepath.Join(home, ".ssh/web1"),
+			}},
+		},
+		{
+			names: []string{"web-db"},
+			want:  hostConfig{User: "everyone"},
 		},
+		{
+			names: []string{"db", "192.0.2.2"},
+			want:  hostConfig{User: "everyone"},
+		},
+	} {
+		if got := cfg.lookup(tt.names...); !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("%v: want %#v, got %#v", tt.names, tt.want, got)
+		}
+	}
+}
+
+func TestShell(t *testing.T) {
+	// tests don't run in a terminal
+	src := `
+try {
+	ssh.shell("127.0.0.1");
+	assert(false, "should need a terminal");
+} catch (e) {
+	assert(String(e).indexOf("shells need a terminal") >= 0, "unexpected error: " + e);
+}
+`
+	stdin, err := ioutil.TempFile("", "godotto_ssh")
+	if err != nil {
+		t.Fatal(err)
 	}
+	defer os.Remove(stdin.Name())
+	defer stdin.Close()
+	vmtest.Run(t, nil, src, func(vm *otto.Otto) error {
+		pkg, _, err := Apply(context.Background(), vm, nil, func(svc *sshSvc) {
+			svc.termFd = int(stdin.Fd())
+		})
+		if err != nil {
+			return err
+		}
+		return vm.Set("ssh", pkg)
+	})
+}
+
+func TestWaitReady(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godotto_ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	host, user, port, auth, _, done := server(t)
+	defer done()
+
+	defer func(dir, log string) { cloudInitDir, cloudInitLog = dir, log }(cloudInitDir, cloudInitLog)
+	cloudInitDir = filepath.Join(dir, "cloud")
+	cloudInitLog = filepath.Join(dir, "cloud-init-output.log")
+	apply := func(vm *otto.Otto) error {
+		pkg, _, err := Apply(context.Background(), vm, auth)
+		if err != nil {
+			return err
+		}
+		return vm.Set("ssh", pkg)
+	}
+
+	vmtest.Run(t, nil, fmt.Sprintf(`
+var res = ssh.wait_ready(%[1]q, {"user": %[2]q, "port": %[3]q});
+equals("disabled", res.status, "should tell there's no cloud-init");
+`, host, user, port), apply)
+
+	for _, name := range []string{"instance", "data"} {
+		if err := os.MkdirAll(filepath.Join(cloudInitDir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result := `{"v1": {"datasource": "DataSourceDigitalOcean", "errors": ["boom"]}}`
+	if err := ioutil.WriteFile(filepath.Join(cloudInitDir, "data", "result.json"), []byte(result), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cloudInitLog, []byte("one\ntwo\nthree\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		ioutil.WriteFile(filepath.Join(cloudInitDir, "instance", "boot-finished"), nil, 0644)
+	}()
+
+	vmtest.Run(t, nil, fmt.Sprintf(`
+var res = ssh.wait_ready(%[1]q, {"user": %[2]q, "port": %[3]q, "log_lines": 2});
+equals("error", res.status, "should tell cloud-init failed");
+equals(["boom"], res.errors, "should have the errors of cloud-init");
+equals("DataSourceDigitalOcean", res.datasource, "should have the datasource");
+equals("two\nthree\n", res.log, "should have the end of the log");
+
+try {
+	ssh.wait_ready(%[1]q, {"user": "nobody", "port": %[3]q, "timeout": "1m"});
+	assert(false, "should fail");
+} catch (e) {
+	assert(String(e).indexOf("unable to authenticate") >= 0, "should not wait when the credentials are refused: " + e);
+}
+`, host, user, port), apply)
+
+	droplet := &godo.Droplet{Name: "web-1"}
+	if err := new(Provisioner).WaitReady(context.Background(), droplet); err == nil || !strings.Contains(err.Error(), "needs the ssh package") {
+		t.Errorf("want an error without the ssh package, got %v", err)
+	}
+}
+
+func TestHandshakeRetries(t *testing.T) {
+	host, user, port, auth, _, done := server(t)
+	defer done()
+
+	// a host that's starting, closing the first connections
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	var accepted int32
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			if atomic.AddInt32(&accepted, 1) <= 2 {
+				conn.Close()
+				continue
+			}
+			go func() {
+				defer conn.Close()
+				up, err := net.Dial("tcp", net.JoinHostPort(host, port))
+				if err != nil {
+					return
+				}
+				defer up.Close()
+				go func() {
+					io.Copy(up, conn)
+					up.Close()
+				}()
+				io.Copy(conn, up)
+			}()
+		}
+	}()
+	_, starting, _ := net.SplitHostPort(l.Addr().String())
+
+	vmtest.Run(t, nil, fmt.Sprintf(`
+var session = ssh.session(%[1]q, {"user": %[2]q, "port": %[3]q, "timeout": "10s"});
+equals('you sent "1"', session.exec("1").stdout, "should retry connections closed by the host");
+session.close();
+
+var start = Date.now();
+try {
+	ssh.session(%[1]q, {"user": "nobody", "port": %[4]q, "timeout": "10s"});
+	assert(false, "should fail");
+} catch (e) {
+	assert(String(e).indexOf("unable to authenticate") >= 0, "unexpected error: " + e);
+}
+assert(Date.now() - start < 1000, "should not retry refused credentials");
+`, host, user, starting, port), func(vm *otto.Otto) error {
+		pkg, _, err := Apply(context.Background(), vm, auth)
+		if err != nil {
+			return err
+		}
+		return vm.Set("ssh", pkg)
+	})
+	if n := atomic.LoadInt32(&accepted); n != 3 {
+		t.Errorf("want 3 connections to the starting host, got %d", n)
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godotto_ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	host, user, port, auth, _, done := server(t)
+	defer done()
+
+	configFile := filepath.Join(dir, "config")
+	config := fmt.Sprintf("Host web-1\n  User %s\n  Port %s\n", user, port)
+	if err := ioutil.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	droplet := &godo.Droplet{Name: "web-1", Networks: &godo.Networks{
+		V4: []godo.NetworkV4{{IPAddress: host, Type: "public"}},
+	}}
+
+	p := new(Provisioner)
+	if _, err := p.RunScript(context.Background(), droplet, "id"); err == nil || !strings.Contains(err.Error(), "needs the ssh package") {
+		t.Errorf("want an error without the ssh package, got %v", err)
+	}
+	_, cleanup, err := Apply(context.Background(), otto.New(), auth, UseConfig(configFile), UseProvisioner(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	out, err := p.RunScript(context.Background(), droplet, "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `you sent "sudo -n sh -c 'id'"`; out != want {
+		t.Errorf("should run as root with sudo, want %q, got %q", want, out)
+	}
+}
+
+func TestRunAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godotto_ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a server per droplet, found by name in the config
+	var (
+		auth   ssh.AuthMethod
+		config bytes.Buffer
+		all    []*godo.Droplet
+	)
+	for _, name := range []string{"web-1", "web-2", "db-1"} {
+		host, user, port, a, _, done := server(t)
+		defer done()
+		auth = a
+		fmt.Fprintf(&config, "Host %s\n  User %s\n  Port %s\n", name, user, port)
+		d := &godo.Droplet{ID: len(all) + 1, Name: name, Networks: &godo.Networks{
+			V4: []godo.NetworkV4{{IPAddress: host, Type: "public"}},
+		}}
+		if strings.HasPrefix(name, "web-") {
+			d.Tags = []string{"web"}
+		}
+		all = append(all, d)
+	}
+	configFile := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(configFile, config.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cloud := mockcloud.Client(nil)
+	cloud.MockDroplets.ListFn = func(_ context.Context, opts ...droplets.ListOpt) (<-chan droplets.Droplet, <-chan error) {
+		c, errc := make(chan droplets.Droplet, len(all)), make(chan error, 1)
+		for _, d := range all {
+			if len(opts) == 0 || len(d.Tags) > 0 { // only tag filters are used
+				c <- &droplet{d}
+			}
+		}
+		close(c)
+		close(errc)
+		return c, errc
+	}
+
+	src := `
+var res = ssh.run_all("tag:web", "1");
+assert(res.ok === 2 && res.failed === 0 && res.skipped === 0, "should have run on the tagged droplets: " + JSON.stringify(res));
+equals(["web-1", "web-2"], res.results.map(function(r) { return r.name }), "should keep the order of the droplets");
+equals('you sent "1"', res.results[1].stdout, "should have the output of each host");
+assert(res.results[1].code === 0, "should have the exit code of each host");
+
+res = ssh.run_all(["web-1", "db-1", {"id": 9, "name": "nowhere"}], "fail boom", {"summary": true});
+assert(res.failed === 3, "failures shouldn't throw: " + JSON.stringify(res));
+equals(["web-1", "db-1"], res.groups[0].hosts, "should group identical outputs");
+assert(res.groups[0].count === 2 && res.groups[0].code === 3, "unexpected group: " + JSON.stringify(res.groups[0]));
+equals("boom\n", res.groups[0].stderr, "should have the output of the group");
+equals(["nowhere"], res.groups[1].hosts, "unreachable droplets should be apart");
+assert(res.groups[1].error, "should tell why a droplet can't be reached");
+
+res = ssh.run_all(["web-1", "web-2", "db-1"], "fail boom", {"rolling": true});
+assert(res.failed === 1 && res.skipped === 2, "should stop after the first failure: " + JSON.stringify(res));
+assert(res.results[2].skipped, "should mark the droplets not run on");
+
+res = ssh.run_all(["web-1", "web-2"], "sleep 10", {"timeout": "100ms", "concurrency": 2});
+res.results.forEach(function(r) {
+	equals("timed out after 100ms", r.error, "should time out each host");
+});
+
+var seen = {};
+ssh.run_all("tag:web", "lines 2", {"stream": true, "on_line": function(line, stream, host) {
+	seen[host] = (seen[host] || 0) + 1;
+}});
+assert(seen["web-1"] === 4 && seen["web-2"] === 4, "should have seen the lines of each host: " + JSON.stringify(seen));
+
+try {
+	ssh.run_all("web-9", "1");
+	assert(false, "should throw on unknown droplets");
+} catch (e) {
+	assert(String(e).indexOf('no droplet named "web-9"') >= 0, "unexpected error: " + e);
+}
+`
+
+	var out bytes.Buffer
+	vmtest.Run(t, nil, src, func(vm *otto.Otto) error {
+		pkg, _, err := Apply(context.Background(), vm, auth,
+			UseConfig(configFile), UseCloud(cloud), UseOutput(&out, &out))
+		if err != nil {
+			return err
+		}
+		return vm.Set("ssh", pkg)
+	})
+	if want := "[web-2] line 2\n"; !strings.Contains(out.String(), want) {
+		t.Errorf("want %q streamed, got %q", want, out.String())
+	}
+}
+
+func TestVia(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godotto_ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bastion, user, bastionPort, auth, _, closeBastion := server(t)
+	defer closeBastion()
+	_, _, port, _, _, closeTarget := server(t)
+	defer closeTarget()
+	configFile := filepath.Join(dir, "config")
+	config := fmt.Sprintf("Host bastion\n  HostName %s\n  Port %s\nHost db-1\n  Port %s\nHost *\n  User %s\n", bastion, bastionPort, port, user)
+	if err := ioutil.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	src := `
+// only on the private network
+var droplet = {"id": 1, "name": "db-1", "networks": {"v4": [{"ip_address": "127.0.0.1", "type": "private"}]}};
+
+var session = ssh.session(droplet, {"via": "bastion"});
+try {
+	equals('you sent "1"', session.exec("1").stdout, "should have gone through the bastion");
+} finally {
+	session.close();
+}
+`
+	before := atomic.LoadInt32(&tunnels)
+	vmtest.Run(t, nil, src, func(vm *otto.Otto) error {
+		pkg, _, err := Apply(context.Background(), vm, auth, UseConfig(configFile))
+		if err != nil {
+			return err
+		}
+		return vm.Set("ssh", pkg)
+	})
+	if n := atomic.LoadInt32(&tunnels) - before; n != 1 {
+		t.Errorf("want a tunnel through the bastion, got %d", n)
+	}
+}
+
+func TestForward(t *testing.T) {
+	host, user, port, auth, _, done := server(t)
+	defer done()
+
+	// an echo server, only reached through the host
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	roundtrip := func(conn net.Conn, err error) string {
+		if err != nil {
+			return "error: " + err.Error()
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "ping")
+		data := make([]byte, 4)
+		if _, err := io.ReadFull(conn, data); err != nil {
+			return "error: " + err.Error()
+		}
+		return string(data)
+	}
+
+	src := fmt.Sprintf(`
+var session = ssh.session(%[1]q, {"user": %[2]q, "port": %[3]q});
+try {
+	var fwd = session.forward(0, "127.0.0.1", %[4]d);
+	assert(fwd.port > 0, "should have picked a port");
+	equals("ping", roundtrip(fwd.port), "should have reached the remote port");
+	fwd.close();
+	assert(roundtrip(fwd.port).indexOf("error") === 0, "should have stopped forwarding");
+
+	var proxy = session.socks();
+	equals("ping", socks_roundtrip(proxy.port, "127.0.0.1:%[4]d"), "should have reached the address through the proxy");
+	equals("ping", socks_roundtrip(proxy.port, "localhost:%[4]d"), "should have resolved names remotely");
+} finally {
+	session.close();
+}
+`, host, user, port, echo.Addr().(*net.TCPAddr).Port)
+
+	vmtest.Run(t, nil, src, func(vm *otto.Otto) error {
+		pkg, _, err := Apply(context.Background(), vm, auth)
+		if err != nil {
+			return err
+		}
+		if err := vm.Set("roundtrip", func(port int) string {
+			return roundtrip(net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port)))
+		}); err != nil {
+			return err
+		}
+		if err := vm.Set("socks_roundtrip", func(port int, addr string) string {
+			dialer, err := proxy.SOCKS5("tcp", fmt.Sprintf("127.0.0.1:%d", port), nil, proxy.Direct)
+			if err != nil {
+				return "error: " + err.Error()
+			}
+			return roundtrip(dialer.Dial("tcp", addr))
+		}); err != nil {
+			return err
+		}
+		return vm.Set("ssh", pkg)
+	})
+}
+
+type droplet struct{ d *godo.Droplet }
+
+func (d *droplet) Struct() *godo.Droplet { return d.d }
+
+func server(t testing.TB, authorized ...ssh.PublicKey) (host, user, port string, auth ssh.AuthMethod, hostKey ssh.PublicKey, close func() error) {
 	k, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
 		panic("Failed to generate private key")
 	}
-
 	private, err := ssh.NewSignerFromKey(k)
 	if err != nil {
 		panic("Failed to parse private key")
 	}
+	host, user, port, auth, close = serverWithHostKeys(t, []ssh.Signer{private}, authorized...)
+	return host, user, port, auth, private.PublicKey(), close
+}
 
-	config.AddHostKey(private)
+// serverWithHostKeys starts a test server with many host keys.
+func serverWithHostKeys(t testing.TB, hostKeys []ssh.Signer, authorized ...ssh.PublicKey) (host, user, port string, auth ssh.AuthMethod, close func() error) {
+	user = "testuser"
+	password := "tiger"
+	auth = ssh.Password(password)
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == user && string(pass) == password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %q", c.User())
+		},
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			for _, k := range authorized {
+				if c.User() == user && bytes.Equal(k.Marshal(), key.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, fmt.Errorf("key rejected for %q", c.User())
+		},
+	}
+	for _, k := range hostKeys {
+		config.AddHostKey(k)
+	}
 
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -90,13 +1123,18 @@ func server(t testing.TB) (host, user, port string, auth ssh.AuthMethod, close f
 
 			_, chans, reqs, err := ssh.NewServerConn(nConn, config)
 			if err != nil {
-				panic("failed to handshake")
+				// as when the client refuses the host key
+				continue
 			}
 
 			go ssh.DiscardRequests(reqs)
 
 			for newChannel := range chans {
 
+				if newChannel.ChannelType() == "direct-tcpip" {
+					go tunnel(newChannel)
+					continue
+				}
 				if newChannel.ChannelType() != "session" {
 					newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
 					continue
@@ -121,9 +1159,8 @@ func server(t testing.TB) (host, user, port string, auth ssh.AuthMethod, close f
 				}
 				req.Reply(ok, nil)
 				if ok {
-					fmt.Fprintf(channel, "you sent %q", line)
-					channel.CloseWrite()
-					channel.SendRequest("exit-status", false, []byte{0, 0, 0, 0})
+					go ssh.DiscardRequests(requests)
+					serve(channel, line)
 				}
 				channel.Close()
 			}
@@ -133,3 +1170,78 @@ func server(t testing.TB) (host, user, port string, auth ssh.AuthMethod, close f
 	host, port, _ = net.SplitHostPort(listener.Addr().String())
 	return host, user, port, auth, listener.Close
 }
+
+// tunnels opened through the test servers.
+var tunnels int32
+
+// tunnel connects a channel to the address asked for, as for port forwards.
+func tunnel(newChannel ssh.NewChannel) {
+	var to struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &to); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(to.Host, strconv.Itoa(int(to.Port))))
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	defer conn.Close()
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+	atomic.AddInt32(&tunnels, 1)
+	go ssh.DiscardRequests(requests)
+	replied := make(chan struct{})
+	go func() {
+		io.Copy(channel, conn)
+		channel.CloseWrite()
+		close(replied)
+	}()
+	io.Copy(conn, channel)
+	conn.(*net.TCPConn).CloseWrite()
+	<-replied
+}
+
+// serve a command sent to the test server: `fail <msg>` prints msg on stderr
+// and exits with 3, `cat` echoes its input, `lines <n>` prints n lines on
+// stdout and stderr, and anything else is sent back. Copies with scp, and `sh -c` scripts, are run locally.
+func serve(channel ssh.Channel, line string) {
+	var status uint32
+	switch {
+	case strings.HasPrefix(line, "scp "), strings.HasPrefix(line, "sh -c "):
+		cmd := exec.Command("sh", "-c", line)
+		cmd.Stdout, cmd.Stderr = channel, channel.Stderr()
+		// as sshd, don't wait for the input to end
+		stdin, _ := cmd.StdinPipe()
+		go func() {
+			io.Copy(stdin, channel)
+			stdin.Close()
+		}()
+		if err := cmd.Run(); err != nil {
+			status = 1
+		}
+	case strings.HasPrefix(line, "fail "):
+		fmt.Fprintln(channel.Stderr(), strings.TrimPrefix(line, "fail "))
+		status = 3
+	case line == "cat":
+		io.Copy(channel, channel)
+	case strings.HasPrefix(line, "lines "):
+		n, _ := strconv.Atoi(strings.TrimPrefix(line, "lines "))
+		for i := 1; i <= n; i++ {
+			fmt.Fprintf(channel, "line %d\n", i)
+			fmt.Fprintf(channel.Stderr(), "err %d\n", i)
+		}
+	default:
+		fmt.Fprintf(channel, "you sent %q", line)
+	}
+	channel.CloseWrite()
+	channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/aybabtme/godotto"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/mockcloud"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
 	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/robertkrimen/otto"
 )
@@ -19,14 +20,22 @@ type RunOption func(vm *otto.Otto) error
 
 // Run the JS source against godotto.
 func Run(t testing.TB, cloud cloud.Client, src string, opts ...RunOption) {
+	RunContext(t, context.Background(), cloud, src, opts...)
+}
+
+// RunContext runs the JS source against godotto, applied with ctx.
+func RunContext(t testing.TB, ctx context.Context, cloud cloud.Client, src string, opts ...RunOption) {
 
 	if cloud == nil {
 		cloud = mockcloud.Client(nil)
 	}
 
 	vm := otto.New()
+	// the completions of handles run as they're waited on
+	eventloop.Use(vm, eventloop.New())
+	defer eventloop.Use(vm, nil)
 
-	pkg, err := godotto.Apply(context.Background(), vm, cloud)
+	pkg, err := godotto.Apply(ctx, vm, cloud)
 	if err != nil {
 		t.Fatal(err)
 	}
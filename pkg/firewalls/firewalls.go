@@ -8,6 +8,7 @@ import (
 	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud/firewalls"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
 	"github.com/robertkrimen/otto"
 )
 
@@ -28,10 +29,21 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 		Name   string
 		Method interface{}
 	}{
-		{"create", svc.create},
 		{"get", svc.get},
-		{"delete", svc.delete},
 		{"list", svc.list},
+	} {
+
+		if err := root.Set(applier.Name, applier.Method); err != nil {
+			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
+		}
+	}
+
+	for _, applier := range []struct {
+		Name    string
+		Prepare func(otto.FunctionCall) eventloop.Task
+	}{
+		{"create", svc.create},
+		{"delete", svc.delete},
 		{"update", svc.update},
 		{"add_tags", svc.addTags},
 		{"remove_tags", svc.removeTags},
@@ -39,9 +51,9 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 		{"remove_droplets", svc.removeDroplets},
 		{"add_rules", svc.addRules},
 		{"remove_rules", svc.removeRules},
+		{"wait_applied", svc.waitApplied},
 	} {
-
-		if err := root.Set(applier.Name, applier.Method); err != nil {
+		if err := eventloop.Bind(root, applier.Name, applier.Prepare); err != nil {
 			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
 		}
 	}
@@ -54,161 +66,146 @@ type firewallsSvc struct {
 	svc firewalls.Client
 }
 
-func (svc *firewallsSvc) create(all otto.FunctionCall) otto.Value {
+func (svc *firewallsSvc) create(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 	arg := all.Argument(0)
 
-	req := godojs.ArgFirewallCreate(vm, arg)
+	req := godojs.ArgFirewallCreate(svc.ctx, vm, arg)
 
-	f, err := svc.svc.Create(svc.ctx, req.Name, req.InboundRules, req.OutboundRules, firewalls.UseGodoCreate(req))
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		f, err := svc.svc.Create(svc.ctx, req.Name, req.InboundRules, req.OutboundRules, firewalls.UseGodoCreate(req))
+		if err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.FirewallKind)
+		return func(vm *otto.Otto) otto.Value {
+			return svc.firewallToVM(vm, f.Struct())
+		}, nil
 	}
-
-	return godojs.FirewallToVM(vm, f.Struct())
 }
 
 func (svc *firewallsSvc) get(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
 	arg := all.Argument(0)
 
-	fwID := godojs.ArgFirewallID(vm, arg)
+	fwID := godojs.ArgFirewallID(svc.ctx, vm, arg)
 	f, err := svc.svc.Get(svc.ctx, fwID)
 	if err != nil {
 		ottoutil.Throw(vm, err.Error())
 	}
 
-	return godojs.FirewallToVM(vm, f.Struct())
+	return svc.firewallToVM(vm, f.Struct())
 }
 
-func (svc *firewallsSvc) delete(all otto.FunctionCall) otto.Value {
+func (svc *firewallsSvc) delete(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 	arg := all.Argument(0)
 
-	fwID := godojs.ArgFirewallID(vm, arg)
-	err := svc.svc.Delete(svc.ctx, fwID)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	fwID := godojs.ArgFirewallID(godojs.Fresh(svc.ctx), vm, arg)
+	return func() (eventloop.Result, error) {
+		if err := svc.svc.Delete(svc.ctx, fwID); err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.FirewallKind)
+		return nil, nil
 	}
-
-	return q
 }
 
 func (svc *firewallsSvc) list(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
 
-	var fws = make([]otto.Value, 0)
-	fwc, errc := svc.svc.List(svc.ctx)
-	for f := range fwc {
-		fws = append(fws, godojs.FirewallToVM(vm, f.Struct()))
-	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	v, err := vm.ToValue(fws)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	return v
+	ctx, cancel := context.WithCancel(svc.ctx)
+	fwc, errc := svc.svc.List(ctx)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+		f, more := <-fwc
+		if !more {
+			return q, false, <-errc
+		}
+		return svc.firewallToVM(vm, f.Struct()), true, nil
+	}, cancel)
 }
 
-func (svc *firewallsSvc) update(all otto.FunctionCall) otto.Value {
+func (svc *firewallsSvc) update(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 
-	fwID := godojs.ArgFirewallID(vm, all.Argument(0))
-	req := godojs.ArgFirewallUpdate(vm, all.Argument(1))
-	f, err := svc.svc.Update(svc.ctx, fwID, firewalls.UseGodoFirewall(req))
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	fwID := godojs.ArgFirewallID(svc.ctx, vm, all.Argument(0))
+	req := godojs.ArgFirewallUpdate(svc.ctx, vm, all.Argument(1))
+	return func() (eventloop.Result, error) {
+		f, err := svc.svc.Update(svc.ctx, fwID, firewalls.UseGodoFirewall(req))
+		if err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.FirewallKind)
+		return func(vm *otto.Otto) otto.Value {
+			return svc.firewallToVM(vm, f.Struct())
+		}, nil
 	}
-
-	return godojs.FirewallToVM(vm, f.Struct())
 }
 
-func (svc *firewallsSvc) addTags(all otto.FunctionCall) otto.Value {
+func (svc *firewallsSvc) addTags(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 
-	fwID := godojs.ArgFirewallID(vm, all.Argument(0))
+	fwID := godojs.ArgFirewallID(svc.ctx, vm, all.Argument(0))
 	tags := godojs.ArgTags(vm, all.Argument(1))
 
-	err := svc.svc.AddTags(svc.ctx, fwID, tags...)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.AddTags(svc.ctx, fwID, tags...)
 	}
-
-	return q
 }
 
-func (svc *firewallsSvc) removeTags(all otto.FunctionCall) otto.Value {
+func (svc *firewallsSvc) removeTags(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 
-	fwID := godojs.ArgFirewallID(vm, all.Argument(0))
+	fwID := godojs.ArgFirewallID(svc.ctx, vm, all.Argument(0))
 	tags := godojs.ArgTags(vm, all.Argument(1))
 
-	err := svc.svc.RemoveTags(svc.ctx, fwID, tags...)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.RemoveTags(svc.ctx, fwID, tags...)
 	}
-
-	return q
 }
 
-func (svc *firewallsSvc) addDroplets(all otto.FunctionCall) otto.Value {
+func (svc *firewallsSvc) addDroplets(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 
-	fwID := godojs.ArgFirewallID(vm, all.Argument(0))
-	dropletIDs := godojs.ArgDropletIDs(vm, all.Argument(1))
+	fwID := godojs.ArgFirewallID(svc.ctx, vm, all.Argument(0))
+	dropletIDs := godojs.ArgDropletIDs(svc.ctx, vm, all.Argument(1))
 
-	err := svc.svc.AddDroplets(svc.ctx, fwID, dropletIDs...)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.AddDroplets(svc.ctx, fwID, dropletIDs...)
 	}
-
-	return q
 }
 
-func (svc *firewallsSvc) removeDroplets(all otto.FunctionCall) otto.Value {
+func (svc *firewallsSvc) removeDroplets(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 
-	fwID := godojs.ArgFirewallID(vm, all.Argument(0))
-	dropletIDs := godojs.ArgDropletIDs(vm, all.Argument(1))
+	fwID := godojs.ArgFirewallID(svc.ctx, vm, all.Argument(0))
+	dropletIDs := godojs.ArgDropletIDs(svc.ctx, vm, all.Argument(1))
 
-	err := svc.svc.RemoveDroplets(svc.ctx, fwID, dropletIDs...)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.RemoveDroplets(svc.ctx, fwID, dropletIDs...)
 	}
-
-	return q
 }
 
-func (svc *firewallsSvc) addRules(all otto.FunctionCall) otto.Value {
+func (svc *firewallsSvc) addRules(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 
-	fwID := godojs.ArgFirewallID(vm, all.Argument(0))
-	inboundRules := godojs.ArgInboundRules(vm, all.Argument(1))
-	outboundRules := godojs.ArgOutboundRules(vm, all.Argument(2))
+	fwID := godojs.ArgFirewallID(svc.ctx, vm, all.Argument(0))
+	inboundRules := godojs.ArgInboundRules(svc.ctx, vm, all.Argument(1))
+	outboundRules := godojs.ArgOutboundRules(svc.ctx, vm, all.Argument(2))
 
-	err := svc.svc.AddRules(svc.ctx, fwID, inboundRules, outboundRules)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.AddRules(svc.ctx, fwID, inboundRules, outboundRules)
 	}
-
-	return q
 }
 
-func (svc *firewallsSvc) removeRules(all otto.FunctionCall) otto.Value {
+func (svc *firewallsSvc) removeRules(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 
-	fwID := godojs.ArgFirewallID(vm, all.Argument(0))
-	inboundRules := godojs.ArgInboundRules(vm, all.Argument(1))
-	outboundRules := godojs.ArgOutboundRules(vm, all.Argument(2))
+	fwID := godojs.ArgFirewallID(svc.ctx, vm, all.Argument(0))
+	inboundRules := godojs.ArgInboundRules(svc.ctx, vm, all.Argument(1))
+	outboundRules := godojs.ArgOutboundRules(svc.ctx, vm, all.Argument(2))
 
-	err := svc.svc.RemoveRules(svc.ctx, fwID, inboundRules, outboundRules)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.RemoveRules(svc.ctx, fwID, inboundRules, outboundRules)
 	}
-
-	return q
 }
@@ -841,3 +841,27 @@ var outbound_rules = [
 pkg.remove_rules("test-uuid", inbound_rules, outbound_rules);
 `)
 }
+
+func TestFirewallAsync(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	cloud.MockFirewalls.CreateFn = func(_ context.Context, name string, inboundRules []godo.InboundRule, outboundRules []godo.OutboundRule, opts ...firewalls.CreateOpt) (firewalls.Firewall, error) {
+		return &firewall{f}, nil
+	}
+	var tagged []string
+	cloud.MockFirewalls.AddTagsFn = func(_ context.Context, _ string, tags ...string) error {
+		tagged = append(tagged, tags...)
+		return nil
+	}
+
+	vmtest.Run(t, cloud, `
+var pkg = cloud.firewalls;
+var fw = pkg.create_async({"name": "test-sg", "inbound_rules": [], "outbound_rules": []}).wait("1s");
+equals(fw.id, "test-uuid", "should have created the firewall");
+fw.add_tags_async(["web"]).wait("1s");
+pkg.add_tags_async(fw.id, ["db"]).wait("1s");
+`)
+
+	if len(tagged) != 2 || tagged[0] != "web" || tagged[1] != "db" {
+		t.Fatalf("want tags %v, got %v", []string{"web", "db"}, tagged)
+	}
+}
@@ -0,0 +1,95 @@
+package firewalls
+
+import (
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/firewalls"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/digitalocean/godo"
+	"github.com/robertkrimen/otto"
+)
+
+// firewallToVM converts a firewall and binds methods to it, so that scripts
+// can do `fw.add(d)`.
+func (svc *firewallsSvc) firewallToVM(vm *otto.Otto, g *godo.Firewall) otto.Value {
+	v := godojs.FirewallToVM(vm, g)
+	if g == nil {
+		return v
+	}
+	id := g.ID
+
+	ottoutil.SetMethods(vm, v, map[string]func(otto.FunctionCall) otto.Value{
+		"refresh": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			f, err := svc.svc.Get(svc.ctx, id)
+			if err != nil {
+				ottoutil.Throw(vm, err.Error())
+			}
+			return ottoutil.Assign(vm, all.This, godojs.FirewallToVM(vm, f.Struct()))
+		},
+	})
+	return eventloop.Methods(vm, v, map[string]func(otto.FunctionCall) eventloop.Task{
+		"delete": func(all otto.FunctionCall) eventloop.Task {
+			return func() (eventloop.Result, error) {
+				if err := svc.svc.Delete(svc.ctx, id); err != nil {
+					return nil, err
+				}
+				godojs.Invalidate(svc.ctx, cloud.FirewallKind)
+				return nil, nil
+			}
+		},
+		"update": func(all otto.FunctionCall) eventloop.Task {
+			this := all.This
+			req := godojs.ArgFirewallUpdate(svc.ctx, all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				f, err := svc.svc.Update(svc.ctx, id, firewalls.UseGodoFirewall(req))
+				if err != nil {
+					return nil, err
+				}
+				godojs.Invalidate(svc.ctx, cloud.FirewallKind)
+				return func(vm *otto.Otto) otto.Value {
+					return ottoutil.Assign(vm, this, godojs.FirewallToVM(vm, f.Struct()))
+				}, nil
+			}
+		},
+		"add": func(all otto.FunctionCall) eventloop.Task {
+			dropletIDs := godojs.ArgDropletIDList(svc.ctx, all.Otto, all.ArgumentList...)
+			return func() (eventloop.Result, error) {
+				return nil, svc.svc.AddDroplets(svc.ctx, id, dropletIDs...)
+			}
+		},
+		"remove": func(all otto.FunctionCall) eventloop.Task {
+			dropletIDs := godojs.ArgDropletIDList(svc.ctx, all.Otto, all.ArgumentList...)
+			return func() (eventloop.Result, error) {
+				return nil, svc.svc.RemoveDroplets(svc.ctx, id, dropletIDs...)
+			}
+		},
+		"add_tags": func(all otto.FunctionCall) eventloop.Task {
+			tags := godojs.ArgTags(all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				return nil, svc.svc.AddTags(svc.ctx, id, tags...)
+			}
+		},
+		"remove_tags": func(all otto.FunctionCall) eventloop.Task {
+			tags := godojs.ArgTags(all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				return nil, svc.svc.RemoveTags(svc.ctx, id, tags...)
+			}
+		},
+		"add_rules": func(all otto.FunctionCall) eventloop.Task {
+			inboundRules := godojs.ArgInboundRules(svc.ctx, all.Otto, all.Argument(0))
+			outboundRules := godojs.ArgOutboundRules(svc.ctx, all.Otto, all.Argument(1))
+			return func() (eventloop.Result, error) {
+				return nil, svc.svc.AddRules(svc.ctx, id, inboundRules, outboundRules)
+			}
+		},
+		"remove_rules": func(all otto.FunctionCall) eventloop.Task {
+			inboundRules := godojs.ArgInboundRules(svc.ctx, all.Otto, all.Argument(0))
+			outboundRules := godojs.ArgOutboundRules(svc.ctx, all.Otto, all.Argument(1))
+			return func() (eventloop.Result, error) {
+				return nil, svc.svc.RemoveRules(svc.ctx, id, inboundRules, outboundRules)
+			}
+		},
+	})
+}
@@ -0,0 +1,40 @@
+package firewalls
+
+import (
+	"fmt"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/firewalls"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/godoutil"
+	"github.com/robertkrimen/otto"
+)
+
+// waitApplied waits for the pending changes of a firewall to be applied to
+// its droplets, as in `cloud.firewalls.wait_applied(fw, {timeout: "5m"})`.
+func (svc *firewallsSvc) waitApplied(all otto.FunctionCall) eventloop.Task {
+	vm := all.Otto
+	id := godojs.ArgFirewallID(svc.ctx, vm, all.Argument(0))
+	opts := godojs.ArgWaitOptions(vm, all.Argument(1))
+
+	return func() (eventloop.Result, error) {
+		var f firewalls.Firewall
+		err := godoutil.WaitFor(svc.ctx, func() (bool, error) {
+			var err error
+			if f, err = svc.svc.Get(svc.ctx, id); err != nil {
+				return false, err
+			}
+			g := f.Struct()
+			if g.Status == "failed" {
+				return false, fmt.Errorf("firewall %q failed to apply", id)
+			}
+			return g.Status != "waiting" && len(g.PendingChanges) == 0, nil
+		}, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("waiting for firewall %q to be applied: %v", id, err)
+		}
+		return func(vm *otto.Otto) otto.Value {
+			return svc.firewallToVM(vm, f.Struct())
+		}, nil
+	}
+}
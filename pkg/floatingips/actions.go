@@ -4,8 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
 	"github.com/aybabtme/godotto/pkg/extra/godojs"
-	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud/floatingips"
 	"github.com/robertkrimen/otto"
@@ -22,13 +22,13 @@ func applyAction(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.
 		svc: client.FloatingIPs().Actions(),
 	}
 	for _, applier := range []struct {
-		Name   string
-		Method func(otto.FunctionCall) otto.Value
+		Name    string
+		Prepare func(otto.FunctionCall) eventloop.Task
 	}{
 		{"assign", svc.assign},
 		{"unassign", svc.unassign},
 	} {
-		if err := root.Set(applier.Name, applier.Method); err != nil {
+		if err := eventloop.Bind(root, applier.Name, applier.Prepare); err != nil {
 			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
 		}
 	}
@@ -41,23 +41,27 @@ type actionSvc struct {
 	svc floatingips.ActionClient
 }
 
-func (svc *actionSvc) assign(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) assign(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	ip := godojs.ArgFloatingIPActualIP(vm, all.Argument(0))
-	dropletID := godojs.ArgDropletID(vm, all.Argument(1))
-	err := svc.svc.Assign(svc.ctx, ip, dropletID)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	ip := godojs.ArgFloatingIPActualIP(svc.ctx, vm, all.Argument(0))
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(1))
+	return func() (eventloop.Result, error) {
+		if err := svc.svc.Assign(svc.ctx, ip, dropletID); err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.FloatingIPKind)
+		return nil, nil
 	}
-	return q
 }
 
-func (svc *actionSvc) unassign(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) unassign(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	ip := godojs.ArgFloatingIPActualIP(vm, all.Argument(0))
-	err := svc.svc.Unassign(svc.ctx, ip)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	ip := godojs.ArgFloatingIPActualIP(godojs.Fresh(svc.ctx), vm, all.Argument(0))
+	return func() (eventloop.Result, error) {
+		if err := svc.svc.Unassign(svc.ctx, ip); err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.FloatingIPKind)
+		return nil, nil
 	}
-	return q
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud/floatingips"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
 
 	"github.com/robertkrimen/otto"
 )
@@ -35,9 +36,7 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 		Method interface{}
 	}{
 		{"list", svc.list},
-		{"create", svc.create},
 		{"get", svc.get},
-		{"delete", svc.delete},
 		{"actions", actions},
 	} {
 		if err := root.Set(applier.Name, applier.Method); err != nil {
@@ -45,6 +44,18 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 		}
 	}
 
+	for _, applier := range []struct {
+		Name    string
+		Prepare func(otto.FunctionCall) eventloop.Task
+	}{
+		{"create", svc.create},
+		{"delete", svc.delete},
+	} {
+		if err := eventloop.Bind(root, applier.Name, applier.Prepare); err != nil {
+			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
+		}
+	}
+
 	return root.Value(), nil
 }
 
@@ -53,54 +64,56 @@ type floatingIPSvc struct {
 	svc floatingips.Client
 }
 
-func (svc *floatingIPSvc) create(all otto.FunctionCall) otto.Value {
+func (svc *floatingIPSvc) create(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 
-	req := godojs.ArgFloatingIPCreateRequest(vm, all.Argument(0))
-	fip, err := svc.svc.Create(svc.ctx, req.Region, floatingips.UseGodoFloatingIP(req))
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	req := godojs.ArgFloatingIPCreateRequest(svc.ctx, vm, all.Argument(0))
+	return func() (eventloop.Result, error) {
+		fip, err := svc.svc.Create(svc.ctx, req.Region, floatingips.UseGodoFloatingIP(req))
+		if err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.FloatingIPKind)
+		return func(vm *otto.Otto) otto.Value {
+			return svc.floatingIPToVM(vm, fip.Struct())
+		}, nil
 	}
-	return godojs.FloatingIPToVM(vm, fip.Struct())
 }
 
 func (svc *floatingIPSvc) get(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
 
-	ip := godojs.ArgFloatingIPActualIP(vm, all.Argument(0))
+	ip := godojs.ArgFloatingIPActualIP(svc.ctx, vm, all.Argument(0))
 	fip, err := svc.svc.Get(svc.ctx, ip)
 	if err != nil {
 		ottoutil.Throw(vm, err.Error())
 	}
-	return godojs.FloatingIPToVM(vm, fip.Struct())
+	return svc.floatingIPToVM(vm, fip.Struct())
 }
 
-func (svc *floatingIPSvc) delete(all otto.FunctionCall) otto.Value {
+func (svc *floatingIPSvc) delete(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	ip := godojs.ArgFloatingIPActualIP(vm, all.Argument(0))
+	ip := godojs.ArgFloatingIPActualIP(godojs.Fresh(svc.ctx), vm, all.Argument(0))
 
-	err := svc.svc.Delete(svc.ctx, ip)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		if err := svc.svc.Delete(svc.ctx, ip); err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.FloatingIPKind)
+		return nil, nil
 	}
-	return q
 }
 
 func (svc *floatingIPSvc) list(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
 
-	var floatingIPs = make([]otto.Value, 0)
-	floatingIPc, errc := svc.svc.List(svc.ctx)
-	for d := range floatingIPc {
-		floatingIPs = append(floatingIPs, godojs.FloatingIPToVM(vm, d.Struct()))
-	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	v, err := vm.ToValue(floatingIPs)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-	return v
+	ctx, cancel := context.WithCancel(svc.ctx)
+	floatingIPc, errc := svc.svc.List(ctx)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+		d, more := <-floatingIPc
+		if !more {
+			return q, false, <-errc
+		}
+		return svc.floatingIPToVM(vm, d.Struct()), true, nil
+	}, cancel)
 }
@@ -174,3 +174,33 @@ var pkg = cloud.floating_ips;
 pkg.delete("127.0.0.1");
 `)
 }
+
+func TestMethodsAsync(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	cloud.MockFloatingIPs.GetFn = func(_ context.Context, ip string) (floatingips.FloatingIP, error) {
+		return &floatingip{&godo.FloatingIP{IP: ip, Region: &godo.Region{Slug: "nyc3"}}}, nil
+	}
+	var assigned []int
+	cloud.MockFloatingIPs.MockFloatingIPActions.AssignFn = func(_ context.Context, ip string, did int) error {
+		assigned = append(assigned, did)
+		return nil
+	}
+	cloud.MockFloatingIPs.MockFloatingIPActions.UnassignFn = func(_ context.Context, ip string) error {
+		return errors.New("not assigned")
+	}
+
+	vmtest.Run(t, cloud, `
+var ip = cloud.floating_ips.get("127.0.0.1");
+ip.assign_async(42).wait("1s");
+try {
+	ip.unassign_async().wait("1s");
+	throw "should have failed";
+} catch (e) {
+	assert(String(e).indexOf("not assigned") >= 0, "should fail with the error of the API: " + e);
+}
+`)
+
+	if len(assigned) != 1 || assigned[0] != 42 {
+		t.Fatalf("want assigned to 42, got %v", assigned)
+	}
+}
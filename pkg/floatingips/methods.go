@@ -0,0 +1,61 @@
+package floatingips
+
+import (
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/digitalocean/godo"
+	"github.com/robertkrimen/otto"
+)
+
+// floatingIPToVM converts a floating IP and binds methods to it, so that
+// scripts can do `ip.assign(d)`.
+func (svc *floatingIPSvc) floatingIPToVM(vm *otto.Otto, g *godo.FloatingIP) otto.Value {
+	v := godojs.FloatingIPToVM(vm, g)
+	if g == nil {
+		return v
+	}
+	ip := g.IP
+
+	ottoutil.SetMethods(vm, v, map[string]func(otto.FunctionCall) otto.Value{
+		"refresh": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			fip, err := svc.svc.Get(svc.ctx, ip)
+			if err != nil {
+				ottoutil.Throw(vm, err.Error())
+			}
+			return ottoutil.Assign(vm, all.This, godojs.FloatingIPToVM(vm, fip.Struct()))
+		},
+	})
+	return eventloop.Methods(vm, v, map[string]func(otto.FunctionCall) eventloop.Task{
+		"delete": func(all otto.FunctionCall) eventloop.Task {
+			return func() (eventloop.Result, error) {
+				if err := svc.svc.Delete(svc.ctx, ip); err != nil {
+					return nil, err
+				}
+				godojs.Invalidate(svc.ctx, cloud.FloatingIPKind)
+				return nil, nil
+			}
+		},
+		"assign": func(all otto.FunctionCall) eventloop.Task {
+			dropletID := godojs.ArgDropletID(svc.ctx, all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				if err := svc.svc.Actions().Assign(svc.ctx, ip, dropletID); err != nil {
+					return nil, err
+				}
+				godojs.Invalidate(svc.ctx, cloud.FloatingIPKind)
+				return nil, nil
+			}
+		},
+		"unassign": func(all otto.FunctionCall) eventloop.Task {
+			return func() (eventloop.Result, error) {
+				if err := svc.svc.Actions().Unassign(svc.ctx, ip); err != nil {
+					return nil, err
+				}
+				godojs.Invalidate(svc.ctx, cloud.FloatingIPKind)
+				return nil, nil
+			}
+		},
+	})
+}
@@ -0,0 +1,120 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/do/graph"
+	"github.com/aybabtme/godotto/pkg/extra/do/inventory"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/robertkrimen/otto"
+)
+
+var q = otto.Value{}
+
+// Apply creates `cloud.graph([inventory])`, which ties together the resources
+// of the account, or of an inventory taken earlier, and tells what depends on
+// what.
+func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value, error) {
+	svc := graphSvc{ctx: ctx, client: client}
+	return vm.ToValue(svc.build)
+}
+
+type graphSvc struct {
+	ctx    context.Context
+	client cloud.Client
+}
+
+func (svc *graphSvc) build(all otto.FunctionCall) otto.Value {
+	vm := all.Otto
+	var inv *inventory.Inventory
+	if arg := all.Argument(0); arg.IsDefined() && !arg.IsNull() {
+		inv = godojs.ArgInventory(vm, arg)
+	} else {
+		var err error
+		inv, err = inventory.Take(svc.ctx, svc.client)
+		if err != nil {
+			ottoutil.Throw(vm, err.Error())
+		}
+	}
+	return svc.graphToVM(vm, graph.Build(inv))
+}
+
+func (svc *graphSvc) graphToVM(vm *otto.Otto, g *graph.Graph) otto.Value {
+	obj, _ := vm.Object(`({})`)
+	return ottoutil.SetMethods(vm, obj.Value(), map[string]func(otto.FunctionCall) otto.Value{
+		"nodes": func(all otto.FunctionCall) otto.Value {
+			return godojs.JSONToVM(all.Otto, g.Nodes())
+		},
+		"edges": func(all otto.FunctionCall) otto.Value {
+			return godojs.JSONToVM(all.Otto, g.Edges())
+		},
+		"node": func(all otto.FunctionCall) otto.Value {
+			n, ok := g.Node(argRef(all.Otto, all.Argument(0)))
+			if !ok {
+				return otto.NullValue()
+			}
+			return godojs.JSONToVM(all.Otto, n)
+		},
+		"dependencies": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			edges := g.Dependencies(argRef(vm, all.Argument(0)), argKinds(vm, all.Argument(1))...)
+			return godojs.JSONToVM(vm, edges)
+		},
+		"dependents": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			edges := g.Dependents(argRef(vm, all.Argument(0)), argKinds(vm, all.Argument(1))...)
+			return godojs.JSONToVM(vm, edges)
+		},
+		"impact": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			return godojs.JSONToVM(vm, g.Impact(argRef(vm, all.Argument(0))))
+		},
+		"firewalls_for": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			return godojs.JSONToVM(vm, g.FirewallsFor(godojs.ArgDropletID(svc.ctx, vm, all.Argument(0))))
+		},
+		"dot": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			buf := bytes.NewBuffer(nil)
+			if err := g.WriteDOT(buf); err != nil {
+				ottoutil.Throw(vm, err.Error())
+			}
+			v, _ := vm.ToValue(buf.String())
+			return v
+		},
+	})
+}
+
+// argRef reads a reference to a node, given as `"droplet:1234"` or as a node
+// like `{kind: "droplet", id: "1234"}`.
+func argRef(vm *otto.Otto, v otto.Value) string {
+	if v.IsString() {
+		return ottoutil.String(vm, v)
+	}
+	if !v.IsObject() {
+		ottoutil.Throw(vm, "argument must be a reference like \"droplet:1234\" or a node, got a %q", v.Class())
+	}
+	kind := ottoutil.String(vm, ottoutil.GetObject(vm, v, "kind", true))
+	id := ottoutil.GetObject(vm, v, "id", true)
+	return graph.Ref(kind, id.String())
+}
+
+// argKinds reads options like `{kind: "firewall"}` or
+// `{kind: ["firewall", "load_balancer"]}`.
+func argKinds(vm *otto.Otto, v otto.Value) []string {
+	if !v.IsDefined() || v.IsNull() {
+		return nil
+	}
+	kind := ottoutil.GetObject(vm, v, "kind", false)
+	switch {
+	case !kind.IsDefined():
+		return nil
+	case kind.IsString():
+		return []string{ottoutil.String(vm, kind)}
+	default:
+		return ottoutil.StringSlice(vm, kind)
+	}
+}
@@ -0,0 +1,61 @@
+package graph_test
+
+import (
+	"testing"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/mockcloud"
+	"github.com/aybabtme/godotto/pkg/extra/vmtest"
+)
+
+func TestGraph(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+
+	vmtest.Run(t, cloud, `
+var inv = {
+	version: 1,
+	droplets: [
+		{id: 1, name: "web-1", tags: ["web"], volume_ids: ["vol-1"],
+		 networks: {v4: [{ip_address: "10.0.0.1", type: "public"}]}},
+		{id: 2, name: "db-1", tags: ["db"], volume_ids: []}
+	],
+	volumes: [{id: "vol-1", name: "data", droplet_ids: [1]}],
+	snapshots: [{id: "snap-1", name: "data-backup", resource_id: "vol-1", resource_type: "volume"}],
+	domains: [{name: "example.com", records: [{id: 1, type: "A", name: "www", data: "10.0.0.1"}]}],
+	floating_ips: [{ip: "10.0.0.9", droplet: {id: 2}}],
+	tags: [{name: "web"}, {name: "db"}],
+	load_balancers: [{id: "lb-1", name: "front", tag: "web"}],
+	firewalls: [
+		{id: "fw-1", name: "web-fw", tags: ["web"]},
+		{id: "fw-2", name: "db-fw", droplet_ids: [2]}
+	]
+};
+var g = cloud.graph(inv);
+
+assert(g.nodes().length == 11, "should have a node per resource");
+
+var deps = g.dependents("droplet:1");
+var rels = deps.map(function(e) { return e.from.kind + "/" + e.rel; }).sort();
+equals(rels, ["domain/points_to", "firewall/applies_to", "load_balancer/targets"], "should know what depends on a droplet");
+
+var fws = g.firewalls_for({id: 1});
+assert(fws.length == 1, "should find the firewalls of a droplet");
+equals(fws[0].from.name, "web-fw");
+equals(fws[0].via, "tag:web", "should tell the firewall applies through a tag");
+
+fws = g.firewalls_for(2);
+equals(fws[0].from.name, "db-fw");
+equals(fws[0].via, undefined);
+
+var impact = g.impact({kind: "volume", id: "vol-1"});
+assert(impact.length == 1, "snapshots shouldn't break when their volume is deleted");
+equals(impact[0].from.name, "web-1");
+assert(g.dependents("volume:vol-1").length == 2, "snapshots should depend on their volume");
+
+equals(g.dependencies("droplet:1", {kind: "volume"})[0].to.id, "vol-1");
+equals(g.node("droplet:404"), null);
+
+var dot = g.dot();
+assert(dot.indexOf("digraph account {") == 0, "should be a DOT digraph");
+assert(dot.indexOf('"firewall:fw-1" -> "droplet:1"') > 0, "should have the edges");
+`)
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/aybabtme/godotto/pkg/extra/godojs"
-	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud/images"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 
 	"github.com/digitalocean/godo"
 	"github.com/robertkrimen/otto"
@@ -35,10 +36,20 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 		{"list_application", svc.listApplication},
 		{"list_user", svc.listUser},
 		{"get", svc.get},
+	} {
+		if err := root.Set(applier.Name, applier.Method); err != nil {
+			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
+		}
+	}
+
+	for _, applier := range []struct {
+		Name    string
+		Prepare func(otto.FunctionCall) eventloop.Task
+	}{
 		{"update", svc.update},
 		{"delete", svc.delete},
 	} {
-		if err := root.Set(applier.Name, applier.Method); err != nil {
+		if err := eventloop.Bind(root, applier.Name, applier.Prepare); err != nil {
 			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
 		}
 	}
@@ -70,7 +81,7 @@ func (svc *imageSvc) get(all otto.FunctionCall) otto.Value {
 	arg := all.Argument(0)
 	switch {
 	case arg.IsNumber():
-		id := godojs.ArgImageID(vm, all.Argument(0))
+		id := godojs.ArgImageID(svc.ctx, vm, all.Argument(0))
 		img, err = svc.svc.GetByID(svc.ctx, id)
 	case arg.IsString():
 		slug := godojs.ArgImageSlug(vm, all.Argument(0))
@@ -82,34 +93,61 @@ func (svc *imageSvc) get(all otto.FunctionCall) otto.Value {
 	return godojs.ImageToVM(vm, img.Struct())
 }
 
-func (svc *imageSvc) update(all otto.FunctionCall) otto.Value {
+func (svc *imageSvc) update(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 
 	var (
 		// they read the same arg, just different fields
-		id  = godojs.ArgImageID(vm, all.Argument(0))
+		id  = godojs.ArgImageID(svc.ctx, vm, all.Argument(0))
 		req = svc.argImageUpdate(all, 1)
 	)
-	img, err := svc.svc.Update(svc.ctx, id, images.UseGodoImage(req))
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		img, err := svc.svc.Update(svc.ctx, id, images.UseGodoImage(req))
+		if err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.ImageKind, cloud.SnapshotKind)
+		return func(vm *otto.Otto) otto.Value {
+			return godojs.ImageToVM(vm, img.Struct())
+		}, nil
 	}
-	return godojs.ImageToVM(vm, img.Struct())
 }
 
-func (svc *imageSvc) delete(all otto.FunctionCall) otto.Value {
+func (svc *imageSvc) delete(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	id := godojs.ArgImageID(vm, all.Argument(0))
+	id := godojs.ArgImageID(godojs.Fresh(svc.ctx), vm, all.Argument(0))
 
-	err := svc.svc.Delete(svc.ctx, id)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		if err := svc.svc.Delete(svc.ctx, id); err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.ImageKind, cloud.SnapshotKind)
+		return nil, nil
+	}
+}
+
+// argListOpts reads filters like `{type: "distribution", private: false}`.
+func (svc *imageSvc) argListOpts(all otto.FunctionCall, i int) []images.ListOpt {
+	vm := all.Otto
+	arg := all.Argument(i)
+	if !arg.IsObject() {
+		return nil
+	}
+	var opts []images.ListOpt
+	if v := ottoutil.GetObject(vm, arg, "type", false); v.IsDefined() {
+		opts = append(opts, images.FilterType(ottoutil.String(vm, v)))
+	}
+	if v := ottoutil.GetObject(vm, arg, "private", false); v.IsDefined() {
+		opts = append(opts, images.FilterPrivate(ottoutil.Bool(vm, v)))
 	}
-	return q
+	return opts
 }
 
 func (svc *imageSvc) list(all otto.FunctionCall) otto.Value {
-	return svc.listCommon(all, svc.svc.List)
+	opts := svc.argListOpts(all, 0)
+	return svc.listCommon(all, func(ctx context.Context) (<-chan images.Image, <-chan error) {
+		return svc.svc.List(ctx, opts...)
+	})
 }
 
 func (svc *imageSvc) listDistribution(all otto.FunctionCall) otto.Value {
@@ -129,18 +167,13 @@ type listfunc func(context.Context) (<-chan images.Image, <-chan error)
 func (svc *imageSvc) listCommon(all otto.FunctionCall, listfn listfunc) otto.Value {
 	vm := all.Otto
 
-	var images = make([]otto.Value, 0)
-	imagec, errc := listfn(svc.ctx)
-	for d := range imagec {
-		images = append(images, godojs.ImageToVM(vm, d.Struct()))
-	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	v, err := vm.ToValue(images)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-	return v
+	ctx, cancel := context.WithCancel(svc.ctx)
+	imagec, errc := listfn(ctx)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+		d, more := <-imagec
+		if !more {
+			return q, false, <-errc
+		}
+		return godojs.ImageToVM(vm, d.Struct()), true, nil
+	}, cancel)
 }
@@ -38,7 +38,7 @@ func TestThrows(t *testing.T) {
 		close(ec)
 		return lc, ec
 	}
-	cloud.MockImages.ListFn = listfn
+	cloud.MockImages.ListFn = func(ctx context.Context, _ ...images.ListOpt) (<-chan images.Image, <-chan error) { return listfn(ctx) }
 	cloud.MockImages.ListApplicationFn = listfn
 	cloud.MockImages.ListDistributionFn = listfn
 	cloud.MockImages.ListUserFn = listfn
@@ -102,7 +102,7 @@ func TestList(t *testing.T) {
 		return lc, ec
 	}
 	cloud := mockcloud.Client(nil)
-	cloud.MockImages.ListFn = listfn
+	cloud.MockImages.ListFn = func(ctx context.Context, _ ...images.ListOpt) (<-chan images.Image, <-chan error) { return listfn(ctx) }
 	cloud.MockImages.ListApplicationFn = listfn
 	cloud.MockImages.ListDistributionFn = listfn
 	cloud.MockImages.ListUserFn = listfn
@@ -227,3 +227,22 @@ var pkg = cloud.images;
 pkg.delete(42);
 `)
 }
+
+func TestDeleteAsync(t *testing.T) {
+	var deleted []int
+	cloud := mockcloud.Client(nil)
+	cloud.MockImages.DeleteFn = func(_ context.Context, id int) error {
+		deleted = append(deleted, id)
+		return nil
+	}
+
+	vmtest.Run(t, cloud, `
+var pkg = cloud.images;
+
+pkg.delete_async(42).wait("1s");
+`)
+
+	if len(deleted) != 1 || deleted[0] != 42 {
+		t.Fatalf("want image 42 deleted, got %v", deleted)
+	}
+}
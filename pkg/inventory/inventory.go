@@ -0,0 +1,56 @@
+package inventory
+
+import (
+	"context"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/do/inventory"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/robertkrimen/otto"
+)
+
+var q = otto.Value{}
+
+// Apply creates `cloud.inventory()`, which takes a snapshot of every resource
+// of the account, and `cloud.inventory.diff(a, b)`, which tells what was
+// added, removed and changed from one snapshot to another.
+func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value, error) {
+	svc := inventorySvc{ctx: ctx, client: client}
+	fn, err := vm.ToValue(svc.take)
+	if err != nil {
+		return q, err
+	}
+	if err := fn.Object().Set("diff", svc.diff); err != nil {
+		return q, err
+	}
+	return fn, nil
+}
+
+type inventorySvc struct {
+	ctx    context.Context
+	client cloud.Client
+}
+
+func (svc *inventorySvc) take(all otto.FunctionCall) otto.Value {
+	vm := all.Otto
+	inv, err := inventory.Take(svc.ctx, svc.client)
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	return godojs.JSONToVM(vm, inv)
+}
+
+func (svc *inventorySvc) diff(all otto.FunctionCall) otto.Value {
+	vm := all.Otto
+	a := godojs.ArgInventory(vm, all.Argument(0))
+	b := godojs.ArgInventory(vm, all.Argument(1))
+	changes, err := inventory.Diff(a, b)
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	if changes == nil {
+		changes = []inventory.Change{}
+	}
+	return godojs.JSONToVM(vm, changes)
+}
@@ -0,0 +1,150 @@
+package inventory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/domains"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/droplets"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/firewalls"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/floatingips"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/images"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/keys"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/loadbalancers"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/snapshots"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/tags"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/volumes"
+	"github.com/aybabtme/godotto/pkg/extra/do/mockcloud"
+	"github.com/aybabtme/godotto/pkg/extra/vmtest"
+	"github.com/digitalocean/godo"
+	"github.com/robertkrimen/otto"
+)
+
+type droplet struct{ *godo.Droplet }
+
+func (k *droplet) Struct() *godo.Droplet { return k.Droplet }
+
+type domain struct{ *godo.Domain }
+
+func (k *domain) Struct() *godo.Domain { return k.Domain }
+
+type record struct{ *godo.DomainRecord }
+
+func (k *record) Struct() *godo.DomainRecord { return k.DomainRecord }
+
+func noErr() <-chan error {
+	errc := make(chan error)
+	close(errc)
+	return errc
+}
+
+// fakeCloud has the given droplets, a domain with a record, and no other
+// resources.
+func fakeCloud(current *[]*godo.Droplet) *mockcloud.Mock {
+	cloud := mockcloud.Client(nil)
+	cloud.MockDroplets.ListFn = func(context.Context, ...droplets.ListOpt) (<-chan droplets.Droplet, <-chan error) {
+		c := make(chan droplets.Droplet, len(*current))
+		for _, d := range *current {
+			c <- &droplet{d}
+		}
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockVolumes.ListVolumesFn = func(context.Context, ...volumes.ListOpt) (<-chan volumes.Volume, <-chan error) {
+		c := make(chan volumes.Volume)
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockSnapshots.ListFn = func(context.Context) (<-chan snapshots.Snapshot, <-chan error) {
+		c := make(chan snapshots.Snapshot)
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockImages.ListFn = func(context.Context, ...images.ListOpt) (<-chan images.Image, <-chan error) {
+		c := make(chan images.Image)
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockDomains.ListFn = func(context.Context) (<-chan domains.Domain, <-chan error) {
+		c := make(chan domains.Domain, 1)
+		c <- &domain{&godo.Domain{Name: "example.com", TTL: 1800}}
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockDomains.ListRecordFn = func(_ context.Context, name string, _ ...domains.RecordListOpt) (<-chan domains.Record, <-chan error) {
+		c := make(chan domains.Record, 1)
+		c <- &record{&godo.DomainRecord{ID: 1, Type: "A", Name: "www", Data: "127.0.0.1"}}
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockFloatingIPs.ListFn = func(context.Context) (<-chan floatingips.FloatingIP, <-chan error) {
+		c := make(chan floatingips.FloatingIP)
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockKeys.ListFn = func(context.Context) (<-chan keys.Key, <-chan error) {
+		c := make(chan keys.Key)
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockTags.ListFn = func(context.Context) (<-chan tags.Tag, <-chan error) {
+		c := make(chan tags.Tag)
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockLoadBalancers.ListFn = func(context.Context) (<-chan loadbalancers.LoadBalancer, <-chan error) {
+		c := make(chan loadbalancers.LoadBalancer)
+		close(c)
+		return c, noErr()
+	}
+	cloud.MockFirewalls.ListFn = func(context.Context) (<-chan firewalls.Firewall, <-chan error) {
+		c := make(chan firewalls.Firewall)
+		close(c)
+		return c, noErr()
+	}
+	return cloud
+}
+
+func TestInventory(t *testing.T) {
+	current := []*godo.Droplet{
+		{ID: 1, Name: "web-1", Status: "active", Tags: []string{"web"}},
+		{ID: 2, Name: "web-2", Status: "active"},
+	}
+	cloud := fakeCloud(&current)
+
+	vmtest.Run(t, cloud, `
+var before = cloud.inventory();
+assert(before.version == 1, "should be versioned");
+assert(before.droplets.length == 2, "should have the droplets");
+equals(before.domains[0].records[0].name, "www", "should have the records of domains");
+equals(before.volumes, [], "should have empty lists");
+
+change();
+var after = cloud.inventory();
+
+var changes = cloud.inventory.diff(before, JSON.stringify(after));
+assert(changes.length == 3, "should have a change per resource");
+
+equals(changes[0].op, "changed");
+equals(changes[0].id, "1");
+equals(changes[0].fields[0].path, "status");
+equals(changes[0].fields[0].old, "active");
+equals(changes[0].fields[0].new, "off");
+
+equals(changes[1].op, "removed");
+equals(changes[1].name, "web-2");
+
+equals(changes[2].op, "added");
+equals(changes[2].name, "web-3");
+
+assert(cloud.inventory.diff(after, after).length == 0, "should have no changes");
+`, func(vm *otto.Otto) error {
+		return vm.Set("change", func(otto.FunctionCall) otto.Value {
+			current = []*godo.Droplet{
+				{ID: 1, Name: "web-1", Status: "off", Tags: []string{"web"}},
+				{ID: 3, Name: "web-3", Status: "new"},
+			}
+			return otto.UndefinedValue()
+		})
+	})
+}
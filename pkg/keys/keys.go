@@ -1,13 +1,15 @@
 package keys
 
 import (
-	"fmt"
 	"context"
+	"fmt"
+	"regexp"
 
-	"github.com/aybabtme/godotto/pkg/extra/godojs"
-	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud/keys"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 
 	"github.com/robertkrimen/otto"
 )
@@ -30,12 +32,22 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 		Method func(otto.FunctionCall) otto.Value
 	}{
 		{"list", svc.list},
-		{"create", svc.create},
 		{"get", svc.get},
+	} {
+		if err := root.Set(applier.Name, applier.Method); err != nil {
+			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
+		}
+	}
+
+	for _, applier := range []struct {
+		Name    string
+		Prepare func(otto.FunctionCall) eventloop.Task
+	}{
+		{"create", svc.create},
 		{"update", svc.update},
 		{"delete", svc.delete},
 	} {
-		if err := root.Set(applier.Name, applier.Method); err != nil {
+		if err := eventloop.Bind(root, applier.Name, applier.Prepare); err != nil {
 			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
 		}
 	}
@@ -48,15 +60,24 @@ type keySvc struct {
 	svc keys.Client
 }
 
-func (svc *keySvc) create(all otto.FunctionCall) otto.Value {
+func (svc *keySvc) create(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 
 	req := godojs.ArgKeyCreate(vm, all.Argument(0))
-	key, err := svc.svc.Create(svc.ctx, req.Name, req.PublicKey)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		key, err := svc.svc.Create(svc.ctx, req.Name, req.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.KeyKind)
+		return keyResult(key), nil
+	}
+}
+
+func keyResult(key keys.Key) eventloop.Result {
+	return func(vm *otto.Otto) otto.Value {
+		return godojs.KeyToVM(vm, key.Struct())
 	}
-	return godojs.KeyToVM(vm, key.Struct())
 }
 
 func (svc *keySvc) get(all otto.FunctionCall) otto.Value {
@@ -67,13 +88,12 @@ func (svc *keySvc) get(all otto.FunctionCall) otto.Value {
 		err error
 	)
 	arg := all.Argument(0)
-	switch {
-	case arg.IsNumber():
-		id := godojs.ArgKeyID(vm, all.Argument(0))
-		key, err = svc.svc.GetByID(svc.ctx, id)
-	case arg.IsString():
-		fp := godojs.ArgKeyFingerprint(vm, all.Argument(0))
+	if isFingerprint(arg) {
+		fp := godojs.ArgKeyFingerprint(vm, arg)
 		key, err = svc.svc.GetByFingerprint(svc.ctx, fp)
+	} else {
+		id := godojs.ArgKeyID(svc.ctx, vm, arg)
+		key, err = svc.svc.GetByID(svc.ctx, id)
 	}
 	if err != nil {
 		ottoutil.Throw(vm, err.Error())
@@ -81,62 +101,73 @@ func (svc *keySvc) get(all otto.FunctionCall) otto.Value {
 	return godojs.KeyToVM(vm, key.Struct())
 }
 
-func (svc *keySvc) update(all otto.FunctionCall) otto.Value {
+func (svc *keySvc) update(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 	arg := all.Argument(0)
-	var (
-		key keys.Key
-		err error
-	)
-	switch {
-	case arg.IsNumber():
-		id := godojs.ArgKeyID(vm, all.Argument(0))
-		req := godojs.ArgKeyUpdate(vm, all.Argument(1))
-		key, err = svc.svc.UpdateByID(svc.ctx, id, keys.UseGodoKey(req))
-	case arg.IsString():
-		fp := godojs.ArgKeyFingerprint(vm, all.Argument(0))
-		req := godojs.ArgKeyUpdate(vm, all.Argument(1))
-		key, err = svc.svc.UpdateByFingerprint(svc.ctx, fp, keys.UseGodoKey(req))
+	req := godojs.ArgKeyUpdate(vm, all.Argument(1))
+	if isFingerprint(arg) {
+		fp := godojs.ArgKeyFingerprint(vm, arg)
+		return func() (eventloop.Result, error) {
+			key, err := svc.svc.UpdateByFingerprint(svc.ctx, fp, keys.UseGodoKey(req))
+			if err != nil {
+				return nil, err
+			}
+			godojs.Invalidate(svc.ctx, cloud.KeyKind)
+			return keyResult(key), nil
+		}
 	}
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	id := godojs.ArgKeyID(svc.ctx, vm, arg)
+	return func() (eventloop.Result, error) {
+		key, err := svc.svc.UpdateByID(svc.ctx, id, keys.UseGodoKey(req))
+		if err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.KeyKind)
+		return keyResult(key), nil
 	}
-	return godojs.KeyToVM(vm, key.Struct())
 }
 
-func (svc *keySvc) delete(all otto.FunctionCall) otto.Value {
+func (svc *keySvc) delete(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 	arg := all.Argument(0)
-	var err error
-	switch {
-	case arg.IsNumber():
-		id := godojs.ArgKeyID(vm, all.Argument(0))
-		err = svc.svc.DeleteByID(svc.ctx, id)
-	case arg.IsString():
-		fp := godojs.ArgKeyFingerprint(vm, all.Argument(0))
-		err = svc.svc.DeleteByFingerprint(svc.ctx, fp)
+	if isFingerprint(arg) {
+		fp := godojs.ArgKeyFingerprint(vm, arg)
+		return func() (eventloop.Result, error) {
+			if err := svc.svc.DeleteByFingerprint(svc.ctx, fp); err != nil {
+				return nil, err
+			}
+			godojs.Invalidate(svc.ctx, cloud.KeyKind)
+			return nil, nil
+		}
 	}
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	id := godojs.ArgKeyID(godojs.Fresh(svc.ctx), vm, arg)
+	return func() (eventloop.Result, error) {
+		if err := svc.svc.DeleteByID(svc.ctx, id); err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.KeyKind)
+		return nil, nil
 	}
-	return q
+}
+
+var fingerprintRegexp = regexp.MustCompile(`^([0-9a-fA-F]{2}:){15}[0-9a-fA-F]{2}$`)
+
+// isFingerprint tells if v is the fingerprint of a key, rather than its ID
+// or its name.
+func isFingerprint(v otto.Value) bool {
+	return v.IsString() && fingerprintRegexp.MatchString(v.String())
 }
 
 func (svc *keySvc) list(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
 
-	var keys = make([]otto.Value, 0)
-	keyc, errc := svc.svc.List(svc.ctx)
-	for d := range keyc {
-		keys = append(keys, godojs.KeyToVM(vm, d.Struct()))
-	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	v, err := vm.ToValue(keys)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-	return v
+	ctx, cancel := context.WithCancel(svc.ctx)
+	keyc, errc := svc.svc.List(ctx)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+		d, more := <-keyc
+		if !more {
+			return q, false, <-errc
+		}
+		return godojs.KeyToVM(vm, d.Struct()), true, nil
+	}, cancel)
 }
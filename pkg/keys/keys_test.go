@@ -4,9 +4,11 @@ import (
 	"context"
 	"testing"
 
-	"github.com/aybabtme/godotto/pkg/extra/vmtest"
+	doCloud "github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud/keys"
 	"github.com/aybabtme/godotto/pkg/extra/do/mockcloud"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/vmtest"
 	"github.com/digitalocean/godo"
 )
 
@@ -52,3 +54,52 @@ assert(key.fingerprint, "should have a 'fingerprint' field");
 assert(key.public_key, "should have a 'public_key' field");
     `)
 }
+
+func TestAsync(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	cloud.MockKeys.CreateFn = func(_ context.Context, name, publicKey string, _ ...keys.CreateOpt) (keys.Key, error) {
+		return &key{&godo.Key{ID: 1, Name: name, PublicKey: publicKey}}, nil
+	}
+	var deleted []int
+	cloud.MockKeys.DeleteByIDFn = func(_ context.Context, id int) error {
+		deleted = append(deleted, id)
+		return nil
+	}
+
+	vmtest.Run(t, cloud, `
+var pkg = cloud.keys;
+var k = pkg.create_async({name: "deploy", public_key: "ssh-ed25519 AAAA"}).wait("1s");
+equals(k.name, "deploy", "should have created the key");
+pkg.delete_async(k.id).wait("1s");
+`)
+
+	if len(deleted) != 1 || deleted[0] != 1 {
+		t.Fatalf("want key 1 deleted, got %v", deleted)
+	}
+}
+
+func TestGetByName(t *testing.T) {
+	fp := "3b:16:bf:e4:8b:00:8b:b8:59:8c:a9:d3:f0:19:45:fa"
+	cloud := mockcloud.Client(nil)
+	cloud.MockKeys.ListFn = func(_ context.Context) (<-chan keys.Key, <-chan error) {
+		kc := make(chan keys.Key, 1)
+		kc <- &key{&godo.Key{ID: 1, Name: "deploy", Fingerprint: fp}}
+		close(kc)
+		ec := make(chan error)
+		close(ec)
+		return kc, ec
+	}
+	cloud.MockKeys.GetByIDFn = func(_ context.Context, id int) (keys.Key, error) {
+		return &key{&godo.Key{ID: id, Name: "deploy", Fingerprint: fp}}, nil
+	}
+	cloud.MockKeys.GetByFingerprintFn = func(_ context.Context, got string) (keys.Key, error) {
+		return &key{&godo.Key{ID: 1, Name: "deploy", Fingerprint: got}}, nil
+	}
+
+	ctx := godojs.WithResolver(context.Background(), doCloud.NewResolver(cloud))
+	vmtest.RunContext(t, ctx, cloud, `
+var pkg = cloud.keys;
+assert(pkg.get("deploy").id == 1, "should have found the key by name");
+equals(pkg.get("`+fp+`").fingerprint, "`+fp+`", "should have found the key by fingerprint");
+`)
+}
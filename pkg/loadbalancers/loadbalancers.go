@@ -8,6 +8,7 @@ import (
 	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud/loadbalancers"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
 	"github.com/robertkrimen/otto"
 )
 
@@ -28,18 +29,29 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 		Name   string
 		Method interface{}
 	}{
-		{"create", svc.create},
 		{"get", svc.get},
-		{"update", svc.update},
 		{"list", svc.list},
+	} {
+
+		if err := root.Set(applier.Name, applier.Method); err != nil {
+			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
+		}
+	}
+
+	for _, applier := range []struct {
+		Name    string
+		Prepare func(otto.FunctionCall) eventloop.Task
+	}{
+		{"create", svc.create},
+		{"update", svc.update},
 		{"delete", svc.delete},
 		{"add_droplets", svc.addDroplets},
 		{"remove_droplets", svc.removeDroplets},
 		{"add_forwarding_rules", svc.addForwardingRules},
 		{"remove_forwarding_rules", svc.removeForwardingRules},
+		{"wait_active", svc.waitActive},
 	} {
-
-		if err := root.Set(applier.Name, applier.Method); err != nil {
+		if err := eventloop.Bind(root, applier.Name, applier.Prepare); err != nil {
 			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
 		}
 	}
@@ -52,128 +64,120 @@ type loadBalancersSvc struct {
 	svc loadbalancers.Client
 }
 
-func (svc *loadBalancersSvc) create(all otto.FunctionCall) otto.Value {
+func (svc *loadBalancersSvc) create(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 	arg := all.Argument(0)
 
-	req := godojs.ArgLoadBalancerCreateRequest(vm, arg)
+	req := godojs.ArgLoadBalancerCreateRequest(svc.ctx, vm, arg)
 
-	l, err := svc.svc.Create(svc.ctx, req.Name, req.Region, req.ForwardingRules, loadbalancers.UseGodoCreate(req))
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		l, err := svc.svc.Create(svc.ctx, req.Name, req.Region, req.ForwardingRules, loadbalancers.UseGodoCreate(req))
+		if err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.LoadBalancerKind)
+		return func(vm *otto.Otto) otto.Value {
+			return svc.loadBalancerToVM(vm, l.Struct())
+		}, nil
 	}
-
-	return godojs.LoadBalancerToVM(vm, l.Struct())
 }
 
 func (svc *loadBalancersSvc) get(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
 	arg := all.Argument(0)
 
-	lbId := godojs.ArgLoadBalancerID(vm, arg)
+	lbId := godojs.ArgLoadBalancerID(svc.ctx, vm, arg)
 	l, err := svc.svc.Get(svc.ctx, lbId)
 	if err != nil {
 		ottoutil.Throw(vm, err.Error())
 	}
 
-	return godojs.LoadBalancerToVM(vm, l.Struct())
+	return svc.loadBalancerToVM(vm, l.Struct())
 }
 
-func (svc *loadBalancersSvc) update(all otto.FunctionCall) otto.Value {
+func (svc *loadBalancersSvc) update(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 
-	lbId := godojs.ArgLoadBalancerID(vm, all.Argument(0))
-	req := godojs.ArgLoadBalancerUpdate(vm, all.Argument(1))
-	l, err := svc.svc.Update(svc.ctx, lbId, loadbalancers.UseGodoLoadBalancer(req))
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	lbId := godojs.ArgLoadBalancerID(svc.ctx, vm, all.Argument(0))
+	req := godojs.ArgLoadBalancerUpdate(svc.ctx, vm, all.Argument(1))
+	return func() (eventloop.Result, error) {
+		l, err := svc.svc.Update(svc.ctx, lbId, loadbalancers.UseGodoLoadBalancer(req))
+		if err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.LoadBalancerKind)
+		return func(vm *otto.Otto) otto.Value {
+			return svc.loadBalancerToVM(vm, l.Struct())
+		}, nil
 	}
-
-	return godojs.LoadBalancerToVM(vm, l.Struct())
 }
 
-func (svc *loadBalancersSvc) delete(all otto.FunctionCall) otto.Value {
+func (svc *loadBalancersSvc) delete(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 	arg := all.Argument(0)
 
-	lbId := godojs.ArgLoadBalancerID(vm, arg)
+	lbId := godojs.ArgLoadBalancerID(godojs.Fresh(svc.ctx), vm, arg)
 
-	err := svc.svc.Delete(svc.ctx, lbId)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		if err := svc.svc.Delete(svc.ctx, lbId); err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.LoadBalancerKind)
+		return nil, nil
 	}
-	return q
 }
 
 func (svc *loadBalancersSvc) list(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
 
-	var lbs = make([]otto.Value, 0)
-	lbc, errc := svc.svc.List(svc.ctx)
-	for l := range lbc {
-		lbs = append(lbs, godojs.LoadBalancerToVM(vm, l.Struct()))
-	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	v, err := vm.ToValue(lbs)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	return v
+	ctx, cancel := context.WithCancel(svc.ctx)
+	lbc, errc := svc.svc.List(ctx)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+		l, more := <-lbc
+		if !more {
+			return q, false, <-errc
+		}
+		return svc.loadBalancerToVM(vm, l.Struct()), true, nil
+	}, cancel)
 }
 
-func (svc *loadBalancersSvc) addDroplets(all otto.FunctionCall) otto.Value {
+func (svc *loadBalancersSvc) addDroplets(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 
-	lbId := godojs.ArgLoadBalancerID(vm, all.Argument(0))
-	dropletIds := godojs.ArgDropletIDs(vm, all.Argument(1))
+	lbId := godojs.ArgLoadBalancerID(svc.ctx, vm, all.Argument(0))
+	dropletIds := godojs.ArgDropletIDs(svc.ctx, vm, all.Argument(1))
 
-	err := svc.svc.AddDroplets(svc.ctx, lbId, dropletIds...)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.AddDroplets(svc.ctx, lbId, dropletIds...)
 	}
-
-	return q
 }
 
-func (svc *loadBalancersSvc) removeDroplets(all otto.FunctionCall) otto.Value {
+func (svc *loadBalancersSvc) removeDroplets(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	lbId := godojs.ArgLoadBalancerID(vm, all.Argument(0))
-	dropletIds := godojs.ArgDropletIDs(vm, all.Argument(1))
+	lbId := godojs.ArgLoadBalancerID(svc.ctx, vm, all.Argument(0))
+	dropletIds := godojs.ArgDropletIDs(svc.ctx, vm, all.Argument(1))
 
-	err := svc.svc.RemoveDroplets(svc.ctx, lbId, dropletIds...)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.RemoveDroplets(svc.ctx, lbId, dropletIds...)
 	}
-
-	return q
 }
 
-func (svc *loadBalancersSvc) addForwardingRules(all otto.FunctionCall) otto.Value {
+func (svc *loadBalancersSvc) addForwardingRules(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	lbId := godojs.ArgLoadBalancerID(vm, all.Argument(0))
+	lbId := godojs.ArgLoadBalancerID(svc.ctx, vm, all.Argument(0))
 	rules := godojs.ArgForwardingRules(vm, all.Argument(1))
 
-	err := svc.svc.AddForwardingRules(svc.ctx, lbId, rules...)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.AddForwardingRules(svc.ctx, lbId, rules...)
 	}
-
-	return q
 }
 
-func (svc *loadBalancersSvc) removeForwardingRules(all otto.FunctionCall) otto.Value {
+func (svc *loadBalancersSvc) removeForwardingRules(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	lbId := godojs.ArgLoadBalancerID(vm, all.Argument(0))
+	lbId := godojs.ArgLoadBalancerID(svc.ctx, vm, all.Argument(0))
 	rules := godojs.ArgForwardingRules(vm, all.Argument(1))
 
-	err := svc.svc.RemoveForwardingRules(svc.ctx, lbId, rules...)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.RemoveForwardingRules(svc.ctx, lbId, rules...)
 	}
-
-	return q
 }
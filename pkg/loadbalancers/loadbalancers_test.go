@@ -627,3 +627,82 @@ func TestLoadBalancerRemoveDroplets(t *testing.T) {
 		pkg.remove_droplets("test-uuid", [42]);
 	`)
 }
+
+func TestLoadBalancerMethods(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	cloud.MockLoadBalancers.GetFn = func(_ context.Context, id string) (loadbalancers.LoadBalancer, error) {
+		return &loadBalancer{l}, nil
+	}
+	var added []int
+	cloud.MockLoadBalancers.AddDropletsFn = func(_ context.Context, gotId string, dropletIds ...int) error {
+		if gotId != "test-uuid" {
+			t.Fatalf("want %v got %v", "test-uuid", gotId)
+		}
+		added = dropletIds
+		return nil
+	}
+
+	vmtest.Run(t, cloud, `
+		var lb = cloud.load_balancers.get("test-uuid");
+		lb.add(42, [43, 44]);
+	`)
+
+	if len(added) != 3 || added[0] != 42 || added[1] != 43 || added[2] != 44 {
+		t.Fatalf("want droplets %v, got %v", []int{42, 43, 44}, added)
+	}
+}
+
+func TestLoadBalancerWaitActive(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	polls := 0
+	cloud.MockLoadBalancers.GetFn = func(_ context.Context, id string) (loadbalancers.LoadBalancer, error) {
+		polls++
+		lb := *l
+		if polls >= 3 {
+			lb.Status = "active"
+		}
+		return &loadBalancer{&lb}, nil
+	}
+
+	vmtest.Run(t, cloud, `
+		var lb = cloud.load_balancers.wait_active("test-uuid", {interval: "1ms"});
+		equals(lb.status, "active", "should be active");
+
+		try {
+			cloud.load_balancers.wait_active("test-uuid", {timeout: "10ms", interval: "20ms"});
+		} catch (e) {
+			throw "should not time out once active";
+		}
+	`)
+	if polls != 4 {
+		t.Fatalf("want %d polls, got %d", 4, polls)
+	}
+}
+
+func TestLoadBalancerAsync(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	cloud.MockLoadBalancers.CreateFn = func(_ context.Context, name, region string, forwardingRules []godo.ForwardingRule, opts ...loadbalancers.CreateOpt) (loadbalancers.LoadBalancer, error) {
+		return &loadBalancer{l}, nil
+	}
+	var added []int
+	cloud.MockLoadBalancers.AddDropletsFn = func(_ context.Context, _ string, dropletIds ...int) error {
+		added = append(added, dropletIds...)
+		return nil
+	}
+
+	vmtest.Run(t, cloud, `
+		var pkg = cloud.load_balancers;
+		var lb = pkg.create_async({
+			"name": "example-lb-01",
+			"region": "nyc3",
+			"forwarding_rules": [{"entry_protocol": "http", "entry_port": 80, "target_protocol": "http", "target_port": 80}]
+		}).wait("1s");
+		equals(lb.id, "test-uuid", "should have created the load balancer");
+		lb.add_async(42).wait("1s");
+		pkg.add_droplets_async(lb, [43]).wait("1s");
+	`)
+
+	if len(added) != 2 || added[0] != 42 || added[1] != 43 {
+		t.Fatalf("want droplets %v, got %v", []int{42, 43}, added)
+	}
+}
@@ -0,0 +1,81 @@
+package loadbalancers
+
+import (
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/loadbalancers"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/digitalocean/godo"
+	"github.com/robertkrimen/otto"
+)
+
+// loadBalancerToVM converts a load balancer and binds methods to it, so that
+// scripts can do `lb.add(d)`.
+func (svc *loadBalancersSvc) loadBalancerToVM(vm *otto.Otto, g *godo.LoadBalancer) otto.Value {
+	v := godojs.LoadBalancerToVM(vm, g)
+	if g == nil {
+		return v
+	}
+	id := g.ID
+
+	ottoutil.SetMethods(vm, v, map[string]func(otto.FunctionCall) otto.Value{
+		"refresh": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			l, err := svc.svc.Get(svc.ctx, id)
+			if err != nil {
+				ottoutil.Throw(vm, err.Error())
+			}
+			return ottoutil.Assign(vm, all.This, godojs.LoadBalancerToVM(vm, l.Struct()))
+		},
+	})
+	return eventloop.Methods(vm, v, map[string]func(otto.FunctionCall) eventloop.Task{
+		"delete": func(all otto.FunctionCall) eventloop.Task {
+			return func() (eventloop.Result, error) {
+				if err := svc.svc.Delete(svc.ctx, id); err != nil {
+					return nil, err
+				}
+				godojs.Invalidate(svc.ctx, cloud.LoadBalancerKind)
+				return nil, nil
+			}
+		},
+		"update": func(all otto.FunctionCall) eventloop.Task {
+			this := all.This
+			req := godojs.ArgLoadBalancerUpdate(svc.ctx, all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				l, err := svc.svc.Update(svc.ctx, id, loadbalancers.UseGodoLoadBalancer(req))
+				if err != nil {
+					return nil, err
+				}
+				godojs.Invalidate(svc.ctx, cloud.LoadBalancerKind)
+				return func(vm *otto.Otto) otto.Value {
+					return ottoutil.Assign(vm, this, godojs.LoadBalancerToVM(vm, l.Struct()))
+				}, nil
+			}
+		},
+		"add": func(all otto.FunctionCall) eventloop.Task {
+			dropletIDs := godojs.ArgDropletIDList(svc.ctx, all.Otto, all.ArgumentList...)
+			return func() (eventloop.Result, error) {
+				return nil, svc.svc.AddDroplets(svc.ctx, id, dropletIDs...)
+			}
+		},
+		"remove": func(all otto.FunctionCall) eventloop.Task {
+			dropletIDs := godojs.ArgDropletIDList(svc.ctx, all.Otto, all.ArgumentList...)
+			return func() (eventloop.Result, error) {
+				return nil, svc.svc.RemoveDroplets(svc.ctx, id, dropletIDs...)
+			}
+		},
+		"add_forwarding_rules": func(all otto.FunctionCall) eventloop.Task {
+			rules := godojs.ArgForwardingRules(all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				return nil, svc.svc.AddForwardingRules(svc.ctx, id, rules...)
+			}
+		},
+		"remove_forwarding_rules": func(all otto.FunctionCall) eventloop.Task {
+			rules := godojs.ArgForwardingRules(all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				return nil, svc.svc.RemoveForwardingRules(svc.ctx, id, rules...)
+			}
+		},
+	})
+}
@@ -0,0 +1,42 @@
+package loadbalancers
+
+import (
+	"fmt"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/loadbalancers"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/godoutil"
+	"github.com/robertkrimen/otto"
+)
+
+// waitActive waits for a load balancer to be provisioned, as in
+// `cloud.load_balancers.wait_active(lb, {timeout: "10m"})`.
+func (svc *loadBalancersSvc) waitActive(all otto.FunctionCall) eventloop.Task {
+	vm := all.Otto
+	id := godojs.ArgLoadBalancerID(svc.ctx, vm, all.Argument(0))
+	opts := godojs.ArgWaitOptions(vm, all.Argument(1))
+
+	return func() (eventloop.Result, error) {
+		var l loadbalancers.LoadBalancer
+		err := godoutil.WaitFor(svc.ctx, func() (bool, error) {
+			var err error
+			if l, err = svc.svc.Get(svc.ctx, id); err != nil {
+				return false, err
+			}
+			switch status := l.Struct().Status; status {
+			case "active":
+				return true, nil
+			case "errored":
+				return false, fmt.Errorf("load balancer %q is %s", id, status)
+			}
+			return false, nil
+		}, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("waiting for load balancer %q to be active: %v", id, err)
+		}
+		return func(vm *otto.Otto) otto.Value {
+			return svc.loadBalancerToVM(vm, l.Struct())
+		}, nil
+	}
+}
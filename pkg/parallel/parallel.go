@@ -0,0 +1,179 @@
+package parallel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/robertkrimen/otto"
+)
+
+var q = otto.Value{}
+
+const defaultConcurrency = 4
+
+// Apply creates `cloud.parallel(items, fn, {concurrency: 8})`, which calls
+// fn(item, i) on every item concurrently and returns, in order, the result
+// of each call as `{value: ...}` or `{error: "..."}`.
+//
+// otto is single-threaded, so every worker runs fn in its own copy of the VM.
+// fn sees the variables of the script as they were when `parallel` was
+// called, changes it makes to them are not seen by the script, and the
+// values it returns are copied back as plain data.
+func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value, error) {
+	svc := parallelSvc{ctx: ctx}
+	return vm.ToValue(svc.parallel)
+}
+
+type parallelSvc struct {
+	ctx context.Context
+}
+
+type result struct {
+	value string // as JSON
+	err   string
+	ok    bool
+}
+
+// a worker calls the user's fn with the item, and returns the result as JSON
+// such that it can be moved from one VM to another
+const trampoline = `(function(stash, i) {
+	try {
+		var v = stash.fn(stash.items[i], i);
+		return {ok: true, value: v === undefined ? undefined : JSON.stringify(v)};
+	} catch (e) {
+		return {ok: false, error: (e && e.message !== undefined) ? e.message : String(e)};
+	}
+})`
+
+var stashID uint64
+
+func (svc *parallelSvc) parallel(all otto.FunctionCall) otto.Value {
+	vm := all.Otto
+	items := all.Argument(0)
+	if items.Class() != "Array" {
+		ottoutil.Throw(vm, "argument must be an array, got a %q", items.Class())
+	}
+	fn := all.Argument(1)
+	if !fn.IsFunction() {
+		ottoutil.Throw(vm, "argument must be a function, got a %q", fn.Class())
+	}
+	concurrency := defaultConcurrency
+	if opts := all.Argument(2); opts.IsObject() {
+		if v := ottoutil.GetObject(vm, opts, "concurrency", false); v.IsDefined() {
+			concurrency = ottoutil.Int(vm, v)
+		}
+	}
+	if concurrency < 1 {
+		ottoutil.Throw(vm, "concurrency must be at least 1, got %d", concurrency)
+	}
+	n := ottoutil.Int(vm, ottoutil.GetObject(vm, items, "length", true))
+	if n == 0 {
+		v, err := vm.ToValue([]otto.Value{})
+		if err != nil {
+			ottoutil.Throw(vm, err.Error())
+		}
+		return v
+	}
+	if concurrency > n {
+		concurrency = n
+	}
+
+	// the copies of the VM find the function and items through this global
+	stash := fmt.Sprintf("__parallel_%d", atomic.AddUint64(&stashID, 1))
+	if err := vm.Set(stash, ottoutil.ToPkg(vm, map[string]interface{}{
+		"fn":    fn,
+		"items": items,
+	})); err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	defer vm.Set(stash, otto.UndefinedValue())
+
+	workers := make([]*otto.Otto, concurrency)
+	for w := range workers {
+		workers[w] = vm.Copy()
+	}
+
+	results := make([]result, n)
+	todo := make(chan int)
+	var wg sync.WaitGroup
+	for _, worker := range workers {
+		wg.Add(1)
+		go func(worker *otto.Otto) {
+			defer wg.Done()
+			// each worker runs the completions of the handles it makes
+			loop := eventloop.New()
+			eventloop.Use(worker, loop)
+			defer eventloop.Use(worker, nil)
+			for i := range todo {
+				results[i] = svc.call(worker, loop, stash, i)
+			}
+		}(worker)
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case todo <- i:
+		case <-svc.ctx.Done():
+			for ; i < n; i++ {
+				results[i] = result{err: svc.ctx.Err().Error()}
+			}
+			break feed
+		}
+	}
+	close(todo)
+	wg.Wait()
+
+	out := make([]otto.Value, 0, n)
+	for _, res := range results {
+		fields := map[string]interface{}{}
+		if !res.ok {
+			fields["error"] = res.err
+		} else if res.value != "" {
+			v, err := vm.Call("JSON.parse", nil, res.value)
+			if err != nil {
+				ottoutil.Throw(vm, err.Error())
+			}
+			fields["value"] = v
+		}
+		out = append(out, ottoutil.ToPkg(vm, fields))
+	}
+	v, err := vm.ToValue(out)
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	return v
+}
+
+// call fn on the item i in worker, which is done once the handles it made
+// are complete.
+func (svc *parallelSvc) call(worker *otto.Otto, loop *eventloop.Loop, stash string, i int) result {
+	stashv, err := worker.Get(stash)
+	if err != nil {
+		return result{err: err.Error()}
+	}
+	ret, err := worker.Call(trampoline, nil, stashv, i)
+	if err != nil {
+		return result{err: err.Error()}
+	}
+	if err := loop.Wait(svc.ctx); err != nil {
+		return result{err: err.Error()}
+	}
+	obj := ret.Object()
+	okv, _ := obj.Get("ok")
+	if ok, _ := okv.ToBoolean(); !ok {
+		errv, _ := obj.Get("error")
+		msg, _ := errv.ToString()
+		return result{err: msg}
+	}
+	res := result{ok: true}
+	if v, _ := obj.Get("value"); v.IsDefined() {
+		res.value, _ = v.ToString()
+	}
+	return res
+}
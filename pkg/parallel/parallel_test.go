@@ -0,0 +1,163 @@
+package parallel_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	doCloud "github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/droplets"
+	"github.com/aybabtme/godotto/pkg/extra/do/mockcloud"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/vmtest"
+	"github.com/digitalocean/godo"
+	"github.com/robertkrimen/otto"
+)
+
+type droplet struct {
+	*godo.Droplet
+}
+
+func (k *droplet) Struct() *godo.Droplet { return k.Droplet }
+
+func TestApply(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	vmtest.Run(t, cloud, `
+assert(cloud.parallel != null, "parallel function should be defined");
+    `)
+}
+
+func TestParallel(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+
+	var (
+		mu       sync.Mutex
+		inflight int
+		maxSeen  int
+	)
+	cloud.MockDroplets.GetFn = func(_ context.Context, id int) (droplets.Droplet, error) {
+		mu.Lock()
+		inflight++
+		if inflight > maxSeen {
+			maxSeen = inflight
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inflight--
+		mu.Unlock()
+		return &droplet{&godo.Droplet{ID: id, Name: "droplet"}}, nil
+	}
+
+	vmtest.Run(t, cloud, `
+var prefix = "d-";
+var results = cloud.parallel([1, 2, 3, 4, 5, 6, 7, 8], function(id, i) {
+	if (id == 5) {
+		throw new Error("no droplet 5");
+	}
+	var d = cloud.droplets.get(id);
+	return {name: prefix + d.id, i: i};
+}, {concurrency: 3});
+
+assert(results.length == 8, "should have a result per item");
+results.forEach(function(res, i) {
+	if (i == 4) {
+		equals(res.error, "no droplet 5", "should have the error of the call");
+		return
+	}
+	equals(res.value.name, "d-" + (i + 1), "should be in order");
+	assert(res.value.i == i, "should be called with the index");
+});
+
+assert(cloud.parallel([], function() {}).length == 0, "should accept no items");
+    `)
+
+	if maxSeen > 3 {
+		t.Fatalf("want at most %d calls at once, got %d", 3, maxSeen)
+	}
+	if maxSeen < 2 {
+		t.Fatalf("want calls made concurrently, got at most %d at once", maxSeen)
+	}
+}
+
+func TestParallelThrows(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	vmtest.Run(t, cloud, `
+[
+	function() { cloud.parallel("nope", function() {}) },
+	function() { cloud.parallel([1], "nope") },
+	function() { cloud.parallel([1], function() {}, {concurrency: 0}) },
+].forEach(function(fn) {
+	try {
+		fn();
+		throw "dont catch me";
+	} catch (e) {
+		assert(e != "dont catch me", "should have thrown");
+	}
+});
+    `)
+}
+
+func TestParallelAsync(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	cloud.MockDroplets.MockDropletActions.RebootFn = func(_ context.Context, id int) error {
+		if id == 2 {
+			return errors.New("can't reboot")
+		}
+		return nil
+	}
+
+	var (
+		mu       sync.Mutex
+		rebooted []int
+	)
+	vmtest.Run(t, cloud, `
+var results = cloud.parallel([1, 2, 3], function(id) {
+	cloud.droplets.actions.reboot_async(id).then(function() { rebooted(id) });
+});
+equals(results[1].error, "can't reboot", "should fail on errors no callback handled");
+assert(!results[0].error && !results[2].error, "unexpected errors: " + JSON.stringify(results));
+	`, func(vm *otto.Otto) error {
+		return vm.Set("rebooted", func(all otto.FunctionCall) otto.Value {
+			id, _ := all.Argument(0).ToInteger()
+			mu.Lock()
+			rebooted = append(rebooted, int(id))
+			mu.Unlock()
+			return otto.UndefinedValue()
+		})
+	})
+	sort.Ints(rebooted)
+	if want := []int{1, 3}; !reflect.DeepEqual(want, rebooted) {
+		t.Fatalf("want callbacks called for %v, got %v", want, rebooted)
+	}
+}
+
+func TestParallelResolvesNames(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	cloud.MockDroplets.ListFn = func(_ context.Context, _ ...droplets.ListOpt) (<-chan droplets.Droplet, <-chan error) {
+		lc := make(chan droplets.Droplet, 2)
+		lc <- &droplet{&godo.Droplet{ID: 1, Name: "web-1"}}
+		lc <- &droplet{&godo.Droplet{ID: 2, Name: "web-2"}}
+		close(lc)
+		ec := make(chan error)
+		close(ec)
+		return lc, ec
+	}
+	cloud.MockDroplets.GetFn = func(_ context.Context, id int) (droplets.Droplet, error) {
+		return &droplet{&godo.Droplet{ID: id, Name: "web-" + strconv.Itoa(id)}}, nil
+	}
+
+	ctx := godojs.WithResolver(context.Background(), doCloud.NewResolver(cloud))
+	vmtest.RunContext(t, ctx, cloud, `
+var results = cloud.parallel(["web-1", "web-2"], function(name) {
+	return cloud.droplets.get(name).id;
+});
+assert(results[0].value == 1, "should have resolved the name in the worker");
+assert(results[1].value == 2, "should have resolved the name in the worker");
+    `)
+}
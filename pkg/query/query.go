@@ -0,0 +1,41 @@
+package query
+
+import (
+	"context"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/do/query"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/robertkrimen/otto"
+)
+
+// Apply creates `cloud.query("droplets where 'web' in tags order by name")`,
+// which lists the resources of a kind that match a query. See package
+// github.com/aybabtme/godotto/pkg/extra/do/query for the syntax of queries.
+func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value, error) {
+	svc := querySvc{ctx: ctx, client: client}
+	return vm.ToValue(svc.query)
+}
+
+type querySvc struct {
+	ctx    context.Context
+	client cloud.Client
+}
+
+func (svc *querySvc) query(all otto.FunctionCall) otto.Value {
+	vm := all.Otto
+	arg := all.Argument(0)
+	if !arg.IsString() {
+		ottoutil.Throw(vm, "argument must be a query, got a %q", arg.Class())
+	}
+	q, err := query.Parse(ottoutil.String(vm, arg))
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	results, err := q.Run(svc.ctx, svc.client)
+	if err != nil {
+		ottoutil.Throw(vm, err.Error())
+	}
+	return godojs.JSONToVM(vm, results)
+}
@@ -0,0 +1,45 @@
+package query_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/droplets"
+	"github.com/aybabtme/godotto/pkg/extra/do/mockcloud"
+	"github.com/aybabtme/godotto/pkg/extra/vmtest"
+	"github.com/digitalocean/godo"
+)
+
+type droplet struct{ *godo.Droplet }
+
+func (k *droplet) Struct() *godo.Droplet { return k.Droplet }
+
+func TestQuery(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	cloud.MockDroplets.ListFn = func(context.Context, ...droplets.ListOpt) (<-chan droplets.Droplet, <-chan error) {
+		c := make(chan droplets.Droplet, 3)
+		c <- &droplet{&godo.Droplet{ID: 1, Name: "web-1", Memory: 2048, Region: &godo.Region{Slug: "nyc3"}, Tags: []string{"web"}}}
+		c <- &droplet{&godo.Droplet{ID: 2, Name: "web-2", Memory: 512, Region: &godo.Region{Slug: "nyc3"}, Tags: []string{"web"}}}
+		c <- &droplet{&godo.Droplet{ID: 3, Name: "db-1", Memory: 4096, Region: &godo.Region{Slug: "nyc3"}}}
+		close(c)
+		errc := make(chan error)
+		close(errc)
+		return c, errc
+	}
+
+	vmtest.Run(t, cloud, `
+var found = cloud.query("droplets where region = 'nyc3' and 'web' in tags and memory >= 2048");
+assert(found.length == 1, "should filter the droplets");
+equals(found[0].name, "web-1");
+
+var rows = cloud.query("select name, region.slug from droplets order by memory desc limit 2");
+equals(rows, [{"name": "db-1", "region.slug": "nyc3"}, {"name": "web-1", "region.slug": "nyc3"}]);
+
+try {
+	cloud.query("droplets where nope = 1");
+	throw "should have failed";
+} catch (e) {
+	equals(e.message, 'parsing query: droplets have no field "nope"');
+}
+`)
+}
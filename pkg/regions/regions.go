@@ -46,18 +46,13 @@ type regionSvc struct {
 func (svc *regionSvc) list(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
 
-	var regions = make([]otto.Value, 0)
-	regionc, errc := svc.svc.List(svc.ctx)
-	for d := range regionc {
-		regions = append(regions, godojs.RegionToVM(vm, d.Struct()))
-	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	v, err := vm.ToValue(regions)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-	return v
+	ctx, cancel := context.WithCancel(svc.ctx)
+	regionc, errc := svc.svc.List(ctx)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+		d, more := <-regionc
+		if !more {
+			return q, false, <-errc
+		}
+		return godojs.RegionToVM(vm, d.Struct()), true, nil
+	}, cancel)
 }
@@ -47,18 +47,13 @@ func (svc *sizeSvc) list(all otto.FunctionCall) otto.Value {
 
 	vm := all.Otto
 
-	var sizes = make([]otto.Value, 0)
-	sizec, errc := svc.svc.List(svc.ctx)
-	for d := range sizec {
-		sizes = append(sizes, godojs.SizeToVM(vm, d.Struct()))
-	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	v, err := vm.ToValue(sizes)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-	return v
+	ctx, cancel := context.WithCancel(svc.ctx)
+	sizec, errc := svc.svc.List(ctx)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+		d, more := <-sizec
+		if !more {
+			return q, false, <-errc
+		}
+		return godojs.SizeToVM(vm, d.Struct()), true, nil
+	}, cancel)
 }
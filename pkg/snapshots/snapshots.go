@@ -4,10 +4,11 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/aybabtme/godotto/pkg/extra/godojs"
-	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud/snapshots"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/robertkrimen/otto"
 )
 
@@ -32,7 +33,6 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 		{"list", svc.list},
 		{"list_droplet", svc.listDroplet},
 		{"list_volume", svc.listVolume},
-		{"delete", svc.delete},
 	} {
 
 		if err := root.Set(applier.Name, applier.Method); err != nil {
@@ -40,6 +40,10 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 		}
 	}
 
+	if err := eventloop.Bind(root, "delete", svc.delete); err != nil {
+		return q, fmt.Errorf("preparing method %q, %v", "delete", err)
+	}
+
 	return root.Value(), nil
 }
 
@@ -52,7 +56,7 @@ func (svc *snapshotsSvc) get(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
 	arg := all.Argument(0)
 
-	sId := godojs.ArgSnapshotID(vm, arg)
+	sId := godojs.ArgSnapshotID(svc.ctx, vm, arg)
 	s, err := svc.svc.Get(svc.ctx, sId)
 	if err != nil {
 		ottoutil.Throw(vm, err.Error())
@@ -61,75 +65,60 @@ func (svc *snapshotsSvc) get(all otto.FunctionCall) otto.Value {
 	return godojs.SnapshotToVM(vm, s.Struct())
 }
 
-func (svc *snapshotsSvc) delete(all otto.FunctionCall) otto.Value {
+func (svc *snapshotsSvc) delete(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 	arg := all.Argument(0)
 
-	sId := godojs.ArgSnapshotID(vm, arg)
+	sId := godojs.ArgSnapshotID(godojs.Fresh(svc.ctx), vm, arg)
 
-	err := svc.svc.Delete(svc.ctx, sId)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		if err := svc.svc.Delete(svc.ctx, sId); err != nil {
+			return nil, err
+		}
+		// the snapshots of droplets are images too
+		godojs.Invalidate(svc.ctx, cloud.SnapshotKind, cloud.ImageKind)
+		return nil, nil
 	}
-	return q
 }
 
 func (svc *snapshotsSvc) list(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
 
-	var ss = make([]otto.Value, 0)
-	sc, errc := svc.svc.List(svc.ctx)
-	for s := range sc {
-		ss = append(ss, godojs.SnapshotToVM(vm, s.Struct()))
-	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	v, err := vm.ToValue(ss)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	return v
+	ctx, cancel := context.WithCancel(svc.ctx)
+	sc, errc := svc.svc.List(ctx)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+		s, more := <-sc
+		if !more {
+			return q, false, <-errc
+		}
+		return godojs.SnapshotToVM(vm, s.Struct()), true, nil
+	}, cancel)
 }
 
 func (svc *snapshotsSvc) listDroplet(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
 
-	var ss = make([]otto.Value, 0)
-	sc, errc := svc.svc.ListDroplet(svc.ctx)
-	for s := range sc {
-		ss = append(ss, godojs.SnapshotToVM(vm, s.Struct()))
-	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	v, err := vm.ToValue(ss)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	return v
+	ctx, cancel := context.WithCancel(svc.ctx)
+	sc, errc := svc.svc.ListDroplet(ctx)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+		s, more := <-sc
+		if !more {
+			return q, false, <-errc
+		}
+		return godojs.SnapshotToVM(vm, s.Struct()), true, nil
+	}, cancel)
 }
 
 func (svc *snapshotsSvc) listVolume(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
 
-	var ss = make([]otto.Value, 0)
-	sc, errc := svc.svc.ListVolume(svc.ctx)
-	for s := range sc {
-		ss = append(ss, godojs.SnapshotToVM(vm, s.Struct()))
-	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	v, err := vm.ToValue(ss)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	return v
+	ctx, cancel := context.WithCancel(svc.ctx)
+	sc, errc := svc.svc.ListVolume(ctx)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+		s, more := <-sc
+		if !more {
+			return q, false, <-errc
+		}
+		return godojs.SnapshotToVM(vm, s.Struct()), true, nil
+	}, cancel)
 }
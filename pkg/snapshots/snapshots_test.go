@@ -268,3 +268,21 @@ func TestSnapshotGet(t *testing.T) {
 		equals(l, want, "should have proper object");
 	`)
 }
+
+func TestSnapshotDeleteAsync(t *testing.T) {
+	var deleted []string
+	cloud := mockcloud.Client(nil)
+	cloud.MockSnapshots.DeleteFn = func(_ context.Context, id string) error {
+		deleted = append(deleted, id)
+		return nil
+	}
+
+	vmtest.Run(t, cloud, `
+			var pkg = cloud.snapshots;
+			pkg.delete_async("11223344").wait("1s");
+	`)
+
+	if len(deleted) != 1 || deleted[0] != "11223344" {
+		t.Fatalf("want snapshot %q deleted, got %v", "11223344", deleted)
+	}
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/aybabtme/godotto/pkg/extra/godojs"
-	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud/tags"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/robertkrimen/otto"
 )
 
@@ -28,14 +29,24 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 		Name   string
 		Method interface{}
 	}{
-		{"create", svc.create},
 		{"get", svc.get},
 		{"list", svc.list},
+	} {
+		if err := root.Set(applier.Name, applier.Method); err != nil {
+			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
+		}
+	}
+
+	for _, applier := range []struct {
+		Name    string
+		Prepare func(otto.FunctionCall) eventloop.Task
+	}{
+		{"create", svc.create},
 		{"delete", svc.delete},
 		{"tag_resources", svc.tagResources},
 		{"untag_resources", svc.untagResources},
 	} {
-		if err := root.Set(applier.Name, applier.Method); err != nil {
+		if err := eventloop.Bind(root, applier.Name, applier.Prepare); err != nil {
 			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
 		}
 	}
@@ -48,18 +59,21 @@ type tagSvc struct {
 	svc tags.Client
 }
 
-func (svc *tagSvc) create(all otto.FunctionCall) otto.Value {
+func (svc *tagSvc) create(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 	arg := all.Argument(0)
 
 	req := godojs.ArgTagCreateRequest(vm, arg)
 
-	t, err := svc.svc.Create(svc.ctx, req.Name)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		t, err := svc.svc.Create(svc.ctx, req.Name)
+		if err != nil {
+			return nil, err
+		}
+		return func(vm *otto.Otto) otto.Value {
+			return godojs.TagToVM(vm, t.Struct())
+		}, nil
 	}
-
-	return godojs.TagToVM(vm, t.Struct())
 }
 
 func (svc *tagSvc) get(all otto.FunctionCall) otto.Value {
@@ -79,37 +93,28 @@ func (svc *tagSvc) get(all otto.FunctionCall) otto.Value {
 func (svc *tagSvc) list(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
 
-	var tags = make([]otto.Value, 0)
-	tagc, errc := svc.svc.List(svc.ctx)
-	for t := range tagc {
-		tags = append(tags, godojs.TagToVM(vm, t.Struct()))
-	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	v, err := vm.ToValue(tags)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	return v
+	ctx, cancel := context.WithCancel(svc.ctx)
+	tagc, errc := svc.svc.List(ctx)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+		t, more := <-tagc
+		if !more {
+			return q, false, <-errc
+		}
+		return godojs.TagToVM(vm, t.Struct()), true, nil
+	}, cancel)
 }
 
-func (svc *tagSvc) delete(all otto.FunctionCall) otto.Value {
+func (svc *tagSvc) delete(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 	arg := all.Argument(0)
 
 	tag := ottoutil.String(vm, arg)
-	err := svc.svc.Delete(svc.ctx, tag)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.Delete(svc.ctx, tag)
 	}
-
-	return q
 }
 
-func (svc *tagSvc) tagResources(all otto.FunctionCall) otto.Value {
+func (svc *tagSvc) tagResources(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 	arg := all.Argument(0)
 
@@ -119,15 +124,12 @@ func (svc *tagSvc) tagResources(all otto.FunctionCall) otto.Value {
 		ottoutil.Throw(vm, err.Error())
 	}
 
-	err = svc.svc.TagResources(svc.ctx, name, req.Resources)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.TagResources(svc.ctx, name, req.Resources)
 	}
-
-	return q
 }
 
-func (svc *tagSvc) untagResources(all otto.FunctionCall) otto.Value {
+func (svc *tagSvc) untagResources(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 	arg := all.Argument(0)
 
@@ -137,10 +139,7 @@ func (svc *tagSvc) untagResources(all otto.FunctionCall) otto.Value {
 		ottoutil.Throw(vm, err.Error())
 	}
 
-	err = svc.svc.UntagResources(svc.ctx, name, req.Resources)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.UntagResources(svc.ctx, name, req.Resources)
 	}
-
-	return q
 }
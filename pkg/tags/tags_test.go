@@ -257,3 +257,26 @@ func TestTagUntagResources(t *testing.T) {
 		});
 	`)
 }
+
+func TestTagAsync(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	cloud.MockTags.CreateFn = func(_ context.Context, name string, _ ...tags.CreateOpt) (tags.Tag, error) {
+		return &tag{&godo.Tag{Name: name}}, nil
+	}
+	var tagged []godo.Resource
+	cloud.MockTags.TagFn = func(_ context.Context, name string, res []godo.Resource) error {
+		tagged = append(tagged, res...)
+		return nil
+	}
+
+	vmtest.Run(t, cloud, `
+var pkg = cloud.tags;
+var tag = pkg.create_async({name: "web"}).wait("1s");
+equals(tag.name, "web", "should have created the tag");
+pkg.tag_resources_async({name: "web", resources: [{id: "42", type: "droplet"}]}).wait("1s");
+`)
+
+	if len(tagged) != 1 || tagged[0].ID != "42" {
+		t.Fatalf("want droplet 42 tagged, got %v", tagged)
+	}
+}
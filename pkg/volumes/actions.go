@@ -4,8 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
 	"github.com/aybabtme/godotto/pkg/extra/godojs"
-	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud/volumes"
 	"github.com/robertkrimen/otto"
@@ -22,13 +22,13 @@ func applyAction(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.
 		svc: client.Volumes().Actions(),
 	}
 	for _, applier := range []struct {
-		Name   string
-		Method func(otto.FunctionCall) otto.Value
+		Name    string
+		Prepare func(otto.FunctionCall) eventloop.Task
 	}{
 		{"attach", svc.attach},
 		{"detach_by_droplet_id", svc.detachByDropletID},
 	} {
-		if err := root.Set(applier.Name, applier.Method); err != nil {
+		if err := eventloop.Bind(root, applier.Name, applier.Prepare); err != nil {
 			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
 		}
 	}
@@ -41,24 +41,20 @@ type actionSvc struct {
 	svc volumes.ActionClient
 }
 
-func (svc *actionSvc) attach(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) attach(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	ip := godojs.ArgVolumeID(vm, all.Argument(0))
-	dropletID := godojs.ArgDropletID(vm, all.Argument(1))
-	err := svc.svc.Attach(svc.ctx, ip, dropletID)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	ip := godojs.ArgVolumeID(svc.ctx, vm, all.Argument(0))
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(1))
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.Attach(svc.ctx, ip, dropletID)
 	}
-	return q
 }
 
-func (svc *actionSvc) detachByDropletID(all otto.FunctionCall) otto.Value {
+func (svc *actionSvc) detachByDropletID(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	ip := godojs.ArgVolumeID(vm, all.Argument(0))
-	dropletID := godojs.ArgDropletID(vm, all.Argument(1))
-	err := svc.svc.DetachByDropletID(svc.ctx, ip, dropletID)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	ip := godojs.ArgVolumeID(svc.ctx, vm, all.Argument(0))
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(1))
+	return func() (eventloop.Result, error) {
+		return nil, svc.svc.DetachByDropletID(svc.ctx, ip, dropletID)
 	}
-	return q
 }
@@ -0,0 +1,82 @@
+package volumes
+
+import (
+	"context"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/volumes"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/digitalocean/godo"
+	"github.com/robertkrimen/otto"
+)
+
+// volumeToVM converts a volume and binds methods to it, so that scripts
+// can do `vol.snapshot("name")` or `vol.attach(d)`.
+func (svc *volumeSvc) volumeToVM(vm *otto.Otto, g *godo.Volume) otto.Value {
+	v := godojs.VolumeToVM(vm, g)
+	if g == nil {
+		return v
+	}
+	id := g.ID
+
+	ottoutil.SetMethods(vm, v, map[string]func(otto.FunctionCall) otto.Value{
+		"refresh": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			d, err := svc.svc.GetVolume(svc.ctx, id)
+			if err != nil {
+				ottoutil.Throw(vm, err.Error())
+			}
+			return ottoutil.Assign(vm, all.This, godojs.VolumeToVM(vm, d.Struct()))
+		},
+		"snapshots": func(all otto.FunctionCall) otto.Value {
+			vm := all.Otto
+			ctx, cancel := context.WithCancel(svc.ctx)
+			snapshotc, errc := svc.svc.ListSnapshots(ctx, id)
+			return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+				s, more := <-snapshotc
+				if !more {
+					return q, false, <-errc
+				}
+				return godojs.VolumeSnapshotToVM(vm, s.Struct()), true, nil
+			}, cancel)
+		},
+	})
+	return eventloop.Methods(vm, v, map[string]func(otto.FunctionCall) eventloop.Task{
+		"delete": func(all otto.FunctionCall) eventloop.Task {
+			return func() (eventloop.Result, error) {
+				if err := svc.svc.DeleteVolume(svc.ctx, id); err != nil {
+					return nil, err
+				}
+				godojs.Invalidate(svc.ctx, cloud.VolumeKind)
+				return nil, nil
+			}
+		},
+		"attach": func(all otto.FunctionCall) eventloop.Task {
+			dropletID := godojs.ArgDropletID(svc.ctx, all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				return nil, svc.svc.Actions().Attach(svc.ctx, id, dropletID)
+			}
+		},
+		"detach": func(all otto.FunctionCall) eventloop.Task {
+			dropletID := godojs.ArgDropletID(svc.ctx, all.Otto, all.Argument(0))
+			return func() (eventloop.Result, error) {
+				return nil, svc.svc.Actions().DetachByDropletID(svc.ctx, id, dropletID)
+			}
+		},
+		"snapshot": func(all otto.FunctionCall) eventloop.Task {
+			vm := all.Otto
+			name := ottoutil.String(vm, all.Argument(0))
+			desc := ottoutil.String(vm, all.Argument(1))
+			return func() (eventloop.Result, error) {
+				s, err := svc.svc.CreateSnapshot(svc.ctx, id, name, volumes.SetSnapshotDescription(desc))
+				if err != nil {
+					return nil, err
+				}
+				godojs.Invalidate(svc.ctx, cloud.SnapshotKind)
+				return snapshotResult(s), nil
+			}
+		},
+	})
+}
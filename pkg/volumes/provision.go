@@ -0,0 +1,253 @@
+package volumes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aybabtme/godotto/pkg/extra/cloudinit"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/aybabtme/godotto/pkg/extra/shellutil"
+	"github.com/digitalocean/godo"
+	"github.com/robertkrimen/otto"
+)
+
+// RunFunc runs a shell script as root on a droplet, and returns what it
+// printed.
+type RunFunc func(ctx context.Context, d *godo.Droplet, script string) (string, error)
+
+type runKey struct{}
+
+// WithRun returns a copy of ctx with which `provision` and `unprovision` run
+// their scripts on droplets with fn, like the ssh package does, when ctx is
+// given to Apply.
+func WithRun(ctx context.Context, fn RunFunc) context.Context {
+	return context.WithValue(ctx, runKey{}, fn)
+}
+
+func runScript(ctx context.Context, d *godo.Droplet, script string) (string, error) {
+	fn, ok := ctx.Value(runKey{}).(RunFunc)
+	if !ok || fn == nil {
+		return "", fmt.Errorf("nothing knows how to run scripts on droplets, like the ssh package")
+	}
+	return fn(ctx, d, script)
+}
+
+// how long attached volumes take to show up on droplets, in seconds
+const deviceTimeout = 60
+
+// provisionOpts tell how to set up a volume on a droplet.
+type provisionOpts struct {
+	FS      string // made when the volume has none
+	Mount   string
+	Options string
+	Persist bool // in /etc/fstab
+}
+
+// provisioned tells how a volume was set up.
+type provisioned struct {
+	Device    string `json:"device"`
+	FS        string `json:"fs"`
+	Mount     string `json:"mount"`
+	Formatted bool   `json:"formatted"`
+	Persisted bool   `json:"persisted"`
+}
+
+func argProvisionOpts(vm *otto.Otto, v otto.Value) *provisionOpts {
+	opts := &provisionOpts{FS: "ext4", Options: cloudinit.DefaultMountOptions}
+	if !v.IsDefined() {
+		return opts
+	}
+	if v.Object() == nil {
+		ottoutil.Throw(vm, "optional arguments must be an Object")
+	}
+	if fs := ottoutil.GetObject(vm, v, "fs", false); fs.IsDefined() {
+		opts.FS = ottoutil.String(vm, fs)
+	}
+	opts.Mount = ottoutil.String(vm, ottoutil.GetObject(vm, v, "mount", false))
+	if options := ottoutil.GetObject(vm, v, "options", false); options.IsDefined() {
+		opts.Options = ottoutil.String(vm, options)
+	}
+	opts.Persist = ottoutil.Bool(vm, ottoutil.GetObject(vm, v, "persist", false))
+
+	if opts.FS != "ext4" && opts.FS != "xfs" {
+		ottoutil.Throw(vm, "can't format volumes as %q, only as ext4 or xfs", opts.FS)
+	}
+	if opts.Mount != "" && (!path.IsAbs(opts.Mount) || path.Clean(opts.Mount) == "/" || strings.ContainsAny(opts.Mount, " \t\n")) {
+		ottoutil.Throw(vm, "can't mount volumes on %q", opts.Mount)
+	}
+	if opts.Options == "" || strings.ContainsAny(opts.Options, " \t\n") {
+		ottoutil.Throw(vm, "invalid mount options %q", opts.Options)
+	}
+	return opts
+}
+
+// provision attaches a volume to a droplet if it isn't already, and mounts
+// it, formatting it first if it's blank, as in:
+//
+//	cloud.volumes.provision(vol, droplet, {fs: "ext4", mount: "/data", persist: true})
+//
+// Volumes are mounted on /mnt/<name> unless told otherwise.
+func (svc *volumeSvc) provision(all otto.FunctionCall) eventloop.Task {
+	vm := all.Otto
+	volumeID := godojs.ArgVolumeID(svc.ctx, vm, all.Argument(0))
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(1))
+	opts := argProvisionOpts(vm, all.Argument(2))
+
+	return func() (eventloop.Result, error) {
+		vol, d, err := svc.attachment(volumeID, dropletID)
+		if err != nil {
+			return nil, err
+		}
+		if !attachedTo(vol, dropletID) {
+			if len(vol.DropletIDs) > 0 {
+				return nil, fmt.Errorf("volume %q is attached to droplet %d", vol.Name, vol.DropletIDs[0])
+			}
+			if err := svc.cloud.Volumes().Actions().Attach(svc.ctx, vol.ID, dropletID); err != nil {
+				return nil, fmt.Errorf("attaching volume %q: %v", vol.Name, err)
+			}
+		}
+
+		res := &provisioned{
+			Device:    cloudinit.VolumeDevice(vol.Name),
+			Mount:     opts.Mount,
+			Persisted: opts.Persist,
+		}
+		if res.Mount == "" {
+			res.Mount = "/mnt/" + vol.Name
+		}
+		out, err := runScript(svc.ctx, d, provisionScript(res.Device, res.Mount, opts))
+		if err != nil {
+			return nil, fmt.Errorf("provisioning volume %q: %v", vol.Name, err)
+		}
+		fields := strings.Fields(lastLine(out))
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("provisioning volume %q: unexpected output %q", vol.Name, out)
+		}
+		res.FS, res.Formatted = fields[0], fields[1] == "formatted"
+		return func(vm *otto.Otto) otto.Value {
+			return godojs.JSONToVM(vm, res)
+		}, nil
+	}
+}
+
+// unprovision unmounts a volume from a droplet, forgets it in /etc/fstab,
+// and detaches it, as in `cloud.volumes.unprovision(vol, droplet)`.
+func (svc *volumeSvc) unprovision(all otto.FunctionCall) eventloop.Task {
+	vm := all.Otto
+	volumeID := godojs.ArgVolumeID(svc.ctx, vm, all.Argument(0))
+	dropletID := godojs.ArgDropletID(svc.ctx, vm, all.Argument(1))
+
+	return func() (eventloop.Result, error) {
+		vol, d, err := svc.attachment(volumeID, dropletID)
+		if err != nil {
+			return nil, err
+		}
+		if !attachedTo(vol, dropletID) {
+			return nil, nil
+		}
+		if _, err := runScript(svc.ctx, d, unprovisionScript(cloudinit.VolumeDevice(vol.Name))); err != nil {
+			return nil, fmt.Errorf("unprovisioning volume %q: %v", vol.Name, err)
+		}
+		if err := svc.cloud.Volumes().Actions().DetachByDropletID(svc.ctx, vol.ID, dropletID); err != nil {
+			return nil, fmt.Errorf("detaching volume %q: %v", vol.Name, err)
+		}
+		return nil, nil
+	}
+}
+
+// attachment gets the volume and the droplet it's attached to, or is to be.
+func (svc *volumeSvc) attachment(volumeID string, dropletID int) (*godo.Volume, *godo.Droplet, error) {
+	v, err := svc.svc.GetVolume(svc.ctx, volumeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	d, err := svc.cloud.Droplets().Get(svc.ctx, dropletID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return v.Struct(), d.Struct(), nil
+}
+
+func attachedTo(vol *godo.Volume, dropletID int) bool {
+	for _, id := range vol.DropletIDs {
+		if id == dropletID {
+			return true
+		}
+	}
+	return false
+}
+
+// provisionScript waits for a volume to show up, formats it if it has
+// neither a filesystem nor partitions, and mounts it. It prints the
+// filesystem of the volume, and whether it was formatted.
+func provisionScript(device, mount string, opts *provisionOpts) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `set -e
+dev=%s
+mnt=%s
+n=0
+while [ ! -e "$dev" ]; do
+	n=$((n + 1))
+	if [ "$n" -gt %d ]; then
+		echo "$dev didn't show up" >&2
+		exit 1
+	fi
+	sleep 1
+done
+fs=$(blkid -p -o value -s TYPE "$dev" || true)
+state=kept
+if [ -z "$fs" ]; then
+	if [ -n "$(blkid -p -o value -s PTTYPE "$dev" || true)" ]; then
+		echo "$dev has partitions, but no filesystem to mount" >&2
+		exit 1
+	fi
+	mkfs -t %[4]s "$dev" >&2
+	fs=%[4]s
+	state=formatted
+fi
+mkdir -p "$mnt"
+if mountpoint -q "$mnt"; then
+	if [ "$(findmnt -n -o SOURCE "$mnt")" != "$(readlink -f "$dev")" ]; then
+		echo "something else is mounted on $mnt" >&2
+		exit 1
+	fi
+else
+	mount -t "$fs" -o %[5]s "$dev" "$mnt"
+fi
+`, shellutil.Quote(device), shellutil.Quote(mount), deviceTimeout, shellutil.Quote(opts.FS), shellutil.Quote(opts.Options))
+	if opts.Persist {
+		fmt.Fprintf(&b, `if ! grep -q "^$dev " /etc/fstab; then
+	printf '%%s %%s %%s %%s 0 2\n' "$dev" "$mnt" "$fs" %s >> /etc/fstab
+fi
+`, shellutil.Quote(opts.Options))
+	}
+	b.WriteString(`echo "$fs $state"` + "\n")
+	return b.String()
+}
+
+// unprovisionScript unmounts a volume wherever it's mounted, and removes it
+// from /etc/fstab.
+func unprovisionScript(device string) string {
+	return fmt.Sprintf(`set -e
+dev=%s
+if [ -e "$dev" ]; then
+	sync
+	findmnt -n -o TARGET -S "$(readlink -f "$dev")" | while read -r target; do
+		umount "$target"
+	done
+fi
+if grep -q "^$dev " /etc/fstab; then
+	sed -i "\|^$dev |d" /etc/fstab
+fi
+`, shellutil.Quote(device))
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return lines[len(lines)-1]
+}
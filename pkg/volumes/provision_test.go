@@ -0,0 +1,133 @@
+package volumes_test
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/droplets"
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/volumes"
+	"github.com/aybabtme/godotto/pkg/extra/do/mockcloud"
+	"github.com/aybabtme/godotto/pkg/extra/vmtest"
+	jsvolumes "github.com/aybabtme/godotto/pkg/volumes"
+	"github.com/digitalocean/godo"
+)
+
+type droplet struct {
+	*godo.Droplet
+}
+
+func (k *droplet) Struct() *godo.Droplet { return k.Droplet }
+
+func TestProvision(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		vol      = &godo.Volume{ID: "vol-1", Name: "data"}
+		attached []int
+		detached []int
+		scripts  []string
+	)
+	cloud := mockcloud.Client(nil)
+	cloud.MockVolumes.GetVolumeFn = func(_ context.Context, id string) (volumes.Volume, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		v := *vol
+		return &volume{&v}, nil
+	}
+	cloud.MockDroplets.GetFn = func(_ context.Context, id int) (droplets.Droplet, error) {
+		return &droplet{&godo.Droplet{ID: id, Name: "web-1"}}, nil
+	}
+	cloud.MockVolumes.MockVolumeActions.AttachFn = func(_ context.Context, id string, dropletID int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attached = append(attached, dropletID)
+		vol.DropletIDs = []int{dropletID}
+		return nil
+	}
+	cloud.MockVolumes.MockVolumeActions.DetachByDropletIDFn = func(_ context.Context, id string, dropletID int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		detached = append(detached, dropletID)
+		vol.DropletIDs = nil
+		return nil
+	}
+	ctx := jsvolumes.WithRun(context.Background(), func(_ context.Context, d *godo.Droplet, script string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		scripts = append(scripts, script)
+		if strings.Contains(script, "mkfs") {
+			return "mke2fs done\next4 formatted\n", nil
+		}
+		return "", nil
+	})
+
+	vmtest.RunContext(t, ctx, cloud, `
+var pkg = cloud.volumes;
+var res = pkg.provision({id: "vol-1"}, {id: 1}, {fs: "ext4", mount: "/data", persist: true});
+equals({device: "/dev/disk/by-id/scsi-0DO_Volume_data", fs: "ext4", mount: "/data", formatted: true, persisted: true}, res);
+
+res = pkg.provision({id: "vol-1"}, {id: 1});
+equals("/mnt/data", res.mount, "should mount on /mnt by default");
+assert(!res.persisted, "should only persist when asked to");
+
+[
+	[function() { pkg.provision({id: "vol-1"}, {id: 2}) }, 'volume "data" is attached to droplet 1'],
+	[function() { pkg.provision({id: "vol-1"}, {id: 1}, {fs: "ntfs"}) }, 'can\'t format volumes as "ntfs", only as ext4 or xfs'],
+	[function() { pkg.provision({id: "vol-1"}, {id: 1}, {mount: "data"}) }, 'can\'t mount volumes on "data"'],
+].forEach(function(tt) {
+	try {
+		tt[0](); throw "dont catch me";
+	} catch (e) {
+		equals(tt[1], e.message, "should send the right exception");
+	}
+});
+
+pkg.unprovision({id: "vol-1"}, {id: 1});
+pkg.unprovision({id: "vol-1"}, {id: 1});
+`)
+
+	if len(attached) != 1 || attached[0] != 1 {
+		t.Errorf("should have attached the volume once, got %v", attached)
+	}
+	if len(detached) != 1 || detached[0] != 1 {
+		t.Errorf("should have detached the volume once, got %v", detached)
+	}
+	if len(scripts) != 3 {
+		t.Fatalf("want 3 scripts, got %d", len(scripts))
+	}
+	for _, want := range []string{"dev='/dev/disk/by-id/scsi-0DO_Volume_data'", "mnt='/data'", "mkfs -t 'ext4'", "/etc/fstab"} {
+		if !strings.Contains(scripts[0], want) {
+			t.Errorf("provisioning should contain %q:\n%s", want, scripts[0])
+		}
+	}
+	if strings.Contains(scripts[1], "/etc/fstab") {
+		t.Errorf("should only persist when asked to:\n%s", scripts[1])
+	}
+	if !strings.Contains(scripts[2], "umount") {
+		t.Errorf("unprovisioning should unmount:\n%s", scripts[2])
+	}
+	for _, script := range scripts {
+		if out, err := exec.Command("sh", "-n", "-c", script).CombinedOutput(); err != nil {
+			t.Errorf("invalid script: %v: %s\n%s", err, out, script)
+		}
+	}
+
+	vmtest.RunContext(t, ctx, cloud, `
+var res = cloud.parallel([1], function(id) {
+	return cloud.volumes.provision({id: "vol-1"}, {id: id}).mount;
+});
+equals(undefined, res[0].error, "should run scripts in workers too");
+equals("/mnt/data", res[0].value, "should run scripts in workers too");
+`)
+
+	vmtest.Run(t, cloud, `
+try {
+	cloud.volumes.provision({id: "vol-1"}, {id: 1});
+	assert(false, "should fail without the ssh package");
+} catch (e) {
+	assert(String(e).indexOf("nothing knows how to run scripts on droplets") >= 0, "unexpected error: " + e);
+}
+`)
+}
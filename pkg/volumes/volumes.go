@@ -8,6 +8,7 @@ import (
 	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
 	"github.com/aybabtme/godotto/pkg/extra/do/cloud/volumes"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
 
 	"github.com/robertkrimen/otto"
 )
@@ -21,8 +22,9 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 	}
 
 	svc := volumeSvc{
-		ctx: ctx,
-		svc: client.Volumes(),
+		ctx:   ctx,
+		svc:   client.Volumes(),
+		cloud: client,
 	}
 
 	actions, err := applyAction(ctx, vm, client)
@@ -36,13 +38,9 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 	}{
 		{"list_volumes", svc.listVolume},
 		{"get_volume", svc.getVolume},
-		{"create_volume", svc.createVolume},
-		{"delete_volume", svc.deleteVolume},
 
 		{"list_snapshots", svc.listSnapshots},
 		{"get_snapshot", svc.getSnapshot},
-		{"delete_snapshot", svc.deleteSnapshot},
-		{"create_snapshot", svc.createSnapshot},
 
 		{"actions", actions},
 	} {
@@ -51,90 +49,137 @@ func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value,
 		}
 	}
 
+	for _, applier := range []struct {
+		Name    string
+		Prepare func(otto.FunctionCall) eventloop.Task
+	}{
+		{"create_volume", svc.createVolume},
+		{"delete_volume", svc.deleteVolume},
+
+		{"delete_snapshot", svc.deleteSnapshot},
+		{"create_snapshot", svc.createSnapshot},
+
+		{"wait_attached", svc.waitAttached},
+		{"provision", svc.provision},
+		{"unprovision", svc.unprovision},
+	} {
+		if err := eventloop.Bind(root, applier.Name, applier.Prepare); err != nil {
+			return q, fmt.Errorf("preparing method %q, %v", applier.Name, err)
+		}
+	}
+
 	return root.Value(), nil
 }
 
 type volumeSvc struct {
-	ctx context.Context
-	svc volumes.Client
+	ctx   context.Context
+	svc   volumes.Client
+	cloud cloud.Client
 }
 
-func (svc *volumeSvc) createVolume(all otto.FunctionCall) otto.Value {
+func (svc *volumeSvc) createVolume(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 	arg := all.Argument(0)
 	req := godojs.ArgVolumeCreateRequest(vm, arg)
-	d, err := svc.svc.CreateVolume(
-		svc.ctx,
-		req.Name, req.Region, req.SizeGigaBytes,
-		volumes.SetVolumeDescription(req.Description),
-		volumes.SetVolumeFilesystemType(req.FilesystemType),
-		volumes.SetVolumeFilesystemLabel(req.FilesystemLabel),
-	)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		d, err := svc.svc.CreateVolume(
+			svc.ctx,
+			req.Name, req.Region, req.SizeGigaBytes,
+			volumes.SetVolumeDescription(req.Description),
+			volumes.SetVolumeFilesystemType(req.FilesystemType),
+			volumes.SetVolumeFilesystemLabel(req.FilesystemLabel),
+		)
+		if err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.VolumeKind)
+		return func(vm *otto.Otto) otto.Value {
+			return svc.volumeToVM(vm, d.Struct())
+		}, nil
 	}
-
-	return godojs.VolumeToVM(vm, d.Struct())
 }
 
 func (svc *volumeSvc) getVolume(all otto.FunctionCall) otto.Value {
 	vm := all.Otto
-	id := godojs.ArgVolumeID(vm, all.Argument(0))
+	id := godojs.ArgVolumeID(svc.ctx, vm, all.Argument(0))
 
 	d, err := svc.svc.GetVolume(svc.ctx, id)
 	if err != nil {
 		ottoutil.Throw(vm, err.Error())
 	}
-	return godojs.VolumeToVM(vm, d.Struct())
+	return svc.volumeToVM(vm, d.Struct())
 }
 
-func (svc *volumeSvc) deleteVolume(all otto.FunctionCall) otto.Value {
+func (svc *volumeSvc) deleteVolume(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
-	id := godojs.ArgVolumeID(vm, all.Argument(0))
+	id := godojs.ArgVolumeID(godojs.Fresh(svc.ctx), vm, all.Argument(0))
 
-	err := svc.svc.DeleteVolume(svc.ctx, id)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		if err := svc.svc.DeleteVolume(svc.ctx, id); err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.VolumeKind)
+		return nil, nil
 	}
-	return q
 }
 
-func (svc *volumeSvc) listVolume(all otto.FunctionCall) otto.Value {
-
+// argListOpts reads filters like `{region: "nyc3", name: "data"}`.
+func (svc *volumeSvc) argListOpts(all otto.FunctionCall, i int) []volumes.ListOpt {
 	vm := all.Otto
-
-	var volumes = make([]otto.Value, 0)
-	volumec, errc := svc.svc.ListVolumes(svc.ctx)
-	for d := range volumec {
-		volumes = append(volumes, godojs.VolumeToVM(vm, d.Struct()))
+	arg := all.Argument(i)
+	if !arg.IsObject() {
+		return nil
 	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
+	var opts []volumes.ListOpt
+	if v := ottoutil.GetObject(vm, arg, "region", false); v.IsDefined() {
+		opts = append(opts, volumes.FilterRegion(godojs.ArgRegionSlug(vm, v)))
 	}
-
-	v, err := vm.ToValue(volumes)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	if v := ottoutil.GetObject(vm, arg, "name", false); v.IsDefined() {
+		opts = append(opts, volumes.FilterName(ottoutil.String(vm, v)))
 	}
-	return v
+	return opts
+}
+
+func (svc *volumeSvc) listVolume(all otto.FunctionCall) otto.Value {
+
+	vm := all.Otto
+
+	opts := svc.argListOpts(all, 0)
+	ctx, cancel := context.WithCancel(svc.ctx)
+	volumec, errc := svc.svc.ListVolumes(ctx, opts...)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(0)), func() (otto.Value, bool, error) {
+		d, more := <-volumec
+		if !more {
+			return q, false, <-errc
+		}
+		return svc.volumeToVM(vm, d.Struct()), true, nil
+	}, cancel)
 }
 
-func (svc *volumeSvc) createSnapshot(all otto.FunctionCall) otto.Value {
+func (svc *volumeSvc) createSnapshot(all otto.FunctionCall) eventloop.Task {
 	vm := all.Otto
 	arg := all.Argument(0)
-	req := godojs.ArgSnapshotCreateRequest(vm, arg)
-	d, err := svc.svc.CreateSnapshot(svc.ctx, req.VolumeID, req.Name)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	req := godojs.ArgSnapshotCreateRequest(svc.ctx, vm, arg)
+	return func() (eventloop.Result, error) {
+		d, err := svc.svc.CreateSnapshot(svc.ctx, req.VolumeID, req.Name)
+		if err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.SnapshotKind)
+		return snapshotResult(d), nil
 	}
+}
 
-	return godojs.VolumeSnapshotToVM(vm, d.Struct())
+func snapshotResult(s volumes.Snapshot) eventloop.Result {
+	return func(vm *otto.Otto) otto.Value {
+		return godojs.VolumeSnapshotToVM(vm, s.Struct())
+	}
 }
 
 func (svc *volumeSvc) getSnapshot(all otto.FunctionCall) otto.Value {
 	var (
 		vm = all.Otto
-		id = godojs.ArgSnapshotID(vm, all.Argument(0))
+		id = godojs.ArgSnapshotID(svc.ctx, vm, all.Argument(0))
 	)
 	d, err := svc.svc.GetSnapshot(svc.ctx, id)
 	if err != nil {
@@ -144,37 +189,34 @@ func (svc *volumeSvc) getSnapshot(all otto.FunctionCall) otto.Value {
 	return godojs.VolumeSnapshotToVM(vm, d.Struct())
 }
 
-func (svc *volumeSvc) deleteSnapshot(all otto.FunctionCall) otto.Value {
+func (svc *volumeSvc) deleteSnapshot(all otto.FunctionCall) eventloop.Task {
 	var (
 		vm = all.Otto
-		id = godojs.ArgSnapshotID(vm, all.Argument(0))
+		id = godojs.ArgSnapshotID(godojs.Fresh(svc.ctx), vm, all.Argument(0))
 	)
-	err := svc.svc.DeleteSnapshot(svc.ctx, id)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
+	return func() (eventloop.Result, error) {
+		if err := svc.svc.DeleteSnapshot(svc.ctx, id); err != nil {
+			return nil, err
+		}
+		godojs.Invalidate(svc.ctx, cloud.SnapshotKind)
+		return nil, nil
 	}
-	return q
 }
 
 func (svc *volumeSvc) listSnapshots(all otto.FunctionCall) otto.Value {
 
 	var (
 		vm       = all.Otto
-		volumeID = godojs.ArgVolumeID(vm, all.Argument(0))
+		volumeID = godojs.ArgVolumeID(svc.ctx, vm, all.Argument(0))
 	)
 
-	var Snapshots = make([]otto.Value, 0)
-	snapshotc, errc := svc.svc.ListSnapshots(svc.ctx, volumeID)
-	for d := range snapshotc {
-		Snapshots = append(Snapshots, godojs.VolumeSnapshotToVM(vm, d.Struct()))
-	}
-	if err := <-errc; err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-
-	v, err := vm.ToValue(Snapshots)
-	if err != nil {
-		ottoutil.Throw(vm, err.Error())
-	}
-	return v
+	ctx, cancel := context.WithCancel(svc.ctx)
+	snapshotc, errc := svc.svc.ListSnapshots(ctx, volumeID)
+	return ottoutil.List(vm, ottoutil.WantCursor(vm, all.Argument(1)), func() (otto.Value, bool, error) {
+		d, more := <-snapshotc
+		if !more {
+			return q, false, <-errc
+		}
+		return godojs.VolumeSnapshotToVM(vm, d.Struct()), true, nil
+	}, cancel)
 }
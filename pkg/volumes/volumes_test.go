@@ -32,7 +32,7 @@ assert(pkg.create_snapshot != null, "create_snapshot function should be defined"
 func TestThrows(t *testing.T) {
 	cloud := mockcloud.Client(nil)
 
-	cloud.MockVolumes.ListVolumesFn = func(_ context.Context) (<-chan volumes.Volume, <-chan error) {
+	cloud.MockVolumes.ListVolumesFn = func(_ context.Context, _ ...volumes.ListOpt) (<-chan volumes.Volume, <-chan error) {
 		lc := make(chan volumes.Volume)
 		close(lc)
 		ec := make(chan error, 1)
@@ -125,7 +125,7 @@ var (
 
 func TestVolumeList(t *testing.T) {
 	cloud := mockcloud.Client(nil)
-	cloud.MockVolumes.ListVolumesFn = func(_ context.Context) (<-chan volumes.Volume, <-chan error) {
+	cloud.MockVolumes.ListVolumesFn = func(_ context.Context, _ ...volumes.ListOpt) (<-chan volumes.Volume, <-chan error) {
 		lc := make(chan volumes.Volume, 1)
 		lc <- &volume{&godo.Volume{
 			ID:              "lol",
@@ -386,3 +386,34 @@ var pkg = cloud.volumes;
 pkg.delete_snapshot("my id");
 `)
 }
+
+func TestVolumeAsync(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	cloud.MockVolumes.CreateVolumeFn = func(_ context.Context, name, _ string, size int64, _ ...volumes.CreateOpt) (volumes.Volume, error) {
+		return &volume{&godo.Volume{ID: "vol-1", Region: region, Name: name, SizeGigaBytes: size}}, nil
+	}
+	var snapshotted []string
+	cloud.MockVolumes.CreateSnapshotFn = func(_ context.Context, id, name string, _ ...volumes.SnapshotOpt) (volumes.Snapshot, error) {
+		snapshotted = append(snapshotted, id)
+		return &snapshot{&godo.Snapshot{ID: name, ResourceID: id, Regions: []string{region.Slug}, Name: name}}, nil
+	}
+
+	vmtest.Run(t, cloud, `
+var pkg = cloud.volumes;
+
+var region = { name: "newyork3", slug: "nyc3", sizes: ["small"], available: true, features: ["all"] };
+
+var vol = pkg.create_volume_async({name: "data", size: 100, region: region}).wait("1s");
+equals(vol.id, "vol-1", "should have created the volume");
+
+var s = pkg.create_snapshot_async({volume: vol.id, name: "first"}).wait("1s");
+equals(s.volume_id, "vol-1", "should have snapshotted the volume");
+
+s = vol.snapshot_async("second", "").wait("1s");
+equals(s.name, "second", "should have snapshotted the volume");
+`)
+
+	if len(snapshotted) != 2 || snapshotted[0] != "vol-1" || snapshotted[1] != "vol-1" {
+		t.Fatalf("want 2 snapshots of %q, got %v", "vol-1", snapshotted)
+	}
+}
@@ -0,0 +1,49 @@
+package volumes
+
+import (
+	"fmt"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud/volumes"
+	"github.com/aybabtme/godotto/pkg/extra/eventloop"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/godoutil"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/robertkrimen/otto"
+)
+
+// waitAttached waits for a volume to show as attached, to any droplet or to
+// a given one, as in `cloud.volumes.wait_attached(vol, {droplet: d})`.
+func (svc *volumeSvc) waitAttached(all otto.FunctionCall) eventloop.Task {
+	vm := all.Otto
+	id := godojs.ArgVolumeID(svc.ctx, vm, all.Argument(0))
+	arg := all.Argument(1)
+	opts := godojs.ArgWaitOptions(vm, arg)
+	dropletID := 0
+	if arg.IsObject() {
+		if d := ottoutil.GetObject(vm, arg, "droplet", false); d.IsDefined() {
+			dropletID = godojs.ArgDropletID(svc.ctx, vm, d)
+		}
+	}
+
+	return func() (eventloop.Result, error) {
+		var v volumes.Volume
+		err := godoutil.WaitFor(svc.ctx, func() (bool, error) {
+			var err error
+			if v, err = svc.svc.GetVolume(svc.ctx, id); err != nil {
+				return false, err
+			}
+			for _, did := range v.Struct().DropletIDs {
+				if dropletID == 0 || did == dropletID {
+					return true, nil
+				}
+			}
+			return false, nil
+		}, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("waiting for volume %q to be attached: %v", id, err)
+		}
+		return func(vm *otto.Otto) otto.Value {
+			return svc.volumeToVM(vm, v.Struct())
+		}, nil
+	}
+}
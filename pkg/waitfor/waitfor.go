@@ -0,0 +1,44 @@
+package waitfor
+
+import (
+	"context"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/cloud"
+	"github.com/aybabtme/godotto/pkg/extra/godojs"
+	"github.com/aybabtme/godotto/pkg/extra/godoutil"
+	"github.com/aybabtme/godotto/pkg/extra/ottoutil"
+	"github.com/robertkrimen/otto"
+)
+
+// Apply creates `cloud.wait_for(getter, predicate, {timeout: "5m", interval: "5s"})`,
+// which calls getter until predicate is true of what it returns, backing off
+// between calls, and returns the last value returned by getter.
+func Apply(ctx context.Context, vm *otto.Otto, client cloud.Client) (otto.Value, error) {
+	svc := waitForSvc{ctx: ctx}
+	return vm.ToValue(svc.waitFor)
+}
+
+type waitForSvc struct {
+	ctx context.Context
+}
+
+func (svc *waitForSvc) waitFor(all otto.FunctionCall) otto.Value {
+	vm := all.Otto
+	getter, predicate := all.Argument(0), all.Argument(1)
+	for _, fn := range []otto.Value{getter, predicate} {
+		if !fn.IsFunction() {
+			ottoutil.Throw(vm, "argument must be a function, got a %q", fn.Class())
+		}
+	}
+	opts := godojs.ArgWaitOptions(vm, all.Argument(2))
+
+	var v otto.Value
+	err := godoutil.WaitFor(svc.ctx, func() (bool, error) {
+		v = ottoutil.Call(vm, getter, nil)
+		return ottoutil.Bool(vm, ottoutil.Call(vm, predicate, nil, v)), nil
+	}, opts...)
+	if err != nil {
+		ottoutil.Throw(vm, "waiting: %v", err)
+	}
+	return v
+}
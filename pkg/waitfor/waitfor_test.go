@@ -0,0 +1,44 @@
+package waitfor_test
+
+import (
+	"testing"
+
+	"github.com/aybabtme/godotto/pkg/extra/do/mockcloud"
+	"github.com/aybabtme/godotto/pkg/extra/vmtest"
+)
+
+func TestApply(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	vmtest.Run(t, cloud, `
+assert(cloud.wait_for != null, "wait_for function should be defined");
+    `)
+}
+
+func TestWaitFor(t *testing.T) {
+	cloud := mockcloud.Client(nil)
+	vmtest.Run(t, cloud, `
+var calls = 0;
+var v = cloud.wait_for(function() {
+	calls++;
+	return {ready: calls == 3, calls: calls};
+}, function(v) {
+	return v.ready;
+}, {interval: "1ms"});
+
+assert(v.calls == 3, "should return the value that matched");
+
+try {
+	cloud.wait_for(function() { return 1 }, function() { return false }, {timeout: "20ms", interval: "1ms"});
+	throw "dont catch me";
+} catch (e) {
+	equals(e.message, "waiting: timed out after 20ms", "should time out");
+}
+
+try {
+	cloud.wait_for(function() { throw "from getter" }, function() { return true });
+	throw "dont catch me";
+} catch (e) {
+	equals(e, "from getter", "should rethrow what the getter throws");
+}
+    `)
+}